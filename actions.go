@@ -0,0 +1,605 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// exportedAction is an action as migrate actions exports it: the names of
+// its secrets are kept, their values can't be read.
+type exportedAction struct {
+	Name              string                        `json:"name"`
+	Code              string                        `json:"code"`
+	Runtime           string                        `json:"runtime,omitempty"`
+	Dependencies      []management.ActionDependency `json:"dependencies,omitempty"`
+	SupportedTriggers []management.ActionTrigger    `json:"supported_triggers"`
+	Secrets           []string                      `json:"secrets,omitempty"`
+}
+
+// exportedRule is a legacy rule.
+type exportedRule struct {
+	Name    string `json:"name"`
+	Script  string `json:"script"`
+	Order   int    `json:"order"`
+	Enabled bool   `json:"enabled"`
+}
+
+// exportedHook is a legacy hook with the names of its secrets.
+type exportedHook struct {
+	Name         string            `json:"name"`
+	Script       string            `json:"script"`
+	TriggerID    string            `json:"trigger_id"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	Enabled      bool              `json:"enabled"`
+	Secrets      []string          `json:"secrets,omitempty"`
+}
+
+// actionsExport is the file migrate actions writes: the actions of a
+// tenant, the actions bound to each trigger in execution order and,
+// optionally, the legacy rules, rule config keys and hooks.
+type actionsExport struct {
+	Actions     []exportedAction    `json:"actions"`
+	Bindings    map[string][]string `json:"bindings"`
+	Rules       []exportedRule      `json:"rules,omitempty"`
+	RuleConfigs []string            `json:"rule_configs,omitempty"`
+	Hooks       []exportedHook      `json:"hooks,omitempty"`
+}
+
+func newExportedAction(action *management.Action) exportedAction {
+	exported := exportedAction{
+		Name:              action.GetName(),
+		Code:              action.GetCode(),
+		Runtime:           action.GetRuntime(),
+		SupportedTriggers: action.SupportedTriggers,
+	}
+	if action.Dependencies != nil {
+		exported.Dependencies = *action.Dependencies
+	}
+	if action.Secrets != nil {
+		for _, secret := range *action.Secrets {
+			exported.Secrets = append(exported.Secrets, secret.GetName())
+		}
+		slices.Sort(exported.Secrets)
+	}
+	return exported
+}
+
+// listActions returns the actions of a tenant by name.
+func listActions(ctx context.Context, m *management.Management) (map[string]*management.Action, error) {
+	actions := map[string]*management.Action{}
+	for page := 0; ; page++ {
+		list, err := m.Action.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list actions: %w", apiError(err))
+		}
+		for _, action := range list.Actions {
+			actions[action.GetName()] = action
+		}
+		if !list.HasNext() {
+			return actions, nil
+		}
+	}
+}
+
+// listRules returns the legacy rules of a tenant by name.
+func listRules(ctx context.Context, m *management.Management) (map[string]*management.Rule, error) {
+	rules := map[string]*management.Rule{}
+	for page := 0; ; page++ {
+		list, err := m.Rule.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list rules: %w", apiError(err))
+		}
+		for _, rule := range list.Rules {
+			rules[rule.GetName()] = rule
+		}
+		if !list.HasNext() {
+			return rules, nil
+		}
+	}
+}
+
+// listRuleConfigKeys returns the keys of the rule configs of a tenant.
+func listRuleConfigKeys(ctx context.Context, m *management.Management) ([]string, error) {
+	configs, err := m.RuleConfig.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rule configs: %w", apiError(err))
+	}
+	var keys []string
+	for _, config := range configs {
+		keys = append(keys, config.GetKey())
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// listHooks returns the legacy hooks of a tenant by name.
+func listHooks(ctx context.Context, m *management.Management) (map[string]*management.Hook, error) {
+	hooks := map[string]*management.Hook{}
+	for page := 0; ; page++ {
+		list, err := m.Hook.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list hooks: %w", apiError(err))
+		}
+		for _, hook := range list.Hooks {
+			hooks[hook.GetName()] = hook
+		}
+		if !list.HasNext() {
+			return hooks, nil
+		}
+	}
+}
+
+// hookSecretNames returns the names of the secrets of a hook. Auth0 doesn't
+// return their values.
+func hookSecretNames(ctx context.Context, m *management.Management, hookID string) ([]string, error) {
+	secrets, err := m.Hook.Secrets(ctx, hookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hook secrets: %w", apiError(err))
+	}
+	return sortedKeys(secrets), nil
+}
+
+// exportActions reads the actions and trigger bindings of a tenant and,
+// with legacy, its rules, rule config keys and hooks. Everything is sorted
+// by name, so that exports of a tenant can be diffed.
+func exportActions(ctx context.Context, m *management.Management, legacy bool) (*actionsExport, error) {
+	actions, err := listActions(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	export := &actionsExport{Actions: []exportedAction{}}
+	for _, name := range sortedKeys(actions) {
+		export.Actions = append(export.Actions, newExportedAction(actions[name]))
+	}
+	if export.Bindings, err = triggerBindings(ctx, m); err != nil {
+		return nil, err
+	}
+	if !legacy {
+		return export, nil
+	}
+
+	rules, err := listRules(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(rules) {
+		rule := rules[name]
+		export.Rules = append(export.Rules, exportedRule{Name: name, Script: rule.GetScript(), Order: rule.GetOrder(), Enabled: rule.GetEnabled()})
+	}
+	if export.RuleConfigs, err = listRuleConfigKeys(ctx, m); err != nil {
+		return nil, err
+	}
+
+	hooks, err := listHooks(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(hooks) {
+		hook := hooks[name]
+		secrets, err := hookSecretNames(ctx, m, hook.GetID())
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %w", name, err)
+		}
+		export.Hooks = append(export.Hooks, exportedHook{
+			Name: name, Script: hook.GetScript(), TriggerID: hook.GetTriggerID(),
+			Dependencies: hook.GetDependencies(), Enabled: hook.GetEnabled(), Secrets: secrets,
+		})
+	}
+	return export, nil
+}
+
+// secretValues returns the values of the named secrets, taken from
+// configured or, for the ones it lacks, asked for.
+func secretValues(owner string, names []string, configured map[string]string, ask func(string) (string, error)) (map[string]string, error) {
+	values := map[string]string{}
+	for _, name := range names {
+		value, ok := configured[name]
+		if !ok {
+			var err error
+			if value, err = ask(fmt.Sprintf("Value of secret %s of %s", name, owner)); err != nil {
+				return nil, err
+			}
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// actionChanged reports whether the target action differs from the source
+// action in code, runtime, dependencies, triggers or secret names.
+func actionChanged(source exportedAction, target *management.Action) bool {
+	current := newExportedAction(target)
+	current.Name = source.Name
+	a, _ := json.Marshal(source)
+	b, _ := json.Marshal(current)
+	return string(a) != string(b)
+}
+
+// actionMigration copies actions, trigger bindings, rules and hooks to the
+// target tenant and keeps count of what it did. Secret values are read
+// from secrets, by the name of the action or hook in the target, and rule
+// config values from ruleConfigs; missing values are asked for.
+type actionMigration struct {
+	target      *management.Management
+	remap       nameRemap
+	secrets     map[string]map[string]string
+	ruleConfigs map[string]string
+	ask         func(string) (string, error)
+	dryRun      bool
+
+	created, updated, unchanged, failed int
+	deploy                              []string
+	plan                                []planEntry
+}
+
+func (a *actionMigration) record(resource, action, reason string) {
+	a.plan = append(a.plan, planEntry{Resource: resource, Action: action, Reason: reason})
+	switch action {
+	case diffActionCreate:
+		a.created++
+	case diffActionUpdate:
+		a.updated++
+	default:
+		a.unchanged++
+	}
+	if !a.dryRun && action != planSkip {
+		fmt.Fprintf(messageOutput, "Applied %s %s.\n", action, resource)
+	}
+}
+
+func (a *actionMigration) fail(resource string, err error) {
+	fmt.Fprintf(messageOutput, "Failed to migrate %s: %v\n", resource, err)
+	a.failed++
+}
+
+// actions creates or updates the target actions that differ from the
+// source, with all their secrets, and remembers them for deployment.
+// Actions that are up to date keep their secrets.
+func (a *actionMigration) actions(ctx context.Context, actions []exportedAction) error {
+	existing, err := listActions(ctx, a.target)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range actions {
+		source.Name = a.remap.name("actions", source.Name)
+		resource := "actions/" + source.Name
+		current, exists := existing[source.Name]
+		action := diffActionCreate
+		if exists {
+			if !actionChanged(source, current) {
+				a.record(resource, planSkip, "unchanged")
+				continue
+			}
+			action = diffActionUpdate
+		}
+		if a.dryRun {
+			a.record(resource, action, secretsReason(source.Secrets))
+			continue
+		}
+
+		values, err := secretValues("action "+source.Name, source.Secrets, a.secrets[source.Name], a.ask)
+		if err != nil {
+			return err
+		}
+		secrets := []management.ActionSecret{}
+		for _, name := range source.Secrets {
+			secrets = append(secrets, management.ActionSecret{Name: auth0.String(name), Value: auth0.String(values[name])})
+		}
+		dependencies := source.Dependencies
+		if dependencies == nil {
+			dependencies = []management.ActionDependency{}
+		}
+		update := &management.Action{
+			Name:              auth0.String(source.Name),
+			Code:              auth0.String(source.Code),
+			Dependencies:      &dependencies,
+			SupportedTriggers: source.SupportedTriggers,
+			Secrets:           &secrets,
+		}
+		if source.Runtime != "" {
+			update.Runtime = auth0.String(source.Runtime)
+		}
+
+		if exists {
+			err = a.target.Action.Update(ctx, current.GetID(), update)
+		} else {
+			err = a.target.Action.Create(ctx, update)
+		}
+		if err != nil {
+			a.fail(resource, apiError(err))
+			continue
+		}
+		a.record(resource, action, secretsReason(source.Secrets))
+		a.deploy = append(a.deploy, source.Name)
+	}
+	return nil
+}
+
+func secretsReason(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return "secrets: " + strings.Join(names, ", ")
+}
+
+// bindings binds the actions of each source trigger in the target, in the
+// same order. The bindings of a trigger are replaced as a whole, and
+// triggers without bindings in the source are left alone.
+func (a *actionMigration) bindings(ctx context.Context, bindings map[string][]string) error {
+	current, err := triggerBindings(ctx, a.target)
+	if err != nil {
+		return err
+	}
+
+	for _, trigger := range sortedKeys(bindings) {
+		var names []string
+		for _, name := range bindings[trigger] {
+			names = append(names, a.remap.name("actions", name))
+		}
+		resource := "triggers/" + trigger
+		if slices.Equal(names, current[trigger]) {
+			a.record(resource, planSkip, "unchanged")
+			continue
+		}
+		action := diffActionUpdate
+		if len(current[trigger]) == 0 {
+			action = diffActionCreate
+		}
+		if !a.dryRun {
+			var update []*management.ActionBinding
+			for _, name := range names {
+				update = append(update, &management.ActionBinding{
+					Ref:         &management.ActionBindingReference{Type: auth0.String(management.ActionBindingReferenceByName), Value: auth0.String(name)},
+					DisplayName: auth0.String(name),
+				})
+			}
+			if err := a.target.Action.UpdateBindings(ctx, trigger, update); err != nil {
+				a.fail(resource, apiError(err))
+				continue
+			}
+		}
+		a.record(resource, action, strings.Join(names, " → "))
+	}
+	return nil
+}
+
+// rules creates or updates the legacy rules and sets the rule configs the
+// target lacks. Existing rule configs keep their values.
+func (a *actionMigration) rules(ctx context.Context, rules []exportedRule, configKeys []string) error {
+	existing, err := listRules(ctx, a.target)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range rules {
+		source.Name = a.remap.name("rules", source.Name)
+		resource := "rules/" + source.Name
+		current, exists := existing[source.Name]
+		if exists && current.GetScript() == source.Script && current.GetOrder() == source.Order && current.GetEnabled() == source.Enabled {
+			a.record(resource, planSkip, "unchanged")
+			continue
+		}
+		action := diffActionCreate
+		if exists {
+			action = diffActionUpdate
+		}
+		if !a.dryRun {
+			rule := &management.Rule{Name: auth0.String(source.Name), Script: auth0.String(source.Script), Order: auth0.Int(source.Order), Enabled: auth0.Bool(source.Enabled)}
+			if exists {
+				err = a.target.Rule.Update(ctx, current.GetID(), rule)
+			} else {
+				err = a.target.Rule.Create(ctx, rule)
+			}
+			if err != nil {
+				a.fail(resource, apiError(err))
+				continue
+			}
+		}
+		a.record(resource, action, "")
+	}
+
+	targetKeys, err := listRuleConfigKeys(ctx, a.target)
+	if err != nil {
+		return err
+	}
+	for _, key := range configKeys {
+		resource := "rule-configs/" + key
+		if slices.Contains(targetKeys, key) {
+			a.record(resource, planSkip, "exists")
+			continue
+		}
+		if !a.dryRun {
+			values, err := secretValues("the rules", []string{key}, a.ruleConfigs, a.ask)
+			if err != nil {
+				return err
+			}
+			if err := a.target.RuleConfig.Upsert(ctx, key, &management.RuleConfig{Value: auth0.String(values[key])}); err != nil {
+				a.fail(resource, apiError(err))
+				continue
+			}
+		}
+		a.record(resource, diffActionCreate, "")
+	}
+	return nil
+}
+
+// hooks creates or updates the legacy hooks and adds the secrets the
+// target hooks lack. Existing secrets keep their values.
+func (a *actionMigration) hooks(ctx context.Context, hooks []exportedHook) error {
+	existing, err := listHooks(ctx, a.target)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range hooks {
+		resource := "hooks/" + source.Name
+		current, exists := existing[source.Name]
+		var missingSecrets []string
+		if exists {
+			names, err := hookSecretNames(ctx, a.target, current.GetID())
+			if err != nil {
+				a.fail(resource, err)
+				continue
+			}
+			for _, name := range source.Secrets {
+				if !slices.Contains(names, name) {
+					missingSecrets = append(missingSecrets, name)
+				}
+			}
+		} else {
+			missingSecrets = source.Secrets
+		}
+
+		changed := !exists || current.GetScript() != source.Script || current.GetEnabled() != source.Enabled ||
+			fmt.Sprint(current.GetDependencies()) != fmt.Sprint(source.Dependencies)
+		if !changed && len(missingSecrets) == 0 {
+			a.record(resource, planSkip, "unchanged")
+			continue
+		}
+		action := diffActionCreate
+		if exists {
+			action = diffActionUpdate
+		}
+		if a.dryRun {
+			a.record(resource, action, secretsReason(missingSecrets))
+			continue
+		}
+
+		values, err := secretValues("hook "+source.Name, missingSecrets, a.secrets[source.Name], a.ask)
+		if err != nil {
+			return err
+		}
+		dependencies := source.Dependencies
+		if dependencies == nil {
+			dependencies = map[string]string{}
+		}
+		hook := &management.Hook{Script: auth0.String(source.Script), Dependencies: &dependencies, Enabled: auth0.Bool(source.Enabled)}
+		id := current.GetID()
+		if exists {
+			err = a.target.Hook.Update(ctx, id, hook)
+		} else {
+			hook.Name, hook.TriggerID = auth0.String(source.Name), auth0.String(source.TriggerID)
+			err = a.target.Hook.Create(ctx, hook)
+			id = hook.GetID()
+		}
+		if err == nil && len(values) > 0 {
+			err = a.target.Hook.CreateSecrets(ctx, id, values)
+		}
+		if err != nil {
+			a.fail(resource, apiError(err))
+			continue
+		}
+		a.record(resource, action, secretsReason(missingSecrets))
+	}
+	return nil
+}
+
+func newMigrateActionsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		output    string
+		legacy    bool
+		remapFile string
+		dryRun    bool
+	)
+
+	var actionsCmd = &cobra.Command{
+		Use:   "actions",
+		Short: "Copy actions with their secrets and trigger bindings, and optionally legacy rules and hooks, to the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate actions")
+				clients.guardTarget("migrate actions")
+			}
+			var remap nameRemap
+			var err error
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+
+			export, err := exportActions(ctx, clients.sourceClient(), legacy)
+			if err != nil {
+				log.Fatalf("Failed to export actions: %v", err)
+			}
+			if output != "" {
+				data, err := json.MarshalIndent(export, "", "  ")
+				if err != nil {
+					log.Fatalf("Failed to encode actions: %v", err)
+				}
+				if err := os.WriteFile(output, append(data, '\n'), 0o600); err != nil {
+					log.Fatalf("Failed to write actions: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Exported %d actions, %d rules and %d hooks to %s.\n", len(export.Actions), len(export.Rules), len(export.Hooks), output)
+			}
+
+			migration := &actionMigration{
+				target: clients.targetClient(), remap: remap, dryRun: dryRun,
+				secrets: cfg.ActionSecrets, ruleConfigs: cfg.RuleConfigs, ask: promptText,
+			}
+			if err := migration.actions(ctx, export.Actions); err != nil {
+				log.Fatalf("Failed to migrate actions: %v", err)
+			}
+			if len(migration.deploy) > 0 {
+				deployPoller := newPoller()
+				deployPoller.initial, deployPoller.max, deployPoller.maxWait = time.Second, 10*time.Second, 5*time.Minute
+				if err := deployActions(ctx, migration.target, deployPoller, migration.deploy); err != nil {
+					fmt.Fprintln(messageOutput, err)
+					migration.failed++
+				}
+			}
+			if err := migration.bindings(ctx, export.Bindings); err != nil {
+				log.Fatalf("Failed to bind actions: %v", err)
+			}
+			if legacy {
+				if err := migration.rules(ctx, export.Rules, export.RuleConfigs); err != nil {
+					log.Fatalf("Failed to migrate rules: %v", err)
+				}
+				if err := migration.hooks(ctx, export.Hooks); err != nil {
+					log.Fatalf("Failed to migrate hooks: %v", err)
+				}
+			}
+
+			if dryRun {
+				writePlan(messageOutput, migration.plan)
+				reportResult("migrate actions", map[string]interface{}{"dry_run": true, "plan": migration.plan, "failed": migration.failed})
+				if migration.failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Actions migrated: %d created, %d updated, %d unchanged, %d failed.\n",
+				migration.created, migration.updated, migration.unchanged, migration.failed)
+			reportResult("migrate actions", map[string]interface{}{
+				"created": migration.created, "updated": migration.updated, "unchanged": migration.unchanged,
+				"failed": migration.failed, "deployed": migration.deploy, "plan": migration.plan,
+			})
+			if migration.failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	actionsCmd.Flags().StringVar(&output, "output", "actions.json", "File the exported actions are written to, with secret names but not their values; empty to skip")
+	actionsCmd.Flags().BoolVar(&legacy, "legacy", false, "Also copy legacy rules, rule configs and hooks")
+	actionsCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source action and rule names to their names in the target")
+	actionsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return actionsCmd
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExportActionsRedactsSecrets(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v2/actions/actions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"start": 0, "limit": 100, "total": 2, "actions": []map[string]interface{}{
+				{"id": "a2", "name": "send-welcome", "code": "exports.onExecutePostLogin = async () => {};", "runtime": "node18",
+					"supported_triggers": []map[string]interface{}{{"id": "post-login", "version": "v3"}},
+					"dependencies":       []map[string]interface{}{{"name": "axios", "version": "1.6.0"}},
+					"secrets":            []map[string]interface{}{{"name": "SENDGRID_KEY", "value": "should-not-leak"}, {"name": "FROM"}}},
+				{"id": "a1", "name": "add-claims", "code": "exports.onExecutePostLogin = async () => {};",
+					"supported_triggers": []map[string]interface{}{{"id": "post-login", "version": "v3"}}},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/actions/triggers"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"triggers": []map[string]interface{}{{"id": "post-login", "version": "v3"}}})
+		case strings.HasSuffix(r.URL.Path, "/post-login/bindings"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"bindings": []map[string]interface{}{
+				{"id": "b1", "action": map[string]interface{}{"name": "send-welcome"}},
+				{"id": "b2", "action": map[string]interface{}{"name": "add-claims"}},
+			}})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	export, err := exportActions(context.Background(), m, false)
+	if err != nil {
+		t.Fatalf("Failed to export actions: %v", err)
+	}
+	if len(export.Actions) != 2 || export.Actions[0].Name != "add-claims" {
+		t.Fatalf("Expected actions sorted by name, got %+v", export.Actions)
+	}
+	if !reflect.DeepEqual(export.Actions[1].Secrets, []string{"FROM", "SENDGRID_KEY"}) {
+		t.Errorf("Expected secret names, got %v", export.Actions[1].Secrets)
+	}
+	if !reflect.DeepEqual(export.Bindings, map[string][]string{"post-login": {"send-welcome", "add-claims"}}) {
+		t.Errorf("Unexpected bindings %v", export.Bindings)
+	}
+
+	data, _ := json.Marshal(export)
+	if strings.Contains(string(data), "should-not-leak") {
+		t.Errorf("Export contains a secret value: %s", data)
+	}
+}
+
+func TestActionMigration(t *testing.T) {
+	var mu sync.Mutex
+	var created map[string]interface{}
+	var bound []interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v2/actions/actions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"start": 0, "limit": 100, "total": 1, "actions": []map[string]interface{}{
+				{"id": "a1", "name": "add-claims", "code": "claims();",
+					"supported_triggers": []map[string]interface{}{{"id": "post-login", "version": "v3"}}},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/actions/actions":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "a2"})
+		case strings.HasSuffix(r.URL.Path, "/actions/triggers"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"triggers": []map[string]interface{}{{"id": "post-login", "version": "v3"}}})
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/post-login/bindings"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"bindings": []map[string]interface{}{
+				{"id": "b1", "action": map[string]interface{}{"name": "add-claims"}},
+			}})
+		case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/post-login/bindings"):
+			var body map[string][]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			bound = body["bindings"]
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	triggers := []map[string]interface{}{{"id": "post-login", "version": "v3"}}
+	var actions []exportedAction
+	data, _ := json.Marshal([]map[string]interface{}{
+		{"name": "add-claims", "code": "claims();", "supported_triggers": triggers},
+		{"name": "send-welcome", "code": "welcome();", "supported_triggers": triggers, "secrets": []string{"FROM", "SENDGRID_KEY"}},
+	})
+	json.Unmarshal(data, &actions)
+
+	var asked []string
+	migration := &actionMigration{
+		target:  m,
+		secrets: map[string]map[string]string{"send-welcome": {"SENDGRID_KEY": "sg-123"}},
+		ask: func(question string) (string, error) {
+			asked = append(asked, question)
+			return "noreply@example.com", nil
+		},
+	}
+	if err := migration.actions(context.Background(), actions); err != nil {
+		t.Fatalf("Failed to migrate actions: %v", err)
+	}
+	if migration.created != 1 || migration.unchanged != 1 || migration.failed != 0 {
+		t.Fatalf("Unexpected counts %+v", migration)
+	}
+	if !reflect.DeepEqual(migration.deploy, []string{"send-welcome"}) {
+		t.Errorf("Expected send-welcome to be deployed, got %v", migration.deploy)
+	}
+	if !reflect.DeepEqual(asked, []string{"Value of secret FROM of action send-welcome"}) {
+		t.Errorf("Unexpected prompts %v", asked)
+	}
+	secrets, _ := json.Marshal(created["secrets"])
+	if string(secrets) != `[{"name":"FROM","value":"noreply@example.com"},{"name":"SENDGRID_KEY","value":"sg-123"}]` {
+		t.Errorf("Unexpected secrets %s", secrets)
+	}
+
+	if err := migration.bindings(context.Background(), map[string][]string{"post-login": {"send-welcome", "add-claims"}}); err != nil {
+		t.Fatalf("Failed to bind actions: %v", err)
+	}
+	order, _ := json.Marshal(bound)
+	if string(order) != `[{"display_name":"send-welcome","ref":{"type":"action_name","value":"send-welcome"}},{"display_name":"add-claims","ref":{"type":"action_name","value":"add-claims"}}]` {
+		t.Errorf("Unexpected bindings %s", order)
+	}
+}
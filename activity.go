@@ -0,0 +1,149 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// activityFields are the fields --active-within needs in an export. Users
+// who never logged in are judged by created_at.
+var activityFields = []string{"last_login", "created_at"}
+
+// parseAge parses an age such as 180d, 26w or a Go duration such as 720h.
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid age %q, expected e.g. 180d", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid age %q, expected e.g. 180d", value)
+	}
+	return age, nil
+}
+
+// withActivityFields adds the fields --active-within needs to the exported
+// fields.
+func withActivityFields(fields []string) []string {
+	for _, field := range activityFields {
+		if !slices.Contains(fields, field) {
+			fields = append(slices.Clone(fields), field)
+		}
+	}
+	return fields
+}
+
+// userActiveSince reports whether a user logged in since a time. Users who never
+// logged in count as active if they were created since then, so that
+// recently invited users aren't dropped.
+func userActiveSince(user map[string]interface{}, since time.Time) bool {
+	for _, field := range activityFields {
+		value, _ := user[field].(string)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return true // keep users whose activity can't be judged
+		}
+		return !at.Before(since)
+	}
+	return false
+}
+
+// splitDormantUsers removes the users not active since a time from an
+// exported file, and writes them to dormantFile if set. It returns the
+// numbers of active and dormant users.
+func splitDormantUsers(filename string, since time.Time, dormantFile string) (int, int, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer input.Close()
+
+	active, err := newUsersFileWriter(filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".active"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(active.name)
+	var dormant *usersFileWriter
+	if dormantFile != "" {
+		if dormant, err = newUsersFileWriter(dormantFile); err != nil {
+			active.close()
+			return 0, 0, err
+		}
+	}
+
+	activeCount, dormantCount := 0, 0
+	err = readUsers(input, func(record userRecord) error {
+		if userActiveSince(record.user, since) {
+			activeCount++
+			return active.write(record.user)
+		}
+		dormantCount++
+		if dormant != nil {
+			return dormant.write(record.user)
+		}
+		return nil
+	})
+	if closeErr := active.close(); err == nil {
+		err = closeErr
+	}
+	if dormant != nil {
+		if closeErr := dormant.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to filter the exported users: %w", err)
+	}
+	if err := os.Rename(active.name, filename); err != nil {
+		return 0, 0, fmt.Errorf("failed to replace the exported file: %w", err)
+	}
+	return activeCount, dormantCount, nil
+}
+
+// usersFileWriter writes users as gzipped newline-delimited JSON, the format
+// of Auth0 exports.
+type usersFileWriter struct {
+	name    string
+	file    *os.File
+	gz      *gzip.Writer
+	encoder *json.Encoder
+}
+
+func newUsersFileWriter(name string) (*usersFileWriter, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	gz := gzip.NewWriter(file)
+	return &usersFileWriter{name: name, file: file, gz: gz, encoder: json.NewEncoder(gz)}, nil
+}
+
+func (w *usersFileWriter) write(user map[string]interface{}) error {
+	if err := w.encoder.Encode(user); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return nil
+}
+
+func (w *usersFileWriter) close() error {
+	if err := w.gz.Close(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"180d": 180 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"36h":  36 * time.Hour,
+	} {
+		if age, err := parseAge(value); err != nil || age != expected {
+			t.Errorf("%s: expected %s, got %s %v", value, expected, age, err)
+		}
+	}
+	for _, value := range []string{"", "0d", "-5d", "d", "6 months"} {
+		if _, err := parseAge(value); err == nil {
+			t.Errorf("%q: expected an invalid age", value)
+		}
+	}
+}
+
+func TestSplitDormantUsers(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "exported_users.json")
+	os.WriteFile(filename, []byte(`{"user_id":"1","last_login":"2026-09-01T10:00:00.000Z","created_at":"2020-01-01T00:00:00.000Z"}
+{"user_id":"2","last_login":"2024-01-01T10:00:00.000Z","created_at":"2020-01-01T00:00:00.000Z"}
+{"user_id":"3","created_at":"2026-10-01T00:00:00.000Z"}
+{"user_id":"4","created_at":"2021-01-01T00:00:00.000Z"}
+`), 0644)
+
+	since := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	dormantFile := filepath.Join(dir, "dormant_users.json.gz")
+	active, dormant, err := splitDormantUsers(filename, since, dormantFile)
+	if err != nil {
+		t.Fatalf("Failed to split the users: %v", err)
+	}
+	if active != 2 || dormant != 2 {
+		t.Errorf("Expected 2 active and 2 dormant users, got %d and %d", active, dormant)
+	}
+
+	for file, expected := range map[string][]string{filename: {"1", "3"}, dormantFile: {"2", "4"}} {
+		data, err := readInputFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		records, err := decodeUsers(data)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", file, err)
+		}
+		var ids []string
+		for _, record := range records {
+			ids = append(ids, record.user["user_id"].(string))
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("%s: expected users %v, got %v", filepath.Base(file), expected, ids)
+		}
+	}
+}
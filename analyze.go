@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// maxDistinctValues is how many distinct values of a field are counted
+// before it is considered high-cardinality, such as user_id or email.
+var maxDistinctValues = 20
+
+// fieldStats are the statistics of one field of the exported users.
+// Nested fields are named by their path, e.g. user_metadata.plan.
+type fieldStats struct {
+	Field    string  `json:"field"`
+	Filled   int     `json:"filled"`
+	FillRate float64 `json:"fill_rate"`
+	// MaxSize is the size in bytes of the largest value, as JSON.
+	MaxSize int `json:"max_size"`
+	// Values counts the users per value, as JSON, for fields with at most
+	// maxDistinctValues distinct values; it is nil for the others.
+	Values map[string]int `json:"values,omitempty"`
+}
+
+// fieldAnalysis collects field statistics over a users file. Null values
+// and empty strings, arrays and objects don't count as filled.
+type fieldAnalysis struct {
+	Total       int           `json:"total"`
+	Fields      []*fieldStats `json:"fields"`
+	byField     map[string]*fieldStats
+	maxDistinct int
+}
+
+func newFieldAnalysis(maxDistinct int) *fieldAnalysis {
+	return &fieldAnalysis{byField: map[string]*fieldStats{}, maxDistinct: maxDistinct}
+}
+
+func (a *fieldAnalysis) add(user map[string]interface{}) {
+	a.Total++
+	a.addObject("", user)
+}
+
+func (a *fieldAnalysis) addObject(prefix string, object map[string]interface{}) {
+	for key, value := range object {
+		field := prefix + key
+		if isEmptyValue(value) {
+			continue
+		}
+
+		stats := a.byField[field]
+		if stats == nil {
+			stats = &fieldStats{Field: field, Values: map[string]int{}}
+			a.byField[field] = stats
+			a.Fields = append(a.Fields, stats)
+		}
+		stats.Filled++
+		data, _ := json.Marshal(value)
+		stats.MaxSize = max(stats.MaxSize, len(data))
+		if stats.Values != nil {
+			stats.Values[string(data)]++
+			if len(stats.Values) > a.maxDistinct {
+				stats.Values = nil
+			}
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			a.addObject(field+".", nested)
+		}
+	}
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// finish sorts the fields by name and computes their fill rates.
+func (a *fieldAnalysis) finish() {
+	sort.Slice(a.Fields, func(i, j int) bool { return a.Fields[i].Field < a.Fields[j].Field })
+	for _, stats := range a.Fields {
+		if a.Total > 0 {
+			stats.FillRate = float64(stats.Filled) / float64(a.Total)
+		}
+	}
+}
+
+// analyzeFields reads an exported users file and returns the statistics of
+// its fields.
+func analyzeFields(filename string, maxDistinct int) (*fieldAnalysis, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	analysis := newFieldAnalysis(maxDistinct)
+	err = readUsers(input, func(record userRecord) error {
+		analysis.add(record.user)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the exported users: %w", err)
+	}
+	analysis.finish()
+	return analysis, nil
+}
+
+// writeFieldAnalysis prints a table of the fields, followed by the value
+// counts of the low-cardinality fields, most frequent first.
+func writeFieldAnalysis(w io.Writer, analysis *fieldAnalysis) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tFILLED\tFILL RATE\tDISTINCT\tMAX SIZE")
+	for _, stats := range analysis.Fields {
+		distinct := fmt.Sprintf(">%d", analysis.maxDistinct)
+		if stats.Values != nil {
+			distinct = fmt.Sprint(len(stats.Values))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%s\t%d\n", stats.Field, stats.Filled, stats.FillRate*100, distinct, stats.MaxSize)
+	}
+	tw.Flush()
+
+	for _, stats := range analysis.Fields {
+		if stats.Values == nil {
+			continue
+		}
+		values := sortedKeys(stats.Values)
+		sort.SliceStable(values, func(i, j int) bool { return stats.Values[values[i]] > stats.Values[values[j]] })
+		counts := make([]string, len(values))
+		for i, value := range values {
+			counts[i] = fmt.Sprintf("%s (%d)", value, stats.Values[value])
+		}
+		fmt.Fprintf(w, "\n%s: %s", stats.Field, strings.Join(counts, ", "))
+	}
+	fmt.Fprintln(w)
+}
+
+func newAnalyzeCmd() *cobra.Command {
+	var analyzeCmd = &cobra.Command{
+		Use:   "analyze",
+		Short: "Analyze exported users to design transforms and validation rules",
+	}
+
+	var (
+		input  string
+		format string
+	)
+
+	var fieldsCmd = &cobra.Command{
+		Use:   "fields",
+		Short: "Report the fill rate, distinct values and maximum size of every field of the exported users",
+		Run: func(cmd *cobra.Command, args []string) {
+			if format != "text" && format != "json" {
+				log.Fatalf("Invalid --format %q, expected text or json", format)
+			}
+
+			analysis, err := analyzeFields(input, maxDistinctValues)
+			if err != nil {
+				log.Fatalf("Failed to analyze fields: %v", err)
+			}
+
+			if format == "json" {
+				json.NewEncoder(resultOutput).Encode(analysis)
+				return
+			}
+			fmt.Fprintf(messageOutput, "%d users, %d fields.\n\n", analysis.Total, len(analysis.Fields))
+			writeFieldAnalysis(messageOutput, analysis)
+			reportResult("analyze fields", map[string]interface{}{"total": analysis.Total, "fields": analysis.Fields})
+		},
+	}
+
+	fieldsCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Exported users file, optionally gzipped")
+	fieldsCmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	fieldsCmd.Flags().IntVar(&maxDistinctValues, "max-distinct", maxDistinctValues, "Number of distinct values up to which the values of a field are counted")
+
+	analyzeCmd.AddCommand(fieldsCmd)
+	return analyzeCmd
+}
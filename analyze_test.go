@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeFields(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "users.json")
+	users := `{"user_id": "auth0|1", "email": "a@example.com", "email_verified": true, "user_metadata": {"plan": "pro"}}
+{"user_id": "auth0|2", "email": "b@example.com", "email_verified": false, "user_metadata": {"plan": "free"}, "nickname": ""}
+{"user_id": "auth0|3", "email": "c@example.com", "email_verified": true, "user_metadata": {}, "app_metadata": null}
+`
+	if err := os.WriteFile(filename, []byte(users), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	analysis, err := analyzeFields(filename, 2)
+	if err != nil {
+		t.Fatalf("Failed to analyze fields: %v", err)
+	}
+	if analysis.Total != 3 {
+		t.Errorf("Expected 3 users, got %d", analysis.Total)
+	}
+
+	var fields []string
+	for _, stats := range analysis.Fields {
+		fields = append(fields, stats.Field)
+	}
+	if strings.Join(fields, ",") != "email,email_verified,user_id,user_metadata,user_metadata.plan" {
+		t.Fatalf("Unexpected fields %v", fields)
+	}
+
+	email, verified, plan := analysis.byField["email"], analysis.byField["email_verified"], analysis.byField["user_metadata.plan"]
+	if email.Values != nil || email.MaxSize != len(`"a@example.com"`) {
+		t.Errorf("Expected email to be high-cardinality with its size, got %+v", email)
+	}
+	if verified.Values["true"] != 2 || verified.Values["false"] != 1 || verified.FillRate != 1 {
+		t.Errorf("Unexpected email_verified stats %+v", verified)
+	}
+	if plan.Filled != 2 || plan.FillRate != 2.0/3 {
+		t.Errorf("Unexpected user_metadata.plan stats %+v", plan)
+	}
+
+	var buf bytes.Buffer
+	writeFieldAnalysis(&buf, analysis)
+	if !strings.Contains(buf.String(), "email_verified      3       100.0%     2         5") {
+		t.Errorf("Unexpected table:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "email_verified: true (2), false (1)") {
+		t.Errorf("Expected the value counts of email_verified, got:\n%s", buf.String())
+	}
+}
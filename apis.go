@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// listAPIs returns the APIs of a tenant by identifier, leaving out the
+// tenant's Management API.
+func listAPIs(ctx context.Context, m *management.Management) (map[string]*management.ResourceServer, error) {
+	apis := map[string]*management.ResourceServer{}
+	for page := 0; ; page++ {
+		list, err := m.ResourceServer.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list APIs: %w", apiError(err))
+		}
+		for _, api := range list.ResourceServers {
+			if strings.HasSuffix(api.GetIdentifier(), "/api/v2/") {
+				continue
+			}
+			apis[api.GetIdentifier()] = api
+		}
+		if !list.HasNext() {
+			return apis, nil
+		}
+	}
+}
+
+// apiIdentifier returns the identifier a source API gets in the target: the
+// one from the apis section of remap or, for identifiers that are URLs, the
+// identifier with its host rewritten.
+func apiIdentifier(identifier string, remap nameRemap, rewrites []hostRewrite) string {
+	if to, ok := remap["apis"][identifier]; ok {
+		return to
+	}
+	return rewriteURL(identifier, rewrites)
+}
+
+// mergeScopes returns the source scopes followed by the scopes only the
+// target defines, so that permissions granted in the target keep working.
+func mergeScopes(source, target []management.ResourceServerScope) []management.ResourceServerScope {
+	merged := append([]management.ResourceServerScope{}, source...)
+	defined := map[string]bool{}
+	for _, scope := range source {
+		defined[scope.GetValue()] = true
+	}
+	for _, scope := range target {
+		if !defined[scope.GetValue()] {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
+
+// migratedAPI returns the settings of an API that migrate apis copies. The
+// identifier is set on creation only, and signing secrets are left out, the
+// target tenant creates its own.
+func migratedAPI(api *management.ResourceServer) *management.ResourceServer {
+	return &management.ResourceServer{
+		Name:                api.Name,
+		Scopes:              api.Scopes,
+		SigningAlgorithm:    api.SigningAlgorithm,
+		AllowOfflineAccess:  api.AllowOfflineAccess,
+		TokenLifetime:       api.TokenLifetime,
+		TokenLifetimeForWeb: api.TokenLifetimeForWeb,
+		EnforcePolicies:     api.EnforcePolicies,
+		TokenDialect:        api.TokenDialect,
+		SkipConsentForVerifiableFirstPartyClients: api.SkipConsentForVerifiableFirstPartyClients,
+	}
+}
+
+// migrateAPI creates or updates a source API in the target tenant under
+// the given identifier and returns the action taken. The scopes of an
+// existing API are merged with the source scopes. Nothing is changed on a
+// dry run.
+func migrateAPI(ctx context.Context, m *management.Management, api, existing *management.ResourceServer, identifier string, dryRun bool) (string, error) {
+	migrated := migratedAPI(api)
+
+	if existing == nil {
+		if !dryRun {
+			migrated.Identifier = &identifier
+			if err := m.ResourceServer.Create(ctx, migrated); err != nil {
+				return "", fmt.Errorf("failed to create API: %w", apiError(err))
+			}
+		}
+		return diffActionCreate, nil
+	}
+
+	scopes := mergeScopes(api.GetScopes(), existing.GetScopes())
+	migrated.Scopes = &scopes
+	a, _ := json.Marshal(migrated)
+	b, _ := json.Marshal(migratedAPI(existing))
+	if string(a) == string(b) {
+		return planSkip, nil
+	}
+	if !dryRun {
+		if err := m.ResourceServer.Update(ctx, existing.GetID(), migrated); err != nil {
+			return "", fmt.Errorf("failed to update API: %w", apiError(err))
+		}
+	}
+	return diffActionUpdate, nil
+}
+
+func newMigrateAPIsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		rewriteHosts []string
+		mappingFile  string
+		remapFile    string
+		dryRun       bool
+	)
+
+	var apisCmd = &cobra.Command{
+		Use:   "apis",
+		Short: "Copy APIs with their scopes and token settings to the target tenant, and write a mapping of their identifiers for migrate roles",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate apis")
+				clients.guardTarget("migrate apis")
+			}
+			rewrites, err := parseHostRewrites(rewriteHosts)
+			if err != nil {
+				log.Fatalf("Invalid --rewrite-host: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			apis, err := listAPIs(ctx, clients.sourceClient())
+			if err != nil {
+				log.Fatalf("Failed to read source APIs: %v", err)
+			}
+			targetClient := clients.targetClient()
+			existing, err := listAPIs(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target APIs: %v", err)
+			}
+
+			mapping := map[string]string{}
+			created, updated, failed := 0, 0, 0
+			var plan []planEntry
+			for _, identifier := range sortedKeys(apis) {
+				targetIdentifier := apiIdentifier(identifier, remap, rewrites)
+				action, err := migrateAPI(ctx, targetClient, apis[identifier], existing[targetIdentifier], targetIdentifier, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate API %s: %v\n", identifier, err)
+					failed++
+					continue
+				}
+				mapping[identifier] = targetIdentifier
+				plan = append(plan, planEntry{Resource: "apis/" + targetIdentifier, Action: action})
+				switch action {
+				case diffActionCreate:
+					created++
+				case diffActionUpdate:
+					updated++
+				}
+				if !dryRun {
+					fmt.Fprintf(messageOutput, "API %s: %s → %s (%s)\n", apis[identifier].GetName(), identifier, targetIdentifier, action)
+				}
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate apis", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			if mappingFile != "" && len(mapping) > 0 {
+				data, err := yaml.Marshal(nameRemap{"apis": mapping})
+				if err != nil {
+					log.Fatalf("Failed to encode API identifier mapping: %v", err)
+				}
+				if err := os.WriteFile(mappingFile, data, 0o644); err != nil {
+					log.Fatalf("Failed to write API identifier mapping: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "API identifier mapping written to %s; pass it to migrate roles and users permissions import with --remap.\n", mappingFile)
+			}
+			fmt.Fprintf(messageOutput, "APIs migrated: %d APIs, %d created, %d updated, %d failed.\n", len(apis), created, updated, failed)
+			reportResult("migrate apis", map[string]interface{}{
+				"apis": len(apis), "created": created, "updated": updated, "failed": failed,
+				"mapping": mapping, "mapping_file": mappingFile,
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	apisCmd.Flags().StringSliceVar(&rewriteHosts, "rewrite-host", nil, "Replace a host in API identifiers that are URLs, as old.example.com=new.example.com; repeatable")
+	apisCmd.Flags().StringVar(&mappingFile, "mapping", "api_remap.yaml", "Remap file the mapping of source to target API identifiers is written to; empty to skip")
+	apisCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source API identifiers to their identifiers in the target, under apis")
+	apisCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return apisCmd
+}
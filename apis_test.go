@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAPIIdentifier(t *testing.T) {
+	remap := nameRemap{"apis": {"https://api.example.com/orders": "https://orders.example.com"}}
+	rewrites := []hostRewrite{{from: "api.example.com", to: "api.staging.example.com"}}
+
+	tests := map[string]string{
+		"https://api.example.com/orders":  "https://orders.example.com",
+		"https://api.example.com/billing": "https://api.staging.example.com/billing",
+		"urn:example:inventory":           "urn:example:inventory",
+	}
+	for identifier, expected := range tests {
+		if got := apiIdentifier(identifier, remap, rewrites); got != expected {
+			t.Errorf("apiIdentifier(%q) = %q, expected %q", identifier, got, expected)
+		}
+	}
+
+	// The mapping migrate apis writes is a remap file.
+	data, _ := yaml.Marshal(nameRemap{"apis": tests})
+	filename := filepath.Join(t.TempDir(), "api_remap.yaml")
+	os.WriteFile(filename, data, 0o644)
+	loaded, err := loadNameRemap(filename)
+	if err != nil || loaded.name("apis", "https://api.example.com/billing") != "https://api.staging.example.com/billing" {
+		t.Errorf("Expected the mapping to load as a remap file, got %v (%v)", loaded, err)
+	}
+}
+
+func TestMigrateAPIMergesScopes(t *testing.T) {
+	var updated management.ResourceServer
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v2/resource-servers/rs_1" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&updated)
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	}))
+
+	source := &management.ResourceServer{
+		Name:            auth0.String("Orders"),
+		Identifier:      auth0.String("https://api.example.com/orders"),
+		EnforcePolicies: auth0.Bool(true),
+		TokenLifetime:   auth0.Int(3600),
+		Scopes: &[]management.ResourceServerScope{
+			{Value: auth0.String("read:orders"), Description: auth0.String("Read orders")},
+		},
+	}
+	existing := &management.ResourceServer{
+		ID:         auth0.String("rs_1"),
+		Name:       auth0.String("Orders"),
+		Identifier: auth0.String("https://orders.example.com"),
+		Scopes: &[]management.ResourceServerScope{
+			{Value: auth0.String("read:orders"), Description: auth0.String("Old description")},
+			{Value: auth0.String("delete:orders")},
+		},
+	}
+
+	action, err := migrateAPI(context.Background(), m, source, existing, "https://orders.example.com", false)
+	if err != nil || action != diffActionUpdate {
+		t.Fatalf("Expected the API to be updated, got %s (%v)", action, err)
+	}
+	scopes := updated.GetScopes()
+	if len(scopes) != 2 || scopes[0].GetDescription() != "Read orders" || scopes[1].GetValue() != "delete:orders" {
+		t.Errorf("Expected merged scopes, got %+v", scopes)
+	}
+	if updated.Identifier != nil || !updated.GetEnforcePolicies() || updated.GetTokenLifetime() != 3600 {
+		t.Errorf("Unexpected update %+v", updated)
+	}
+
+	existing.Scopes, existing.EnforcePolicies, existing.TokenLifetime = &scopes, source.EnforcePolicies, source.TokenLifetime
+	if action, err := migrateAPI(context.Background(), m, source, existing, "https://orders.example.com", false); err != nil || action != planSkip {
+		t.Errorf("Expected an up to date API to be skipped, got %s (%v)", action, err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// managementBasePath is where the Management API lives on a tenant domain.
+const managementBasePath = "/api/v2"
+
+// parseAPIURL checks a Management API base URL, such as
+// https://api.example.com/api/v2 for a private cloud deployment.
+func parseAPIURL(value string) (*url.URL, error) {
+	apiURL, err := url.Parse(value)
+	if err != nil || (apiURL.Scheme != "https" && apiURL.Scheme != "http") || apiURL.Host == "" {
+		return nil, fmt.Errorf("invalid API URL %q, expected an http(s) URL such as https://api.example.com/api/v2", value)
+	}
+	return apiURL, nil
+}
+
+// apiURLTransport sends Management API requests to a base URL other than
+// the tenant domain. Token requests don't pass through it, so they still go
+// to the tenant domain.
+type apiURLTransport struct {
+	apiURL *url.URL
+	base   http.RoundTripper
+}
+
+func (t apiURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.Path, managementBasePath) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.apiURL.Scheme
+		req.URL.Host = t.apiURL.Host
+		req.URL.Path = strings.TrimSuffix(t.apiURL.Path, "/") + strings.TrimPrefix(req.URL.Path, managementBasePath)
+		req.URL.RawPath = ""
+		req.Host = ""
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newManagementClient creates a client authenticating with the client
+// credentials of domain. apiURL and audience, if set, override the
+// Management API base URL and the token audience, for private cloud and
+// regional deployments.
+func newManagementClient(ctx context.Context, domain, clientID, clientSecret, apiURL, audience string) (*management.Management, error) {
+	options := []management.Option{management.WithClientCredentials(ctx, clientID, clientSecret)}
+	if audience != "" {
+		options[0] = management.WithClientCredentialsAndAudience(ctx, clientID, clientSecret, audience)
+	}
+
+	// Retries are done by retryTransport instead of the SDK, which gives up
+	// after two rate limited attempts.
+	var transport http.RoundTripper = newRetryTransport(logTransport(throttleTransport(http.DefaultTransport)))
+	if apiURL != "" {
+		parsed, err := parseAPIURL(apiURL)
+		if err != nil {
+			return nil, err
+		}
+		transport = apiURLTransport{apiURL: parsed, base: transport}
+	}
+	options = append(options, management.WithClient(&http.Client{Transport: transport}), management.WithNoRetries())
+
+	return management.New(domain, options...)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestAPIURLTransport(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "completed"})
+	}))
+	defer server.Close()
+
+	apiURL, err := parseAPIURL(server.URL + "/private/api/v2/")
+	if err != nil {
+		t.Fatalf("Failed to parse API URL: %v", err)
+	}
+	m, err := management.New("tenant.example.com", management.WithInsecure(),
+		management.WithClient(&http.Client{Transport: apiURLTransport{apiURL: apiURL, base: http.DefaultTransport}}))
+	if err != nil {
+		t.Fatalf("Failed to create management client: %v", err)
+	}
+
+	if _, err := m.Job.Read(context.Background(), "job_1"); err != nil {
+		t.Fatalf("Failed to read job: %v", err)
+	}
+	if path != "/private/api/v2/jobs/job_1" {
+		t.Errorf("Expected the request to go to the API URL, got path %s", path)
+	}
+}
+
+func TestParseAPIURL(t *testing.T) {
+	if _, err := parseAPIURL("https://api.example.com/api/v2"); err != nil {
+		t.Errorf("Expected a valid API URL, got %v", err)
+	}
+	for _, value := range []string{"api.example.com", "ftp://api.example.com", "https://"} {
+		if _, err := parseAPIURL(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+
+	cfg := &config{Profiles: map[string]profileConfig{
+		"private": {Domain: "login.example.com", ClientID: "id", ClientSecret: "secret", APIURL: "api.example.com"},
+	}}
+	problems := cfg.lint()
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "profiles.private: api_url") {
+		t.Errorf("Expected an api_url problem, got %v", problems)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// approvalDir holds the decisions written by the approve command, one file
+// per gate.
+const approvalDir = "auth0-tools.approvals"
+
+// approvalPhases are the phases an approval gate can be defined for. They
+// are the same as the hook phases.
+var approvalPhases = []string{"pre_export", "post_export", "pre_import", "post_import"}
+
+// errRejected is returned when a gate is rejected instead of approved.
+var errRejected = errors.New("rejected")
+
+// approvalGate holds a phase until it is approved, either with
+// `approve <phase>` or by a POST to /approve/<phase> on the listen address.
+// notify is POSTed a JSON object describing the gate when it is reached.
+type approvalGate struct {
+	Notify  string        `yaml:"notify"`
+	Listen  string        `yaml:"listen"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// approvalDecision reads and consumes a decision written by the approve
+// command, so every run needs a new approval.
+func approvalDecision(phase string) (string, bool) {
+	filename := filepath.Join(approvalDir, phase)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", false
+	}
+	os.Remove(filename)
+	return strings.TrimSpace(string(data)), true
+}
+
+func writeApprovalDecision(phase, decision string) error {
+	if err := os.MkdirAll(approvalDir, 0755); err != nil {
+		return fmt.Errorf("failed to create approval directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(approvalDir, phase), []byte(decision+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write approval: %w", err)
+	}
+	return nil
+}
+
+// approvalHandler accepts POST /approve/<phase> and /reject/<phase> with the
+// gate's token and passes the decision on.
+func approvalHandler(phase, token string, decisions chan<- string) http.Handler {
+	mux := http.NewServeMux()
+	for _, decision := range []string{"approve", "reject"} {
+		decision := decision
+		mux.HandleFunc("/"+decision+"/"+phase, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if r.URL.Query().Get("token") != token {
+				http.Error(w, "invalid token", http.StatusForbidden)
+				return
+			}
+			select {
+			case decisions <- decision:
+			default:
+			}
+			fmt.Fprintf(w, "%s: %s recorded\n", phase, decision)
+		})
+	}
+	return mux
+}
+
+// awaitApproval notifies about the gate of phase and waits until it is
+// approved or rejected, or its timeout (if set) passes.
+func awaitApproval(ctx context.Context, gate approvalGate, phase string, data map[string]interface{}) error {
+	decisions := make(chan string, 1)
+	notification := map[string]interface{}{"phase": phase, "time": time.Now().UTC().Format(time.RFC3339)}
+	for key, value := range data {
+		notification[key] = value
+	}
+
+	// A decision left over from an earlier run doesn't count.
+	approvalDecision(phase)
+
+	if gate.Listen != "" {
+		tokenBytes := make([]byte, 16)
+		if _, err := rand.Read(tokenBytes); err != nil {
+			return fmt.Errorf("failed to create approval token: %w", err)
+		}
+		token := hex.EncodeToString(tokenBytes)
+
+		listener, err := net.Listen("tcp", gate.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for approvals: %w", err)
+		}
+		server := &http.Server{Handler: approvalHandler(phase, token, decisions)}
+		go server.Serve(listener)
+		defer server.Close()
+
+		notification["approve_path"] = fmt.Sprintf("/approve/%s?token=%s", phase, token)
+		notification["reject_path"] = fmt.Sprintf("/reject/%s?token=%s", phase, token)
+	}
+
+	if gate.Notify != "" {
+		payload, _ := json.Marshal(notification)
+		resp, err := http.Post(gate.Notify, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to send approval notification: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("approval notification failed with HTTP %d", resp.StatusCode)
+		}
+	}
+
+	fmt.Fprintf(messageOutput, "Waiting for approval of %s. Run `approve %s` to continue.\n", phase, phase)
+	emitEvent("approval_required", map[string]interface{}{"phase": phase})
+
+	var decision string
+	approvalPoller := poller{initial: time.Second, max: 5 * time.Second, maxWait: gate.Timeout}
+	err := approvalPoller.poll(ctx, func(ctx context.Context) (bool, error) {
+		select {
+		case decision = <-decisions:
+			return true, nil
+		default:
+		}
+		var ok bool
+		decision, ok = approvalDecision(phase)
+		return ok, nil
+	})
+	if errors.Is(err, errPollTimeout) {
+		return fmt.Errorf("no approval for %s within %s", phase, gate.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if decision == "reject" {
+		return fmt.Errorf("%s was %w", phase, errRejected)
+	}
+	fmt.Fprintf(messageOutput, "%s approved.\n", phase)
+	return nil
+}
+
+// mustApprove waits for the approval gate of phase, if configured, and
+// aborts the command if it is rejected or times out.
+func (h *hookRunner) mustApprove(ctx context.Context, phase string, data map[string]interface{}) {
+	gate, ok := h.approvals[phase]
+	if !ok {
+		return
+	}
+	if err := awaitApproval(ctx, gate, phase, data); err != nil {
+		h.fatalf("Aborting: %v", err)
+	}
+}
+
+func newApproveCmd() *cobra.Command {
+	var reject bool
+
+	var approveCmd = &cobra.Command{
+		Use:   "approve <phase>",
+		Short: "Approve (or reject) a waiting approval gate",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			phase := args[0]
+			if !slices.Contains(approvalPhases, phase) {
+				log.Fatalf("Unknown phase %q, expected one of %s", phase, strings.Join(approvalPhases, ", "))
+			}
+
+			decision := "approve"
+			if reject {
+				decision = "reject"
+			}
+			if err := writeApprovalDecision(phase, decision); err != nil {
+				log.Fatalf("Failed to %s %s: %v", decision, phase, err)
+			}
+			fmt.Fprintf(messageOutput, "%s: %s recorded.\n", phase, decision)
+		},
+	}
+
+	approveCmd.Flags().BoolVar(&reject, "reject", false, "Reject the gate, aborting the waiting command")
+
+	return approveCmd
+}
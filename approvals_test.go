@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAwaitApprovalNotifiesAndWaits(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	var notification map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&notification)
+		writeApprovalDecision("pre_import", "reject")
+	}))
+	defer server.Close()
+
+	err := awaitApproval(context.Background(), approvalGate{Notify: server.URL}, "pre_import", map[string]interface{}{"chunks": 3})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Expected the gate to be rejected, got %v", err)
+	}
+	if notification["phase"] != "pre_import" || notification["chunks"] != float64(3) {
+		t.Errorf("Unexpected notification %v", notification)
+	}
+	if _, ok := approvalDecision("pre_import"); ok {
+		t.Errorf("Expected the decision to be consumed")
+	}
+}
+
+func TestApprovalHandler(t *testing.T) {
+	decisions := make(chan string, 1)
+	handler := approvalHandler("post_export", "secret", decisions)
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/approve/post_export?token=secret", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/approve/post_export?token=wrong", http.StatusForbidden},
+		{http.MethodPost, "/approve/pre_import?token=secret", http.StatusNotFound},
+		{http.MethodPost, "/approve/post_export?token=secret", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.target, tc.status, rec.Code)
+		}
+	}
+
+	if decision := <-decisions; decision != "approve" {
+		t.Errorf("Expected an approval, got %s", decision)
+	}
+}
+
+func TestApprovalLint(t *testing.T) {
+	cfg := &config{Approvals: map[string]approvalGate{"pre_import": {}, "mid_import": {}}}
+	problems := cfg.lint()
+	if len(problems) != 1 || problems[0] != "approvals.mid_import: unknown phase, expected one of pre_export, post_export, pre_import, post_import" {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+}
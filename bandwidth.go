@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bwlimit is the --bwlimit flag, e.g. 10MB/s.
+var bwlimit string
+
+// bandwidth limits the export downloads and import uploads of the run
+// together, nil if there is no limit.
+var bandwidth *bandwidthLimiter
+
+var bandwidthUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// parseBandwidth parses a rate such as 10MB/s, 512KiB/s or 1000000 into
+// bytes per second.
+func parseBandwidth(value string) (int64, error) {
+	text := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(value), "/s"))
+	number := strings.TrimRightFunc(text, func(r rune) bool {
+		return r >= 'a' && r <= 'z'
+	})
+
+	unit, ok := bandwidthUnits[strings.TrimSpace(text[len(number):])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit in %q, expected B, KB, MB, GB, KiB, MiB or GiB per second", value)
+	}
+	amount, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || amount*unit < 1 {
+		return 0, fmt.Errorf("invalid bandwidth %q, expected a rate such as 10MB/s", value)
+	}
+	return int64(amount * unit), nil
+}
+
+// bandwidthLimiter spaces out reads so that all streams sharing it together
+// transfer at most rate bytes per second.
+type bandwidthLimiter struct {
+	rate int64
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newBandwidthLimiter(rate int64) *bandwidthLimiter {
+	return &bandwidthLimiter{rate: rate}
+}
+
+// wait accounts for n transferred bytes and sleeps until the rate allows
+// them.
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+type throttledReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Small reads keep the transfer smooth instead of bursting.
+	if burst := int(max(t.limiter.rate/10, 1024)); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.r.Read(p)
+	t.limiter.wait(n)
+	return n, err
+}
+
+// throttle limits reads from r to the --bwlimit bandwidth.
+func throttle(r io.Reader) io.Reader {
+	if bandwidth == nil {
+		return r
+	}
+	return &throttledReader{r: r, limiter: bandwidth}
+}
+
+// throttledTransport limits request bodies, such as the users files of
+// import jobs, to the --bwlimit bandwidth.
+type throttledTransport struct {
+	base http.RoundTripper
+}
+
+func (t throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body := req.Body
+		req = req.Clone(req.Context())
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{throttle(body), body}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// throttleTransport makes a Management API transport honour --bwlimit.
+func throttleTransport(base http.RoundTripper) http.RoundTripper {
+	if bandwidth == nil {
+		return base
+	}
+	return throttledTransport{base: base}
+}
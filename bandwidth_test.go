@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	valid := map[string]int64{
+		"10MB/s":   10000000,
+		"512KiB/s": 524288,
+		"1.5 mb":   1500000,
+		"2000":     2000,
+	}
+	for value, expected := range valid {
+		if rate, err := parseBandwidth(value); err != nil || rate != expected {
+			t.Errorf("Expected %s to be %d bytes/s, got %d %v", value, expected, rate, err)
+		}
+	}
+
+	for _, value := range []string{"", "fast", "10XB/s", "0MB/s", "-1KB/s"} {
+		if _, err := parseBandwidth(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	defer func() { bandwidth = nil }()
+	bandwidth = newBandwidthLimiter(100000)
+
+	start := time.Now()
+	data, err := io.ReadAll(throttle(bytes.NewReader(make([]byte, 20000))))
+	if err != nil || len(data) != 20000 {
+		t.Fatalf("Failed to read throttled data: %d bytes, %v", len(data), err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 20KB at 100KB/s to take about 200ms, took %s", elapsed)
+	}
+}
+
+func TestThrottledTransport(t *testing.T) {
+	defer func() { bandwidth = nil }()
+	bandwidth = newBandwidthLimiter(100000)
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: throttledTransport{base: http.DefaultTransport}}
+	start := time.Now()
+	resp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(make([]byte, 20000)))
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	resp.Body.Close()
+
+	if received != 20000 {
+		t.Errorf("Expected 20000 bytes uploaded, got %d", received)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the upload to be throttled, took %s", elapsed)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// customTextPrompts are the Universal Login prompts whose texts can be
+// customized per language.
+var customTextPrompts = []management.PromptType{
+	management.PromptLogin, management.PromptLoginID, management.PromptLoginPassword,
+	management.PromptLoginPasswordLess, management.PromptLoginEmailVerification,
+	management.PromptSignup, management.PromptSignupID, management.PromptSignupPassword,
+	management.PromptPhoneIdentifierEnrollment, management.PromptPhoneIdentifierChallenge,
+	management.PromptEmailIdentifierChallenge, management.PromptResetPassword,
+	management.PromptCustomForm, management.PromptConsent, management.PromptCustomizedConsent,
+	management.PromptLogout, management.PromptMFAPush, management.PromptMFAOTP,
+	management.PromptMFAVoice, management.PromptMFAPhone, management.PromptMFAWebAuthn,
+	management.PromptMFASMS, management.PromptMFAEmail, management.PromptMFARecoveryCode,
+	management.PromptMFA, management.PromptStatus, management.PromptDeviceFlow,
+	management.PromptEmailVerification, management.PromptEmailOTPChallenge,
+	management.PromptOrganizations, management.PromptInvitation, management.PromptCommon,
+	management.PromptPasskeys, management.PromptCaptcha,
+}
+
+// brandingMigration copies the branding settings, the Universal Login page
+// template, the prompt settings, the prompts' custom texts and the theme
+// of the new Universal Login to the target tenant, and keeps count of what
+// it did.
+type brandingMigration struct {
+	source, target *management.Management
+	dryRun         bool
+
+	created, updated, unchanged, failed int
+	plan                                []planEntry
+}
+
+func (b *brandingMigration) record(resource, action string) {
+	b.plan = append(b.plan, planEntry{Resource: resource, Action: action})
+	switch action {
+	case diffActionCreate:
+		b.created++
+	case diffActionUpdate:
+		b.updated++
+	default:
+		b.unchanged++
+	}
+	if !b.dryRun && action != planSkip {
+		fmt.Fprintf(messageOutput, "Applied %s %s.\n", action, resource)
+	}
+}
+
+func (b *brandingMigration) fail(resource string, err error) {
+	fmt.Fprintf(messageOutput, "Failed to migrate %s: %v\n", resource, err)
+	b.failed++
+}
+
+// apply copies a setting the target has at most once: the source value is
+// applied unless it matches the current one as JSON. exists tells whether
+// the target has the setting yet.
+func (b *brandingMigration) apply(resource string, source, current interface{}, exists bool, update func() error) {
+	action := diffActionCreate
+	if exists {
+		a, _ := json.Marshal(source)
+		c, _ := json.Marshal(current)
+		if string(a) == string(c) {
+			b.record(resource, planSkip)
+			return
+		}
+		action = diffActionUpdate
+	}
+	if !b.dryRun {
+		if err := update(); err != nil {
+			b.fail(resource, err)
+			return
+		}
+	}
+	b.record(resource, action)
+}
+
+// settings copies the colors, logo, favicon and font.
+func (b *brandingMigration) settings(ctx context.Context) {
+	source, err := b.source.Branding.Read(ctx)
+	if err != nil {
+		b.fail("branding", fmt.Errorf("failed to read source branding: %w", apiError(err)))
+		return
+	}
+	current, err := b.target.Branding.Read(ctx)
+	if err != nil {
+		b.fail("branding", fmt.Errorf("failed to read target branding: %w", apiError(err)))
+		return
+	}
+	b.apply("branding", source, current, true, func() error {
+		if err := b.target.Branding.Update(ctx, source); err != nil {
+			return fmt.Errorf("failed to update branding: %w", apiError(err))
+		}
+		return nil
+	})
+}
+
+// universalLogin copies the page template of the Universal Login, if the
+// source has one. Auth0 only accepts it on tenants with a custom domain.
+func (b *brandingMigration) universalLogin(ctx context.Context) {
+	source, err := b.source.Branding.UniversalLogin(ctx)
+	if isNotFound(err) {
+		return
+	}
+	if err != nil {
+		b.fail("branding/templates/universal-login", fmt.Errorf("failed to read source template: %w", apiError(err)))
+		return
+	}
+	current, err := b.target.Branding.UniversalLogin(ctx)
+	if err != nil && !isNotFound(err) {
+		b.fail("branding/templates/universal-login", fmt.Errorf("failed to read target template: %w", apiError(err)))
+		return
+	}
+	b.apply("branding/templates/universal-login", source, current, err == nil, func() error {
+		if err := b.target.Branding.SetUniversalLogin(ctx, source); err != nil {
+			return fmt.Errorf("failed to set template (the target needs a custom domain): %w", apiError(err))
+		}
+		return nil
+	})
+}
+
+// prompts copies which Universal Login experience is used and how the
+// login screen asks for the identifier.
+func (b *brandingMigration) prompts(ctx context.Context) {
+	source, err := b.source.Prompt.Read(ctx)
+	if err != nil {
+		b.fail("prompts", fmt.Errorf("failed to read source prompt settings: %w", apiError(err)))
+		return
+	}
+	current, err := b.target.Prompt.Read(ctx)
+	if err != nil {
+		b.fail("prompts", fmt.Errorf("failed to read target prompt settings: %w", apiError(err)))
+		return
+	}
+	b.apply("prompts", source, current, true, func() error {
+		if err := b.target.Prompt.Update(ctx, source); err != nil {
+			return fmt.Errorf("failed to update prompt settings: %w", apiError(err))
+		}
+		return nil
+	})
+}
+
+// customText copies the custom texts of every prompt in the given
+// languages. Prompts without custom texts in the source are left alone.
+func (b *brandingMigration) customText(ctx context.Context, languages []string) {
+	for _, prompt := range customTextPrompts {
+		for _, language := range languages {
+			resource := fmt.Sprintf("prompts/%s/custom-text/%s", prompt, language)
+			source, err := b.source.Prompt.CustomText(ctx, string(prompt), language)
+			if err != nil {
+				b.fail(resource, fmt.Errorf("failed to read source custom text: %w", apiError(err)))
+				continue
+			}
+			if len(source) == 0 {
+				continue
+			}
+			current, err := b.target.Prompt.CustomText(ctx, string(prompt), language)
+			if err != nil {
+				b.fail(resource, fmt.Errorf("failed to read target custom text: %w", apiError(err)))
+				continue
+			}
+			b.apply(resource, source, current, len(current) > 0, func() error {
+				if err := b.target.Prompt.SetCustomText(ctx, string(prompt), language, source); err != nil {
+					return fmt.Errorf("failed to set custom text: %w", apiError(err))
+				}
+				return nil
+			})
+		}
+	}
+}
+
+// theme copies the default theme of the new Universal Login, if the
+// source has one.
+func (b *brandingMigration) theme(ctx context.Context) {
+	source, err := b.source.BrandingTheme.Default(ctx)
+	if isNotFound(err) {
+		return
+	}
+	if err != nil {
+		b.fail("branding/themes/default", fmt.Errorf("failed to read source theme: %w", apiError(err)))
+		return
+	}
+	current, err := b.target.BrandingTheme.Default(ctx)
+	if err != nil && !isNotFound(err) {
+		b.fail("branding/themes/default", fmt.Errorf("failed to read target theme: %w", apiError(err)))
+		return
+	}
+
+	theme := *source
+	theme.ID = nil
+	exists := err == nil
+	var currentTheme management.BrandingTheme
+	if exists {
+		currentTheme = *current
+		currentTheme.ID = nil
+	}
+	b.apply("branding/themes/default", theme, currentTheme, exists, func() error {
+		if !exists {
+			if err := b.target.BrandingTheme.Create(ctx, &theme); err != nil {
+				return fmt.Errorf("failed to create theme: %w", apiError(err))
+			}
+			return nil
+		}
+		if err := b.target.BrandingTheme.Update(ctx, current.GetID(), &theme); err != nil {
+			return fmt.Errorf("failed to update theme: %w", apiError(err))
+		}
+		return nil
+	})
+}
+
+// tenantLanguages returns the languages enabled on a tenant, English if
+// none are set.
+func tenantLanguages(ctx context.Context, m *management.Management) ([]string, error) {
+	tenant, err := m.Tenant.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant settings: %w", apiError(err))
+	}
+	if languages := tenant.GetEnabledLocales(); len(languages) > 0 {
+		return languages, nil
+	}
+	return []string{"en"}, nil
+}
+
+func newMigrateBrandingCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		languages []string
+		dryRun    bool
+	)
+
+	var brandingCmd = &cobra.Command{
+		Use:   "branding",
+		Short: "Copy branding, the Universal Login page template, prompt custom texts and the theme to the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate branding")
+				clients.guardTarget("migrate branding")
+			}
+
+			migration := &brandingMigration{source: clients.sourceClient(), target: clients.targetClient(), dryRun: dryRun}
+			if len(languages) == 0 {
+				var err error
+				if languages, err = tenantLanguages(ctx, migration.source); err != nil {
+					log.Fatalf("Failed to read source languages: %v", err)
+				}
+			}
+
+			migration.settings(ctx)
+			migration.universalLogin(ctx)
+			migration.prompts(ctx)
+			migration.customText(ctx, languages)
+			migration.theme(ctx)
+
+			if dryRun {
+				writePlan(messageOutput, migration.plan)
+				reportResult("migrate branding", map[string]interface{}{"dry_run": true, "plan": migration.plan, "failed": migration.failed})
+				if migration.failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Branding migrated: %d created, %d updated, %d unchanged, %d failed.\n",
+				migration.created, migration.updated, migration.unchanged, migration.failed)
+			reportResult("migrate branding", map[string]interface{}{
+				"created": migration.created, "updated": migration.updated, "unchanged": migration.unchanged,
+				"failed": migration.failed, "plan": migration.plan,
+			})
+			if migration.failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	brandingCmd.Flags().StringSliceVar(&languages, "languages", nil, "Languages whose custom texts are copied, e.g. en,fr; by default the source tenant's enabled languages")
+	brandingCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return brandingCmd
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMigrateBranding(t *testing.T) {
+	notFound := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 404, "message": "Not found"})
+	}
+	theme := map[string]interface{}{
+		"themeId": "theme_source", "displayName": "Example",
+		"colors": map[string]interface{}{"primary_button": "#0059d6"},
+	}
+
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet:
+			t.Errorf("Unexpected write to the source %s %s", r.Method, r.URL.Path)
+		case strings.HasSuffix(r.URL.Path, "/branding"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"logo_url": "https://cdn.example.com/logo.png", "colors": map[string]interface{}{"primary": "#0059d6"}})
+		case strings.HasSuffix(r.URL.Path, "/branding/templates/universal-login"):
+			notFound(w)
+		case strings.HasSuffix(r.URL.Path, "/prompts"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"universal_login_experience": "new", "identifier_first": true})
+		case strings.HasSuffix(r.URL.Path, "/prompts/login/custom-text/en"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"login": map[string]interface{}{"title": "Welcome back"}})
+		case strings.Contains(r.URL.Path, "/custom-text/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		case strings.HasSuffix(r.URL.Path, "/branding/themes/default"):
+			json.NewEncoder(w).Encode(theme)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	requests := map[string]map[string]interface{}{}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			requests[r.Method+" "+strings.TrimPrefix(r.URL.Path, "/api/v2")] = body
+			w.Write([]byte(`{}`))
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/branding"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"logo_url": "https://cdn.example.com/old-logo.png"})
+		case strings.HasSuffix(r.URL.Path, "/prompts"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"universal_login_experience": "new", "identifier_first": true})
+		case strings.Contains(r.URL.Path, "/custom-text/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		case strings.HasSuffix(r.URL.Path, "/branding/themes/default"):
+			notFound(w)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	ctx := context.Background()
+	migration := &brandingMigration{source: source, target: target}
+	migration.settings(ctx)
+	migration.universalLogin(ctx)
+	migration.prompts(ctx)
+	migration.customText(ctx, []string{"en"})
+	migration.theme(ctx)
+
+	if migration.created != 2 || migration.updated != 1 || migration.unchanged != 1 || migration.failed != 0 {
+		t.Errorf("Expected 2 created, 1 updated and 1 unchanged, got %+v", migration.plan)
+	}
+	if requests["PATCH /branding"]["logo_url"] != "https://cdn.example.com/logo.png" {
+		t.Errorf("Expected the logo to be updated, got %v", requests)
+	}
+	if _, ok := requests["PUT /prompts/login/custom-text/en"]["login"]; !ok {
+		t.Errorf("Expected the login texts to be set, got %v", requests)
+	}
+	created, ok := requests["POST /branding/themes"]
+	if !ok || created["displayName"] != "Example" || created["themeId"] != nil {
+		t.Errorf("Expected the theme to be created without the source ID, got %v", requests)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+const (
+	bundleVersion      = 1
+	bundleManifestPath = "manifest.json"
+	bundleUsersPath    = "users.json"
+)
+
+// bundleManifest describes the contents of a migration bundle: a zip file
+// holding the exported users, tenant config resources and mapping files.
+type bundleManifest struct {
+	Version   int                   `json:"version"`
+	CreatedAt string                `json:"created_at"`
+	Source    string                `json:"source,omitempty"`
+	Users     *bundleFile           `json:"users,omitempty"`
+	Resources map[string]bundleFile `json:"resources,omitempty"`
+	Mappings  []bundleFile          `json:"mappings,omitempty"`
+}
+
+type bundleFile struct {
+	Path   string `json:"path"`
+	Count  int    `json:"count,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+func isBundle(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+type bundleWriter struct {
+	zip      *zip.Writer
+	manifest bundleManifest
+}
+
+func newBundleWriter(w io.Writer, source string) *bundleWriter {
+	return &bundleWriter{
+		zip:      zip.NewWriter(w),
+		manifest: bundleManifest{Version: bundleVersion, CreatedAt: time.Now().UTC().Format(time.RFC3339), Source: source},
+	}
+}
+
+func (b *bundleWriter) add(path string, data []byte, count int) (bundleFile, error) {
+	w, err := b.zip.Create(path)
+	if err != nil {
+		return bundleFile{}, fmt.Errorf("failed to add %s to bundle: %w", path, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return bundleFile{}, fmt.Errorf("failed to add %s to bundle: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	return bundleFile{Path: path, Count: count, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+func (b *bundleWriter) addUsers(data []byte, count int) error {
+	file, err := b.add(bundleUsersPath, data, count)
+	b.manifest.Users = &file
+	return err
+}
+
+func (b *bundleWriter) addResources(kind string, resources map[string]map[string]interface{}) error {
+	items := make([]map[string]interface{}, 0, len(resources))
+	for _, key := range sortedKeys(resources) {
+		items = append(items, resources[key])
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+
+	file, err := b.add("resources/"+kind+".json", data, len(items))
+	if b.manifest.Resources == nil {
+		b.manifest.Resources = map[string]bundleFile{}
+	}
+	b.manifest.Resources[kind] = file
+	return err
+}
+
+func (b *bundleWriter) addMapping(name string, data []byte) error {
+	file, err := b.add("mappings/"+name, data, 0)
+	b.manifest.Mappings = append(b.manifest.Mappings, file)
+	return err
+}
+
+// close writes the manifest and finishes the zip file.
+func (b *bundleWriter) close() error {
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	w, err := b.zip.Create(bundleManifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to add manifest to bundle: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to add manifest to bundle: %w", err)
+	}
+	return b.zip.Close()
+}
+
+type bundle struct {
+	manifest bundleManifest
+	zip      *zip.Reader
+}
+
+func openBundle(data []byte) (*bundle, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	b := &bundle{zip: reader}
+	manifest, err := b.readFile(bundleManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifest, &b.manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if b.manifest.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.manifest.Version)
+	}
+	return b, nil
+}
+
+// readBundleFile opens the bundle in filename. It returns nil if the file is
+// not a bundle.
+func readBundleFile(filename string) (*bundle, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if isEncrypted(data) {
+		key, err := encryptionKey()
+		if err != nil {
+			return nil, err
+		}
+		if data, err = decryptData(data, key); err != nil {
+			return nil, err
+		}
+	}
+	if !isBundle(data) {
+		return nil, nil
+	}
+	return openBundle(data)
+}
+
+func (b *bundle) readFile(path string) ([]byte, error) {
+	file, err := b.zip.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from bundle: %w", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from bundle: %w", path, err)
+	}
+	return data, nil
+}
+
+// read returns the content of a file listed in the manifest, checking it
+// against the manifest checksum.
+func (b *bundle) read(file bundleFile) ([]byte, error) {
+	data, err := b.readFile(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != file.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch for %s in bundle", file.Path)
+	}
+	return data, nil
+}
+
+func (b *bundle) users() ([]byte, error) {
+	if b.manifest.Users == nil {
+		return nil, fmt.Errorf("bundle contains no users")
+	}
+	return b.read(*b.manifest.Users)
+}
+
+// resources returns the bundled resources of a kind keyed by the kind's key
+// field, as fetchResources does for a tenant.
+func (b *bundle) resources(kind resourceKind) (map[string]map[string]interface{}, error) {
+	file, ok := b.manifest.Resources[kind.name]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := b.read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s from bundle: %w", file.Path, err)
+	}
+
+	resources := map[string]map[string]interface{}{}
+	for _, item := range items {
+		resources[resourceKey(kind, item)] = item
+	}
+	return resources, nil
+}
+
+// applyBundleResources creates and updates the bundled config resources on
+// the target tenant. Resources that only exist on the target are left alone.
+func applyBundleResources(ctx context.Context, m *management.Management, b *bundle) (int, error) {
+	applied := 0
+
+	for _, name := range sortedKeys(b.manifest.Resources) {
+		kind, err := findResourceKind(name)
+		if err != nil {
+			return applied, err
+		}
+
+		source, err := b.resources(kind)
+		if err != nil {
+			return applied, err
+		}
+
+		target, err := fetchResources(ctx, m, kind)
+		if err != nil {
+			return applied, fmt.Errorf("failed to read target %s: %w", kind.name, err)
+		}
+
+		for _, d := range diffResources(kind.name, source, target.resources) {
+			if d.action == diffActionDelete {
+				continue
+			}
+			if err := applyResourceDiff(ctx, m, kind, d, target.ids[d.key]); err != nil {
+				return applied, fmt.Errorf("failed to %s %s: %w", d.action, d.resource(), err)
+			}
+			fmt.Fprintf(messageOutput, "Applied %s %s.\n", d.action, d.resource())
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+func newBundleCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var bundleCmd = &cobra.Command{
+		Use:   "bundle",
+		Short: "Package a migration as a single zip file",
+	}
+
+	var (
+		tenant    string
+		input     string
+		output    string
+		resources []string
+		include   []string
+		exclude   []string
+		mappings  []string
+	)
+
+	var createCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create a bundle with the exported users, config resources and mapping files",
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonData, err := readInputFile(input)
+			if err != nil {
+				log.Fatalf("Failed to read the input file: %v", err)
+			}
+
+			records, err := decodeUsers(jsonData)
+			if err != nil {
+				log.Fatalf("Failed to parse users: %v", err)
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				log.Fatalf("Failed to create bundle: %v", err)
+			}
+			defer file.Close()
+
+			source := os.Getenv("SOURCE_DOMAIN")
+			if tenant != "source" {
+				source = tenant
+			}
+
+			writer := newBundleWriter(file, source)
+			if err := writer.addUsers(jsonData, len(records)); err != nil {
+				log.Fatalf("%v", err)
+			}
+
+			if len(resources) > 0 {
+				client, err := selectTenant(tenant, clients)
+				if err != nil {
+					log.Fatalf("Failed to select tenant: %v", err)
+				}
+				filter, err := newResourceFilter(include, exclude)
+				if err != nil {
+					log.Fatalf("Invalid filter: %v", err)
+				}
+
+				for _, name := range resources {
+					kind, err := findResourceKind(name)
+					if err != nil {
+						log.Fatalf("Invalid resource: %v", err)
+					}
+
+					set, err := fetchResources(ctx, client, kind)
+					if err != nil {
+						log.Fatalf("Failed to read %s: %v", kind.name, err)
+					}
+					included := filter.apply(kind.name, set.resources)
+					if err := writer.addResources(kind.name, included); err != nil {
+						log.Fatalf("%v", err)
+					}
+					fmt.Fprintf(messageOutput, "Added %d %s.\n", len(included), kind.name)
+				}
+			}
+
+			for _, mapping := range mappings {
+				data, err := os.ReadFile(mapping)
+				if err != nil {
+					log.Fatalf("Failed to read mapping file: %v", err)
+				}
+				if err := writer.addMapping(filepath.Base(mapping), data); err != nil {
+					log.Fatalf("%v", err)
+				}
+			}
+
+			if err := writer.close(); err != nil {
+				log.Fatalf("Failed to write bundle: %v", err)
+			}
+
+			if pii.requiresEncryption(userFields(records)) {
+				key, err := encryptionKey()
+				if err == nil {
+					err = encryptFile(output, key)
+				}
+				if err != nil {
+					os.Remove(output)
+					log.Fatalf("PII policy: %v", err)
+				}
+			}
+
+			fmt.Fprintf(messageOutput, "Bundle written to %s with %d users.\n", output, len(records))
+			reportResult("bundle create", map[string]interface{}{"file": output, "manifest": writer.manifest})
+		},
+	}
+
+	createCmd.Flags().StringVar(&tenant, "tenant", "source", "Tenant to read config resources from: source, target or a profile from the config")
+	createCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Exported users file, optionally gzipped")
+	createCmd.Flags().StringVar(&output, "output", "migration.zip", "Bundle file to write")
+	createCmd.Flags().StringSliceVar(&resources, "resources", []string{"clients", "apis", "roles", "connections"}, "Config resource types to include (empty for none)")
+	createCmd.Flags().StringSliceVar(&include, "include", nil, "Only include the resources matching these type:glob patterns, e.g. clients:app-*")
+	createCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "Leave out the resources matching these type:glob patterns, e.g. connections:legacy-*")
+	createCmd.Flags().StringSliceVar(&mappings, "mapping", nil, "Mapping files to include, e.g. a user ID mapping")
+
+	var showCmd = &cobra.Command{
+		Use:   "show <bundle>",
+		Short: "Print the manifest of a bundle after verifying its checksums",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := readBundleFile(args[0])
+			if err != nil {
+				log.Fatalf("Failed to open bundle: %v", err)
+			}
+			if b == nil {
+				log.Fatalf("%s is not a bundle", args[0])
+			}
+
+			files := append([]bundleFile{}, b.manifest.Mappings...)
+			if b.manifest.Users != nil {
+				files = append(files, *b.manifest.Users)
+			}
+			for _, file := range b.manifest.Resources {
+				files = append(files, file)
+			}
+			for _, file := range files {
+				if _, err := b.read(file); err != nil {
+					log.Fatalf("Invalid bundle: %v", err)
+				}
+			}
+
+			encoder := json.NewEncoder(resultOutput)
+			encoder.SetIndent("", "  ")
+			encoder.Encode(b.manifest)
+		},
+	}
+
+	bundleCmd.AddCommand(createCmd, showCmd)
+	return bundleCmd
+}
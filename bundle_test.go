@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	users := []byte("{\"user_id\": \"1\"}\n{\"user_id\": \"2\"}\n")
+	roles := map[string]map[string]interface{}{
+		"admin":  {"name": "admin", "description": "Administrators"},
+		"viewer": {"name": "viewer"},
+	}
+
+	var buf bytes.Buffer
+	writer := newBundleWriter(&buf, "source.eu.auth0.com")
+	if err := writer.addUsers(users, 2); err != nil {
+		t.Fatalf("Failed to add users: %v", err)
+	}
+	if err := writer.addResources("roles", roles); err != nil {
+		t.Fatalf("Failed to add roles: %v", err)
+	}
+	if err := writer.addMapping("user_ids.csv", []byte("old,new\n")); err != nil {
+		t.Fatalf("Failed to add mapping: %v", err)
+	}
+	if err := writer.close(); err != nil {
+		t.Fatalf("Failed to close bundle: %v", err)
+	}
+
+	filename := "test_bundle.zip"
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	defer os.Remove(filename)
+
+	data, err := readInputFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read users from bundle: %v", err)
+	}
+	if !bytes.Equal(data, users) {
+		t.Errorf("Expected users %q, got %q", users, data)
+	}
+
+	b, err := readBundleFile(filename)
+	if err != nil || b == nil {
+		t.Fatalf("Failed to open bundle: %v", err)
+	}
+	if b.manifest.Source != "source.eu.auth0.com" || b.manifest.Users.Count != 2 || len(b.manifest.Mappings) != 1 {
+		t.Errorf("Unexpected manifest: %+v", b.manifest)
+	}
+
+	kind, _ := findResourceKind("roles")
+	bundled, err := b.resources(kind)
+	if err != nil {
+		t.Fatalf("Failed to read roles from bundle: %v", err)
+	}
+	if !reflect.DeepEqual(bundled, roles) {
+		t.Errorf("Expected roles %v, got %v", roles, bundled)
+	}
+
+	b.manifest.Users.SHA256 = strings.Repeat("0", 64)
+	if _, err := b.users(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
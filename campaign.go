@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/auth0/go-auth0/authentication"
+	"github.com/auth0/go-auth0/authentication/database"
+	"github.com/spf13/cobra"
+)
+
+const (
+	campaignStatusSent     = "sent"
+	campaignStatusFailed   = "failed"
+	campaignStatusExcluded = "excluded"
+)
+
+type campaignRecord struct {
+	UserID    string    `json:"user_id,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// campaignState tracks the outcome per email address so an interrupted
+// campaign can be re-run without emailing the same user twice.
+type campaignState struct {
+	Users map[string]*campaignRecord `json:"users"`
+}
+
+func loadCampaignState(filename string) (*campaignState, error) {
+	state := &campaignState{Users: map[string]*campaignRecord{}}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse campaign state: %w", err)
+	}
+	if state.Users == nil {
+		state.Users = map[string]*campaignRecord{}
+	}
+
+	return state, nil
+}
+
+func (s *campaignState) save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal campaign state: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write campaign state: %w", err)
+	}
+	return nil
+}
+
+func (s *campaignState) set(email, userID, status string, err error) {
+	record := &campaignRecord{UserID: userID, Status: status, UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.Users[email] = record
+}
+
+func (s *campaignState) counts() map[string]int {
+	counts := map[string]int{}
+	for _, record := range s.Users {
+		counts[record.Status]++
+	}
+	return counts
+}
+
+// sendWindow is a daily time-of-day range in local time. A window whose end
+// is before its start wraps past midnight (e.g. 22:00-06:00).
+type sendWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+func parseSendWindow(value string) (*sendWindow, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", value)
+	}
+
+	var bounds [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", value, err)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if bounds[0] == bounds[1] {
+		return nil, fmt.Errorf("invalid window %q, start and end must differ", value)
+	}
+
+	return &sendWindow{start: bounds[0], end: bounds[1]}, nil
+}
+
+// wait returns how long to wait from now until the window is open.
+func (w *sendWindow) wait(now time.Time) time.Duration {
+	if w == nil {
+		return 0
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	if w.start < w.end {
+		if offset >= w.start && offset < w.end {
+			return 0
+		}
+		if offset < w.start {
+			return w.start - offset
+		}
+		return 24*time.Hour - offset + w.start
+	}
+
+	if offset >= w.start || offset < w.end {
+		return 0
+	}
+	return w.start - offset
+}
+
+// loadExclusionList reads one email address or user_id per line. Blank lines
+// and lines starting with # are ignored.
+func loadExclusionList(filename string) (map[string]bool, error) {
+	excluded := map[string]bool{}
+	if filename == "" {
+		return excluded, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open exclusion list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		excluded[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read exclusion list: %w", err)
+	}
+
+	return excluded, nil
+}
+
+func parseUsers(data []byte) ([]map[string]interface{}, error) {
+	records, err := decodeUsers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		users[i] = record.user
+	}
+	return users, nil
+}
+
+func getTargetAuthenticationClient(ctx context.Context) (*authentication.Authentication, error) {
+	domain := os.Getenv("DESTINATION_DOMAIN")
+	clientID := os.Getenv("DESTINATION_CLIENT_ID")
+	clientSecret := os.Getenv("DESTINATION_CLIENT_SECRET")
+
+	if domain == "" || clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("target Auth0 credentials are missing. Please check your .env file")
+	}
+
+	return authentication.New(ctx, domain, authentication.WithClientID(clientID), authentication.WithClientSecret(clientSecret))
+}
+
+// campaign sends one message per user in batches, honouring the sending
+// window, exclusion list and previously recorded state.
+type campaign struct {
+	send       func(ctx context.Context, user map[string]interface{}) error
+	batchSize  int
+	batchDelay time.Duration
+	interval   time.Duration
+	window     *sendWindow
+	excluded   map[string]bool
+	state      *campaignState
+	stateFile  string
+}
+
+func (c *campaign) run(ctx context.Context, users []map[string]interface{}) error {
+	var pending []map[string]interface{}
+
+	for _, user := range users {
+		email, _ := user["email"].(string)
+		userID, _ := user["user_id"].(string)
+		if email == "" {
+			continue
+		}
+
+		if record, ok := c.state.Users[email]; ok && record.Status == campaignStatusSent {
+			continue
+		}
+
+		if c.excluded[strings.ToLower(email)] || c.excluded[strings.ToLower(userID)] {
+			c.state.set(email, userID, campaignStatusExcluded, nil)
+			continue
+		}
+
+		pending = append(pending, user)
+	}
+
+	fmt.Fprintf(messageOutput, "%d users pending.\n", len(pending))
+
+	for start := 0; start < len(pending); start += c.batchSize {
+		end := min(start+c.batchSize, len(pending))
+		fmt.Fprintf(messageOutput, "Sending emails %d-%d/%d...\n", start+1, end, len(pending))
+
+		for _, user := range pending[start:end] {
+			// The window is checked before every send, so a large batch
+			// doesn't run past its end.
+			if wait := c.window.wait(time.Now()); wait > 0 {
+				if err := c.state.save(c.stateFile); err != nil {
+					return err
+				}
+				fmt.Fprintf(messageOutput, "Outside of sending window, waiting %s...\n", wait.Round(time.Second))
+				if err := c.pause(ctx, wait); err != nil {
+					return err
+				}
+			}
+
+			email, _ := user["email"].(string)
+			userID, _ := user["user_id"].(string)
+
+			err := c.send(ctx, user)
+			if err != nil {
+				fmt.Fprintf(messageOutput, "Failed to send email to %s: %v\n", redactPII("email", email), err)
+				c.state.set(email, userID, campaignStatusFailed, err)
+			} else {
+				c.state.set(email, userID, campaignStatusSent, nil)
+			}
+
+			if c.interval > 0 {
+				if err := c.pause(ctx, c.interval); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := c.state.save(c.stateFile); err != nil {
+			return err
+		}
+
+		if end < len(pending) && c.batchDelay > 0 {
+			if err := c.pause(ctx, c.batchDelay); err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.state.save(c.stateFile)
+}
+
+// pause waits for d, or until ctx is done. The state is saved first when
+// ctx is done, so a resumed campaign skips the users sent so far.
+func (c *campaign) pause(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		if err := c.state.save(c.stateFile); err != nil {
+			return err
+		}
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// campaignOptions holds the flags shared by every campaign subcommand.
+type campaignOptions struct {
+	input      string
+	batchSize  int
+	batchDelay time.Duration
+	rate       float64
+	window     string
+	exclude    string
+	stateFile  string
+}
+
+func (o *campaignOptions) addFlags(cmd *cobra.Command, defaultStateFile string) {
+	cmd.Flags().StringVar(&o.input, "input", "exported_users.json.gz", "Exported users file, optionally gzipped")
+	cmd.Flags().IntVar(&o.batchSize, "batch-size", 100, "Number of emails sent per batch")
+	cmd.Flags().DurationVar(&o.batchDelay, "batch-delay", time.Minute, "Delay between batches")
+	cmd.Flags().Float64Var(&o.rate, "rate", 0, "Maximum emails sent per second (0 for unlimited)")
+	cmd.Flags().StringVar(&o.window, "window", "", "Daily sending window in local time, e.g. 09:00-17:00")
+	cmd.Flags().StringVar(&o.exclude, "exclude", "", "File with emails or user IDs to skip, one per line")
+	cmd.Flags().StringVar(&o.stateFile, "state", defaultStateFile, "File tracking per-user campaign status")
+}
+
+// run loads the users and campaign state and sends to every pending user.
+func (o *campaignOptions) run(ctx context.Context, send func(ctx context.Context, user map[string]interface{}) error) {
+	if o.batchSize <= 0 {
+		log.Fatalf("Batch size must be greater than zero")
+	}
+
+	window, err := parseSendWindow(o.window)
+	if err != nil {
+		log.Fatalf("Failed to parse sending window: %v", err)
+	}
+
+	excluded, err := loadExclusionList(o.exclude)
+	if err != nil {
+		log.Fatalf("Failed to load exclusion list: %v", err)
+	}
+
+	state, err := loadCampaignState(o.stateFile)
+	if err != nil {
+		log.Fatalf("Failed to load campaign state: %v", err)
+	}
+
+	jsonData, err := readInputFile(o.input)
+	if err != nil {
+		log.Fatalf("Failed to read the input file: %v", err)
+	}
+
+	users, err := parseUsers(jsonData)
+	if err != nil {
+		log.Fatalf("Failed to parse users: %v", err)
+	}
+
+	var interval time.Duration
+	if o.rate > 0 {
+		interval = time.Duration(float64(time.Second) / o.rate)
+	}
+
+	c := &campaign{
+		send:       send,
+		batchSize:  o.batchSize,
+		batchDelay: o.batchDelay,
+		interval:   interval,
+		window:     window,
+		excluded:   excluded,
+		state:      state,
+		stateFile:  o.stateFile,
+	}
+
+	// Interrupting the campaign stops it at once, with the state saved.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := c.run(ctx, users); err != nil {
+		log.Fatalf("Campaign failed: %v", err)
+	}
+
+	counts := state.counts()
+	fmt.Fprintf(messageOutput, "Campaign finished: %d sent, %d failed, %d excluded.\n",
+		counts[campaignStatusSent], counts[campaignStatusFailed], counts[campaignStatusExcluded])
+	reportResult("campaign", map[string]interface{}{
+		"sent":     counts[campaignStatusSent],
+		"failed":   counts[campaignStatusFailed],
+		"excluded": counts[campaignStatusExcluded],
+	})
+}
+
+func newCampaignCmd(ctx context.Context) *cobra.Command {
+	var campaignCmd = &cobra.Command{
+		Use:   "campaign",
+		Short: "Run email campaigns against migrated users",
+	}
+
+	var (
+		resetOpts  campaignOptions
+		connection string
+	)
+
+	var passwordResetCmd = &cobra.Command{
+		Use:   "password-reset",
+		Short: "Send password reset emails to migrated users in the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			checkWritable("send password resets")
+			if connection == "" {
+				connection = os.Getenv("DESTINATION_CONNECTION_NAME")
+			}
+			if connection == "" {
+				log.Fatalf("A target database connection name is required (--connection)")
+			}
+
+			authClient, err := getTargetAuthenticationClient(ctx)
+			if err != nil {
+				log.Fatalf("Failed to create Auth0 target authentication client: %v", err)
+			}
+
+			resetOpts.run(ctx, func(ctx context.Context, user map[string]interface{}) error {
+				email, _ := user["email"].(string)
+				_, err := authClient.Database.ChangePassword(ctx, database.ChangePasswordRequest{
+					Email:      email,
+					Connection: connection,
+				})
+				return err
+			})
+		},
+	}
+
+	resetOpts.addFlags(passwordResetCmd, "password_reset_campaign.json")
+	passwordResetCmd.Flags().StringVar(&connection, "connection", "", "Target database connection name (default DESTINATION_CONNECTION_NAME)")
+
+	campaignCmd.AddCommand(passwordResetCmd, newNotifyCmd(ctx))
+	return campaignCmd
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSendWindowWait(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		window string
+		now    time.Duration
+		want   time.Duration
+	}{
+		{"09:00-17:00", 10 * time.Hour, 0},
+		{"09:00-17:00", 8 * time.Hour, time.Hour},
+		{"09:00-17:00", 18 * time.Hour, 15 * time.Hour},
+		{"22:00-06:00", 23 * time.Hour, 0},
+		{"22:00-06:00", 2 * time.Hour, 0},
+		{"22:00-06:00", 12 * time.Hour, 10 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		w, err := parseSendWindow(tt.window)
+		if err != nil {
+			t.Fatalf("Failed to parse window %s: %v", tt.window, err)
+		}
+
+		got := w.wait(day.Add(tt.now))
+		if got != tt.want {
+			t.Errorf("Window %s at %s: expected wait %s, got %s", tt.window, tt.now, tt.want, got)
+		}
+	}
+
+	if _, err := parseSendWindow("09:00"); err == nil {
+		t.Errorf("Expected an error for a window without an end")
+	}
+}
+
+func TestLoadExclusionList(t *testing.T) {
+	filename := "exclusions.txt"
+	content := "# staff accounts\nAdmin@Example.com\n\nauth0|123\n"
+
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write exclusion list: %v", err)
+	}
+	defer os.Remove(filename)
+
+	excluded, err := loadExclusionList(filename)
+	if err != nil {
+		t.Fatalf("Failed to load exclusion list: %v", err)
+	}
+
+	if len(excluded) != 2 {
+		t.Fatalf("Expected 2 exclusions, got %d", len(excluded))
+	}
+	if !excluded["admin@example.com"] || !excluded["auth0|123"] {
+		t.Errorf("Unexpected exclusions: %v", excluded)
+	}
+}
+
+func TestCampaignRunCancelledDuringBatchDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stateFile := filepath.Join(t.TempDir(), "campaign.json")
+	sent := 0
+	c := &campaign{
+		send: func(ctx context.Context, user map[string]interface{}) error {
+			sent++
+			cancel()
+			return nil
+		},
+		batchSize:  1,
+		batchDelay: time.Hour,
+		state:      &campaignState{Users: map[string]*campaignRecord{}},
+		stateFile:  stateFile,
+	}
+	users := []map[string]interface{}{{"email": "a@example.com"}, {"email": "b@example.com"}}
+
+	start := time.Now()
+	if err := c.run(ctx, users); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the batch delay to be cut short, took %s", elapsed)
+	}
+	if sent != 1 {
+		t.Errorf("Expected 1 email sent before the cancellation, got %d", sent)
+	}
+	state, err := loadCampaignState(stateFile)
+	if err != nil || state.Users["a@example.com"] == nil || state.Users["a@example.com"].Status != campaignStatusSent {
+		t.Errorf("Expected the sent email to be saved, got %+v %v", state, err)
+	}
+}
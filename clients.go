@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// hostRewrite replaces a host in the URLs of migrated clients, as given
+// with --rewrite-host old.example.com=new.example.com.
+type hostRewrite struct {
+	from, to string
+}
+
+func parseHostRewrites(values []string) ([]hostRewrite, error) {
+	var rewrites []hostRewrite
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		if !ok || from == "" || to == "" || strings.ContainsAny(from+to, "/:") {
+			return nil, fmt.Errorf("invalid host rewrite %q, expected old.example.com=new.example.com", value)
+		}
+		rewrites = append(rewrites, hostRewrite{from: strings.ToLower(from), to: to})
+	}
+	return rewrites, nil
+}
+
+// rewriteURL replaces the host of a URL if a rewrite matches it exactly,
+// keeping the scheme, port, path and query. URLs that don't parse, such
+// as some wildcard callbacks, are returned unchanged.
+func rewriteURL(raw string, rewrites []hostRewrite) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	for _, rewrite := range rewrites {
+		if strings.ToLower(u.Hostname()) != rewrite.from {
+			continue
+		}
+		port := u.Port()
+		u.Host = rewrite.to
+		if port != "" {
+			u.Host += ":" + port
+		}
+		return u.String()
+	}
+	return raw
+}
+
+func rewriteURLs(urls *[]string, rewrites []hostRewrite) *[]string {
+	if urls == nil {
+		return nil
+	}
+	rewritten := make([]string, len(*urls))
+	for i, raw := range *urls {
+		rewritten[i] = rewriteURL(raw, rewrites)
+	}
+	return &rewritten
+}
+
+// listClients returns the clients of a tenant by name, leaving out the
+// tenant's global client.
+func listClients(ctx context.Context, m *management.Management) (map[string]*management.Client, error) {
+	applications := map[string]*management.Client{}
+	for page := 0; ; page++ {
+		list, err := m.Client.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clients: %w", apiError(err))
+		}
+		for _, client := range list.Clients {
+			if client.GetName() == "All Applications" {
+				continue
+			}
+			applications[client.GetName()] = client
+		}
+		if !list.HasNext() {
+			return applications, nil
+		}
+	}
+}
+
+// migratedClient returns the settings of a client that migrate clients
+// copies, with the hosts of its URLs rewritten. IDs, secrets and keys are
+// left out, the target tenant creates its own.
+func migratedClient(client *management.Client, rewrites []hostRewrite) *management.Client {
+	migrated := &management.Client{
+		Name:                        client.Name,
+		Description:                 client.Description,
+		AppType:                     client.AppType,
+		LogoURI:                     client.LogoURI,
+		IsFirstParty:                client.IsFirstParty,
+		OIDCConformant:              client.OIDCConformant,
+		GrantTypes:                  client.GrantTypes,
+		Callbacks:                   rewriteURLs(client.Callbacks, rewrites),
+		AllowedLogoutURLs:           rewriteURLs(client.AllowedLogoutURLs, rewrites),
+		WebOrigins:                  rewriteURLs(client.WebOrigins, rewrites),
+		AllowedOrigins:              rewriteURLs(client.AllowedOrigins, rewrites),
+		JWTConfiguration:            client.JWTConfiguration,
+		RefreshToken:                client.RefreshToken,
+		TokenEndpointAuthMethod:     client.TokenEndpointAuthMethod,
+		CrossOriginAuth:             client.CrossOriginAuth,
+		SSODisabled:                 client.SSODisabled,
+		ClientMetadata:              client.ClientMetadata,
+		OrganizationUsage:           client.OrganizationUsage,
+		OrganizationRequireBehavior: client.OrganizationRequireBehavior,
+	}
+	if client.InitiateLoginURI != nil {
+		uri := rewriteURL(client.GetInitiateLoginURI(), rewrites)
+		migrated.InitiateLoginURI = &uri
+	}
+	return migrated
+}
+
+// clientChanged reports whether the settings migrate clients copies differ
+// between the migrated source client and the target client.
+func clientChanged(migrated, target *management.Client) bool {
+	current := migratedClient(target, nil)
+	current.Name = migrated.Name
+	a, _ := json.Marshal(migrated)
+	b, _ := json.Marshal(current)
+	return string(a) != string(b)
+}
+
+// migrateClient creates or updates a source client in the target tenant,
+// matched by name, and returns the action taken and the target client ID.
+// Nothing is changed on a dry run, so new clients have no ID then.
+func migrateClient(ctx context.Context, m *management.Management, client, existing *management.Client, name string, rewrites []hostRewrite, dryRun bool) (string, string, error) {
+	migrated := migratedClient(client, rewrites)
+	migrated.Name = &name
+
+	switch {
+	case existing == nil:
+		if dryRun {
+			return diffActionCreate, "", nil
+		}
+		if err := m.Client.Create(ctx, migrated); err != nil {
+			return "", "", fmt.Errorf("failed to create client: %w", apiError(err))
+		}
+		return diffActionCreate, migrated.GetClientID(), nil
+	case !clientChanged(migrated, existing):
+		return planSkip, existing.GetClientID(), nil
+	default:
+		if !dryRun {
+			if err := m.Client.Update(ctx, existing.GetClientID(), migrated); err != nil {
+				return "", "", fmt.Errorf("failed to update client: %w", apiError(err))
+			}
+		}
+		return diffActionUpdate, existing.GetClientID(), nil
+	}
+}
+
+func newMigrateClientsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		rewriteHosts []string
+		mappingFile  string
+		remapFile    string
+		dryRun       bool
+	)
+
+	var clientsCmd = &cobra.Command{
+		Use:   "clients",
+		Short: "Copy applications to the target tenant, rewriting the hosts of their URLs, and write a mapping of old to new client IDs",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate clients")
+				clients.guardTarget("migrate clients")
+			}
+			rewrites, err := parseHostRewrites(rewriteHosts)
+			if err != nil {
+				log.Fatalf("Invalid --rewrite-host: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			sourceClient := clients.sourceClient()
+			targetClient := clients.targetClient()
+
+			applications, err := listClients(ctx, sourceClient)
+			if err != nil {
+				log.Fatalf("Failed to read source clients: %v", err)
+			}
+			existing, err := listClients(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target clients: %v", err)
+			}
+
+			mapping := map[string]string{}
+			created, updated, failed := 0, 0, 0
+			var plan []planEntry
+			for _, name := range sortedKeys(applications) {
+				client := applications[name]
+				targetName := remap.name("clients", name)
+				action, id, err := migrateClient(ctx, targetClient, client, existing[targetName], targetName, rewrites, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate client %s: %v\n", name, err)
+					failed++
+					continue
+				}
+				plan = append(plan, planEntry{Resource: "clients/" + targetName, Action: action})
+				if id != "" {
+					mapping[client.GetClientID()] = id
+				}
+				switch action {
+				case diffActionCreate:
+					created++
+				case diffActionUpdate:
+					updated++
+				}
+				if !dryRun {
+					fmt.Fprintf(messageOutput, "Client %s: %s → %s (%s)\n", name, client.GetClientID(), id, action)
+				}
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate clients", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			if mappingFile != "" {
+				data, err := json.MarshalIndent(mapping, "", "  ")
+				if err != nil {
+					log.Fatalf("Failed to encode client ID mapping: %v", err)
+				}
+				if err := os.WriteFile(mappingFile, append(data, '\n'), 0o644); err != nil {
+					log.Fatalf("Failed to write client ID mapping: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Client ID mapping written to %s.\n", mappingFile)
+			}
+			if created > 0 {
+				fmt.Fprintln(messageOutput, "New clients have new client secrets; update the applications that use them.")
+			}
+			fmt.Fprintf(messageOutput, "Clients migrated: %d clients, %d created, %d updated, %d failed.\n", len(applications), created, updated, failed)
+			reportResult("migrate clients", map[string]interface{}{
+				"clients": len(applications), "created": created, "updated": updated, "failed": failed,
+				"mapping": mapping, "mapping_file": mappingFile,
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	clientsCmd.Flags().StringSliceVar(&rewriteHosts, "rewrite-host", nil, "Replace a host in callback, logout, web origin and login URLs, as old.example.com=new.example.com; repeatable")
+	clientsCmd.Flags().StringVar(&mappingFile, "mapping", "client_ids.json", "JSON file the mapping of source to target client IDs is written to; empty to skip")
+	clientsCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source client names to their names in the target")
+	clientsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return clientsCmd
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestRewriteURL(t *testing.T) {
+	rewrites, err := parseHostRewrites([]string{"app.old.example.com=app.new.example.com", "LOCALHOST=dev.example.com"})
+	if err != nil {
+		t.Fatalf("Failed to parse rewrites: %v", err)
+	}
+
+	tests := map[string]string{
+		"https://app.old.example.com/callback?x=1": "https://app.new.example.com/callback?x=1",
+		"https://APP.OLD.EXAMPLE.COM":              "https://app.new.example.com",
+		"http://localhost:3000/callback":           "http://dev.example.com:3000/callback",
+		"https://other.example.com/callback":       "https://other.example.com/callback",
+		"https://sub.app.old.example.com/callback": "https://sub.app.old.example.com/callback",
+		"com.example.app://callback":               "com.example.app://callback",
+	}
+	for raw, expected := range tests {
+		if got := rewriteURL(raw, rewrites); got != expected {
+			t.Errorf("rewriteURL(%q) = %q, expected %q", raw, got, expected)
+		}
+	}
+
+	for _, invalid := range []string{"old.example.com", "=new.example.com", "https://old.example.com=new.example.com"} {
+		if _, err := parseHostRewrites([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestMigrateClient(t *testing.T) {
+	var created management.Client
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/clients" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&created)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"client_id": "new-id", "name": created.GetName()})
+	}))
+
+	rewrites := []hostRewrite{{from: "old.example.com", to: "new.example.com"}}
+	source := &management.Client{
+		ClientID:          auth0.String("old-id"),
+		ClientSecret:      auth0.String("secret"),
+		Name:              auth0.String("Web App"),
+		AppType:           auth0.String("regular_web"),
+		GrantTypes:        &[]string{"authorization_code", "refresh_token"},
+		Callbacks:         &[]string{"https://old.example.com/callback"},
+		AllowedLogoutURLs: &[]string{"https://old.example.com/"},
+		RefreshToken:      &management.ClientRefreshToken{RotationType: auth0.String("rotating")},
+	}
+
+	action, id, err := migrateClient(context.Background(), m, source, nil, "Web App", rewrites, false)
+	if err != nil {
+		t.Fatalf("Failed to migrate client: %v", err)
+	}
+	if action != diffActionCreate || id != "new-id" {
+		t.Errorf("Expected the client to be created as new-id, got %s %s", action, id)
+	}
+	if created.ClientSecret != nil || created.ClientID != nil {
+		t.Errorf("Client ID and secret must not be copied")
+	}
+	if created.GetCallbacks()[0] != "https://new.example.com/callback" || created.GetAllowedLogoutURLs()[0] != "https://new.example.com/" {
+		t.Errorf("URLs not rewritten: %v %v", created.GetCallbacks(), created.GetAllowedLogoutURLs())
+	}
+	if created.GetRefreshToken().GetRotationType() != "rotating" {
+		t.Errorf("Refresh token settings not copied")
+	}
+
+	existing := migratedClient(source, rewrites)
+	existing.ClientID = auth0.String("new-id")
+	action, id, err = migrateClient(context.Background(), m, source, existing, "Web App", rewrites, false)
+	if err != nil || action != planSkip || id != "new-id" {
+		t.Errorf("Expected an up to date client to be skipped, got %s %s %v", action, id, err)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// remapIDs translates IDs of one tenant into the IDs of the same resources
+// in another tenant, through their names. IDs without a counterpart are
+// returned as missing.
+func remapIDs(ids []string, sourceNames, targetIDs map[string]string) (mapped, missing []string) {
+	for _, id := range ids {
+		if targetID, ok := targetIDs[sourceNames[id]]; ok && sourceNames[id] != "" {
+			mapped = append(mapped, targetID)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return mapped, missing
+}
+
+// listConnections returns the connections of a tenant by name.
+func listConnections(ctx context.Context, m *management.Management) (map[string]*management.Connection, error) {
+	connections := map[string]*management.Connection{}
+	for page := 0; ; page++ {
+		list, err := m.Connection.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list connections: %w", apiError(err))
+		}
+		for _, connection := range list.Connections {
+			connections[connection.GetName()] = connection
+		}
+		if !list.HasNext() {
+			return connections, nil
+		}
+	}
+}
+
+// cloneEnabledClients enables each target connection for the same clients
+// as its source connection. Client IDs differ between tenants, so they are
+// mapped through the client names, after remapping them. Connections the
+// filter excludes are left alone. It returns the names of the connections
+// that changed, or would change on a dry run.
+func cloneEnabledClients(ctx context.Context, source, target *management.Management, filter *resourceFilter, remap nameRemap, dryRun bool) ([]string, error) {
+	clientsKind, err := findResourceKind("clients")
+	if err != nil {
+		return nil, err
+	}
+	sourceClients, err := fetchResources(ctx, source, clientsKind)
+	if err != nil {
+		return nil, err
+	}
+	targetClients, err := fetchResources(ctx, target, clientsKind)
+	if err != nil {
+		return nil, err
+	}
+	sourceNames := map[string]string{}
+	for name, id := range sourceClients.ids {
+		sourceNames[id] = remap.name("clients", name)
+	}
+
+	sourceConnections, err := listConnections(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	targetConnections, err := listConnections(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, name := range sortedKeys(sourceConnections) {
+		existing, ok := targetConnections[remap.name("connections", name)]
+		if !ok || !filter.allows("connections", name) {
+			continue
+		}
+
+		enabled, missing := remapIDs(sourceConnections[name].GetEnabledClients(), sourceNames, targetClients.ids)
+		if len(missing) > 0 {
+			fmt.Fprintf(messageOutput, "Connection %s: %d enabled clients don't exist in the target.\n", name, len(missing))
+		}
+		current := slices.Clone(existing.GetEnabledClients())
+		slices.Sort(current)
+		slices.Sort(enabled)
+		if slices.Equal(current, enabled) {
+			continue
+		}
+
+		changed = append(changed, name)
+		if dryRun {
+			continue
+		}
+		if enabled == nil {
+			enabled = []string{}
+		}
+		if err := target.Connection.Update(ctx, existing.GetID(), &management.Connection{EnabledClients: &enabled}); err != nil {
+			return changed, fmt.Errorf("failed to update the clients of connection %s: %w", name, apiError(err))
+		}
+		fmt.Fprintf(messageOutput, "Enabled connection %s for %d clients.\n", name, len(enabled))
+	}
+	return changed, nil
+}
+
+// deployActions deploys the named actions on a tenant once they are built,
+// so that they can be bound to triggers.
+func deployActions(ctx context.Context, m *management.Management, p poller, names []string) error {
+	actionsKind, err := findResourceKind("actions")
+	if err != nil {
+		return err
+	}
+	actions, err := fetchResources(ctx, m, actionsKind)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		id := actions.ids[name]
+		err := p.poll(ctx, func(ctx context.Context) (bool, error) {
+			action, err := m.Action.Read(ctx, id)
+			if err != nil {
+				return false, apiError(err)
+			}
+			if action.GetStatus() == management.ActionStatusFailed {
+				return false, fmt.Errorf("the action failed to build")
+			}
+			return action.GetStatus() == management.ActionStatusBuilt, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build action %s: %w", name, err)
+		}
+		if _, err := m.Action.Deploy(ctx, id); err != nil {
+			return fmt.Errorf("failed to deploy action %s: %w", name, apiError(err))
+		}
+		fmt.Fprintf(messageOutput, "Deployed action %s.\n", name)
+	}
+	return nil
+}
+
+func newCloneCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var cloneCmd = &cobra.Command{
+		Use:   "clone",
+		Short: "Copy configuration from the source tenant to the target tenant",
+	}
+
+	var (
+		resources []string
+		include   []string
+		exclude   []string
+		remapFile string
+		dryRun    bool
+	)
+
+	var tenantCmd = &cobra.Command{
+		Use:   "tenant",
+		Short: "Create or update the source tenant's configuration resources on the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("clone tenant")
+				clients.guardTarget("overwrite tenant configuration")
+			}
+
+			filter, err := newResourceFilter(include, exclude)
+			if err != nil {
+				log.Fatalf("Invalid filter: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			// Resources are cloned in the order of resourceKinds, so that
+			// clients exist before connections are enabled for them.
+			var kinds []resourceKind
+			for _, name := range resources {
+				if _, err := findResourceKind(name); err != nil {
+					log.Fatalf("Invalid resource: %v", err)
+				}
+			}
+			for _, kind := range resourceKinds {
+				if slices.Contains(resources, kind.name) {
+					kinds = append(kinds, kind)
+				}
+			}
+
+			sourceClient, targetClient := clients.sourceClient(), clients.targetClient()
+			created, updated, failed := 0, 0, 0
+			var changedActions []string
+			var plan []planEntry
+
+			for _, kind := range kinds {
+				source, err := fetchResources(ctx, sourceClient, kind)
+				if err != nil {
+					log.Fatalf("Failed to read source %s: %v", kind.name, err)
+				}
+				target, err := fetchResources(ctx, targetClient, kind)
+				if err != nil {
+					log.Fatalf("Failed to read target %s: %v", kind.name, err)
+				}
+				source.resources = filter.apply(kind.name, source.resources)
+				target.resources = filter.apply(kind.name, target.resources)
+				if source.resources, err = remap.apply(kind, source.resources); err != nil {
+					log.Fatalf("Failed to remap %s: %v", kind.name, err)
+				}
+
+				diffs := diffResources(kind.name, source.resources, target.resources)
+				if dryRun {
+					for _, d := range diffs {
+						lintResource(kind, d)
+					}
+					plan = append(plan, resourcePlan(kind.name, source.resources, target.resources, false)...)
+					continue
+				}
+
+				for _, d := range diffs {
+					if d.action == diffActionDelete {
+						continue
+					}
+					if err := applyResourceDiff(ctx, targetClient, kind, d, target.ids[d.key]); err != nil {
+						fmt.Fprintf(messageOutput, "Failed to %s %s: %v\n", d.action, d.resource(), err)
+						failed++
+						continue
+					}
+					fmt.Fprintf(messageOutput, "Applied %s %s.\n", d.action, d.resource())
+
+					if d.action == diffActionCreate {
+						created++
+					} else {
+						updated++
+					}
+					if kind.name == "actions" {
+						changedActions = append(changedActions, d.key)
+					}
+				}
+			}
+
+			if len(changedActions) > 0 {
+				deployPoller := newPoller()
+				deployPoller.initial, deployPoller.max, deployPoller.maxWait = time.Second, 10*time.Second, 5*time.Minute
+				if err := deployActions(ctx, targetClient, deployPoller, changedActions); err != nil {
+					fmt.Fprintln(messageOutput, err)
+					failed++
+				}
+			}
+
+			var remapped []string
+			if slices.Contains(resources, "connections") {
+				if remapped, err = cloneEnabledClients(ctx, sourceClient, targetClient, filter, remap, dryRun); err != nil {
+					fmt.Fprintln(messageOutput, err)
+					failed++
+				}
+			}
+
+			if dryRun {
+				for _, name := range remapped {
+					plan = append(plan, planEntry{Resource: "connections/" + name, Action: diffActionUpdate, Reason: "enabled_clients"})
+				}
+				writePlan(messageOutput, plan)
+				reportResult("clone tenant", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Clone finished: %d created, %d updated, %d connections re-enabled for their clients, %d failed.\n",
+				created, updated, len(remapped), failed)
+			reportResult("clone tenant", map[string]interface{}{
+				"created": created, "updated": updated, "connections_remapped": len(remapped), "failed": failed,
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var all []string
+	for _, kind := range resourceKinds {
+		all = append(all, kind.name)
+	}
+	tenantCmd.Flags().StringSliceVar(&resources, "resources", all, "Resource types to clone")
+	tenantCmd.Flags().StringSliceVar(&include, "include", nil, "Only clone the resources matching these type:glob patterns, e.g. clients:app-*")
+	tenantCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "Leave the resources matching these type:glob patterns alone, e.g. connections:legacy-*")
+	tenantCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source resource names to their names in the target, per resource type")
+	tenantCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	cloneCmd.AddCommand(tenantCmd)
+	return cloneCmd
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRemapIDs(t *testing.T) {
+	sourceNames := map[string]string{"src_1": "Web App", "src_2": "Mobile App", "src_3": "Legacy App"}
+	targetIDs := map[string]string{"Web App": "tgt_1", "Mobile App": "tgt_2"}
+
+	mapped, missing := remapIDs([]string{"src_1", "src_2", "src_3", "src_unknown"}, sourceNames, targetIDs)
+	if !reflect.DeepEqual(mapped, []string{"tgt_1", "tgt_2"}) {
+		t.Errorf("Expected the target IDs of the named clients, got %v", mapped)
+	}
+	if !reflect.DeepEqual(missing, []string{"src_3", "src_unknown"}) {
+		t.Errorf("Expected the clients without a target counterpart, got %v", missing)
+	}
+}
+
+func TestCloneEnabledClients(t *testing.T) {
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/clients"):
+			w.Write([]byte(`{"clients":[{"client_id":"src_web","name":"Web App"},{"client_id":"src_api","name":"API Client"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/connections"):
+			w.Write([]byte(`{"connections":[{"id":"con_src","name":"Users","enabled_clients":["src_web","src_api"]}]}`))
+		}
+	}))
+
+	var mu sync.Mutex
+	var updated map[string]interface{}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/connections/con_tgt"):
+			json.NewDecoder(r.Body).Decode(&updated)
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/clients"):
+			w.Write([]byte(`{"clients":[{"client_id":"tgt_web","name":"Web App"},{"client_id":"tgt_api","name":"API Client"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/connections"):
+			w.Write([]byte(`{"connections":[{"id":"con_tgt","name":"Users","enabled_clients":["tgt_web"]}]}`))
+		}
+	}))
+
+	changed, err := cloneEnabledClients(context.Background(), source, target, &resourceFilter{}, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to clone enabled clients: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"Users"}) {
+		t.Errorf("Expected connection Users to change, got %v", changed)
+	}
+	if !reflect.DeepEqual(updated["enabled_clients"], []interface{}{"tgt_api", "tgt_web"}) {
+		t.Errorf("Expected the target client IDs to be enabled, got %v", updated)
+	}
+}
+
+func TestDeployActions(t *testing.T) {
+	var mu sync.Mutex
+	reads, deployed := 0, []string{}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/deploy"):
+			deployed = append(deployed, r.URL.Path)
+			w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/actions/actions"):
+			w.Write([]byte(`{"actions":[{"id":"act_1","name":"add-claims"}]}`))
+		default:
+			// Building on the first read, built on the second.
+			reads++
+			status := "building"
+			if reads > 1 {
+				status = "built"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "act_1", "name": "add-claims", "status": status})
+		}
+	}))
+
+	if err := deployActions(context.Background(), m, poller{}, []string{"add-claims"}); err != nil {
+		t.Fatalf("Failed to deploy actions: %v", err)
+	}
+	if reads != 2 || !reflect.DeepEqual(deployed, []string{"/api/v2/actions/actions/act_1/deploy"}) {
+		t.Errorf("Expected the action to be deployed once built, got %d reads and deploys %v", reads, deployed)
+	}
+}
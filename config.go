@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// maxImportFileSize is the largest users file Auth0 accepts per import job.
+const maxImportFileSize = 500000
+
+var configFile string
+
+// config is the optional YAML config file, by default ~/.auth0-tools.yaml:
+//
+//	profiles:
+//	  dev:
+//	    domain: dev.eu.auth0.com
+//	    client_id: ...
+//	    client_secret: ...
+//	    connection_id: con_...
+//	    protected: true
+//	  private:
+//	    domain: login.example.com
+//	    api_url: https://api.example.com/api/v2
+//	    audience: https://api.example.com/api/v2/
+//	chunk_size: 500000
+//	schedules:
+//	  - name: nightly-metadata-sync
+//	    cron: "0 2 * * *"
+//	    command: sync metadata
+//	hooks:
+//	  post_import: ./notify.sh
+//	plugins:
+//	  entitlements:
+//	    command: ./plugins/entitlements
+//	presets:
+//	  minimal: [user_id, email, email_verified]
+//	approvals:
+//	  pre_import:
+//	    notify: https://hooks.example.com/approvals
+//	    timeout: 24h
+//	pii:
+//	  fields:
+//	    email: [anonymize, no_log]
+//	encryption_key: ...
+//	pii_salt: ...
+//	action_secrets:
+//	  send-welcome-email:
+//	    SENDGRID_API_KEY: ...
+//	rule_configs:
+//	  LEGACY_API_KEY: ...
+//	email_provider_credentials:
+//	  api_key: ...
+type config struct {
+	Profiles  map[string]profileConfig `yaml:"profiles"`
+	ChunkSize int                      `yaml:"chunk_size"`
+	ReadOnly  bool                     `yaml:"read_only"`
+	Schedules []scheduleConfig         `yaml:"schedules"`
+	Hooks     hooksConfig              `yaml:"hooks"`
+	Plugins   map[string]pluginConfig  `yaml:"plugins"`
+	Presets   map[string][]string      `yaml:"presets"`
+	Approvals map[string]approvalGate  `yaml:"approvals"`
+	PII       piiConfig                `yaml:"pii"`
+
+	// EncryptionKey is the key of the encrypt PII policy and PIISalt keys
+	// the pseudonyms of the anonymize policy. They are usually set with
+	// AUTH0_TOOLS_ENCRYPTION_KEY and AUTH0_TOOLS_PII_SALT.
+	EncryptionKey string `yaml:"encryption_key"`
+	PIISalt       string `yaml:"pii_salt"`
+
+	// ActionSecrets holds secret values by action or hook name, and
+	// RuleConfigs rule config values, for migrate actions.
+	ActionSecrets map[string]map[string]string `yaml:"action_secrets"`
+	RuleConfigs   map[string]string            `yaml:"rule_configs"`
+
+	// EmailProviderCredentials holds the secret credentials of the email
+	// provider, such as api_key or smtp_pass, for migrate emails.
+	EmailProviderCredentials map[string]string `yaml:"email_provider_credentials"`
+}
+
+type profileConfig struct {
+	Domain       string `yaml:"domain"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	ConnectionID string `yaml:"connection_id"`
+	Protected    bool   `yaml:"protected"`
+	APIURL       string `yaml:"api_url"`
+	Audience     string `yaml:"audience"`
+}
+
+type scheduleConfig struct {
+	Name    string `yaml:"name"`
+	Cron    string `yaml:"cron"`
+	Command string `yaml:"command"`
+}
+
+func configPath() (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".auth0-tools.yaml"), nil
+}
+
+// parseConfig decodes the config file, rejecting unknown keys.
+func parseConfig(data []byte) (*config, error) {
+	cfg := &config{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func loadConfig(filename string) (*config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if err := envOverrideError(applyEnvOverrides(cfg, os.Environ())); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadOptionalConfig loads the config file if one exists. A missing default
+// config file is not an error, but an explicit --config file must exist.
+func loadOptionalConfig() (*config, error) {
+	filename, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) && configFile == "" {
+		cfg := &config{}
+		if err := envOverrideError(applyEnvOverrides(cfg, os.Environ())); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	return loadConfig(filename)
+}
+
+// sourceProfile and targetProfile are the profiles selected with --source
+// and --target.
+var sourceProfile, targetProfile string
+
+// applyProfiles makes the given profiles the source and target tenants by
+// setting the SOURCE_* and DESTINATION_* variables every command reads, so
+// they take precedence over the .env file. Empty names are ignored.
+func applyProfiles(cfg *config, source, target string) error {
+	for _, selected := range []struct{ name, prefix string }{
+		{source, "SOURCE_"},
+		{target, "DESTINATION_"},
+	} {
+		if selected.name == "" {
+			continue
+		}
+
+		profile, ok := cfg.Profiles[selected.name]
+		if !ok {
+			return fmt.Errorf("unknown profile %q, expected one of %s", selected.name, strings.Join(sortedKeys(cfg.Profiles), ", "))
+		}
+
+		os.Setenv(selected.prefix+"DOMAIN", profile.Domain)
+		os.Setenv(selected.prefix+"CLIENT_ID", profile.ClientID)
+		os.Setenv(selected.prefix+"CLIENT_SECRET", profile.ClientSecret)
+		os.Setenv(selected.prefix+"CONNECTION_ID", profile.ConnectionID)
+		os.Setenv(selected.prefix+"API_URL", profile.APIURL)
+		os.Setenv(selected.prefix+"AUDIENCE", profile.Audience)
+	}
+	return nil
+}
+
+// lint returns every problem found in the config, in a stable order.
+func (c *config) lint() []string {
+	var problems []string
+
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		profile := c.Profiles[name]
+		for _, field := range []struct{ key, value string }{
+			{"domain", profile.Domain},
+			{"client_id", profile.ClientID},
+			{"client_secret", profile.ClientSecret},
+		} {
+			if field.value == "" {
+				problems = append(problems, fmt.Sprintf("profiles.%s: missing %s", name, field.key))
+			}
+		}
+		if profile.APIURL != "" {
+			if _, err := parseAPIURL(profile.APIURL); err != nil {
+				problems = append(problems, fmt.Sprintf("profiles.%s: api_url: %v", name, err))
+			}
+		}
+	}
+
+	if c.ChunkSize < 0 || c.ChunkSize > maxImportFileSize {
+		problems = append(problems, fmt.Sprintf("chunk_size: %d is invalid, it must be between 1 and %d bytes (Auth0's import file limit)", c.ChunkSize, maxImportFileSize))
+	}
+
+	for i, schedule := range c.Schedules {
+		label := fmt.Sprintf("schedules[%d]", i)
+		if schedule.Name != "" {
+			label = fmt.Sprintf("schedules[%s]", schedule.Name)
+		} else {
+			problems = append(problems, label+": missing name")
+		}
+
+		if schedule.Command == "" {
+			problems = append(problems, label+": missing command")
+		}
+		if err := validateCron(schedule.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid cron expression %q: %v", label, schedule.Cron, err))
+		}
+	}
+
+	for _, name := range sortedKeys(c.Presets) {
+		if len(c.Presets[name]) == 0 {
+			problems = append(problems, fmt.Sprintf("presets.%s: no fields", name))
+		}
+	}
+
+	for _, phase := range sortedKeys(c.Approvals) {
+		if !slices.Contains(approvalPhases, phase) {
+			problems = append(problems, fmt.Sprintf("approvals.%s: unknown phase, expected one of %s", phase, strings.Join(approvalPhases, ", ")))
+		}
+	}
+
+	for _, field := range sortedKeys(c.PII.Fields) {
+		for _, policy := range c.PII.Fields[field] {
+			if !slices.Contains(piiPolicyNames, policy) {
+				problems = append(problems, fmt.Sprintf("pii.fields.%s: unknown policy %q, expected one of %s", field, policy, strings.Join(piiPolicyNames, ", ")))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(c.Plugins) {
+		if c.Plugins[name].Command == "" {
+			problems = append(problems, fmt.Sprintf("plugins.%s: missing command", name))
+		}
+	}
+
+	return problems
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+	names    []string
+}{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// validateCron checks a standard five-field cron expression or one of the
+// @hourly/@daily/@weekly/@monthly/@yearly shorthands.
+func validateCron(expr string) error {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return errors.New("expression is empty")
+	}
+
+	if strings.HasPrefix(expr, "@") {
+		switch expr {
+		case "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly":
+			return nil
+		}
+		return fmt.Errorf("unknown shorthand %s", expr)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	for i, field := range fields {
+		spec := cronFields[i]
+		for _, item := range strings.Split(field, ",") {
+			if err := validateCronItem(item, spec.min, spec.max, spec.names); err != nil {
+				return fmt.Errorf("%s field: %w", spec.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateCronItem(item string, min, max int, names []string) error {
+	rangePart, step, hasStep := strings.Cut(item, "/")
+	if hasStep {
+		n, err := strconv.Atoi(step)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid step %q", step)
+		}
+	}
+
+	if rangePart == "*" {
+		return nil
+	}
+
+	low, high, isRange := strings.Cut(rangePart, "-")
+	lowValue, err := cronValue(low, min, max, names)
+	if err != nil {
+		return err
+	}
+	if !isRange {
+		return nil
+	}
+
+	highValue, err := cronValue(high, min, max, names)
+	if err != nil {
+		return err
+	}
+	if highValue < lowValue {
+		return fmt.Errorf("invalid range %q", rangePart)
+	}
+	return nil
+}
+
+func cronValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(value, name) {
+			return i + min, nil
+		}
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, min, max)
+	}
+	return n, nil
+}
+
+// lintCommand is the config lint command, which runs even if the config
+// can't be loaded.
+var lintCommand *cobra.Command
+
+func newConfigCmd() *cobra.Command {
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate the config file",
+	}
+
+	var lintCmd = &cobra.Command{
+		Use:   "lint",
+		Short: "Validate the config file before running any command",
+		Run: func(cmd *cobra.Command, args []string) {
+			filename, err := configPath()
+			if err != nil {
+				fmt.Fprintln(messageOutput, err)
+				os.Exit(1)
+			}
+
+			data, err := os.ReadFile(filename)
+			if os.IsNotExist(err) && configFile == "" {
+				fmt.Fprintf(messageOutput, "%s: not found, checking %s* environment variables only\n", filename, envPrefix)
+			} else if err != nil {
+				fmt.Fprintf(messageOutput, "%s: failed to read config file: %v\n", filename, err)
+				os.Exit(1)
+			}
+
+			cfg, err := parseConfig(data)
+			if err != nil {
+				var typeErr *yaml.TypeError
+				if errors.As(err, &typeErr) {
+					for _, msg := range typeErr.Errors {
+						fmt.Fprintf(messageOutput, "%s: %s\n", filename, msg)
+					}
+				} else {
+					fmt.Fprintf(messageOutput, "%s: %v\n", filename, err)
+				}
+				os.Exit(1)
+			}
+
+			envErrs := applyEnvOverrides(cfg, os.Environ())
+			for _, err := range envErrs {
+				fmt.Fprintf(messageOutput, "environment: %v\n", err)
+			}
+
+			problems := cfg.lint()
+			for _, problem := range problems {
+				fmt.Fprintf(messageOutput, "%s: %s\n", filename, problem)
+				annotate("error", "Invalid config", filename, errors.New(problem))
+			}
+			for _, err := range envErrs {
+				problems = append(problems, err.Error())
+			}
+			reportResult("config lint", map[string]interface{}{"file": filename, "valid": len(problems) == 0, "problems": problems})
+			if len(problems) > 0 {
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(messageOutput, "%s: OK\n", filename)
+		},
+	}
+	lintCommand = lintCmd
+
+	configCmd.AddCommand(lintCmd)
+	return configCmd
+}
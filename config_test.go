@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigLint(t *testing.T) {
+	data := []byte(`
+profiles:
+  dev:
+    domain: dev.eu.auth0.com
+    client_id: abc
+    client_secret: secret
+  prod:
+    domain: prod.eu.auth0.com
+chunk_size: 900000
+schedules:
+  - name: nightly
+    cron: "0 2 * * mon-fri"
+    command: sync metadata
+  - name: broken
+    cron: "61 * * *"
+`)
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	expected := []string{
+		"profiles.prod: missing client_id",
+		"profiles.prod: missing client_secret",
+		"chunk_size: 900000 is invalid, it must be between 1 and 500000 bytes (Auth0's import file limit)",
+		"schedules[broken]: missing command",
+		`schedules[broken]: invalid cron expression "61 * * *": expected 5 fields, got 4`,
+	}
+
+	if problems := cfg.lint(); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected %q, got %q", expected, problems)
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	_, err := parseConfig([]byte("profiles:\n  dev:\n    domian: dev.eu.auth0.com\nchunksize: 1\n"))
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) || len(typeErr.Errors) != 2 {
+		t.Fatalf("Expected 2 unknown key errors, got %v", err)
+	}
+}
+
+func TestValidateCron(t *testing.T) {
+	for _, expr := range []string{"*/15 * * * *", "0 2 1,15 jan-jun 0", "@daily"} {
+		if err := validateCron(expr); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", expr, err)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "0 24 * * *", "0 0 * * 8", "*/0 * * * *", "5-1 * * * *", "@sometimes"} {
+		if err := validateCron(expr); err == nil {
+			t.Errorf("Expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestApplyProfiles(t *testing.T) {
+	for _, key := range []string{"SOURCE_DOMAIN", "SOURCE_CLIENT_ID", "SOURCE_CLIENT_SECRET", "SOURCE_CONNECTION_ID",
+		"DESTINATION_DOMAIN", "DESTINATION_CLIENT_ID", "DESTINATION_CLIENT_SECRET", "DESTINATION_CONNECTION_ID"} {
+		t.Setenv(key, "from-env")
+	}
+
+	cfg := &config{Profiles: map[string]profileConfig{
+		"dev":  {Domain: "dev.eu.auth0.com", ClientID: "dev-id", ClientSecret: "dev-secret", ConnectionID: "con_dev"},
+		"prod": {Domain: "prod.eu.auth0.com", ClientID: "prod-id", ClientSecret: "prod-secret", ConnectionID: "con_prod"},
+	}}
+
+	if err := applyProfiles(cfg, "", "prod"); err != nil {
+		t.Fatalf("Failed to apply profiles: %v", err)
+	}
+	if os.Getenv("SOURCE_DOMAIN") != "from-env" {
+		t.Errorf("Expected the source tenant to stay unchanged, got %s", os.Getenv("SOURCE_DOMAIN"))
+	}
+	if os.Getenv("DESTINATION_DOMAIN") != "prod.eu.auth0.com" || os.Getenv("DESTINATION_CONNECTION_ID") != "con_prod" {
+		t.Errorf("Expected the prod profile as target, got %s %s", os.Getenv("DESTINATION_DOMAIN"), os.Getenv("DESTINATION_CONNECTION_ID"))
+	}
+
+	if err := applyProfiles(cfg, "staging", ""); err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Errorf("Expected an unknown profile error listing the profiles, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// importRecordStats counts the properties of the users to import that
+// decide whether a connection accepts them.
+type importRecordStats struct {
+	users            int
+	withUsername     int
+	usernameTooShort int
+	usernameTooLong  int
+	withoutHash      int
+}
+
+// connectionProblem is an incompatibility between the target connection
+// and the users to import, with guidance on fixing it. Warnings don't stop
+// the import.
+type connectionProblem struct {
+	Warning  bool   `json:"warning,omitempty"`
+	Problem  string `json:"problem"`
+	Guidance string `json:"guidance"`
+}
+
+func (p connectionProblem) String() string {
+	return p.Problem + ". " + p.Guidance
+}
+
+// usernameLengths returns the username length limits of a database
+// connection, by default 1 to 15 characters.
+func usernameLengths(options *management.ConnectionOptions) (int, int) {
+	minLength, maxLength := 1, 15
+	username, _ := options.Validation["username"].(map[string]interface{})
+	if value, ok := username["min"].(float64); ok {
+		minLength = int(value)
+	}
+	if value, ok := username["max"].(float64); ok {
+		maxLength = int(value)
+	}
+	return minLength, maxLength
+}
+
+// countImportRecords counts the usernames and password hashes of the users
+// in the chunks, checking usernames against the length limits.
+func countImportRecords(chunks *userChunks, minLength, maxLength int) (importRecordStats, error) {
+	var stats importRecordStats
+	for i := 0; i < chunks.count(); i++ {
+		users, err := chunks.chunk(i)
+		if err != nil {
+			return stats, err
+		}
+		for _, user := range users {
+			stats.users++
+			if username, _ := user["username"].(string); username != "" {
+				stats.withUsername++
+				if length := utf8.RuneCountInString(username); length < minLength {
+					stats.usernameTooShort++
+				} else if length > maxLength {
+					stats.usernameTooLong++
+				}
+			}
+			_, hasHash := user["password_hash"]
+			_, hasCustomHash := user["custom_password_hash"]
+			if !hasHash && !hasCustomHash {
+				stats.withoutHash++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// connectionProblems checks the strategy and options of the target
+// connection against the users to import.
+func connectionProblems(connection *management.Connection, stats importRecordStats) []connectionProblem {
+	name := connection.GetName()
+	if connection.GetStrategy() != management.ConnectionStrategyAuth0 {
+		return []connectionProblem{{
+			Problem:  fmt.Sprintf("connection %s has strategy %s, but users can only be imported into database connections", name, connection.GetStrategy()),
+			Guidance: "Set DESTINATION_CONNECTION_ID to a database connection (strategy auth0); users of social and enterprise connections are created when they first log in",
+		}}
+	}
+	options, _ := connection.Options.(*management.ConnectionOptions)
+	if options == nil {
+		options = &management.ConnectionOptions{}
+	}
+
+	var problems []connectionProblem
+	if options.GetEnabledDatabaseCustomization() && !options.GetImportMode() {
+		problems = append(problems, connectionProblem{
+			Problem:  fmt.Sprintf("connection %s uses a custom database without import mode, so its users are stored in the external database", name),
+			Guidance: "Turn on import mode with setup import-mode --apply, or import into a database connection that stores users in Auth0",
+		})
+	}
+
+	withoutUsername := stats.users - stats.withUsername
+	switch {
+	case options.GetRequiresUsername() && withoutUsername > 0:
+		problems = append(problems, connectionProblem{
+			Problem:  fmt.Sprintf("connection %s requires a username, but %d users have none", name, withoutUsername),
+			Guidance: "Set username with a --transform, or turn off \"Requires Username\" on the connection",
+		})
+	case !options.GetRequiresUsername() && stats.withUsername > 0:
+		problems = append(problems, connectionProblem{
+			Problem:  fmt.Sprintf("connection %s doesn't use usernames, but %d users have one", name, stats.withUsername),
+			Guidance: "Turn on \"Requires Username\" on the connection, or drop username with a --transform",
+		})
+	}
+
+	if options.GetRequiresUsername() && stats.usernameTooShort+stats.usernameTooLong > 0 {
+		minLength, maxLength := usernameLengths(options)
+		problems = append(problems, connectionProblem{
+			Problem:  fmt.Sprintf("%d usernames are shorter than %d and %d longer than %d characters, the limits of connection %s", stats.usernameTooShort, minLength, stats.usernameTooLong, maxLength, name),
+			Guidance: "Widen the username length limits of the connection, or shorten the usernames with a --transform",
+		})
+	}
+
+	if options.GetImportMode() && stats.withoutHash > 0 {
+		problems = append(problems, connectionProblem{
+			Warning:  true,
+			Problem:  fmt.Sprintf("connection %s migrates users on login, but %d users are imported without a password hash", name, stats.withoutHash),
+			Guidance: "Imported users no longer go through the login script and have to reset their passwords; export with --password-hashes if the source allows it, or leave these users to the login migration",
+		})
+	}
+	return problems
+}
+
+// checkTargetConnection reads the target connection and checks it against
+// the users in the chunks. It returns the problems found, of which the ones
+// that aren't warnings must stop the import.
+func checkTargetConnection(ctx context.Context, m *management.Management, connectionID string, chunks *userChunks) ([]connectionProblem, error) {
+	connection, err := m.Connection.Read(ctx, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the target connection: %w", apiError(err))
+	}
+
+	options, _ := connection.Options.(*management.ConnectionOptions)
+	if options == nil {
+		options = &management.ConnectionOptions{}
+	}
+	minLength, maxLength := usernameLengths(options)
+	stats, err := countImportRecords(chunks, minLength, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	return connectionProblems(connection, stats), nil
+}
+
+// reportConnectionProblems prints the problems and returns an error if any
+// of them must stop the import.
+func reportConnectionProblems(problems []connectionProblem) error {
+	var errorsFound []string
+	for _, problem := range problems {
+		level := "error"
+		if problem.Warning {
+			level = "warning"
+		} else {
+			errorsFound = append(errorsFound, problem.Problem)
+		}
+		fmt.Fprintf(messageOutput, "Connection check %s: %s\n", level, problem)
+		annotate(level, "Incompatible target connection", "", fmt.Errorf("%s", problem))
+	}
+	if len(errorsFound) > 0 {
+		return fmt.Errorf("the target connection is incompatible with the users: %s", strings.Join(errorsFound, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestConnectionProblems(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	os.MkdirAll(dir, 0o700)
+	input := strings.NewReader(`{"user_id": "1", "email": "user1@example.com", "username": "a-very-long-username"}
+{"user_id": "2", "email": "user2@example.com", "username": "bob"}
+{"user_id": "3", "email": "user3@example.com", "custom_password_hash": {"algorithm": "sha256", "hash": {"value": "abc"}}}`)
+	chunks, err := splitUsers(input, 1000, nil, nil, dir)
+	if err != nil {
+		t.Fatalf("Failed to split users: %v", err)
+	}
+
+	stats, err := countImportRecords(chunks, 1, 15)
+	if err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	if stats.users != 3 || stats.withUsername != 2 || stats.usernameTooLong != 1 || stats.withoutHash != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	tests := []struct {
+		name       string
+		connection *management.Connection
+		problems   []string
+	}{
+		{
+			name:       "social connection",
+			connection: &management.Connection{Name: auth0.String("google-oauth2"), Strategy: auth0.String("google-oauth2")},
+			problems:   []string{"connection google-oauth2 has strategy google-oauth2, but users can only be imported into database connections"},
+		},
+		{
+			name:       "usernames not used",
+			connection: &management.Connection{Name: auth0.String("db"), Strategy: auth0.String("auth0"), Options: &management.ConnectionOptions{}},
+			problems:   []string{"connection db doesn't use usernames, but 2 users have one"},
+		},
+		{
+			name:       "usernames required",
+			connection: &management.Connection{Name: auth0.String("db"), Strategy: auth0.String("auth0"), Options: &management.ConnectionOptions{RequiresUsername: auth0.Bool(true)}},
+			problems: []string{
+				"connection db requires a username, but 1 users have none",
+				"0 usernames are shorter than 1 and 1 longer than 15 characters, the limits of connection db",
+			},
+		},
+		{
+			name: "custom database",
+			connection: &management.Connection{Name: auth0.String("legacy"), Strategy: auth0.String("auth0"), Options: &management.ConnectionOptions{
+				RequiresUsername:             auth0.Bool(true),
+				Validation:                   map[string]interface{}{"username": map[string]interface{}{"min": 1.0, "max": 32.0}},
+				EnabledDatabaseCustomization: auth0.Bool(true),
+			}},
+			problems: []string{
+				"connection legacy uses a custom database without import mode, so its users are stored in the external database",
+				"connection legacy requires a username, but 1 users have none",
+			},
+		},
+		{
+			name: "import mode",
+			connection: &management.Connection{Name: auth0.String("legacy"), Strategy: auth0.String("auth0"), Options: &management.ConnectionOptions{
+				RequiresUsername:             auth0.Bool(true),
+				Validation:                   map[string]interface{}{"username": map[string]interface{}{"min": 1.0, "max": 32.0}},
+				EnabledDatabaseCustomization: auth0.Bool(true),
+				ImportMode:                   auth0.Bool(true),
+			}},
+			problems: []string{
+				"connection legacy requires a username, but 1 users have none",
+				"connection legacy migrates users on login, but 2 users are imported without a password hash",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, _ := test.connection.Options.(*management.ConnectionOptions)
+			if options == nil {
+				options = &management.ConnectionOptions{}
+			}
+			minLength, maxLength := usernameLengths(options)
+			stats, _ := countImportRecords(chunks, minLength, maxLength)
+
+			problems := connectionProblems(test.connection, stats)
+			var got []string
+			for _, problem := range problems {
+				got = append(got, problem.Problem)
+			}
+			if strings.Join(got, "\n") != strings.Join(test.problems, "\n") {
+				t.Errorf("Expected problems %q, got %q", test.problems, got)
+			}
+		})
+	}
+
+	if err := reportConnectionProblems([]connectionProblem{{Warning: true, Problem: "only a warning"}}); err != nil {
+		t.Errorf("Expected warnings not to stop the import, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// loadClientIDMapping reads the mapping of source to target client IDs
+// written by migrate clients.
+func loadClientIDMapping(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ID mapping (written by migrate clients): %w", err)
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse client ID mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// mapEnabledClients maps the enabled clients of a source connection to
+// their target IDs and adds the clients already enabled on the target
+// connection, so that clients only the target has stay enabled. Source
+// clients without a target ID are returned as missing.
+func mapEnabledClients(source, target []string, mapping map[string]string) (enabled, missing []string) {
+	enabled = slices.Clone(target)
+	for _, id := range source {
+		targetID, ok := mapping[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if !slices.Contains(enabled, targetID) {
+			enabled = append(enabled, targetID)
+		}
+	}
+	slices.Sort(enabled)
+	if enabled == nil {
+		enabled = []string{}
+	}
+	return enabled, missing
+}
+
+// migratedConnection returns the settings of a connection that migrate
+// connections copies. The settings of custom database scripts can't be
+// read back, so an existing target connection keeps its own and a new one
+// has none.
+func migratedConnection(connection, existing *management.Connection) *management.Connection {
+	migrated := &management.Connection{
+		DisplayName:        connection.DisplayName,
+		Options:            connection.Options,
+		Metadata:           connection.Metadata,
+		ShowAsButton:       connection.ShowAsButton,
+		IsDomainConnection: connection.IsDomainConnection,
+	}
+	if options, ok := connection.Options.(*management.ConnectionOptions); ok {
+		copied := *options
+		copied.Configuration = nil
+		if existing != nil {
+			if current, ok := existing.Options.(*management.ConnectionOptions); ok {
+				copied.Configuration = current.Configuration
+			}
+		}
+		migrated.Options = &copied
+	}
+	return migrated
+}
+
+// connectionMigration is what migrating one connection did, or would do on
+// a dry run.
+type connectionMigration struct {
+	action         string
+	missingClients []string
+	// configuration lists the settings of the custom database scripts of a
+	// new connection, whose values have to be set by hand.
+	configuration []string
+}
+
+// migrateConnection creates or updates a source connection in the target
+// tenant under the given name, with its options and its enabled clients
+// mapped through the client ID mapping. Nothing is changed on a dry run.
+func migrateConnection(ctx context.Context, m *management.Management, connection, existing *management.Connection, name string, mapping map[string]string, dryRun bool) (connectionMigration, error) {
+	var result connectionMigration
+	if existing != nil && existing.GetStrategy() != connection.GetStrategy() {
+		return result, fmt.Errorf("the target connection has strategy %s, not %s", existing.GetStrategy(), connection.GetStrategy())
+	}
+
+	migrated := migratedConnection(connection, existing)
+	var enabled []string
+	enabled, result.missingClients = mapEnabledClients(connection.GetEnabledClients(), existing.GetEnabledClients(), mapping)
+	migrated.EnabledClients = &enabled
+
+	if existing == nil {
+		result.action = diffActionCreate
+		if options, ok := connection.Options.(*management.ConnectionOptions); ok {
+			result.configuration = sortedKeys(options.GetConfiguration())
+		}
+		if dryRun {
+			return result, nil
+		}
+		migrated.Name, migrated.Strategy = &name, connection.Strategy
+		if err := m.Connection.Create(ctx, migrated); err != nil {
+			return result, fmt.Errorf("failed to create connection: %w", apiError(err))
+		}
+		return result, nil
+	}
+
+	current := migratedConnection(existing, existing)
+	currentEnabled := slices.Clone(existing.GetEnabledClients())
+	slices.Sort(currentEnabled)
+	if currentEnabled == nil {
+		currentEnabled = []string{}
+	}
+	current.EnabledClients = &currentEnabled
+	a, _ := json.Marshal(migrated)
+	b, _ := json.Marshal(current)
+	if string(a) == string(b) {
+		result.action = planSkip
+		return result, nil
+	}
+	result.action = diffActionUpdate
+	if !dryRun {
+		if err := m.Connection.Update(ctx, existing.GetID(), migrated); err != nil {
+			return result, fmt.Errorf("failed to update connection: %w", apiError(err))
+		}
+	}
+	return result, nil
+}
+
+func newMigrateConnectionsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		clientIDsFile string
+		include       []string
+		exclude       []string
+		remapFile     string
+		dryRun        bool
+	)
+
+	var connectionsCmd = &cobra.Command{
+		Use:   "connections",
+		Short: "Copy database, social and enterprise connections with their options to the target tenant, enabling them for the migrated clients",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate connections")
+				clients.guardTarget("migrate connections")
+			}
+			filter, err := newResourceFilter(include, exclude)
+			if err != nil {
+				log.Fatalf("Invalid filter: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+			mapping, err := loadClientIDMapping(clientIDsFile)
+			if err != nil {
+				log.Fatalf("Invalid --client-ids: %v", err)
+			}
+
+			connections, err := listConnections(ctx, clients.sourceClient())
+			if err != nil {
+				log.Fatalf("Failed to read source connections: %v", err)
+			}
+			targetClient := clients.targetClient()
+			existing, err := listConnections(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target connections: %v", err)
+			}
+
+			created, updated, failed := 0, 0, 0
+			missingClients := map[string]bool{}
+			var plan []planEntry
+			for _, name := range sortedKeys(connections) {
+				if !filter.allows("connections", name) {
+					continue
+				}
+				targetName := remap.name("connections", name)
+				result, err := migrateConnection(ctx, targetClient, connections[name], existing[targetName], targetName, mapping, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate connection %s: %v\n", name, err)
+					failed++
+					continue
+				}
+				for _, id := range result.missingClients {
+					missingClients[id] = true
+				}
+				plan = append(plan, planEntry{Resource: "connections/" + targetName, Action: result.action, Reason: connections[name].GetStrategy()})
+				switch result.action {
+				case diffActionCreate:
+					created++
+				case diffActionUpdate:
+					updated++
+				}
+				if len(result.configuration) > 0 {
+					fmt.Fprintf(messageOutput, "Connection %s: set the values of the custom database settings %v in the target, they can't be copied.\n", targetName, result.configuration)
+				}
+				if !dryRun {
+					fmt.Fprintf(messageOutput, "Connection %s (%s): %s.\n", targetName, connections[name].GetStrategy(), result.action)
+				}
+			}
+
+			if len(missingClients) > 0 {
+				fmt.Fprintf(messageOutput, "%d enabled clients aren't in the client ID mapping and weren't enabled; run migrate clients first.\n", len(missingClients))
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate connections", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Connections migrated: %d created, %d updated, %d failed.\n", created, updated, failed)
+			reportResult("migrate connections", map[string]interface{}{
+				"created": created, "updated": updated, "failed": failed, "missing_clients": sortedKeys(missingClients),
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	connectionsCmd.Flags().StringVar(&clientIDsFile, "client-ids", "client_ids.json", "Client ID mapping written by migrate clients")
+	connectionsCmd.Flags().StringSliceVar(&include, "include", nil, "Only migrate the connections matching these connections:glob patterns, e.g. connections:google-*")
+	connectionsCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "Leave the connections matching these connections:glob patterns alone")
+	connectionsCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source connection names to their names in the target")
+	connectionsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return connectionsCmd
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestMapEnabledClients(t *testing.T) {
+	mapping := map[string]string{"old-web": "new-web", "old-spa": "new-spa"}
+	enabled, missing := mapEnabledClients([]string{"old-web", "old-spa", "old-gone"}, []string{"target-only", "new-web"}, mapping)
+	if !reflect.DeepEqual(enabled, []string{"new-spa", "new-web", "target-only"}) {
+		t.Errorf("Unexpected enabled clients %v", enabled)
+	}
+	if !reflect.DeepEqual(missing, []string{"old-gone"}) {
+		t.Errorf("Unexpected missing clients %v", missing)
+	}
+}
+
+func TestMigrateConnection(t *testing.T) {
+	var requests []map[string]interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		body["method"] = r.Method
+		requests = append(requests, body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "con_new"})
+	}))
+
+	source := &management.Connection{
+		Name:           auth0.String("legacy-db"),
+		Strategy:       auth0.String("auth0"),
+		EnabledClients: &[]string{"old-web"},
+		Options: &management.ConnectionOptions{
+			PasswordPolicy:               auth0.String("good"),
+			EnabledDatabaseCustomization: auth0.Bool(true),
+			CustomScripts:                &map[string]string{"login": "function login() {}"},
+			Configuration:                &map[string]string{"API_KEY": "2.0$encrypted"},
+		},
+	}
+	mapping := map[string]string{"old-web": "new-web"}
+
+	result, err := migrateConnection(context.Background(), m, source, nil, "legacy-db", mapping, false)
+	if err != nil || result.action != diffActionCreate {
+		t.Fatalf("Expected the connection to be created, got %+v (%v)", result, err)
+	}
+	if !reflect.DeepEqual(result.configuration, []string{"API_KEY"}) {
+		t.Errorf("Expected the configuration keys to be reported, got %v", result.configuration)
+	}
+	options, _ := requests[0]["options"].(map[string]interface{})
+	if requests[0]["strategy"] != "auth0" || options["passwordPolicy"] != "good" || options["customScripts"] == nil || options["configuration"] != nil {
+		t.Errorf("Unexpected create request %v", requests[0])
+	}
+	if clients, _ := requests[0]["enabled_clients"].([]interface{}); len(clients) != 1 || clients[0] != "new-web" {
+		t.Errorf("Expected the mapped client to be enabled, got %v", requests[0]["enabled_clients"])
+	}
+
+	// An existing connection keeps its configuration, which can't be read
+	// from the source.
+	existing := &management.Connection{
+		ID:             auth0.String("con_1"),
+		Name:           auth0.String("legacy-db"),
+		Strategy:       auth0.String("auth0"),
+		EnabledClients: &[]string{"new-web"},
+		Options: &management.ConnectionOptions{
+			PasswordPolicy: auth0.String("low"),
+			Configuration:  &map[string]string{"API_KEY": "2.0$target"},
+		},
+	}
+	result, err = migrateConnection(context.Background(), m, source, existing, "legacy-db", mapping, false)
+	if err != nil || result.action != diffActionUpdate {
+		t.Fatalf("Expected the connection to be updated, got %+v (%v)", result, err)
+	}
+	options, _ = requests[1]["options"].(map[string]interface{})
+	if requests[1]["method"] != http.MethodPatch || options["passwordPolicy"] != "good" || options["configuration"].(map[string]interface{})["API_KEY"] != "2.0$target" {
+		t.Errorf("Unexpected update request %v", requests[1])
+	}
+
+	existing.Options = migratedConnection(source, existing).Options
+	if result, err := migrateConnection(context.Background(), m, source, existing, "legacy-db", mapping, false); err != nil || result.action != planSkip {
+		t.Errorf("Expected an up to date connection to be skipped, got %+v (%v)", result, err)
+	}
+
+	social := &management.Connection{Name: auth0.String("legacy-db"), Strategy: auth0.String("google-oauth2")}
+	if _, err := migrateConnection(context.Background(), m, social, existing, "legacy-db", mapping, false); err == nil {
+		t.Errorf("Expected a strategy mismatch to fail")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumnTypes maps the columns of an Auth0 CSV export that are not plain
+// strings to their type. Unknown columns are kept as strings.
+var csvColumnTypes = map[string]string{
+	"email_verified": "bool",
+	"phone_verified": "bool",
+	"blocked":        "bool",
+	"logins_count":   "int",
+	"created_at":     "time",
+	"updated_at":     "time",
+	"last_login":     "time",
+	"user_metadata":  "json",
+	"app_metadata":   "json",
+	"identities":     "json",
+}
+
+// csvFlattenPrefixes are the objects whose attributes can be exported as
+// separate CSV columns with --flatten.
+var csvFlattenPrefixes = []string{"user_metadata.", "app_metadata."}
+
+// exportJobFields builds the fields of an export job: the preset fields plus
+// the flattened attributes, each exported as a column named after its path
+// joined with separator.
+func exportJobFields(fields, flatten []string, separator string) []map[string]interface{} {
+	var jobFields []map[string]interface{}
+	for _, field := range fields {
+		jobFields = append(jobFields, map[string]interface{}{"name": field})
+	}
+	for _, path := range flatten {
+		jobFields = append(jobFields, map[string]interface{}{
+			"name":      path,
+			"export_as": strings.ReplaceAll(path, ".", separator),
+		})
+	}
+	return jobFields
+}
+
+// checkFlatten validates the --flatten options of an export.
+func checkFlatten(flatten []string, separator, format string) error {
+	if len(flatten) == 0 {
+		return nil
+	}
+	if format != "csv" {
+		return fmt.Errorf("flattening only applies to the csv format")
+	}
+	if separator == "" {
+		return fmt.Errorf("the separator must not be empty")
+	}
+
+	for _, path := range flatten {
+		if flattenedPath(path) == nil {
+			return fmt.Errorf("%q is not an attribute of %s", path, strings.Join(csvFlattenPrefixes, " or "))
+		}
+	}
+	return nil
+}
+
+// flattenedPath splits a flattened column name such as user_metadata.plan
+// into its path, or returns nil for other columns.
+func flattenedPath(column string) []string {
+	for _, prefix := range csvFlattenPrefixes {
+		if strings.HasPrefix(column, prefix) && len(column) > len(prefix) {
+			return strings.Split(column, ".")
+		}
+	}
+	return nil
+}
+
+// readCSVUsers streams the users of an Auth0 CSV export as the same records
+// as the JSON formats. The header row names the fields, empty cells are left
+// out, and values are converted according to csvColumnTypes. Columns
+// flattened with the default separator, e.g. user_metadata.plan, are put
+// back into their object as strings.
+func readCSVUsers(r io.Reader, fn func(userRecord) error) error {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to parse CSV header: %w", csvError(err, 1, 0))
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+
+	for {
+		offset := reader.InputOffset()
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse CSV: %w", csvError(err, 0, offset))
+		}
+
+		line, _ := reader.FieldPos(0)
+		record := userRecord{line: line, offset: offset, user: map[string]interface{}{}}
+		var flattened []int
+		for i, value := range row {
+			if value == "" {
+				continue
+			}
+			if flattenedPath(header[i]) != nil {
+				flattened = append(flattened, i)
+				continue
+			}
+
+			typed, err := csvValue(header[i], value)
+			if err != nil {
+				if email, ok := csvField(header, row, "email"); ok {
+					record.user["email"] = email
+				}
+				return fmt.Errorf("failed to parse CSV: %w", record.validationError(fmt.Errorf("column %s: %w", header[i], err)))
+			}
+			record.user[header[i]] = typed
+		}
+		// Set after the metadata columns so their attributes aren't lost.
+		for _, i := range flattened {
+			setPath(record.user, flattenedPath(header[i]), row[i])
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// csvValue converts a cell to the type of its column.
+func csvValue(column, value string) (interface{}, error) {
+	switch csvColumnTypes[column] {
+	case "bool":
+		return strconv.ParseBool(value)
+	case "int":
+		return strconv.Atoi(value)
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	case "json":
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+	return value, nil
+}
+
+func csvField(header, row []string, column string) (string, bool) {
+	for i, name := range header {
+		if name == column && i < len(row) {
+			return row[i], true
+		}
+	}
+	return "", false
+}
+
+// csvError converts a csv.ParseError into a ValidationError.
+func csvError(err error, line int, offset int64) *ValidationError {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ValidationError{Line: parseErr.Line, Offset: offset, Err: parseErr.Err}
+	}
+	return &ValidationError{Line: line, Offset: offset, Err: err}
+}
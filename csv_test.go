@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// readCSVRecords collects the records readCSVUsers streams.
+func readCSVRecords(data []byte) ([]userRecord, error) {
+	var records []userRecord
+	err := readCSVUsers(bytes.NewReader(data), func(record userRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+func TestReadCSVUsers(t *testing.T) {
+	data := []byte(`user_id,email,email_verified,logins_count,created_at,app_metadata,nickname
+auth0|1,a@example.com,true,3,2024-01-02T03:04:05.000Z,"{""plan"":""legacy""}",
+auth0|2,b@example.com,false,,,,bee
+`)
+
+	records, err := readCSVRecords(data)
+	if err != nil {
+		t.Fatalf("Failed to decode CSV: %v", err)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"user_id":        "auth0|1",
+			"email":          "a@example.com",
+			"email_verified": true,
+			"logins_count":   3,
+			"created_at":     "2024-01-02T03:04:05Z",
+			"app_metadata":   map[string]interface{}{"plan": "legacy"},
+		},
+		{
+			"user_id":        "auth0|2",
+			"email":          "b@example.com",
+			"email_verified": false,
+			"nickname":       "bee",
+		},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d users, got %d", len(expected), len(records))
+	}
+	for i, record := range records {
+		if !reflect.DeepEqual(record.user, expected[i]) {
+			t.Errorf("Expected user %v, got %v", expected[i], record.user)
+		}
+		if record.line != i+2 {
+			t.Errorf("Expected user %d on line %d, got %d", i, i+2, record.line)
+		}
+	}
+}
+
+func TestReadCSVUsersInvalid(t *testing.T) {
+	data := []byte("user_id,email,email_verified\nauth0|1,a@example.com,true\nauth0|2,b@example.com,maybe\n")
+
+	_, err := readCSVRecords(data)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Line != 3 || validationErr.Email != "b@example.com" {
+		t.Fatalf("Expected a validation error on line 3 for b@example.com, got %v", err)
+	}
+}
+
+func TestReadCSVUsersFlattened(t *testing.T) {
+	data := []byte(`user_metadata.plan,user_id,user_metadata,app_metadata.tier
+pro,auth0|1,"{""locale"":""de""}",gold
+`)
+
+	records, err := readCSVRecords(data)
+	if err != nil {
+		t.Fatalf("Failed to decode CSV: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"user_id":       "auth0|1",
+		"user_metadata": map[string]interface{}{"locale": "de", "plan": "pro"},
+		"app_metadata":  map[string]interface{}{"tier": "gold"},
+	}
+	if len(records) != 1 || !reflect.DeepEqual(records[0].user, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+}
+
+func TestExportJobFields(t *testing.T) {
+	fields := exportJobFields([]string{"user_id"}, []string{"user_metadata.address.city"}, "_")
+	expected := []map[string]interface{}{
+		{"name": "user_id"},
+		{"name": "user_metadata.address.city", "export_as": "user_metadata_address_city"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %v, got %v", expected, fields)
+	}
+
+	if err := checkFlatten([]string{"user_metadata.plan"}, ".", "json"); err == nil {
+		t.Errorf("Expected flattening a JSON export to fail")
+	}
+	if err := checkFlatten([]string{"email"}, ".", "csv"); err == nil {
+		t.Errorf("Expected flattening a top-level field to fail")
+	}
+	if err := checkFlatten([]string{"app_metadata.tier"}, ".", "csv"); err != nil {
+		t.Errorf("Expected app_metadata.tier to be valid, got %v", err)
+	}
+}
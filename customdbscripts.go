@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// customDBScriptNames are the custom database scripts a lazy migration
+// needs, in the order they are printed.
+var customDBScriptNames = []string{"login", "get_user"}
+
+// customDBScriptData is what the custom database script templates are
+// rendered with. Credentials aren't rendered into the scripts, the scripts
+// read them from the connection's settings (configuration.*).
+type customDBScriptData struct {
+	// Domain and Realm are the source tenant and its database connection,
+	// for scripts that migrate from another Auth0 tenant.
+	Domain string
+	Realm  string
+	// APIURL is the base URL of the legacy user API, for scripts that
+	// migrate from an API.
+	APIURL string
+}
+
+// customDBScriptTemplates are the built-in templates per source: auth0
+// checks passwords against another Auth0 tenant and reads profiles with its
+// Management API, api calls a legacy user API.
+var customDBScriptTemplates = map[string]map[string]string{
+	"auth0": {
+		"login": `// Migrates users of {{.Realm}} on {{.Domain}} when they log in.
+// Connection settings: SOURCE_CLIENT_ID and SOURCE_CLIENT_SECRET of a client of
+// the source tenant allowed to use the Password grant and to read users.
+function login(email, password, callback) {
+  const axios = require('axios');
+  const domain = '{{js .Domain}}';
+  const realm = '{{js .Realm}}';
+
+  axios.post('https://' + domain + '/oauth/token', {
+    grant_type: 'http://auth0.com/oauth/grant-type/password-realm',
+    realm: realm,
+    username: email,
+    password: password,
+    client_id: configuration.SOURCE_CLIENT_ID,
+    client_secret: configuration.SOURCE_CLIENT_SECRET,
+    scope: 'openid'
+  }).then(function () {
+    return findUser(axios, domain, realm, email);
+  }).then(function (profile) {
+    if (!profile) return callback(new WrongUsernameOrPasswordError(email));
+    callback(null, profile);
+  }).catch(function (err) {
+    if (err.response && err.response.status === 403) return callback(new WrongUsernameOrPasswordError(email));
+    callback(err);
+  });
+}
+
+// findUser reads the user from the source tenant, keeping its user_id so the
+// migrated user has the same ID in both tenants.
+function findUser(axios, domain, realm, email) {
+  return axios.post('https://' + domain + '/oauth/token', {
+    grant_type: 'client_credentials',
+    client_id: configuration.SOURCE_CLIENT_ID,
+    client_secret: configuration.SOURCE_CLIENT_SECRET,
+    audience: 'https://' + domain + '/api/v2/'
+  }).then(function (token) {
+    return axios.get('https://' + domain + '/api/v2/users-by-email', {
+      params: { email: email },
+      headers: { Authorization: 'Bearer ' + token.data.access_token }
+    });
+  }).then(function (users) {
+    const user = users.data.find(function (candidate) {
+      return candidate.identities.some(function (identity) { return identity.connection === realm; });
+    });
+    if (!user) return null;
+    return {
+      user_id: user.user_id.replace(/^auth0\|/, ''),
+      email: user.email,
+      email_verified: user.email_verified,
+      username: user.username,
+      name: user.name,
+      nickname: user.nickname,
+      picture: user.picture,
+      user_metadata: user.user_metadata,
+      app_metadata: user.app_metadata
+    };
+  });
+}
+`,
+		"get_user": `// Looks up users of {{.Realm}} on {{.Domain}} that haven't been migrated yet,
+// for password resets and sign-ups.
+// Connection settings: SOURCE_CLIENT_ID and SOURCE_CLIENT_SECRET of a client of
+// the source tenant allowed to read users.
+function getByEmail(email, callback) {
+  const axios = require('axios');
+  const domain = '{{js .Domain}}';
+  const realm = '{{js .Realm}}';
+
+  axios.post('https://' + domain + '/oauth/token', {
+    grant_type: 'client_credentials',
+    client_id: configuration.SOURCE_CLIENT_ID,
+    client_secret: configuration.SOURCE_CLIENT_SECRET,
+    audience: 'https://' + domain + '/api/v2/'
+  }).then(function (token) {
+    return axios.get('https://' + domain + '/api/v2/users-by-email', {
+      params: { email: email },
+      headers: { Authorization: 'Bearer ' + token.data.access_token }
+    });
+  }).then(function (users) {
+    const user = users.data.find(function (candidate) {
+      return candidate.identities.some(function (identity) { return identity.connection === realm; });
+    });
+    if (!user) return callback(null);
+    callback(null, {
+      user_id: user.user_id.replace(/^auth0\|/, ''),
+      email: user.email,
+      email_verified: user.email_verified,
+      username: user.username,
+      name: user.name,
+      nickname: user.nickname,
+      picture: user.picture,
+      user_metadata: user.user_metadata,
+      app_metadata: user.app_metadata
+    });
+  }).catch(callback);
+}
+`,
+	},
+	"api": {
+		"login": `// Migrates users of the legacy user API {{.APIURL}} when they log in.
+// The API answers POST /login with the user's profile, or 401 for a wrong
+// password. Connection settings: API_KEY, sent as a bearer token.
+function login(email, password, callback) {
+  const axios = require('axios');
+
+  axios.post('{{js .APIURL}}/login', { email: email, password: password }, {
+    headers: { Authorization: 'Bearer ' + configuration.API_KEY }
+  }).then(function (response) {
+    callback(null, response.data);
+  }).catch(function (err) {
+    if (err.response && (err.response.status === 401 || err.response.status === 404)) {
+      return callback(new WrongUsernameOrPasswordError(email));
+    }
+    callback(err);
+  });
+}
+`,
+		"get_user": `// Looks up users of the legacy user API {{.APIURL}} that haven't been
+// migrated yet, for password resets and sign-ups. The API answers
+// GET /users?email= with the user's profile, or 404.
+// Connection settings: API_KEY, sent as a bearer token.
+function getByEmail(email, callback) {
+  const axios = require('axios');
+
+  axios.get('{{js .APIURL}}/users', {
+    params: { email: email },
+    headers: { Authorization: 'Bearer ' + configuration.API_KEY }
+  }).then(function (response) {
+    callback(null, response.data);
+  }).catch(function (err) {
+    if (err.response && err.response.status === 404) return callback(null);
+    callback(err);
+  });
+}
+`,
+	},
+}
+
+// validate checks that the data has what the templates of a source need.
+func (d customDBScriptData) validate(source string) error {
+	switch source {
+	case "auth0":
+		if d.Domain == "" || d.Realm == "" {
+			return fmt.Errorf("the source tenant's domain (--domain or SOURCE_DOMAIN) and connection name (--realm) are required")
+		}
+	case "api":
+		u, err := url.Parse(d.APIURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("an https URL of the legacy user API (--api-url) is required")
+		}
+	default:
+		return fmt.Errorf("unknown source %q, expected auth0 or api", source)
+	}
+	return nil
+}
+
+// renderCustomDBScripts renders the login and get_user scripts for a
+// source. Templates named login.js.tmpl and get_user.js.tmpl in
+// templateDir replace the built-in ones.
+func renderCustomDBScripts(source string, data customDBScriptData, templateDir string) (map[string]string, error) {
+	if err := data.validate(source); err != nil {
+		return nil, err
+	}
+	data.APIURL = strings.TrimSuffix(data.APIURL, "/")
+
+	scripts := map[string]string{}
+	for _, name := range customDBScriptNames {
+		text := customDBScriptTemplates[source][name]
+		if templateDir != "" {
+			content, err := os.ReadFile(filepath.Join(templateDir, name+".js.tmpl"))
+			if err == nil {
+				text = string(content)
+			} else if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to read template file: %w", err)
+			}
+		}
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+		}
+		var script bytes.Buffer
+		if err := tmpl.Execute(&script, data); err != nil {
+			return nil, fmt.Errorf("failed to render %s script: %w", name, err)
+		}
+		scripts[name] = script.String()
+	}
+	return scripts, nil
+}
+
+func newCustomDBScriptsCmd() *cobra.Command {
+	var (
+		source      string
+		data        customDBScriptData
+		templateDir string
+		outputDir   string
+	)
+
+	var scriptsCmd = &cobra.Command{
+		Use:   "custom-db-scripts",
+		Short: "Print the login and get_user custom database scripts for a lazy migration from another tenant or a legacy API",
+		Run: func(cmd *cobra.Command, args []string) {
+			if data.Domain == "" {
+				data.Domain = os.Getenv("SOURCE_DOMAIN")
+			}
+
+			scripts, err := renderCustomDBScripts(source, data, templateDir)
+			if err != nil {
+				log.Fatalf("Failed to generate scripts: %v", err)
+			}
+
+			if outputDir == "" {
+				for _, name := range customDBScriptNames {
+					fmt.Fprintf(resultOutput, "// %s.js\n%s\n", name, scripts[name])
+				}
+				return
+			}
+			if err := os.MkdirAll(outputDir, 0o755); err != nil {
+				log.Fatalf("Failed to create output directory: %v", err)
+			}
+			for _, name := range customDBScriptNames {
+				filename := filepath.Join(outputDir, name+".js")
+				if err := os.WriteFile(filename, []byte(scripts[name]), 0o644); err != nil {
+					log.Fatalf("Failed to write script: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Wrote %s.\n", filename)
+			}
+		},
+	}
+
+	scriptsCmd.Flags().StringVar(&source, "source", "auth0", "Where users are migrated from: auth0 (another tenant) or api (a legacy user API)")
+	scriptsCmd.Flags().StringVar(&data.Domain, "domain", "", "Domain of the source tenant (default SOURCE_DOMAIN)")
+	scriptsCmd.Flags().StringVar(&data.Realm, "realm", "Username-Password-Authentication", "Name of the source tenant's database connection")
+	scriptsCmd.Flags().StringVar(&data.APIURL, "api-url", "", "Base URL of the legacy user API, for --source api")
+	scriptsCmd.Flags().StringVar(&templateDir, "template-dir", "", "Directory with login.js.tmpl and/or get_user.js.tmpl templates replacing the built-in ones")
+	scriptsCmd.Flags().StringVar(&outputDir, "output-dir", "", "Directory the scripts are written to as login.js and get_user.js; by default they are printed")
+
+	return scriptsCmd
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderCustomDBScripts(t *testing.T) {
+	scripts, err := renderCustomDBScripts("auth0", customDBScriptData{Domain: "old.eu.auth0.com", Realm: "legacy-db"}, "")
+	if err != nil {
+		t.Fatalf("Failed to render scripts: %v", err)
+	}
+	for _, expected := range []string{"function login(email, password, callback)", "const domain = 'old.eu.auth0.com';", "const realm = 'legacy-db';", "configuration.SOURCE_CLIENT_SECRET"} {
+		if !strings.Contains(scripts["login"], expected) {
+			t.Errorf("Expected login script to contain %q:\n%s", expected, scripts["login"])
+		}
+	}
+	if !strings.Contains(scripts["get_user"], "function getByEmail(email, callback)") {
+		t.Errorf("Unexpected get_user script:\n%s", scripts["get_user"])
+	}
+
+	scripts, err = renderCustomDBScripts("api", customDBScriptData{APIURL: "https://legacy.example.com/v1/"}, "")
+	if err != nil {
+		t.Fatalf("Failed to render scripts: %v", err)
+	}
+	if !strings.Contains(scripts["login"], "axios.post('https://legacy.example.com/v1/login'") {
+		t.Errorf("Unexpected login script:\n%s", scripts["login"])
+	}
+
+	for source, data := range map[string]customDBScriptData{
+		"auth0": {Domain: "old.eu.auth0.com"},
+		"api":   {APIURL: "http://legacy.example.com"},
+		"ldap":  {},
+	} {
+		if _, err := renderCustomDBScripts(source, data, ""); err == nil {
+			t.Errorf("Expected source %s with %+v to be rejected", source, data)
+		}
+	}
+}
+
+func TestRenderCustomDBScriptsTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "login.js.tmpl"), []byte("// custom login for {{.Realm}}\n"), 0o644)
+
+	scripts, err := renderCustomDBScripts("auth0", customDBScriptData{Domain: "old.eu.auth0.com", Realm: "legacy-db"}, dir)
+	if err != nil {
+		t.Fatalf("Failed to render scripts: %v", err)
+	}
+	if scripts["login"] != "// custom login for legacy-db\n" {
+		t.Errorf("Expected the custom login template, got %q", scripts["login"])
+	}
+	if !strings.Contains(scripts["get_user"], "function getByEmail") {
+		t.Errorf("Expected the built-in get_user template, got %q", scripts["get_user"])
+	}
+
+	os.WriteFile(filepath.Join(dir, "get_user.js.tmpl"), []byte("{{.Missing}}"), 0o644)
+	if _, err := renderCustomDBScripts("auth0", customDBScriptData{Domain: "old.eu.auth0.com", Realm: "legacy-db"}, dir); err == nil {
+		t.Errorf("Expected an unknown template field to be rejected")
+	}
+}
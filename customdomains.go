@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// findCustomDomain returns the custom domain with the given name, or nil.
+func findCustomDomain(ctx context.Context, m *management.Management, domain string) (*management.CustomDomain, error) {
+	domains, err := m.CustomDomain.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list custom domains: %w", apiError(err))
+	}
+
+	for _, customDomain := range domains {
+		if strings.EqualFold(customDomain.GetDomain(), domain) {
+			return customDomain, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeDNSRecords prints the DNS records Auth0 asks for to verify the domain.
+func writeDNSRecords(w io.Writer, customDomain *management.CustomDomain) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tVALUE")
+	if customDomain.Verification != nil {
+		for _, method := range customDomain.Verification.Methods {
+			name, _ := method["domain"].(string)
+			if name == "" {
+				name = customDomain.GetDomain()
+			}
+			recordType, _ := method["name"].(string)
+			record, _ := method["record"].(string)
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.ToUpper(recordType), record)
+		}
+	}
+	tw.Flush()
+}
+
+// verifyCustomDomain asks Auth0 to check the DNS records of the domain.
+func verifyCustomDomain(ctx context.Context, m *management.Management, customDomain *management.CustomDomain) (*management.CustomDomain, error) {
+	verified, err := m.CustomDomain.Verify(ctx, customDomain.GetID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify custom domain: %w", apiError(err))
+	}
+
+	fmt.Fprintf(messageOutput, "Custom domain %s is %s.\n", customDomain.GetDomain(), verified.GetStatus())
+	return verified, nil
+}
+
+func newCustomDomainsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var customDomainsCmd = &cobra.Command{
+		Use:   "custom-domains",
+		Short: "Set up a custom domain on the target tenant",
+	}
+
+	var certificates string
+
+	var createCmd = &cobra.Command{
+		Use:   "create <domain>",
+		Short: "Create a custom domain on the target tenant and print the DNS records to add",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			checkWritable("create a custom domain")
+
+			targetClient := clients.targetClient()
+
+			customDomain, err := findCustomDomain(ctx, targetClient, args[0])
+			if err != nil {
+				log.Fatalf("Failed to read custom domains: %v", err)
+			}
+
+			if customDomain != nil {
+				fmt.Fprintf(messageOutput, "Custom domain %s already exists (%s).\n", customDomain.GetDomain(), customDomain.GetStatus())
+			} else {
+				customDomain = &management.CustomDomain{
+					Domain:             auth0.String(args[0]),
+					Type:               auth0.String(certificates),
+					VerificationMethod: auth0.String("txt"),
+				}
+				if err := targetClient.CustomDomain.Create(ctx, customDomain); err != nil {
+					log.Fatalf("Failed to create custom domain: %v", apiError(err))
+				}
+				fmt.Fprintf(messageOutput, "Custom domain %s created.\n", customDomain.GetDomain())
+			}
+
+			fmt.Fprintln(messageOutput, "Add these DNS records, then run custom-domains verify:")
+			writeDNSRecords(messageOutput, customDomain)
+			reportResult("custom-domains create", map[string]interface{}{"domain": customDomain.GetDomain(), "id": customDomain.GetID(), "status": customDomain.GetStatus(), "verification": customDomain.Verification})
+		},
+	}
+
+	createCmd.Flags().StringVar(&certificates, "certificates", "auth0_managed_certs", "Certificate provisioning: auth0_managed_certs or self_managed_certs")
+
+	var (
+		wait    bool
+		timeout time.Duration
+	)
+
+	var verifyCmd = &cobra.Command{
+		Use:   "verify <domain>",
+		Short: "Verify a custom domain on the target tenant, optionally waiting until it is ready",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			checkWritable("verify a custom domain")
+
+			targetClient := clients.targetClient()
+
+			customDomain, err := findCustomDomain(ctx, targetClient, args[0])
+			if err != nil {
+				log.Fatalf("Failed to read custom domains: %v", err)
+			}
+			if customDomain == nil {
+				log.Fatalf("Custom domain %s not found, create it first with custom-domains create", args[0])
+			}
+
+			verifyPoller := newPoller()
+			verifyPoller.initial, verifyPoller.max, verifyPoller.maxWait = 10*time.Second, time.Minute, timeout
+
+			err = verifyPoller.poll(ctx, func(ctx context.Context) (bool, error) {
+				verified, err := verifyCustomDomain(ctx, targetClient, customDomain)
+				if err != nil {
+					return false, err
+				}
+				customDomain = verified
+				return !wait || customDomain.GetStatus() == "ready", nil
+			})
+			if errors.Is(err, errPollTimeout) {
+				writeDNSRecords(messageOutput, customDomain)
+				log.Fatalf("Custom domain %s is still %s after %s, check the DNS records above", args[0], customDomain.GetStatus(), timeout)
+			}
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+
+			if customDomain.GetStatus() != "ready" {
+				fmt.Fprintln(messageOutput, "DNS records still to be verified:")
+				writeDNSRecords(messageOutput, customDomain)
+			} else if customDomain.GetType() == "self_managed_certs" && customDomain.GetCNAMEAPIKey() != "" {
+				fmt.Fprintf(messageOutput, "Configure the reverse proxy to send the cname-api-key header: %s\n", customDomain.GetCNAMEAPIKey())
+			}
+
+			reportResult("custom-domains verify", map[string]interface{}{"domain": customDomain.GetDomain(), "status": customDomain.GetStatus(), "origin_domain_name": customDomain.GetOriginDomainName()})
+		},
+	}
+
+	verifyCmd.Flags().BoolVar(&wait, "wait", false, "Keep verifying until the domain is ready")
+	verifyCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long to wait with --wait (0 to wait indefinitely)")
+
+	customDomainsCmd.AddCommand(createCmd, verifyCmd)
+	return customDomainsCmd
+}
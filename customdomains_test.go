@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCustomDomainVerification(t *testing.T) {
+	verifications := 0
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/custom-domains"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"custom_domain_id": "cd_other", "domain": "other.example.com", "status": "ready"},
+				{"custom_domain_id": "cd_1", "domain": "login.example.com", "status": "pending_verification", "verification": map[string]interface{}{
+					"methods": []map[string]interface{}{{"name": "cname", "record": "tenant-cd-1.edge.tenants.auth0.com"}},
+				}},
+			})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/custom-domains/cd_1/verify"):
+			verifications++
+			json.NewEncoder(w).Encode(map[string]interface{}{"custom_domain_id": "cd_1", "domain": "login.example.com", "status": "ready"})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	ctx := context.Background()
+	customDomain, err := findCustomDomain(ctx, m, "LOGIN.example.com")
+	if err != nil || customDomain == nil {
+		t.Fatalf("Expected to find the custom domain, got %v, %v", customDomain, err)
+	}
+
+	var buf bytes.Buffer
+	writeDNSRecords(&buf, customDomain)
+	expected := "NAME               TYPE   VALUE\nlogin.example.com  CNAME  tenant-cd-1.edge.tenants.auth0.com\n"
+	if buf.String() != expected {
+		t.Errorf("Expected DNS records:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	verified, err := verifyCustomDomain(ctx, m, customDomain)
+	if err != nil {
+		t.Fatalf("Failed to verify custom domain: %v", err)
+	}
+	if verified.GetStatus() != "ready" || verifications != 1 {
+		t.Errorf("Expected one verification making the domain ready, got %s after %d", verified.GetStatus(), verifications)
+	}
+
+	missing, err := findCustomDomain(ctx, m, "missing.example.com")
+	if err != nil || missing != nil {
+		t.Errorf("Expected no custom domain, got %v, %v", missing, err)
+	}
+}
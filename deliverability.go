@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	deliverabilityUndeliverable = "undeliverable"
+	// deliverabilityUnknown is for addresses whose domain couldn't be looked
+	// up, e.g. because of a DNS timeout. They aren't excluded.
+	deliverabilityUnknown = "unknown"
+)
+
+// lookupMX and lookupHost resolve the mail servers and addresses of a
+// domain; tests replace them.
+var (
+	lookupMX   = net.DefaultResolver.LookupMX
+	lookupHost = net.DefaultResolver.LookupHost
+)
+
+// undeliverableEmail is a row of the deliverability report.
+type undeliverableEmail struct {
+	userID string
+	email  string
+	status string
+	reason string
+}
+
+// emailSyntaxError returns why an email address can't receive mail because
+// of its syntax, or "" if it looks valid. Display names, comments and
+// domains without a dot are rejected.
+func emailSyntaxError(email string) string {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "invalid syntax"
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(strings.Trim(domain, "."), ".") || strings.HasPrefix(domain, "[") {
+		return "invalid domain"
+	}
+	return ""
+}
+
+// checkMailDomain returns why a domain can't receive mail, or "" if it
+// can. Domains without MX records receive mail on their address records,
+// and a null MX record (RFC 7505) says the domain accepts no mail. Lookup
+// failures other than the domain or its records not existing are returned
+// as errors.
+func checkMailDomain(ctx context.Context, domain string) (string, error) {
+	records, err := lookupMX(ctx, domain)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil && len(records) == 1 && records[0].Host == ".":
+		return "domain accepts no email (null MX)", nil
+	case err == nil && len(records) > 0:
+		return "", nil
+	case err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound):
+		return "", err
+	}
+
+	if _, err := lookupHost(ctx, domain); err != nil {
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "domain has no mail server", nil
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+// checkDeliverability reads an exported users file and returns the users
+// whose email address can't receive mail, along with the number of users
+// with an email address. With checkMX, the domain of every address with a
+// valid syntax is looked up once, on workers goroutines.
+func checkDeliverability(ctx context.Context, filename string, checkMX bool, workers int) ([]undeliverableEmail, int, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer input.Close()
+
+	var results []undeliverableEmail
+	byDomain := map[string][]undeliverableEmail{}
+	total := 0
+	err = readUsers(input, func(record userRecord) error {
+		email, _ := record.user["email"].(string)
+		if email == "" {
+			return nil
+		}
+		total++
+		user := undeliverableEmail{email: email}
+		user.userID, _ = record.user["user_id"].(string)
+		if reason := emailSyntaxError(email); reason != "" {
+			user.status, user.reason = deliverabilityUndeliverable, reason
+			results = append(results, user)
+			return nil
+		}
+		if checkMX {
+			_, domain, _ := strings.Cut(email, "@")
+			domain = strings.ToLower(domain)
+			byDomain[domain] = append(byDomain[domain], user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read the exported users: %w", err)
+	}
+
+	domains := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range domains {
+				status, reason := deliverabilityUndeliverable, ""
+				var err error
+				if reason, err = checkMailDomain(ctx, domain); err != nil {
+					status, reason = deliverabilityUnknown, fmt.Sprintf("lookup failed: %v", err)
+				}
+				if reason == "" {
+					continue
+				}
+				mu.Lock()
+				for _, user := range byDomain[domain] {
+					user.status, user.reason = status, reason
+					results = append(results, user)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, domain := range sortedKeys(byDomain) {
+		domains <- domain
+	}
+	close(domains)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].email < results[j].email })
+	return results, total, nil
+}
+
+func writeDeliverabilityReport(w io.Writer, results []undeliverableEmail) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user_id", "email", "status", "reason"})
+	for _, result := range results {
+		writer.Write([]string{result.userID, result.email, result.status, result.reason})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeExclusionList writes the undeliverable addresses one per line, in
+// the format campaign --exclude reads. Addresses whose domain couldn't be
+// looked up are left out.
+func writeExclusionList(w io.Writer, results []undeliverableEmail) error {
+	if _, err := fmt.Fprintln(w, "# Undeliverable addresses found by report deliverability"); err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.status != deliverabilityUndeliverable {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, result.email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
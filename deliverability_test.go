@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEmailSyntaxError(t *testing.T) {
+	for email, expected := range map[string]string{
+		"jane.doe+tag@example.com": "",
+		"jane@example":             "invalid domain",
+		"jane@@example.com":        "invalid syntax",
+		"Jane <jane@example.com>":  "invalid syntax",
+		"jane doe@example.com":     "invalid syntax",
+		"jane@[192.168.0.1]":       "invalid domain",
+		"user@sub.example.co.uk":   "",
+	} {
+		if reason := emailSyntaxError(email); reason != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, email, reason)
+		}
+	}
+}
+
+func TestCheckDeliverability(t *testing.T) {
+	notFound := &net.DNSError{Err: "no such host", IsNotFound: true}
+	var mxLookups atomic.Int32
+	lookupMX = func(ctx context.Context, domain string) ([]*net.MX, error) {
+		mxLookups.Add(1)
+		switch domain {
+		case "example.com":
+			return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil
+		case "nomail.example.org":
+			return []*net.MX{{Host: ".", Pref: 0}}, nil
+		case "timeout.example.net":
+			return nil, &net.DNSError{Err: "i/o timeout", IsTimeout: true}
+		}
+		return nil, notFound
+	}
+	lookupHost = func(ctx context.Context, domain string) ([]string, error) {
+		if domain == "a-record.example.com" {
+			return []string{"192.0.2.1"}, nil
+		}
+		return nil, notFound
+	}
+	defer func() {
+		lookupMX, lookupHost = net.DefaultResolver.LookupMX, net.DefaultResolver.LookupHost
+	}()
+
+	filename := filepath.Join(t.TempDir(), "users.json")
+	users := `{"user_id": "auth0|1", "email": "a@example.com"}
+{"user_id": "auth0|2", "email": "b@EXAMPLE.com"}
+{"user_id": "auth0|3", "email": "c@a-record.example.com"}
+{"user_id": "auth0|4", "email": "d@missing.example.com"}
+{"user_id": "auth0|5", "email": "e@nomail.example.org"}
+{"user_id": "auth0|6", "email": "f@timeout.example.net"}
+{"user_id": "auth0|7", "email": "not-an-email"}
+{"user_id": "auth0|8"}
+`
+	if err := os.WriteFile(filename, []byte(users), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, total, err := checkDeliverability(context.Background(), filename, true, 3)
+	if err != nil {
+		t.Fatalf("Failed to check deliverability: %v", err)
+	}
+	if total != 7 || mxLookups.Load() != 5 {
+		t.Errorf("Expected 7 emails and one MX lookup per domain, got %d emails and %d lookups", total, mxLookups.Load())
+	}
+
+	var report bytes.Buffer
+	writeDeliverabilityReport(&report, results)
+	expected := `user_id,email,status,reason
+auth0|4,d@missing.example.com,undeliverable,domain has no mail server
+auth0|5,e@nomail.example.org,undeliverable,domain accepts no email (null MX)
+auth0|6,f@timeout.example.net,unknown,lookup failed: lookup : i/o timeout
+auth0|7,not-an-email,undeliverable,invalid syntax
+`
+	if report.String() != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, report.String())
+	}
+
+	var exclusions bytes.Buffer
+	writeExclusionList(&exclusions, results)
+	excludeFile := filepath.Join(t.TempDir(), "undeliverable.txt")
+	os.WriteFile(excludeFile, exclusions.Bytes(), 0o644)
+	excluded, err := loadExclusionList(excludeFile)
+	if err != nil || len(excluded) != 3 || !excluded["d@missing.example.com"] || excluded["f@timeout.example.net"] {
+		t.Errorf("Expected the undeliverable addresses to be excluded, got %v, %v", excluded, err)
+	}
+
+	_, _, err = checkDeliverability(context.Background(), filename, false, 1)
+	if err != nil || mxLookups.Load() != 5 {
+		t.Errorf("Expected no lookups without --mx, got %d, %v", mxLookups.Load(), err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	diffActionCreate = "create"
+	diffActionUpdate = "update"
+	diffActionDelete = "delete"
+)
+
+// resourceDiff describes what has to change on the target for a resource to
+// match the source.
+type resourceDiff struct {
+	kind   string
+	key    string
+	action string
+	from   map[string]interface{}
+	to     map[string]interface{}
+}
+
+func (d resourceDiff) resource() string {
+	return d.kind + "/" + d.key
+}
+
+// diffResources compares the resources of one kind, source against target.
+func diffResources(kind string, source, target map[string]map[string]interface{}) []resourceDiff {
+	var diffs []resourceDiff
+
+	for _, key := range sortedKeys(source) {
+		existing, ok := target[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, resourceDiff{kind: kind, key: key, action: diffActionCreate, to: source[key]})
+		case !reflect.DeepEqual(existing, source[key]):
+			diffs = append(diffs, resourceDiff{kind: kind, key: key, action: diffActionUpdate, from: existing, to: source[key]})
+		}
+	}
+
+	for _, key := range sortedKeys(target) {
+		if _, ok := source[key]; !ok {
+			diffs = append(diffs, resourceDiff{kind: kind, key: key, action: diffActionDelete, from: target[key]})
+		}
+	}
+
+	return diffs
+}
+
+type patchDocument struct {
+	Resource string      `json:"resource"`
+	Action   string      `json:"action"`
+	Patch    interface{} `json:"patch"`
+}
+
+// writeDiffs prints the differences in the given format: text, json-patch
+// (RFC 6902) or merge-patch (RFC 7396). Patch documents turn the target
+// resource into the source resource.
+func writeDiffs(w io.Writer, diffs []resourceDiff, format string) error {
+	switch format {
+	case "text":
+		for _, d := range diffs {
+			switch d.action {
+			case diffActionCreate:
+				fmt.Fprintf(w, "+ %s (missing in target)\n", d.resource())
+			case diffActionDelete:
+				fmt.Fprintf(w, "- %s (only in target)\n", d.resource())
+			case diffActionUpdate:
+				fmt.Fprintf(w, "~ %s\n", d.resource())
+				for _, op := range jsonPatch(d.from, d.to) {
+					value, _ := json.Marshal(op.Value)
+					if op.Op == "remove" {
+						fmt.Fprintf(w, "    %s %s\n", op.Op, op.Path)
+					} else {
+						fmt.Fprintf(w, "    %s %s: %s\n", op.Op, op.Path, value)
+					}
+				}
+			}
+		}
+		if len(diffs) == 0 {
+			fmt.Fprintln(w, "No differences found.")
+		}
+		return nil
+
+	case "json-patch", "merge-patch":
+		documents := []patchDocument{}
+		for _, d := range diffs {
+			document := patchDocument{Resource: d.resource(), Action: d.action}
+			switch {
+			case d.action == diffActionDelete:
+				document.Patch = nil
+			case format == "json-patch" && d.action == diffActionCreate:
+				document.Patch = []jsonPatchOp{{Op: "add", Path: "", Value: d.to}}
+			case format == "json-patch":
+				document.Patch = jsonPatch(d.from, d.to)
+			default:
+				document.Patch = mergePatch(d.from, d.to)
+			}
+			documents = append(documents, document)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(documents)
+
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json-patch or merge-patch", format)
+	}
+}
+
+func newDiffCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var diffCmd = &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the source and target tenants",
+	}
+
+	var (
+		resources   []string
+		include     []string
+		exclude     []string
+		remapFile   string
+		format      string
+		apply       bool
+		autoApprove bool
+		prune       bool
+		dryRun      bool
+	)
+
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Compare configuration resources (clients, APIs, roles, connections) between tenants",
+		Run: func(cmd *cobra.Command, args []string) {
+			if apply && !dryRun {
+				checkWritable("apply config changes")
+				if prune {
+					clients.guardTarget("apply config changes and delete resources")
+				} else {
+					clients.guardTarget("apply config changes")
+				}
+			}
+			filter, err := newResourceFilter(include, exclude)
+			if err != nil {
+				log.Fatalf("Invalid filter: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			var diffs []resourceDiff
+			var plan []planEntry
+			kinds := map[string]resourceKind{}
+			targetIDs := map[string]string{}
+
+			for _, name := range resources {
+				kind, err := findResourceKind(name)
+				if err != nil {
+					log.Fatalf("Invalid resource: %v", err)
+				}
+
+				source, err := fetchResources(ctx, clients.sourceClient(), kind)
+				if err != nil {
+					log.Fatalf("Failed to read source %s: %v", kind.name, err)
+				}
+
+				target, err := fetchResources(ctx, clients.targetClient(), kind)
+				if err != nil {
+					log.Fatalf("Failed to read target %s: %v", kind.name, err)
+				}
+
+				source.resources = filter.apply(kind.name, source.resources)
+				target.resources = filter.apply(kind.name, target.resources)
+				if source.resources, err = remap.apply(kind, source.resources); err != nil {
+					log.Fatalf("Failed to remap %s: %v", kind.name, err)
+				}
+
+				kinds[kind.name] = kind
+				for key, id := range target.ids {
+					targetIDs[kind.name+"/"+key] = id
+				}
+				diffs = append(diffs, diffResources(kind.name, source.resources, target.resources)...)
+				plan = append(plan, resourcePlan(kind.name, source.resources, target.resources, prune)...)
+			}
+
+			if err := writeDiffs(resultOutput, diffs, format); err != nil {
+				log.Fatalf("Failed to write differences: %v", err)
+			}
+
+			if dryRun {
+				for _, d := range diffs {
+					lintResource(kinds[d.kind], d)
+				}
+				writePlan(messageOutput, plan)
+				return
+			}
+
+			if !apply {
+				return
+			}
+
+			applied, skipped, failed := 0, 0, 0
+			for _, d := range diffs {
+				if d.action == diffActionDelete && !prune {
+					skipped++
+					continue
+				}
+
+				if !autoApprove {
+					ok, err := confirm(fmt.Sprintf("Apply %s %s?", d.action, d.resource()))
+					if err != nil {
+						log.Fatalf("%v (re-run with --auto-approve to apply all changes)", err)
+					}
+					if !ok {
+						skipped++
+						continue
+					}
+				}
+
+				err := applyResourceDiff(ctx, clients.targetClient(), kinds[d.kind], d, targetIDs[d.resource()])
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to %s %s: %v\n", d.action, d.resource(), err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(messageOutput, "Applied %s %s.\n", d.action, d.resource())
+				applied++
+			}
+
+			fmt.Fprintf(messageOutput, "Apply finished: %d applied, %d skipped, %d failed.\n", applied, skipped, failed)
+			reportResult("diff config", map[string]interface{}{"applied": applied, "skipped": skipped, "failed": failed})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	configCmd.Flags().StringSliceVar(&resources, "resources", []string{"clients", "apis", "roles", "connections"}, "Resource types to compare")
+	configCmd.Flags().StringSliceVar(&include, "include", nil, "Only compare the resources matching these type:glob patterns, e.g. clients:app-*")
+	configCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "Leave out the resources matching these type:glob patterns, e.g. connections:legacy-*")
+	configCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source resource names to their names in the target, per resource type")
+	configCmd.Flags().StringVar(&format, "format", "text", "Output format: text, json-patch (RFC 6902) or merge-patch (RFC 7396)")
+	configCmd.Flags().BoolVar(&apply, "apply", false, "Apply the differences to the target tenant, asking for each change")
+	configCmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "Apply all changes without asking")
+	configCmd.Flags().BoolVar(&prune, "prune", false, "Also delete resources that only exist in the target tenant")
+	configCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the plan of what --apply would create, update, delete or skip, without applying it")
+
+	diffCmd.AddCommand(configCmd, newDiffUsersCmd(ctx, clients), newDiffRBACCmd(ctx, clients))
+	return diffCmd
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// diskHeadroom is kept free on top of a download, for the other files the
+// tool writes.
+const diskHeadroom = 10 << 20
+
+// checkDiskSpace fails if the file system of filename lacks room for a file
+// of size bytes. The space of an existing file at filename counts as free,
+// since it is replaced. Nothing is checked if the size or the free space is
+// unknown.
+func checkDiskSpace(filename string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	dir := filepath.Dir(filename)
+	free, ok := freeDiskSpace(dir)
+	if !ok {
+		return nil
+	}
+	if info, err := os.Stat(filename); err == nil {
+		free += uint64(info.Size())
+	}
+
+	if needed := uint64(size) + diskHeadroom; free < needed {
+		return fmt.Errorf("not enough disk space in %s: %s needed for %s, %s free", dir, formatSize(needed), filepath.Base(filename), formatSize(free))
+	}
+	return nil
+}
+
+// checkSpoolSpace fails if the file system of the spool directory dir lacks
+// room for the users of filename decompressed, which splitUsers writes twice:
+// to the spool file and to the chunks.
+func checkSpoolSpace(filename, dir string) error {
+	size := decompressedSize(filename)
+	if size <= 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return checkDiskSpace(filepath.Join(dir, "chunks"), 2*size)
+}
+
+// decompressedSize estimates the size of a users file once decompressed.
+// For gzip files it is read from the ISIZE trailer, which holds the size
+// modulo 4 GiB, so whole multiples of 4 GiB are added until it exceeds the
+// compressed size. Other files, including encrypted ones, count with their
+// size on disk. It returns -1 if the file can't be read.
+func decompressedSize(filename string) int64 {
+	file, err := os.Open(filename)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return -1
+	}
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(file, magic); err != nil || !bytes.Equal(magic, []byte{0x1f, 0x8b}) || info.Size() < 18 {
+		return info.Size()
+	}
+	trailer := make([]byte, 4)
+	if _, err := file.ReadAt(trailer, info.Size()-4); err != nil {
+		return info.Size()
+	}
+	size := int64(binary.LittleEndian.Uint32(trailer))
+	for size < info.Size() {
+		size += 1 << 32
+	}
+	return size
+}
+
+func formatSize(bytes uint64) string {
+	size := float64(bytes)
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if size < 1000 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1000
+	}
+	return fmt.Sprintf("%.1f TB", size)
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package main
+
+// freeDiskSpace can't determine the free space on this platform, so the disk
+// space check is skipped.
+func freeDiskSpace(dir string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := freeDiskSpace(dir); !ok {
+		t.Skip("Free disk space is not available on this platform")
+	}
+	filename := filepath.Join(dir, "exported_users.json.gz")
+
+	if err := checkDiskSpace(filename, 1024); err != nil {
+		t.Errorf("Expected room for 1KB, got %v", err)
+	}
+	err := checkDiskSpace(filename, 1<<60)
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Errorf("Expected an error for an exabyte download, got %v", err)
+	}
+	if err := checkDiskSpace(filename, -1); err != nil {
+		t.Errorf("Expected an unknown size not to be checked, got %v", err)
+	}
+
+	os.WriteFile(filename, []byte("old export"), 0644)
+	if err := checkDiskSpace(filename, 1024); err != nil {
+		t.Errorf("Expected room when replacing a file, got %v", err)
+	}
+}
+
+func TestDecompressedSize(t *testing.T) {
+	dir := t.TempDir()
+	users := bytes.Repeat([]byte(`{"email":"a@example.com"}`+"\n"), 1000)
+
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	gzWriter.Write(users)
+	gzWriter.Close()
+	gzipped := filepath.Join(dir, "exported_users.json.gz")
+	os.WriteFile(gzipped, buf.Bytes(), 0644)
+	if size := decompressedSize(gzipped); size != int64(len(users)) {
+		t.Errorf("Expected %d bytes decompressed, got %d", len(users), size)
+	}
+
+	plain := filepath.Join(dir, "users.json")
+	os.WriteFile(plain, users, 0644)
+	if size := decompressedSize(plain); size != int64(len(users)) {
+		t.Errorf("Expected the size of the plain file, got %d", size)
+	}
+
+	if size := decompressedSize(filepath.Join(dir, "missing.json")); size != -1 {
+		t.Errorf("Expected an unknown size for a missing file, got %d", size)
+	}
+
+	if _, ok := freeDiskSpace(dir); !ok {
+		t.Skip("Free disk space is not available on this platform")
+	}
+	if err := checkSpoolSpace(gzipped, filepath.Join(dir, "spool")); err != nil {
+		t.Errorf("Expected room to spool the users, got %v", err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	sizes := map[uint64]string{512: "512.0 B", 1500: "1.5 KB", 2500000000: "2.5 GB", 3e12: "3.0 TB"}
+	for bytes, expected := range sizes {
+		if got := formatSize(bytes); got != expected {
+			t.Errorf("Expected %s for %d bytes, got %s", expected, bytes, got)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// dormantUser is a row of the dormant report: a user with no login since the
+// threshold.
+type dormantUser struct {
+	userID    string
+	email     string
+	lastLogin string
+	createdAt string
+}
+
+// inactiveDays returns the number of days since the last login of a user, or
+// since its creation if it never logged in, or -1 if neither is known.
+func (u dormantUser) inactiveDays(now time.Time) int {
+	for _, value := range []string{u.lastLogin, u.createdAt} {
+		if at, err := time.Parse(time.RFC3339, value); err == nil {
+			return int(now.Sub(at) / (24 * time.Hour))
+		}
+	}
+	return -1
+}
+
+// findDormantUsers reads an exported users file and returns the users not
+// active since a time, along with the number of users in the file. If archive
+// is set, the full records of the dormant users are written to it.
+func findDormantUsers(filename string, since time.Time, archive string) ([]dormantUser, int, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer input.Close()
+
+	var writer *usersFileWriter
+	if archive != "" {
+		if writer, err = newUsersFileWriter(archive); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var dormant []dormantUser
+	total := 0
+	err = readUsers(input, func(record userRecord) error {
+		total++
+		if userActiveSince(record.user, since) {
+			return nil
+		}
+
+		user := dormantUser{}
+		user.userID, _ = record.user["user_id"].(string)
+		user.email, _ = record.user["email"].(string)
+		user.lastLogin, _ = record.user["last_login"].(string)
+		user.createdAt, _ = record.user["created_at"].(string)
+		dormant = append(dormant, user)
+
+		if writer != nil {
+			return writer.write(record.user)
+		}
+		return nil
+	})
+	if writer != nil {
+		if closeErr := writer.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read the exported users: %w", err)
+	}
+	return dormant, total, nil
+}
+
+func writeDormantReport(w io.Writer, users []dormantUser, now time.Time) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user_id", "email", "last_login", "created_at", "days_inactive"})
+	for _, user := range users {
+		days := ""
+		if n := user.inactiveDays(now); n >= 0 {
+			days = strconv.Itoa(n)
+		}
+		writer.Write([]string{user.userID, user.email, user.lastLogin, user.createdAt, days})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// deleteDormantUsers deletes archived users from a tenant, one request per
+// interval.
+func deleteDormantUsers(ctx context.Context, m *management.Management, users []dormantUser, interval time.Duration) (int, int) {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.userID
+	}
+
+	bar := newProgress("Deleting dormant users", int64(len(ids)), false)
+	defer bar.done()
+	return deleteUsers(ctx, m, ids, 1, interval, bar)
+}
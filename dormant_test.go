@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindDormantUsers(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "exported_users.json")
+	os.WriteFile(filename, []byte(`{"user_id":"auth0|1","email":"a@example.com","last_login":"2026-09-01T10:00:00.000Z","created_at":"2020-01-01T00:00:00.000Z"}
+{"user_id":"auth0|2","email":"b@example.com","last_login":"2024-01-01T10:00:00.000Z","created_at":"2020-01-01T00:00:00.000Z"}
+{"user_id":"auth0|3","created_at":"2021-01-01T00:00:00.000Z"}
+`), 0644)
+
+	now := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	archive := filepath.Join(dir, "dormant_users.json.gz")
+	users, total, err := findDormantUsers(filename, now.Add(-365*24*time.Hour), archive)
+	if err != nil {
+		t.Fatalf("Failed to find dormant users: %v", err)
+	}
+	if total != 3 || len(users) != 2 {
+		t.Fatalf("Expected 2 of 3 users to be dormant, got %d of %d", len(users), total)
+	}
+
+	data, err := readInputFile(archive)
+	if err != nil {
+		t.Fatalf("Failed to read the archive: %v", err)
+	}
+	records, err := decodeUsers(data)
+	if err != nil {
+		t.Fatalf("Failed to decode the archive: %v", err)
+	}
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.user["user_id"].(string))
+	}
+	if !reflect.DeepEqual(ids, []string{"auth0|2", "auth0|3"}) {
+		t.Errorf("Expected the dormant users in the archive, got %v", ids)
+	}
+
+	var buf bytes.Buffer
+	if err := writeDormantReport(&buf, users, now); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	expected := `user_id,email,last_login,created_at,days_inactive
+auth0|2,b@example.com,2024-01-01T10:00:00.000Z,2020-01-01T00:00:00.000Z,1017
+auth0|3,,,2021-01-01T00:00:00.000Z,2113
+`
+	if buf.String() != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestDeleteDormantUsers(t *testing.T) {
+	var deletedIDs []string
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/users/")
+		switch id {
+		case "auth0|gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"statusCode":404,"message":"The user does not exist."}`))
+		case "auth0|fail":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"statusCode":400,"message":"Bad request"}`))
+		default:
+			deletedIDs = append(deletedIDs, id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	users := []dormantUser{{userID: "auth0|1"}, {userID: "auth0|gone"}, {userID: "auth0|fail"}, {}}
+	deleted, failed := deleteDormantUsers(context.Background(), m, users, 0)
+	if deleted != 2 || failed != 2 {
+		t.Errorf("Expected 2 deleted and 2 failed users, got %d and %d", deleted, failed)
+	}
+	if !reflect.DeepEqual(deletedIDs, []string{"auth0|1"}) {
+		t.Errorf("Unexpected deleted users %v", deletedIDs)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// downloadAttempts is how often an export download is tried before giving up.
+const downloadAttempts = 5
+
+// downloadBackoff is the wait before retrying a failed download when the
+// server doesn't send Retry-After. It doubles with every attempt.
+var downloadBackoff = 2 * time.Second
+
+// downloadError is returned when the export file server answers with an
+// error status instead of the file.
+type downloadError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *downloadError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("download failed with HTTP %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("download failed with HTTP %d", e.StatusCode)
+}
+
+// expired reports whether the signed download URL is no longer valid. The
+// storage behind export jobs answers expired URLs with 403 Forbidden.
+func (e *downloadError) expired() bool {
+	if e.StatusCode == http.StatusForbidden {
+		return true
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500 && strings.Contains(strings.ToLower(e.Body), "expired")
+}
+
+func (e *downloadError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func newDownloadError(resp *http.Response) *downloadError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &downloadError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// downloadExpiryMargin is how long before its expiry a download URL is
+// replaced by a new one from the job, so that no download or retry starts
+// with a URL about to expire.
+var downloadExpiryMargin = time.Minute
+
+// downloadExpiryWarning is how close to its expiry a download URL has to be
+// for a warning.
+const downloadExpiryWarning = 10 * time.Minute
+
+// locationExpiry returns when a signed download URL expires, from its
+// X-Amz-Date and X-Amz-Expires (signature version 4) or Expires (version 2)
+// query parameters. URLs without them aren't known to expire.
+func locationExpiry(location string) (time.Time, bool) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	query := u.Query()
+
+	if signed, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date")); err == nil {
+		if seconds, err := strconv.Atoi(query.Get("X-Amz-Expires")); err == nil {
+			return signed.Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+	if epoch, err := strconv.ParseInt(query.Get("Expires"), 10, 64); err == nil {
+		return time.Unix(epoch, 0), true
+	}
+	return time.Time{}, false
+}
+
+// downloadExport downloads the file of a completed export job. Rate limited
+// and failed downloads are retried, honouring Retry-After, and an expired
+// download URL is replaced by re-reading the job. A URL that expires within
+// downloadExpiryMargin is replaced before a download is tried, and one that
+// expires within downloadExpiryWarning is warned about.
+func downloadExport(ctx context.Context, m *management.Management, jobID, location, filename string) error {
+	backoff := downloadBackoff
+	warned := ""
+
+	refresh := func() error {
+		job, err := m.Job.Read(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("failed to refresh download URL: %w", apiError(err))
+		}
+		location = job.GetLocation()
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		if expiry, ok := locationExpiry(location); ok {
+			remaining := time.Until(expiry)
+			switch {
+			case remaining < downloadExpiryMargin:
+				fmt.Fprintln(messageOutput, "Download URL is about to expire, requesting a new one...")
+				if err := refresh(); err != nil {
+					return err
+				}
+			case remaining < downloadExpiryWarning && warned != location:
+				warned = location
+				fmt.Fprintf(messageOutput, "Warning: the download URL expires in %s.\n", remaining.Round(time.Second))
+				emitEvent("download_url_expiring", map[string]interface{}{"job_id": jobID, "expires_at": expiry.UTC().Format(time.RFC3339)})
+			}
+		}
+
+		err := downloadFile(location, filename)
+
+		var dlErr *downloadError
+		if err == nil || !errors.As(err, &dlErr) || attempt == downloadAttempts {
+			return err
+		}
+
+		switch {
+		case dlErr.expired():
+			fmt.Fprintln(messageOutput, "Download URL has expired, requesting a new one...")
+			if err := refresh(); err != nil {
+				return err
+			}
+			continue
+		case !dlErr.retryable():
+			return err
+		}
+
+		wait := dlErr.RetryAfter
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		if dlErr.StatusCode == http.StatusTooManyRequests {
+			emitEvent("rate_limited", map[string]interface{}{"error": dlErr.Error()})
+		}
+		fmt.Fprintf(messageOutput, "%v, retrying in %s...\n", err, wait)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func newExportDownloadCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		tenant     string
+		output     string
+		jobTimeout time.Duration
+	)
+
+	var downloadCmd = &cobra.Command{
+		Use:   "download <job-id>",
+		Short: "Download the file of a previously started export job",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			jobID := args[0]
+
+			m, err := selectTenant(tenant, clients)
+			if err != nil {
+				log.Fatalf("Invalid tenant: %v", err)
+			}
+
+			job, err := waitForExport(ctx, m, jobID, jobTimeout)
+			if err != nil {
+				log.Fatalf("Failed to read export job: %v", err)
+			}
+			if job.GetType() != "users_export" {
+				log.Fatalf("Job %s is a %s job, not a users export", jobID, job.GetType())
+			}
+
+			if output == "" {
+				format := job.GetFormat()
+				if format == "" {
+					format = "json"
+				}
+				output = "exported_users." + format + ".gz"
+			}
+			filename := localOutput(output)
+
+			if err := downloadExport(ctx, m, jobID, job.GetLocation(), filename); err != nil {
+				log.Fatalf("Failed to download the file (export files are only kept for a limited time): %v", err)
+			}
+
+			// The fields of the job aren't known here, so any encrypt policy applies.
+			if len(pii.fieldsWith(piiEncrypt)) > 0 {
+				key, err := encryptionKey()
+				if err == nil {
+					err = encryptFile(filename, key)
+				}
+				if err != nil {
+					os.Remove(filename)
+					log.Fatalf("PII policy: %v", err)
+				}
+			}
+
+			exported, err := countFileUsers(filename)
+			if err != nil {
+				log.Fatalf("Failed to read the exported file: %v", err)
+			}
+
+			if filename != output {
+				err := uploadStorageFile(ctx, filename, output)
+				os.Remove(filename)
+				if err != nil {
+					log.Fatalf("Failed to upload the exported file: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Uploaded the export to %s.\n", output)
+			}
+
+			fmt.Fprintf(messageOutput, "Downloaded %d users from export job %s.\n", exported, jobID)
+			reportResult("export download", map[string]interface{}{"job_id": jobID, "file": output, "users": exported})
+		},
+	}
+
+	downloadCmd.Flags().StringVar(&tenant, "tenant", "source", "Tenant the export job was started in: source, target or a config profile")
+	downloadCmd.Flags().StringVar(&output, "output", "", "File or s3://, gs:// or azblob:// URL to save the export as (default exported_users.<format>.gz)")
+	downloadCmd.Flags().DurationVar(&jobTimeout, "job-timeout", time.Hour, "How long to wait if the export job is still running (0 to wait indefinitely)")
+
+	return downloadCmd
+}
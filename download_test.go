@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadExportRetriesRateLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("users"))
+	}))
+	defer server.Close()
+
+	downloadBackoff = 0
+	defer func() { downloadBackoff = 2 * time.Second }()
+
+	filename := filepath.Join(t.TempDir(), "users.json.gz")
+	if err := downloadExport(context.Background(), nil, "job_1", server.URL, filename); err != nil {
+		t.Fatalf("Expected the download to be retried, got %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "users" || requests != 2 {
+		t.Errorf("Expected the file after 2 requests, got %q after %d", data, requests)
+	}
+}
+
+func TestDownloadExportRefreshesExpiredURL(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Error(w, "Request has expired", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("users"))
+	}))
+	defer files.Close()
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "completed", "location": files.URL + "/new"})
+	}))
+
+	filename := filepath.Join(t.TempDir(), "users.json.gz")
+	if err := downloadExport(context.Background(), m, "job_1", files.URL+"/old", filename); err != nil {
+		t.Fatalf("Expected the URL to be refreshed, got %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "users" {
+		t.Errorf("Unexpected file contents %q", data)
+	}
+}
+
+func TestDownloadExportRefreshesExpiringURL(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			t.Errorf("Expected the expiring URL not to be used")
+		}
+		w.Write([]byte("users"))
+	}))
+	defer files.Close()
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "completed", "location": files.URL + "/new"})
+	}))
+
+	signed := time.Now().UTC().Add(-59*time.Minute - 30*time.Second).Format("20060102T150405Z")
+	filename := filepath.Join(t.TempDir(), "users.json.gz")
+	if err := downloadExport(context.Background(), m, "job_1", files.URL+"/old?X-Amz-Date="+signed+"&X-Amz-Expires=3600", filename); err != nil {
+		t.Fatalf("Expected the URL to be refreshed, got %v", err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "users" {
+		t.Errorf("Unexpected file contents %q", data)
+	}
+}
+
+func TestLocationExpiry(t *testing.T) {
+	expiry, ok := locationExpiry("https://bucket.s3.amazonaws.com/job.json.gz?X-Amz-Date=20240102T030405Z&X-Amz-Expires=60&X-Amz-Signature=abc")
+	if !ok || !expiry.Equal(time.Date(2024, 1, 2, 3, 5, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected signature version 4 expiry %v (%v)", expiry, ok)
+	}
+	expiry, ok = locationExpiry("https://cdn.example.com/job.json.gz?Expires=1704164765&Signature=abc")
+	if !ok || expiry.Unix() != 1704164765 {
+		t.Errorf("Unexpected signature version 2 expiry %v (%v)", expiry, ok)
+	}
+	if _, ok := locationExpiry("https://cdn.example.com/job.json.gz"); ok {
+		t.Errorf("Expected an unsigned URL not to expire")
+	}
+}
+
+func TestDownloadExportGivesUp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := downloadExport(context.Background(), nil, "job_1", server.URL, filepath.Join(t.TempDir(), "users.json.gz"))
+	var dlErr *downloadError
+	if !errors.As(err, &dlErr) || dlErr.StatusCode != http.StatusNotFound || requests != 1 {
+		t.Errorf("Expected a single failed request, got %v after %d", err, requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if wait := retryAfter("3"); wait != 3*time.Second {
+		t.Errorf("Expected 3s, got %s", wait)
+	}
+	if wait := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); wait <= 0 || wait > time.Minute {
+		t.Errorf("Expected up to a minute, got %s", wait)
+	}
+	if wait := retryAfter("soon"); wait != 0 {
+		t.Errorf("Expected no wait, got %s", wait)
+	}
+}
+
+func TestWaitForExport(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := "completed"
+		if r.URL.Path == "/api/v2/jobs/job_failed" {
+			status = "failed"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "type": "users_export", "status": status, "location": "https://example.com/users.json.gz"})
+	}))
+
+	job, err := waitForExport(context.Background(), m, "job_1", time.Minute)
+	if err != nil || job.GetLocation() != "https://example.com/users.json.gz" {
+		t.Fatalf("Expected the completed job, got %v %v", job, err)
+	}
+
+	if _, err := waitForExport(context.Background(), m, "job_failed", time.Minute); !errors.Is(err, ErrJobFailed) {
+		t.Errorf("Expected a failed job error, got %v", err)
+	}
+}
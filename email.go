@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/spf13/cobra"
+)
+
+type mailer interface {
+	Send(ctx context.Context, to, subject, body string, html bool) error
+}
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// smtpMessage builds an email message. Header values can come from user
+// data, so line breaks in them are rejected rather than letting them add
+// headers, and the subject is encoded for non-ASCII characters.
+func smtpMessage(from, to, subject, body string, html bool) ([]byte, error) {
+	for name, value := range map[string]string{"From": from, "To": to, "Subject": subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%s header contains a line break", name)
+		}
+	}
+	contentType := "text/plain"
+	if html {
+		contentType = "text/html"
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=UTF-8\r\n\r\n", contentType)
+	msg.WriteString(body)
+	return msg.Bytes(), nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string, html bool) error {
+	msg, err := smtpMessage(m.from, to, subject, body, html)
+	if err != nil {
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{to}, msg); err != nil {
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
+	}
+	return nil
+}
+
+type sendGridMailer struct {
+	endpoint string
+	apiKey   string
+	from     string
+}
+
+func (m *sendGridMailer) Send(ctx context.Context, to, subject, body string, html bool) error {
+	contentType := "text/plain"
+	if html {
+		contentType = "text/html"
+	}
+
+	payload := map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{"to": []map[string]string{{"email": to}}},
+		},
+		"from":    map[string]string{"email": m.from},
+		"subject": subject,
+		"content": []map[string]string{{"type": contentType, "value": body}},
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SendGrid request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create SendGrid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email via SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("SendGrid returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func getMailer(provider string) (mailer, error) {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("EMAIL_FROM is missing. Please check your .env file")
+	}
+
+	switch provider {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is missing. Please check your .env file")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &smtpMailer{
+			addr:     host + ":" + port,
+			host:     host,
+			username: os.Getenv("SMTP_USERNAME"),
+			password: os.Getenv("SMTP_PASSWORD"),
+			from:     from,
+		}, nil
+	case "sendgrid":
+		apiKey := os.Getenv("SENDGRID_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("SENDGRID_API_KEY is missing. Please check your .env file")
+		}
+		return &sendGridMailer{
+			endpoint: "https://api.sendgrid.com/v3/mail/send",
+			apiKey:   apiKey,
+			from:     from,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown email provider %q, expected smtp or sendgrid", provider)
+	}
+}
+
+type templateExecutor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// emailTemplate renders a subject and body for a single user. Templates are
+// executed against the exported user object, e.g. {{.email}} or
+// {{.user_metadata.first_name}}.
+type emailTemplate struct {
+	subject *texttemplate.Template
+	body    templateExecutor
+	html    bool
+}
+
+func loadEmailTemplate(subject, filename string) (*emailTemplate, error) {
+	subjectTmpl, err := texttemplate.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subject template: %w", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	tmpl := &emailTemplate{subject: subjectTmpl}
+	if strings.HasSuffix(filename, ".html") {
+		tmpl.html = true
+		tmpl.body, err = htmltemplate.New("body").Option("missingkey=error").Parse(string(content))
+	} else {
+		tmpl.body, err = texttemplate.New("body").Option("missingkey=error").Parse(string(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template file: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+func (t *emailTemplate) render(user map[string]interface{}) (string, string, error) {
+	var subject, body strings.Builder
+
+	if err := t.subject.Execute(&subject, user); err != nil {
+		return "", "", fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := t.body.Execute(&body, user); err != nil {
+		return "", "", fmt.Errorf("failed to render body: %w", err)
+	}
+
+	return subject.String(), body.String(), nil
+}
+
+func newNotifyCmd(ctx context.Context) *cobra.Command {
+	var (
+		opts     campaignOptions
+		provider string
+		subject  string
+		template string
+	)
+
+	var notifyCmd = &cobra.Command{
+		Use:   "notify",
+		Short: "Send a templated email to every migrated user via SMTP or SendGrid",
+		Run: func(cmd *cobra.Command, args []string) {
+			if template == "" {
+				log.Fatalf("An email template file is required (--template)")
+			}
+
+			tmpl, err := loadEmailTemplate(subject, template)
+			if err != nil {
+				log.Fatalf("Failed to load email template: %v", err)
+			}
+
+			m, err := getMailer(provider)
+			if err != nil {
+				log.Fatalf("Failed to configure email provider: %v", err)
+			}
+
+			opts.run(ctx, func(ctx context.Context, user map[string]interface{}) error {
+				subject, body, err := tmpl.render(user)
+				if err != nil {
+					return err
+				}
+				email, _ := user["email"].(string)
+				return m.Send(ctx, email, subject, body, tmpl.html)
+			})
+		},
+	}
+
+	opts.addFlags(notifyCmd, "notify_campaign.json")
+	notifyCmd.Flags().StringVar(&provider, "provider", "smtp", "Email provider: smtp or sendgrid")
+	notifyCmd.Flags().StringVar(&subject, "subject", "Your account has moved", "Email subject template")
+	notifyCmd.Flags().StringVar(&template, "template", "", "Email body template file (.html for HTML emails)")
+
+	return notifyCmd
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEmailTemplateRender(t *testing.T) {
+	filename := "template.html"
+	content := `<p>Hi {{.name}}, your plan {{.user_metadata.plan}} has moved.</p>`
+
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	defer os.Remove(filename)
+
+	tmpl, err := loadEmailTemplate("Welcome {{.email}}", filename)
+	if err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+
+	user := map[string]interface{}{
+		"email":         "user1@example.com",
+		"name":          "<User>",
+		"user_metadata": map[string]interface{}{"plan": "pro"},
+	}
+
+	subject, body, err := tmpl.render(user)
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+
+	if subject != "Welcome user1@example.com" {
+		t.Errorf("Unexpected subject: %s", subject)
+	}
+
+	expectedBody := "<p>Hi &lt;User&gt;, your plan pro has moved.</p>"
+	if body != expectedBody {
+		t.Errorf("Expected %s, but got %s", expectedBody, body)
+	}
+
+	if _, _, err := tmpl.render(map[string]interface{}{"email": "user2@example.com"}); err == nil {
+		t.Errorf("Expected an error for a user missing template fields")
+	}
+}
+
+func TestSendGridMailer(t *testing.T) {
+	var payload map[string]interface{}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer mockServer.Close()
+
+	m := &sendGridMailer{endpoint: mockServer.URL, apiKey: "test-key", from: "noreply@example.com"}
+
+	err := m.Send(context.Background(), "user1@example.com", "Hello", "Body", false)
+	if err != nil {
+		t.Fatalf("Failed to send email: %v", err)
+	}
+
+	if payload["subject"] != "Hello" {
+		t.Errorf("Unexpected payload: %v", payload)
+	}
+}
+
+func TestSMTPMessage(t *testing.T) {
+	msg, err := smtpMessage("noreply@example.com", "user1@example.com", "Willkommen zurück", "Body", false)
+	if err != nil {
+		t.Fatalf("Failed to build message: %v", err)
+	}
+	if !strings.Contains(string(msg), "Subject: =?utf-8?q?Willkommen_zur=C3=BCck?=\r\n") {
+		t.Errorf("Expected the subject to be encoded, got:\n%s", msg)
+	}
+
+	for _, header := range [][2]string{
+		{"user1@example.com\r\nBcc: victim@example.com", "Hello"},
+		{"user1@example.com", "Hello\nBcc: victim@example.com"},
+	} {
+		if _, err := smtpMessage("noreply@example.com", header[0], header[1], "Body", false); err == nil {
+			t.Errorf("Expected a line break in %q to be rejected", header)
+		}
+	}
+}
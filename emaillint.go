@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// emailTemplateVariables are the Liquid variables Auth0 provides to every
+// email template.
+var emailTemplateVariables = []string{
+	"application.name", "application.clientID", "application.callback_domain", "application.clientMetadata",
+	"connection.name", "request_language", "support_email", "support_url", "tenant", "friendly_name",
+	"user.email", "user.email_verified", "user.family_name", "user.given_name", "user.name",
+	"user.nickname", "user.picture", "user.app_metadata", "user.user_metadata",
+	"organization.id", "organization.name", "organization.display_name", "organization.metadata",
+	"organization.branding.logo_url", "organization.branding.colors.primary", "organization.branding.colors.page_background",
+}
+
+// emailTemplateObjects are the variables whose attributes are user-defined
+// and therefore can't be checked.
+var emailTemplateObjects = []string{"application.clientMetadata", "user.app_metadata", "user.user_metadata", "organization.metadata"}
+
+// emailTemplateExtraVariables are the variables only some templates get.
+var emailTemplateExtraVariables = map[string][]string{
+	"verify_email":         {"url"},
+	"verify_email_by_code": {"code"},
+	"reset_email":          {"url"},
+	"reset_email_by_code":  {"code"},
+	"change_password":      {"url"},
+	"password_reset":       {"url"},
+	"blocked_account":      {"url", "user.source_ip", "user.city", "user.country"},
+	"stolen_credentials":   {"url"},
+	"enrollment_email":     {"url"},
+	"mfa_oob_code":         {"code"},
+	"user_invitation":      {"url", "inviter.name"},
+}
+
+var (
+	// liquidMarkup matches an output ({{ expression }}) or a tag
+	// ({% name arguments %}).
+	liquidMarkup    = regexp.MustCompile(`(?s){{-?(.*?)-?}}|{%-?\s*(\w+)(.*?)-?%}`)
+	liquidString    = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	liquidIndex     = regexp.MustCompile(`\[[^\]]*\]`)
+	liquidReference = regexp.MustCompile(`[A-Za-z_][\w.]*`)
+	liquidLocal     = regexp.MustCompile(`^\s*(\w+)`)
+)
+
+// liquidKeywords are the words of Liquid expressions that aren't variables.
+var liquidKeywords = []string{"and", "or", "contains", "in", "true", "false", "nil", "null", "blank", "empty", "forloop", "with", "as", "limit", "offset", "reversed"}
+
+// liquidReferences returns the variables an expression refers to, without
+// string literals, keywords and index expressions.
+func liquidReferences(expression string) []string {
+	var references []string
+	expression = liquidString.ReplaceAllString(expression, "")
+	expression = liquidIndex.ReplaceAllString(expression, "")
+	for _, reference := range liquidReference.FindAllString(expression, -1) {
+		reference = strings.TrimSuffix(reference, ".")
+		if !slices.Contains(liquidKeywords, strings.SplitN(reference, ".", 2)[0]) {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// knownEmailVariable reports whether Auth0 renders a variable in a template.
+func knownEmailVariable(template, variable string, locals []string) bool {
+	root := strings.SplitN(variable, ".", 2)[0]
+	if slices.Contains(locals, root) {
+		return true
+	}
+	for _, object := range emailTemplateObjects {
+		if strings.HasPrefix(variable, object+".") {
+			return true
+		}
+	}
+	return slices.Contains(emailTemplateVariables, variable) || slices.Contains(emailTemplateExtraVariables[template], variable)
+}
+
+// lintEmailTemplate checks the Liquid variables of an email template against
+// the ones Auth0 provides for it, and returns a warning for each variable
+// that won't render. Variables defined by the template itself (assign,
+// capture and for) are accepted.
+func lintEmailTemplate(resource map[string]interface{}) []string {
+	template, _ := resource["template"].(string)
+
+	var warnings []string
+	for _, field := range []string{"subject", "from", "resultUrl", "body"} {
+		text, _ := resource[field].(string)
+
+		var locals, references []string
+		for _, markup := range liquidMarkup.FindAllStringSubmatch(text, -1) {
+			output, tag, arguments := markup[1], markup[2], markup[3]
+			switch tag {
+			case "":
+				references = append(references, liquidReferences(strings.SplitN(output, "|", 2)[0])...)
+			case "assign", "capture", "for":
+				if local := liquidLocal.FindStringSubmatch(arguments); local != nil {
+					locals = append(locals, local[1])
+				}
+				separator := " in "
+				if tag == "assign" {
+					separator = "="
+				}
+				if _, value, ok := strings.Cut(arguments, separator); ok && tag != "capture" {
+					references = append(references, liquidReferences(strings.SplitN(value, "|", 2)[0])...)
+				}
+			case "if", "elsif", "unless", "case", "when":
+				references = append(references, liquidReferences(arguments)...)
+			}
+		}
+
+		reported := map[string]bool{}
+		for _, variable := range references {
+			if reported[variable] || knownEmailVariable(template, variable, locals) {
+				continue
+			}
+			reported[variable] = true
+			warnings = append(warnings, fmt.Sprintf("%s in the %s is not a variable Auth0 provides for %s and won't render", variable, field, template))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintEmailTemplate(t *testing.T) {
+	template := map[string]interface{}{
+		"template": "verify_email",
+		"subject":  "Welcome to {{ application.name }}, {{ user.first_name | default: 'there' }}",
+		"body": `{% assign greeting = "Hello" %}{{ greeting }} {{ user.given_name }},
+{% if user.user_metadata.plan == "pro" %}Thanks for going pro!{% endif %}
+{% for item in user.app_metadata.items %}{{ item.name }}{% endfor %}
+<a href="{{ url }}">Verify</a> or enter {{ code }}.
+{%- if organization.nam -%}{{ organization.display_name }}{%- endif -%}`,
+	}
+
+	warnings := lintEmailTemplate(template)
+	var variables []string
+	for _, warning := range warnings {
+		variables = append(variables, strings.Fields(warning)[0])
+	}
+
+	expected := []string{"user.first_name", "code", "organization.nam"}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("Expected warnings for %v, got %v", expected, warnings)
+	}
+	if !strings.Contains(warnings[0], "in the subject") || !strings.Contains(warnings[1], "for verify_email") {
+		t.Errorf("Expected the warnings to name the field and template, got %v", warnings)
+	}
+}
+
+func TestLintEmailTemplateCode(t *testing.T) {
+	template := map[string]interface{}{"template": "verify_email_by_code", "body": "Your code is {{ code }}."}
+	if warnings := lintEmailTemplate(template); len(warnings) != 0 {
+		t.Errorf("Expected code to be provided for verify_email_by_code, got %v", warnings)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// emailProviderSecrets are the credentials of each email provider that
+// Auth0 doesn't return. The other credentials, such as the SMTP host or the
+// SES region, are copied.
+var emailProviderSecrets = map[string][]string{
+	management.EmailProviderMandrill:  {"api_key"},
+	management.EmailProviderSES:       {"secretAccessKey"},
+	management.EmailProviderSendGrid:  {"api_key"},
+	management.EmailProviderSparkPost: {"api_key"},
+	management.EmailProviderMailgun:   {"api_key"},
+	management.EmailProviderSMTP:      {"smtp_pass"},
+	management.EmailProviderAzureCS:   {"connectionString"},
+	management.EmailProviderMS365:     {"clientSecret"},
+}
+
+// readEmailProvider returns the email provider of a tenant with the
+// credentials Auth0 returns, or nil if the tenant has none.
+func readEmailProvider(ctx context.Context, m *management.Management) (*management.EmailProvider, error) {
+	provider, err := m.EmailProvider.Read(ctx, management.IncludeFields("name", "enabled", "default_from_address", "credentials", "settings"))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email provider: %w", apiError(err))
+	}
+	return provider, nil
+}
+
+// redactedEmailProvider returns the settings of an email provider that
+// migrate emails copies, with the secret credentials left out.
+func redactedEmailProvider(provider *management.EmailProvider) (*management.EmailProvider, error) {
+	credentials := map[string]interface{}{}
+	if provider.Credentials != nil {
+		data, err := json.Marshal(provider.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode email provider credentials: %w", err)
+		}
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return nil, fmt.Errorf("failed to decode email provider credentials: %w", err)
+		}
+	}
+	for _, name := range emailProviderSecrets[provider.GetName()] {
+		delete(credentials, name)
+	}
+	return &management.EmailProvider{
+		Name:               provider.Name,
+		Enabled:            provider.Enabled,
+		DefaultFromAddress: provider.DefaultFromAddress,
+		Credentials:        credentials,
+		Settings:           provider.Settings,
+	}, nil
+}
+
+// migrateEmailProvider creates or updates the email provider of the target
+// tenant from the source one and returns the action taken. The secret
+// credentials are taken from configured or asked for, and only when the
+// provider changes, so an up to date provider keeps its credentials.
+// Nothing is changed on a dry run.
+func migrateEmailProvider(ctx context.Context, m *management.Management, provider, existing *management.EmailProvider, configured map[string]string, ask func(string) (string, error), dryRun bool) (string, error) {
+	migrated, err := redactedEmailProvider(provider)
+	if err != nil {
+		return "", err
+	}
+
+	action := diffActionCreate
+	if existing != nil {
+		current, err := redactedEmailProvider(existing)
+		if err != nil {
+			return "", err
+		}
+		a, _ := json.Marshal(migrated)
+		b, _ := json.Marshal(current)
+		if string(a) == string(b) {
+			return planSkip, nil
+		}
+		action = diffActionUpdate
+	}
+	if dryRun {
+		return action, nil
+	}
+
+	secrets, err := secretValues("email provider "+provider.GetName(), emailProviderSecrets[provider.GetName()], configured, ask)
+	if err != nil {
+		return "", err
+	}
+	credentials := migrated.Credentials.(map[string]interface{})
+	for name, value := range secrets {
+		credentials[name] = value
+	}
+
+	if existing == nil {
+		if err := m.EmailProvider.Create(ctx, migrated); err != nil {
+			return "", fmt.Errorf("failed to create email provider: %w", apiError(err))
+		}
+		return action, nil
+	}
+	if err := m.EmailProvider.Update(ctx, migrated); err != nil {
+		return "", fmt.Errorf("failed to update email provider: %w", apiError(err))
+	}
+	return action, nil
+}
+
+// readEmailTemplate returns an email template of a tenant, or nil if the
+// tenant hasn't customized it.
+func readEmailTemplate(ctx context.Context, m *management.Management, name string) (*management.EmailTemplate, error) {
+	template, err := m.EmailTemplate.Read(ctx, name)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email template %s: %w", name, apiError(err))
+	}
+	return template, nil
+}
+
+// migratedEmailTemplate returns the settings of an email template that
+// migrate emails copies: the body, subject, sender, result URL and link
+// lifetime, and whether it is enabled.
+func migratedEmailTemplate(template *management.EmailTemplate) *management.EmailTemplate {
+	return &management.EmailTemplate{
+		Body:                   template.Body,
+		From:                   template.From,
+		ResultURL:              template.ResultURL,
+		Subject:                template.Subject,
+		Syntax:                 template.Syntax,
+		URLLifetimeInSecoonds:  template.URLLifetimeInSecoonds,
+		Enabled:                template.Enabled,
+		IncludeEmailInRedirect: template.IncludeEmailInRedirect,
+	}
+}
+
+// migrateEmailTemplate creates or updates a source email template in the
+// target tenant and returns the action taken. Nothing is changed on a dry
+// run.
+func migrateEmailTemplate(ctx context.Context, m *management.Management, template, existing *management.EmailTemplate, dryRun bool) (string, error) {
+	migrated := migratedEmailTemplate(template)
+
+	if existing == nil {
+		if !dryRun {
+			migrated.Template = template.Template
+			if err := m.EmailTemplate.Create(ctx, migrated); err != nil {
+				return "", fmt.Errorf("failed to create email template: %w", apiError(err))
+			}
+		}
+		return diffActionCreate, nil
+	}
+
+	a, _ := json.Marshal(migrated)
+	b, _ := json.Marshal(migratedEmailTemplate(existing))
+	if string(a) == string(b) {
+		return planSkip, nil
+	}
+	if !dryRun {
+		if err := m.EmailTemplate.Update(ctx, template.GetTemplate(), migrated); err != nil {
+			return "", fmt.Errorf("failed to update email template: %w", apiError(err))
+		}
+	}
+	return diffActionUpdate, nil
+}
+
+func newMigrateEmailsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		templates    []string
+		skipProvider bool
+		dryRun       bool
+	)
+
+	var emailsCmd = &cobra.Command{
+		Use:   "emails",
+		Short: "Copy the email provider and the email templates to the target tenant, asking for the provider's credentials",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate emails")
+				clients.guardTarget("migrate emails")
+			}
+			for _, name := range templates {
+				if !slices.Contains(emailTemplateNames, name) {
+					log.Fatalf("Invalid --templates: unknown email template %q, expected one of %v", name, emailTemplateNames)
+				}
+			}
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+
+			sourceClient := clients.sourceClient()
+			targetClient := clients.targetClient()
+			created, updated, failed := 0, 0, 0
+			var plan []planEntry
+			record := func(resource, action string) {
+				plan = append(plan, planEntry{Resource: resource, Action: action})
+				switch action {
+				case diffActionCreate:
+					created++
+				case diffActionUpdate:
+					updated++
+				}
+				if !dryRun {
+					fmt.Fprintf(messageOutput, "%s: %s.\n", resource, action)
+				}
+			}
+
+			if !skipProvider {
+				provider, err := readEmailProvider(ctx, sourceClient)
+				if err != nil {
+					log.Fatalf("Failed to read source email provider: %v", err)
+				}
+				existing, err := readEmailProvider(ctx, targetClient)
+				if err != nil {
+					log.Fatalf("Failed to read target email provider: %v", err)
+				}
+				if provider == nil {
+					fmt.Fprintln(messageOutput, "The source tenant has no email provider, it sends with Auth0's built-in one.")
+				} else {
+					action, err := migrateEmailProvider(ctx, targetClient, provider, existing, cfg.EmailProviderCredentials, promptText, dryRun)
+					if err != nil {
+						fmt.Fprintf(messageOutput, "Failed to migrate email provider %s: %v\n", provider.GetName(), err)
+						failed++
+					} else {
+						record("email-provider/"+provider.GetName(), action)
+					}
+				}
+			}
+
+			if len(templates) == 0 {
+				templates = emailTemplateNames
+			}
+			for _, name := range templates {
+				template, err := readEmailTemplate(ctx, sourceClient, name)
+				if err != nil {
+					log.Fatalf("Failed to read source email templates: %v", err)
+				}
+				if template == nil {
+					continue
+				}
+				existing, err := readEmailTemplate(ctx, targetClient, name)
+				if err != nil {
+					log.Fatalf("Failed to read target email templates: %v", err)
+				}
+				action, err := migrateEmailTemplate(ctx, targetClient, template, existing, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate email template %s: %v\n", name, err)
+					failed++
+					continue
+				}
+				record("email-templates/"+name, action)
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate emails", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Emails migrated: %d created, %d updated, %d failed.\n", created, updated, failed)
+			reportResult("migrate emails", map[string]interface{}{
+				"created": created, "updated": updated, "failed": failed, "plan": plan,
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	emailsCmd.Flags().StringSliceVar(&templates, "templates", nil, "Only copy these email templates, e.g. verify_email,reset_email; by default all customized templates")
+	emailsCmd.Flags().BoolVar(&skipProvider, "skip-provider", false, "Leave the target's email provider alone and only copy templates")
+	emailsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return emailsCmd
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestMigrateEmailProvider(t *testing.T) {
+	var created map[string]interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/emails/provider"):
+			if !strings.Contains(r.URL.Query().Get("fields"), "credentials") {
+				t.Errorf("Expected the credentials to be requested, got fields %q", r.URL.Query().Get("fields"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name": "smtp", "enabled": true, "default_from_address": "no-reply@example.com",
+				"credentials": map[string]interface{}{"smtp_host": "smtp.example.com", "smtp_port": 587, "smtp_user": "mailer"},
+			})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/emails/provider"):
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	ctx := context.Background()
+	provider, err := readEmailProvider(ctx, m)
+	if err != nil {
+		t.Fatalf("Failed to read email provider: %v", err)
+	}
+
+	asked := []string{}
+	ask := func(question string) (string, error) {
+		asked = append(asked, question)
+		return "asked", nil
+	}
+	action, err := migrateEmailProvider(ctx, m, provider, nil, nil, ask, false)
+	if err != nil || action != diffActionCreate {
+		t.Fatalf("Expected the provider to be created, got %q, %v", action, err)
+	}
+	credentials, _ := created["credentials"].(map[string]interface{})
+	if credentials["smtp_host"] != "smtp.example.com" || credentials["smtp_port"] != float64(587) || credentials["smtp_pass"] != "asked" {
+		t.Errorf("Unexpected credentials %v", credentials)
+	}
+	if created["default_from_address"] != "no-reply@example.com" || len(asked) != 1 {
+		t.Errorf("Expected the sender to be copied and smtp_pass to be asked for once, got %v and %v", created, asked)
+	}
+
+	// An unchanged provider keeps its credentials, so nothing is asked for.
+	action, err = migrateEmailProvider(ctx, m, provider, provider, nil, ask, false)
+	if err != nil || action != planSkip || len(asked) != 1 {
+		t.Errorf("Expected the provider to be skipped without asking, got %q, %v after %v", action, err, asked)
+	}
+
+	redacted, _ := redactedEmailProvider(&management.EmailProvider{
+		Name:        auth0.String("sendgrid"),
+		Credentials: &management.EmailProviderCredentialsSendGrid{APIKey: auth0.String("secret")},
+	})
+	if len(redacted.Credentials.(map[string]interface{})) != 0 {
+		t.Errorf("Expected the API key to be redacted, got %v", redacted.Credentials)
+	}
+}
+
+func TestMigrateEmailTemplates(t *testing.T) {
+	var updated map[string]interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/email-templates/welcome_email"):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 404, "message": "Template not found"})
+		case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/email-templates/verify_email"):
+			json.NewDecoder(r.Body).Decode(&updated)
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	ctx := context.Background()
+	missing, err := readEmailTemplate(ctx, m, "welcome_email")
+	if err != nil || missing != nil {
+		t.Fatalf("Expected no template, got %v, %v", missing, err)
+	}
+
+	source := &management.EmailTemplate{
+		Template: auth0.String("verify_email"), Subject: auth0.String("Verify your email"),
+		Body: auth0.String("<html>{{ url }}</html>"), From: auth0.String("Example <no-reply@example.com>"),
+		ResultURL: auth0.String("https://app.example.com/verified"), Syntax: auth0.String("liquid"),
+		Enabled: auth0.Bool(true),
+	}
+	target := *source
+	target.ResultURL = auth0.String("https://old.example.com/verified")
+
+	action, err := migrateEmailTemplate(ctx, m, source, &target, false)
+	if err != nil || action != diffActionUpdate {
+		t.Fatalf("Expected the template to be updated, got %q, %v", action, err)
+	}
+	if updated["resultUrl"] != "https://app.example.com/verified" || updated["body"] != "<html>{{ url }}</html>" {
+		t.Errorf("Unexpected update %v", updated)
+	}
+
+	action, err = migrateEmailTemplate(ctx, m, source, source, false)
+	if err != nil || action != planSkip {
+		t.Errorf("Expected an unchanged template to be skipped, got %q, %v", action, err)
+	}
+}
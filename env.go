@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix marks environment variables that override config keys. Nested
+// keys are joined with underscores, e.g. AUTH0_TOOLS_CHUNK_SIZE,
+// AUTH0_TOOLS_PROFILES_PROD_CLIENT_SECRET or AUTH0_TOOLS_SCHEDULES_0_CRON.
+const envPrefix = "AUTH0_TOOLS_"
+
+// applyEnvOverrides sets config values from AUTH0_TOOLS_* variables in
+// environ (as returned by os.Environ). Variables that don't map to a config
+// key are reported as errors.
+func applyEnvOverrides(cfg *config, environ []string) []error {
+	var errs []error
+
+	for _, entry := range environ {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(name, envPrefix))
+		if err := setConfigValue(reflect.ValueOf(cfg).Elem(), key, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+func setConfigValue(v reflect.Value, key, value string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		field, rest, ok := matchStructField(v.Type(), key)
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		return setConfigValue(v.Field(field), rest, value)
+
+	case reflect.Map:
+		name, rest, ok := matchMapKey(v.Type().Elem(), key)
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		mapKey := reflect.ValueOf(name)
+		for _, existing := range v.MapKeys() {
+			if strings.EqualFold(existing.String(), name) {
+				mapKey = existing
+			}
+		}
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if current := v.MapIndex(mapKey); current.IsValid() {
+			elem.Set(current)
+		}
+		if err := setConfigValue(elem, rest, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(mapKey, elem)
+		return nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && key == "" {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items))
+			return nil
+		}
+
+		indexPart, rest, _ := strings.Cut(key, "_")
+		index, err := strconv.Atoi(indexPart)
+		if err != nil || index < 0 || index > v.Len() {
+			return fmt.Errorf("invalid list index %q", indexPart)
+		}
+		if index == v.Len() {
+			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+		}
+		return setConfigValue(v.Index(index), rest, value)
+	}
+
+	if key != "" {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+
+	// Durations are int64s, so they are parsed before integers.
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config value type %s", v.Type())
+	}
+	return nil
+}
+
+// matchStructField finds the field whose yaml name is key or a prefix of it,
+// preferring the longest name so chunk_size wins over a hypothetical chunk.
+func matchStructField(t reflect.Type, key string) (int, string, bool) {
+	best, bestName := -1, ""
+
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if (key == name || strings.HasPrefix(key, name+"_")) && len(name) > len(bestName) {
+			best, bestName = i, name
+		}
+	}
+
+	if best < 0 {
+		return 0, "", false
+	}
+	return best, strings.TrimPrefix(strings.TrimPrefix(key, bestName), "_"), true
+}
+
+// matchMapKey splits key into a map entry name and the remaining key path.
+// Names may contain underscores, so the shortest name whose remainder is a
+// valid path into the element type is used.
+func matchMapKey(elem reflect.Type, key string) (string, string, bool) {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '_' {
+			continue
+		}
+		name, rest := key[:i], key[i+1:]
+		if configPathExists(elem, rest) {
+			return name, rest, true
+		}
+	}
+
+	if configPathExists(elem, "") {
+		return key, "", true
+	}
+	return "", "", false
+}
+
+func configPathExists(t reflect.Type, key string) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		field, rest, ok := matchStructField(t, key)
+		return ok && configPathExists(t.Field(field).Type, rest)
+	case reflect.Map:
+		_, _, ok := matchMapKey(t.Elem(), key)
+		return ok
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return key == ""
+		}
+		indexPart, rest, _ := strings.Cut(key, "_")
+		if _, err := strconv.Atoi(indexPart); err != nil {
+			return false
+		}
+		return configPathExists(t.Elem(), rest)
+	default:
+		return key == ""
+	}
+}
+
+func envOverrideError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("invalid environment overrides: %s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	cfg := &config{
+		Profiles: map[string]profileConfig{
+			"my_prod": {Domain: "prod.eu.auth0.com", ClientID: "abc"},
+		},
+	}
+
+	errs := applyEnvOverrides(cfg, []string{
+		"PATH=/usr/bin",
+		"AUTH0_TOOLS_CHUNK_SIZE=100000",
+		"AUTH0_TOOLS_PROFILES_MY_PROD_CLIENT_SECRET=secret",
+		"AUTH0_TOOLS_PROFILES_DEV_DOMAIN=dev.eu.auth0.com",
+		"AUTH0_TOOLS_SCHEDULES_0_CRON=@daily",
+		"AUTH0_TOOLS_UNKNOWN=1",
+	})
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error for the unknown key, got %v", errs)
+	}
+
+	if cfg.ChunkSize != 100000 {
+		t.Errorf("Expected chunk size 100000, got %d", cfg.ChunkSize)
+	}
+
+	prod := cfg.Profiles["my_prod"]
+	if prod.ClientSecret != "secret" || prod.ClientID != "abc" {
+		t.Errorf("Expected my_prod profile to be merged, got %+v", prod)
+	}
+
+	if cfg.Profiles["dev"].Domain != "dev.eu.auth0.com" {
+		t.Errorf("Expected dev profile to be created, got %+v", cfg.Profiles["dev"])
+	}
+
+	if len(cfg.Schedules) != 1 || cfg.Schedules[0].Cron != "@daily" {
+		t.Errorf("Expected one schedule with cron @daily, got %+v", cfg.Schedules)
+	}
+}
+
+func TestApplyEnvOverridesDurations(t *testing.T) {
+	cfg := &config{}
+	errs := applyEnvOverrides(cfg, []string{
+		"AUTH0_TOOLS_APPROVALS_PRE_IMPORT_TIMEOUT=24h",
+		"AUTH0_TOOLS_APPROVALS_POST_EXPORT_TIMEOUT=1000",
+	})
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error for the duration without a unit, got %v", errs)
+	}
+	if timeout := cfg.Approvals["pre_import"].Timeout; timeout != 24*time.Hour {
+		t.Errorf("Expected a timeout of 24h, got %s", timeout)
+	}
+}
+
+func TestLoadConfigSecretsFromEnv(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(filename, []byte("pii:\n  fields:\n    email: [encrypt, anonymize]\n"), 0644)
+	t.Setenv("AUTH0_TOOLS_ENCRYPTION_KEY", "key")
+	t.Setenv("AUTH0_TOOLS_PII_SALT", "salt")
+
+	cfg, err := loadConfig(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.EncryptionKey != "key" || cfg.PIISalt != "salt" {
+		t.Errorf("Expected the encryption key and salt from the environment, got %q and %q", cfg.EncryptionKey, cfg.PIISalt)
+	}
+}
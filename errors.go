@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// Sentinel errors for branching on the failure mode with errors.Is. The typed
+// errors below match them and carry the details.
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrJobFailed   = errors.New("job failed")
+	ErrValidation  = errors.New("validation failed")
+	ErrJobTimeout  = errors.New("job timed out")
+)
+
+// RateLimitedError is returned when the Management API responds with 429.
+type RateLimitedError struct {
+	Err error
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited by Auth0: %v", e.Err)
+}
+
+func (e *RateLimitedError) Unwrap() error { return e.Err }
+
+func (e *RateLimitedError) Is(target error) bool { return target == ErrRateLimited }
+
+// JobFailedError is returned when an Auth0 job finishes with status failed.
+// Reasons holds the per-user errors reported by the job, when available.
+type JobFailedError struct {
+	JobID   string
+	Reasons []string
+}
+
+func (e *JobFailedError) Error() string {
+	if len(e.Reasons) == 0 {
+		return fmt.Sprintf("job %s failed", e.JobID)
+	}
+	return fmt.Sprintf("job %s failed: %s", e.JobID, strings.Join(e.Reasons, "; "))
+}
+
+func (e *JobFailedError) Is(target error) bool { return target == ErrJobFailed }
+
+// JobTimeoutError is returned when the tool stops waiting for an Auth0 job.
+// The job itself is not cancelled: Status is its state on Auth0's side when
+// the tool gave up, and it may still complete.
+type JobTimeoutError struct {
+	JobID  string
+	Status string
+	Waited time.Duration
+}
+
+func (e *JobTimeoutError) Error() string {
+	return fmt.Sprintf("gave up waiting for job %s after %s; it is still %s on Auth0 and may complete later, check it with GET /api/v2/jobs/%s", e.JobID, e.Waited, e.Status, e.JobID)
+}
+
+func (e *JobTimeoutError) Is(target error) bool { return target == ErrJobTimeout }
+
+// ValidationError is returned when an input record cannot be parsed or is
+// invalid. Line is the 1-based line number in the input and Offset the byte
+// offset of the problem, when known. Email identifies the user if it could be
+// read from the record.
+type ValidationError struct {
+	Line   int
+	Offset int64
+	Email  string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	location := fmt.Sprintf("line %d", e.Line)
+	if e.Offset > 0 {
+		location += fmt.Sprintf(", byte %d", e.Offset)
+	}
+	if e.Email != "" {
+		location += fmt.Sprintf(" (%s)", redactPII("email", e.Email))
+	}
+	return fmt.Sprintf("%s: %v", location, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// apiError converts Management API errors into the typed errors above.
+func apiError(err error) error {
+	var mErr management.Error
+	if errors.As(err, &mErr) && mErr.Status() == http.StatusTooManyRequests {
+		emitEvent("rate_limited", map[string]interface{}{"error": err.Error()})
+		return &RateLimitedError{Err: err}
+	}
+	return err
+}
+
+// isJobQuotaError reports whether Auth0 refused to create a job because the
+// tenant already has too many pending jobs.
+func isJobQuotaError(err error) bool {
+	var mErr management.Error
+	return errors.As(err, &mErr) && strings.Contains(strings.ToLower(mErr.Error()), "too many pending jobs")
+}
+
+// isNotFound reports whether Auth0 answered 404, e.g. for a setting the
+// tenant hasn't customized.
+func isNotFound(err error) bool {
+	var mErr management.Error
+	return errors.As(err, &mErr) && mErr.Status() == http.StatusNotFound
+}
+
+// jobErrorReasons formats the per-user errors of a job as "email: message".
+// The user_id is used instead when emails must not be logged.
+func jobErrorReasons(jobErrors []management.JobError) []string {
+	var reasons []string
+	for _, jobError := range jobErrors {
+		identifier, _ := jobError.User["email"].(string)
+		if identifier == "" || redactPII("email", identifier) != identifier {
+			identifier, _ = jobError.User["user_id"].(string)
+		}
+
+		for _, userError := range jobError.Errors {
+			reasons = append(reasons, fmt.Sprintf("%s: %s %s", identifier, userError.Code, userError.Message))
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	_, err := splitJSONData([]byte("{\"user_id\": \"1\"}\n{not json}"), 100, true)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Line != 2 {
+		t.Fatalf("Expected a validation error on line 2, got %v", err)
+	}
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected error to match ErrValidation")
+	}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"statusCode": 429, "error": "Too Many Requests", "message": "Global limit has been reached"}`)
+	}))
+
+	_, err = submitImportJob(context.Background(), m, []map[string]interface{}{{"email": "user1@example.com"}})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected error to match ErrRateLimited, got %v", err)
+	}
+
+	jobErr := fmt.Errorf("import failed: %w", &JobFailedError{JobID: "job_1", Reasons: []string{"user1@example.com: DUPLICATED_USER"}})
+	var jobFailed *JobFailedError
+	if !errors.As(jobErr, &jobFailed) || len(jobFailed.Reasons) != 1 || !errors.Is(jobErr, ErrJobFailed) {
+		t.Errorf("Expected a job failed error with reasons, got %v", jobErr)
+	}
+}
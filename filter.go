@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// resourcePattern matches the keys of one resource type, or of all types
+// with kind "*", against a glob.
+type resourcePattern struct {
+	kind, glob string
+}
+
+// resourceFilter narrows a config migration down to some resources with
+// --include and --exclude patterns of the form kind:glob, e.g.
+// clients:app-*. When a type has include patterns, only its resources that
+// match one of them are migrated; types without are migrated in full.
+// Excluded resources are left alone even if they are included.
+type resourceFilter struct {
+	include, exclude []resourcePattern
+}
+
+func parseResourcePatterns(patterns []string) ([]resourcePattern, error) {
+	var parsed []resourcePattern
+	for _, pattern := range patterns {
+		kind, glob, ok := strings.Cut(pattern, ":")
+		if !ok || glob == "" {
+			return nil, fmt.Errorf("invalid pattern %q, expected type:glob, e.g. clients:app-*", pattern)
+		}
+		if kind != "*" {
+			if _, err := findResourceKind(kind); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		parsed = append(parsed, resourcePattern{kind: kind, glob: glob})
+	}
+	return parsed, nil
+}
+
+func newResourceFilter(include, exclude []string) (*resourceFilter, error) {
+	var f resourceFilter
+	var err error
+	if f.include, err = parseResourcePatterns(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = parseResourcePatterns(exclude); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// matchAny reports whether any of the patterns for kind matches key, and
+// whether there were patterns for kind at all.
+func matchAny(patterns []resourcePattern, kind, key string) (matched, found bool) {
+	for _, p := range patterns {
+		if p.kind != kind && p.kind != "*" {
+			continue
+		}
+		found = true
+		if ok, _ := path.Match(p.glob, key); ok {
+			return true, true
+		}
+	}
+	return false, found
+}
+
+// allows reports whether the resource of a kind with a key is migrated.
+func (f *resourceFilter) allows(kind, key string) bool {
+	if matched, found := matchAny(f.include, kind, key); found && !matched {
+		return false
+	}
+	excluded, _ := matchAny(f.exclude, kind, key)
+	return !excluded
+}
+
+// apply returns the resources of a kind the filter allows.
+func (f *resourceFilter) apply(kind string, resources map[string]map[string]interface{}) map[string]map[string]interface{} {
+	filtered := map[string]map[string]interface{}{}
+	for key, resource := range resources {
+		if f.allows(kind, key) {
+			filtered[key] = resource
+		}
+	}
+	return filtered
+}
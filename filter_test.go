@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceFilter(t *testing.T) {
+	filter, err := newResourceFilter([]string{"clients:app-*", "clients:portal"}, []string{"clients:app-legacy", "*:test-*"})
+	if err != nil {
+		t.Fatalf("Failed to parse the filter: %v", err)
+	}
+
+	for _, tc := range []struct {
+		kind, key string
+		allowed   bool
+	}{
+		{"clients", "app-web", true},
+		{"clients", "portal", true},
+		{"clients", "admin", false},
+		{"clients", "app-legacy", false},
+		{"clients", "test-app", false},
+		{"connections", "legacy-db", true},
+		{"connections", "test-db", false},
+	} {
+		if allowed := filter.allows(tc.kind, tc.key); allowed != tc.allowed {
+			t.Errorf("%s/%s: expected allowed %v, got %v", tc.kind, tc.key, tc.allowed, allowed)
+		}
+	}
+
+	resources := map[string]map[string]interface{}{"app-web": {"name": "app-web"}, "admin": {"name": "admin"}}
+	expected := map[string]map[string]interface{}{"app-web": {"name": "app-web"}}
+	if filtered := filter.apply("clients", resources); !reflect.DeepEqual(filtered, expected) {
+		t.Errorf("Expected %v, got %v", expected, filtered)
+	}
+}
+
+func TestInvalidResourceFilter(t *testing.T) {
+	for _, pattern := range []string{"app-*", "clients:", "widgets:app-*", "clients:[app"} {
+		if _, err := newResourceFilter([]string{pattern}, nil); err == nil {
+			t.Errorf("%s: expected an invalid pattern", pattern)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type k8sMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type k8sContainer struct {
+	Name    string       `yaml:"name"`
+	Image   string       `yaml:"image"`
+	Args    []string     `yaml:"args"`
+	EnvFrom []k8sEnvFrom `yaml:"envFrom"`
+}
+
+type k8sEnvFrom struct {
+	SecretRef struct {
+		Name string `yaml:"name"`
+	} `yaml:"secretRef"`
+}
+
+type k8sPodTemplate struct {
+	Spec struct {
+		RestartPolicy string         `yaml:"restartPolicy"`
+		Containers    []k8sContainer `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+type k8sJobSpec struct {
+	BackoffLimit int            `yaml:"backoffLimit"`
+	Template     k8sPodTemplate `yaml:"template"`
+}
+
+type k8sJob struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMetadata `yaml:"metadata"`
+	Spec       k8sJobSpec  `yaml:"spec"`
+}
+
+type k8sCronJob struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   k8sMetadata `yaml:"metadata"`
+	Spec       struct {
+		Schedule          string `yaml:"schedule"`
+		ConcurrencyPolicy string `yaml:"concurrencyPolicy"`
+		JobTemplate       struct {
+			Spec k8sJobSpec `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+	} `yaml:"spec"`
+}
+
+// splitArgs splits a command line into arguments, honouring single and
+// double quotes so queries like app_metadata.plan:"legacy" survive.
+func splitArgs(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+type k8sJobOptions struct {
+	name      string
+	namespace string
+	image     string
+	secret    string
+	command   string
+	schedule  string
+}
+
+func (o *k8sJobOptions) jobSpec() (k8sJobSpec, error) {
+	args, err := splitArgs(o.command)
+	if err != nil {
+		return k8sJobSpec{}, err
+	}
+	if len(args) == 0 {
+		return k8sJobSpec{}, fmt.Errorf("command is empty")
+	}
+	args = append(args, "--non-interactive")
+
+	container := k8sContainer{
+		Name:  "auth0-tools",
+		Image: o.image,
+		Args:  args,
+	}
+	var envFrom k8sEnvFrom
+	envFrom.SecretRef.Name = o.secret
+	container.EnvFrom = []k8sEnvFrom{envFrom}
+
+	spec := k8sJobSpec{BackoffLimit: 0}
+	spec.Template.Spec.RestartPolicy = "Never"
+	spec.Template.Spec.Containers = []k8sContainer{container}
+	return spec, nil
+}
+
+func (o *k8sJobOptions) manifest() ([]byte, error) {
+	spec, err := o.jobSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := k8sMetadata{
+		Name:      o.name,
+		Namespace: o.namespace,
+		Labels:    map[string]string{"app.kubernetes.io/name": "auth0-tools"},
+	}
+
+	if o.schedule == "" {
+		return yaml.Marshal(k8sJob{APIVersion: "batch/v1", Kind: "Job", Metadata: metadata, Spec: spec})
+	}
+
+	if err := validateCron(o.schedule); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", o.schedule, err)
+	}
+
+	cronJob := k8sCronJob{APIVersion: "batch/v1", Kind: "CronJob", Metadata: metadata}
+	cronJob.Spec.Schedule = o.schedule
+	cronJob.Spec.ConcurrencyPolicy = "Forbid"
+	cronJob.Spec.JobTemplate.Spec = spec
+	return yaml.Marshal(cronJob)
+}
+
+func newGenerateCmd() *cobra.Command {
+	var generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate deployment artifacts for running the tool",
+	}
+
+	var (
+		opts         k8sJobOptions
+		scheduleName string
+	)
+
+	var k8sJobCmd = &cobra.Command{
+		Use:   "k8s-job",
+		Short: "Print a Kubernetes Job or CronJob manifest for a migration or scheduled sync",
+		Run: func(cmd *cobra.Command, args []string) {
+			if scheduleName != "" {
+				cfg, err := loadOptionalConfig()
+				if err != nil {
+					log.Fatalf("Failed to load config: %v", err)
+				}
+
+				found := false
+				for _, schedule := range cfg.Schedules {
+					if schedule.Name == scheduleName {
+						opts.command, opts.schedule, found = schedule.Command, schedule.Cron, true
+					}
+				}
+				if !found {
+					log.Fatalf("Schedule %q not found in config", scheduleName)
+				}
+				if opts.name == "" {
+					opts.name = "auth0-tools-" + scheduleName
+				}
+			}
+
+			if opts.image == "" {
+				log.Fatalf("A container image is required (--image)")
+			}
+			if opts.command == "" {
+				log.Fatalf("A command (--command) or a configured schedule (--schedule) is required")
+			}
+			if opts.name == "" {
+				opts.name = "auth0-tools"
+			}
+
+			manifest, err := opts.manifest()
+			if err != nil {
+				log.Fatalf("Failed to generate manifest: %v", err)
+			}
+			resultOutput.Write(manifest)
+		},
+	}
+
+	k8sJobCmd.Flags().StringVar(&opts.command, "command", "", "Command to run, e.g. \"import --max-pending 2\"")
+	k8sJobCmd.Flags().StringVar(&opts.schedule, "cron", "", "Cron schedule; generates a CronJob instead of a Job")
+	k8sJobCmd.Flags().StringVar(&scheduleName, "schedule", "", "Name of a schedule from the config file to generate a CronJob for")
+	k8sJobCmd.Flags().StringVar(&opts.name, "name", "", "Name of the Job or CronJob")
+	k8sJobCmd.Flags().StringVar(&opts.namespace, "namespace", "", "Kubernetes namespace")
+	k8sJobCmd.Flags().StringVar(&opts.image, "image", "", "Container image with the auth0-tools binary as entrypoint")
+	k8sJobCmd.Flags().StringVar(&opts.secret, "secret", "auth0-tools", "Secret holding the tenant credentials as environment variables")
+
+	generateCmd.AddCommand(k8sJobCmd, newCustomDBScriptsCmd())
+	return generateCmd
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`users update --query 'app_metadata.plan:"legacy"' --set "app_metadata.plan=standard"`)
+	if err != nil {
+		t.Fatalf("Failed to split args: %v", err)
+	}
+
+	expected := []string{"users", "update", "--query", `app_metadata.plan:"legacy"`, "--set", "app_metadata.plan=standard"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+
+	if _, err := splitArgs(`import --input "users.json`); err == nil {
+		t.Errorf("Expected an error for an unterminated quote")
+	}
+}
+
+func TestK8sJobManifest(t *testing.T) {
+	opts := &k8sJobOptions{name: "nightly", image: "example/auth0-tools:1.0", secret: "auth0-creds", command: "sync metadata", schedule: "0 2 * * *"}
+
+	manifest, err := opts.manifest()
+	if err != nil {
+		t.Fatalf("Failed to generate manifest: %v", err)
+	}
+
+	for _, expected := range []string{"kind: CronJob", "schedule: 0 2 * * *", "name: auth0-creds", "- --non-interactive", "restartPolicy: Never"} {
+		if !strings.Contains(string(manifest), expected) {
+			t.Errorf("Expected manifest to contain %q:\n%s", expected, manifest)
+		}
+	}
+
+	opts.schedule = ""
+	manifest, err = opts.manifest()
+	if err != nil || !strings.Contains(string(manifest), "kind: Job") {
+		t.Errorf("Expected a Job manifest, got %s (%v)", manifest, err)
+	}
+}
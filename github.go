@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// githubActions reports whether the tool runs inside a GitHub Actions job.
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+func escapeAnnotation(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeAnnotationProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// annotate emits a ::error or ::warning workflow command. When err carries a
+// ValidationError the annotation points at the offending line of file.
+func annotate(level, title, file string, err error) {
+	if !githubActions() {
+		return
+	}
+
+	properties := []string{"title=" + escapeAnnotationProperty(title)}
+	if file != "" {
+		properties = append(properties, "file="+escapeAnnotationProperty(file))
+
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			properties = append(properties, fmt.Sprintf("line=%d", validationErr.Line))
+		}
+	}
+
+	fmt.Fprintf(messageOutput, "::%s %s::%s\n", level, strings.Join(properties, ","), escapeAnnotation(err.Error()))
+}
+
+// writeStepSummary appends markdown to the job's step summary.
+func writeStepSummary(markdown string) error {
+	filename := os.Getenv("GITHUB_STEP_SUMMARY")
+	if !githubActions() || filename == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(markdown); err != nil {
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+	return nil
+}
+
+// importSummaryMarkdown renders the chunk results as a markdown table.
+func importSummaryMarkdown(results []chunkResult) string {
+	var b strings.Builder
+	b.WriteString("### Auth0 user import\n\n")
+	b.WriteString("| Chunk | Chunk ID | Job | Status | Total | Inserted | Updated | Failed |\n")
+	b.WriteString("| ---: | --- | --- | --- | ---: | ---: | ---: | ---: |\n")
+
+	for _, result := range results {
+		summary := result.summary
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %d | %d | %d | %d |\n",
+			result.chunk, result.chunkID, result.jobID, result.status,
+			summary.GetTotal(), summary.GetInserted(), summary.GetUpdated(), summary.GetFailed())
+	}
+
+	totals := importTotals(results)
+	fmt.Fprintf(&b, "| **Total** | | | | %d | %d | %d | %d |\n", totals.Total, totals.Inserted, totals.Updated, totals.Failed)
+
+	return b.String()
+}
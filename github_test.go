@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestWriteStepSummary(t *testing.T) {
+	filename := "step_summary.md"
+	defer os.Remove(filename)
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_STEP_SUMMARY", filename)
+
+	total, inserted, failed := 3, 2, 1
+	results := []chunkResult{
+		{chunk: 1, chunkID: "0123456789ab", jobID: "job_1", status: "completed", summary: &management.JobSummary{Total: &total, Inserted: &inserted, Failed: &failed}},
+	}
+
+	if err := writeStepSummary(importSummaryMarkdown(results)); err != nil {
+		t.Fatalf("Failed to write step summary: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read step summary: %v", err)
+	}
+
+	expectedRow := "| 1 | 0123456789ab | job_1 | completed | 3 | 2 | 0 | 1 |"
+	if !strings.Contains(string(content), expectedRow) {
+		t.Errorf("Expected step summary to contain %q, got:\n%s", expectedRow, content)
+	}
+
+	totalRow := "| **Total** | | | | 3 | 2 | 0 | 1 |"
+	if !strings.Contains(string(content), totalRow) {
+		t.Errorf("Expected step summary to contain %q, got:\n%s", totalRow, content)
+	}
+}
+
+func TestEscapeAnnotation(t *testing.T) {
+	got := escapeAnnotation("100% failed\nline two")
+	if got != "100%25 failed%0Aline two" {
+		t.Errorf("Unexpected escaped annotation: %s", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"reflect"
+	"time"
+)
+
+// hooksConfig holds shell commands run around the export and import phases.
+// Each hook receives a JSON object describing the phase on stdin.
+type hooksConfig struct {
+	PreExport  string `yaml:"pre_export"`
+	PostExport string `yaml:"post_export"`
+	PreImport  string `yaml:"pre_import"`
+	PostImport string `yaml:"post_import"`
+	OnError    string `yaml:"on_error"`
+}
+
+// command returns the hook configured for phase, e.g. "post_import".
+func (h hooksConfig) command(phase string) string {
+	v := reflect.ValueOf(h)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("yaml") == phase {
+			return v.Field(i).String()
+		}
+	}
+	return ""
+}
+
+type hookRunner struct {
+	hooks     hooksConfig
+	approvals map[string]approvalGate
+	command   string
+}
+
+func loadHooks(command string) *hookRunner {
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	return &hookRunner{hooks: cfg.Hooks, approvals: cfg.Approvals, command: command}
+}
+
+// run executes the hook for phase, if configured, with sh -c.
+func (h *hookRunner) run(phase string, data map[string]interface{}) error {
+	command := h.hooks.command(phase)
+	if command == "" {
+		return nil
+	}
+
+	input := map[string]interface{}{
+		"phase":   phase,
+		"command": h.command,
+		"time":    time.Now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range data {
+		input[key] = value
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s hook input: %w", phase, err)
+	}
+
+	fmt.Fprintf(messageOutput, "Running %s hook...\n", phase)
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = messageOutput
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "AUTH0_TOOLS_HOOK="+phase)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", phase, err)
+	}
+	return nil
+}
+
+// mustRun runs a pre-phase hook and aborts the command if it fails.
+func (h *hookRunner) mustRun(phase string, data map[string]interface{}) {
+	if err := h.run(phase, data); err != nil {
+		h.fatalf("Aborting: %v", err)
+	}
+}
+
+// fatalf runs the on_error hook and exits like log.Fatalf.
+func (h *hookRunner) fatalf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if err := h.run("on_error", map[string]interface{}{"error": message}); err != nil {
+		fmt.Fprintln(messageOutput, err)
+	}
+	log.Fatal(message)
+}
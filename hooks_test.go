@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestHookRunner(t *testing.T) {
+	filename := "hook_input.json"
+	defer os.Remove(filename)
+
+	hooks := &hookRunner{
+		hooks:   hooksConfig{PostImport: `test "$AUTH0_TOOLS_HOOK" = post_import && cat > ` + filename},
+		command: "import",
+	}
+
+	if err := hooks.run("post_import", map[string]interface{}{"chunks": 3}); err != nil {
+		t.Fatalf("Failed to run hook: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read hook input: %v", err)
+	}
+
+	var input map[string]interface{}
+	if err := json.Unmarshal(data, &input); err != nil {
+		t.Fatalf("Failed to parse hook input: %v", err)
+	}
+	if input["phase"] != "post_import" || input["command"] != "import" || input["chunks"] != float64(3) {
+		t.Errorf("Unexpected hook input: %v", input)
+	}
+
+	if err := hooks.run("pre_import", nil); err != nil {
+		t.Errorf("Expected unconfigured hook to be skipped, got %v", err)
+	}
+
+	hooks.hooks.PreImport = "exit 3"
+	if err := hooks.run("pre_import", nil); err == nil {
+		t.Errorf("Expected failing hook to return an error")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// exportedIdentity is an entry of the identities array of an exported user.
+type exportedIdentity struct {
+	provider string
+	userID   string
+}
+
+func (i exportedIdentity) fullUserID() string {
+	return i.provider + "|" + i.userID
+}
+
+// exportedIdentities splits the identities of an exported user into its
+// database identity (if any) and the other identities linked to it.
+func exportedIdentities(user map[string]interface{}) (bool, []exportedIdentity) {
+	identities, _ := user["identities"].([]interface{})
+	hasDatabase := false
+	var linked []exportedIdentity
+
+	for _, item := range identities {
+		identity, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		provider, _ := identity["provider"].(string)
+		userID := fmt.Sprint(identity["user_id"]) // social user IDs may be numbers
+		if provider == "" || identity["user_id"] == nil {
+			continue
+		}
+
+		if provider == "auth0" {
+			hasDatabase = true
+		} else {
+			linked = append(linked, exportedIdentity{provider: provider, userID: userID})
+		}
+	}
+
+	return hasDatabase, linked
+}
+
+// identityLink links a secondary user into a primary database user.
+type identityLink struct {
+	primaryID string
+	identity  exportedIdentity
+}
+
+func hasIdentity(user *management.User, identity exportedIdentity) bool {
+	for _, existing := range user.Identities {
+		if existing.GetProvider() == identity.provider && existing.GetUserID() == identity.userID {
+			return true
+		}
+	}
+	return false
+}
+
+// planIdentityLinks finds, for every exported user that had a database and a
+// social identity linked on the source, the matching database user and the
+// social user on the target. Social users only exist on the target once they
+// have logged in there, so missing ones are counted and can be linked by a
+// later run.
+func planIdentityLinks(ctx context.Context, m *management.Management, matcher *userMatcher, users []map[string]interface{}, interval time.Duration) ([]identityLink, error) {
+	var links []identityLink
+	missing, notLoggedIn := 0, 0
+
+	for _, user := range users {
+		hasDatabase, linked := exportedIdentities(user)
+		if !hasDatabase || len(linked) == 0 {
+			continue
+		}
+
+		key, ok := matcher.key(user)
+		if !ok {
+			continue
+		}
+
+		matches, err := matcher.find(ctx, m, user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s in target tenant: %w", key, apiError(err))
+		}
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+
+		var primary *management.User
+		for _, match := range matches {
+			if len(match.Identities) > 0 && match.Identities[0].GetProvider() == "auth0" {
+				if primary != nil {
+					return nil, fmt.Errorf("%s matches several database users in target tenant", key)
+				}
+				primary = match
+			}
+		}
+		if primary == nil {
+			missing++
+			continue
+		}
+
+		for _, identity := range linked {
+			if hasIdentity(primary, identity) {
+				continue
+			}
+
+			_, err := m.User.Read(ctx, identity.fullUserID())
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+			var mErr management.Error
+			if errors.As(err, &mErr) && mErr.Status() == http.StatusNotFound {
+				notLoggedIn++
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s in target tenant: %w", identity.fullUserID(), apiError(err))
+			}
+
+			links = append(links, identityLink{primaryID: primary.GetID(), identity: identity})
+		}
+	}
+
+	fmt.Fprintf(messageOutput, "%d users not found in target tenant, %d social identities not yet created there.\n", missing, notLoggedIn)
+	return links, nil
+}
+
+func linkIdentities(ctx context.Context, m *management.Management, links []identityLink, interval time.Duration) (int, int) {
+	linked, failed := 0, 0
+
+	for _, link := range links {
+		_, err := m.User.Link(ctx, link.primaryID, &management.UserIdentityLink{
+			Provider: &link.identity.provider,
+			UserID:   &link.identity.userID,
+		})
+		if err != nil {
+			fmt.Fprintf(messageOutput, "Failed to link %s to %s: %v\n", link.identity.fullUserID(), link.primaryID, apiError(err))
+			failed++
+		} else {
+			linked++
+		}
+
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+
+	return linked, failed
+}
+
+func newSyncIdentitiesCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		input  string
+		match  string
+		dryRun bool
+		rate   float64
+	)
+
+	var identitiesCmd = &cobra.Command{
+		Use:   "identities",
+		Short: "Re-link social identities to imported database users, as they were linked on the source",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("link identities")
+			}
+
+			matcher, err := parseMatcher(match)
+			if err != nil {
+				log.Fatalf("Invalid match strategy: %v", err)
+			}
+
+			jsonData, err := readInputFile(input)
+			if err != nil {
+				log.Fatalf("Failed to read the input file: %v", err)
+			}
+
+			users, err := parseUsers(jsonData)
+			if err != nil {
+				log.Fatalf("Failed to parse users: %v", err)
+			}
+
+			var interval time.Duration
+			if rate > 0 {
+				interval = time.Duration(float64(time.Second) / rate)
+			}
+
+			targetClient := clients.targetClient()
+
+			fmt.Fprintf(messageOutput, "Checking linked identities of %d users...\n", len(users))
+			links, err := planIdentityLinks(ctx, targetClient, matcher, users, interval)
+			if err != nil {
+				log.Fatalf("Failed to plan identity links: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d identities need to be linked.\n", len(links))
+			if dryRun || len(links) == 0 {
+				reportResult("sync identities", map[string]interface{}{"pending": len(links), "dry_run": dryRun})
+				return
+			}
+
+			linked, failed := linkIdentities(ctx, targetClient, links, interval)
+			fmt.Fprintf(messageOutput, "Linking finished: %d linked, %d failed.\n", linked, failed)
+			reportResult("sync identities", map[string]interface{}{"linked": linked, "failed": failed})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	identitiesCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Exported source users file, optionally gzipped")
+	identitiesCmd.Flags().StringVar(&match, "match", "email", "How users are matched across tenants: email, username, user_id, metadata:<key> or expr:<query>")
+	identitiesCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report how many identities would be linked")
+	identitiesCmd.Flags().Float64Var(&rate, "rate", 10, "Maximum API requests per second (0 for unlimited)")
+
+	return identitiesCmd
+}
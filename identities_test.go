@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportedIdentities(t *testing.T) {
+	var user map[string]interface{}
+	json.Unmarshal([]byte(`{"identities": [
+		{"provider": "auth0", "user_id": "abc", "connection": "Username-Password-Authentication"},
+		{"provider": "google-oauth2", "user_id": "1234", "isSocial": true},
+		{"provider": "github", "user_id": 5678, "isSocial": true}
+	]}`), &user)
+
+	hasDatabase, linked := exportedIdentities(user)
+	if !hasDatabase {
+		t.Errorf("Expected a database identity")
+	}
+	if len(linked) != 2 || linked[0].fullUserID() != "google-oauth2|1234" || linked[1].fullUserID() != "github|5678" {
+		t.Errorf("Unexpected linked identities: %v", linked)
+	}
+}
+
+func TestPlanIdentityLinks(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/users-by-email"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"user_id": "auth0|abc", "identities": []map[string]interface{}{{"provider": "auth0", "user_id": "abc"}, {"provider": "github", "user_id": "5678"}}},
+				{"user_id": "google-oauth2|1234", "identities": []map[string]interface{}{{"provider": "google-oauth2", "user_id": "1234"}}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/users/google-oauth2|1234"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"user_id": "google-oauth2|1234"})
+		case strings.HasSuffix(r.URL.Path, "/users/twitter|42"):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": 404, "message": "The user does not exist."})
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+
+	var users []map[string]interface{}
+	json.Unmarshal([]byte(`[
+		{"email": "a@example.com", "identities": [
+			{"provider": "auth0", "user_id": "abc"},
+			{"provider": "google-oauth2", "user_id": "1234"},
+			{"provider": "github", "user_id": "5678"},
+			{"provider": "twitter", "user_id": "42"}
+		]},
+		{"email": "b@example.com", "identities": [{"provider": "auth0", "user_id": "def"}]}
+	]`), &users)
+
+	matcher, _ := parseMatcher("email")
+	links, err := planIdentityLinks(context.Background(), m, matcher, users, 0)
+	if err != nil {
+		t.Fatalf("Failed to plan identity links: %v", err)
+	}
+
+	if len(links) != 1 || links[0].primaryID != "auth0|abc" || links[0].identity.fullUserID() != "google-oauth2|1234" {
+		t.Errorf("Expected google-oauth2|1234 to be linked to auth0|abc, got %v", links)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// importModeScripts are the custom database scripts a connection in import
+// mode calls to migrate users on login and on password resets.
+var importModeScripts = []string{"login", "get_user"}
+
+// importModeChanges lists the settings that must change for a database
+// connection to migrate users from a legacy store on login: custom
+// database scripts, and import mode so that migrated users are stored in
+// Auth0.
+func importModeChanges(options *management.ConnectionOptions) []string {
+	var changes []string
+	if !options.GetEnabledDatabaseCustomization() {
+		changes = append(changes, "enabledDatabaseCustomization")
+	}
+	if !options.GetImportMode() {
+		changes = append(changes, "import_mode")
+	}
+	return changes
+}
+
+// missingImportModeScripts returns the custom database scripts import mode
+// needs that the connection doesn't have.
+func missingImportModeScripts(options *management.ConnectionOptions) []string {
+	var missing []string
+	for _, name := range importModeScripts {
+		if options.GetCustomScripts()[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// enableImportMode turns on custom database scripts and import mode on a
+// database connection. Auth0 replaces the options of a connection as a
+// whole, so the current options are sent back with both settings set.
+func enableImportMode(ctx context.Context, m *management.Management, connection *management.Connection) error {
+	options, _ := connection.Options.(*management.ConnectionOptions)
+	if options == nil {
+		options = &management.ConnectionOptions{}
+	}
+	options.EnabledDatabaseCustomization = auth0.Bool(true)
+	options.ImportMode = auth0.Bool(true)
+
+	if err := m.Connection.Update(ctx, connection.GetID(), &management.Connection{Options: options}); err != nil {
+		return fmt.Errorf("failed to update connection %s: %w", connection.GetName(), apiError(err))
+	}
+	return nil
+}
+
+func newSetupCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var setupCmd = &cobra.Command{
+		Use:   "setup",
+		Short: "Prepare the target tenant for a migration",
+	}
+
+	var (
+		connectionID string
+		apply        bool
+	)
+
+	var importModeCmd = &cobra.Command{
+		Use:   "import-mode",
+		Short: "Check, and with --apply enable, the custom database and import mode settings of the target connection for a lazy migration",
+		Run: func(cmd *cobra.Command, args []string) {
+			if connectionID == "" {
+				connectionID = os.Getenv("DESTINATION_CONNECTION_ID")
+			}
+			targetClient := clients.targetClient()
+
+			connection, err := targetClient.Connection.Read(ctx, connectionID)
+			if err != nil {
+				log.Fatalf("Failed to read the target connection: %v", apiError(err))
+			}
+			if connection.GetStrategy() != management.ConnectionStrategyAuth0 {
+				log.Fatalf("Connection %s has strategy %s, but only database connections (strategy auth0) can migrate users on login", connection.GetName(), connection.GetStrategy())
+			}
+			options, _ := connection.Options.(*management.ConnectionOptions)
+			if options == nil {
+				options = &management.ConnectionOptions{}
+			}
+
+			changes := importModeChanges(options)
+			missingScripts := missingImportModeScripts(options)
+			result := map[string]interface{}{"connection": connection.GetName(), "changes": changes, "missing_scripts": missingScripts, "applied": false}
+
+			switch {
+			case len(changes) == 0:
+				fmt.Fprintf(messageOutput, "Connection %s already uses a custom database in import mode.\n", connection.GetName())
+			case !apply:
+				for _, setting := range changes {
+					fmt.Fprintf(messageOutput, "Connection %s: %s is off.\n", connection.GetName(), setting)
+				}
+				fmt.Fprintln(messageOutput, "Re-run with --apply to turn it on.")
+			default:
+				checkWritable("enable import mode")
+				clients.guardTarget("enable import mode")
+				if err := enableImportMode(ctx, targetClient, connection); err != nil {
+					log.Fatalf("Failed to enable import mode: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Turned on %v for connection %s.\n", changes, connection.GetName())
+				result["applied"] = true
+			}
+
+			if len(missingScripts) > 0 {
+				fmt.Fprintf(messageOutput, "Warning: connection %s has no %v script. Users can't log in until the scripts that read them from the legacy store are set; generate custom-db-scripts prints them.\n", connection.GetName(), missingScripts)
+				annotate("warning", "Custom database scripts missing", "", fmt.Errorf("connection %s has no %v script", connection.GetName(), missingScripts))
+			}
+
+			reportResult("setup import-mode", result)
+			if len(changes) > 0 && !apply {
+				os.Exit(1)
+			}
+		},
+	}
+
+	importModeCmd.Flags().StringVar(&connectionID, "connection-id", "", "ID of the database connection to set up (default DESTINATION_CONNECTION_ID)")
+	importModeCmd.Flags().BoolVar(&apply, "apply", false, "Turn on the missing settings; without it, only report them")
+
+	setupCmd.AddCommand(importModeCmd)
+	return setupCmd
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestImportModeChanges(t *testing.T) {
+	options := &management.ConnectionOptions{}
+	if changes := importModeChanges(options); !reflect.DeepEqual(changes, []string{"enabledDatabaseCustomization", "import_mode"}) {
+		t.Fatalf("Unexpected changes %v", changes)
+	}
+	if missing := missingImportModeScripts(options); !reflect.DeepEqual(missing, []string{"login", "get_user"}) {
+		t.Fatalf("Unexpected missing scripts %v", missing)
+	}
+
+	options.EnabledDatabaseCustomization = auth0.Bool(true)
+	options.ImportMode = auth0.Bool(true)
+	options.CustomScripts = &map[string]string{"login": "function login() {}"}
+	if changes := importModeChanges(options); len(changes) != 0 {
+		t.Fatalf("Unexpected changes %v", changes)
+	}
+	if missing := missingImportModeScripts(options); !reflect.DeepEqual(missing, []string{"get_user"}) {
+		t.Fatalf("Unexpected missing scripts %v", missing)
+	}
+}
+
+func TestEnableImportModeKeepsOptions(t *testing.T) {
+	var patched map[string]interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v2/connections/con_1" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&patched)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	connection := &management.Connection{
+		ID:       auth0.String("con_1"),
+		Name:     auth0.String("legacy"),
+		Strategy: auth0.String("auth0"),
+		Options:  &management.ConnectionOptions{RequiresUsername: auth0.Bool(true)},
+	}
+	if err := enableImportMode(context.Background(), m, connection); err != nil {
+		t.Fatalf("Failed to enable import mode: %v", err)
+	}
+
+	options, _ := patched["options"].(map[string]interface{})
+	if options["enabledDatabaseCustomization"] != true || options["import_mode"] != true || options["requires_username"] != true {
+		t.Fatalf("Unexpected options %v", options)
+	}
+	if _, ok := patched["name"]; ok {
+		t.Fatalf("Only the options should be patched, got %v", patched)
+	}
+}
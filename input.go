@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// inputReader is an opened users file with the readers layered on it.
+type inputReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *inputReader) Close() error {
+	var errs []error
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		errs = append(errs, r.closers[i].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openInputFile opens a users file for streaming, decompressing it if it is
+// gzipped. The compression is detected from the gzip magic bytes, not the
+// file name. Encrypted files and migration bundles can't be streamed, they
+// are read into memory and the (bundled) users file is returned.
+func openInputFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	input := &inputReader{closers: []io.Closer{file}}
+
+	reader := bufio.NewReader(file)
+	if header, _ := reader.Peek(len(encryptedFileMagic)); isEncrypted(header) {
+		reader, err = decryptedReader(reader)
+		if err != nil {
+			input.Close()
+			return nil, err
+		}
+	}
+
+	magic, _ := reader.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			input.Close()
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		input.closers = append(input.closers, gzReader)
+		reader = bufio.NewReader(gzReader)
+	}
+	input.Reader = reader
+
+	if magic, _ := reader.Peek(4); isBundle(magic) {
+		defer input.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input file: %w", err)
+		}
+		b, err := openBundle(data)
+		if err != nil {
+			return nil, err
+		}
+		users, err := b.users()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(users)), nil
+	}
+
+	return input, nil
+}
+
+// readInputFile reads a users file into memory, see openInputFile.
+func readInputFile(filename string) ([]byte, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return data, nil
+}
+
+// userRecord is a user read from an input file, with the 1-based line and
+// byte offset its record starts at.
+type userRecord struct {
+	line   int
+	offset int64
+	user   map[string]interface{}
+}
+
+// validationError reports a problem with the record, including the user's
+// email if the record has one.
+func (r userRecord) validationError(err error) *ValidationError {
+	email, _ := r.user["email"].(string)
+	return &ValidationError{Line: r.line, Offset: r.offset, Email: email, Err: err}
+}
+
+// decodeUsers reads users from a JSON array, newline-delimited JSON,
+// concatenated JSON objects or an Auth0 CSV export, see readUsers.
+func decodeUsers(data []byte) ([]userRecord, error) {
+	var records []userRecord
+	err := readUsers(bytes.NewReader(data), func(record userRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// countFileUsers counts the users of a users file, streaming it so that large
+// exports aren't loaded into memory.
+func countFileUsers(filename string) (int, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+
+	count := 0
+	err = readUsers(input, func(userRecord) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// readUsers streams users from a JSON array, newline-delimited JSON,
+// concatenated JSON objects or an Auth0 CSV export, detecting the format from
+// the first character, and calls fn with each record in order.
+// Records are decoded one at a time, so parse errors point at the offending
+// record rather than the whole file, and only the current record is held in
+// memory.
+func readUsers(r io.Reader, fn func(userRecord) error) error {
+	input := bufio.NewReader(r)
+	first := firstByte(input)
+	if first != 0 && first != '[' && first != '{' {
+		return readCSVUsers(input, fn)
+	}
+	isArray := first == '['
+
+	records := &recordReader{r: input, line: 1}
+	decoder := json.NewDecoder(records)
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", records.decodeError(0, err))
+		}
+	}
+
+	for {
+		decoder.More() // buffers the start of the next record
+		start := records.recordStart(decoder.InputOffset())
+		if isArray && !decoder.More() {
+			break
+		}
+
+		var user map[string]interface{}
+		err := decoder.Decode(&user)
+		if errors.Is(err, io.EOF) && !isArray {
+			break
+		}
+		if err == nil && user == nil {
+			err = errors.New("expected a JSON object")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", records.decodeError(start, err))
+		}
+		if err := fn(userRecord{line: records.lineOf(start), offset: start, user: user}); err != nil {
+			return err
+		}
+		records.discard(decoder.InputOffset())
+	}
+
+	if isArray {
+		start := records.recordStart(decoder.InputOffset())
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("failed to parse JSON: %w", records.decodeError(start, err))
+		}
+		if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse JSON: %w", records.decodeError(start, errors.New("unexpected data after the array")))
+		}
+	}
+
+	return nil
+}
+
+// firstByte returns the first non-whitespace byte of the input without
+// consuming it, or 0 if there is none.
+func firstByte(r *bufio.Reader) byte {
+	for n := 1; n <= r.Size(); n++ {
+		data, _ := r.Peek(n)
+		if len(data) < n {
+			return 0
+		}
+		if c := data[n-1]; bytes.IndexByte([]byte(" \t\r\n"), c) < 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+var emailPattern = regexp.MustCompile(`"email"\s*:\s*"([^"]*)"`)
+
+// recordReader passes the input to a JSON decoder and keeps the bytes read
+// since the end of the last decoded record, so the current record can be
+// located and quoted in errors.
+type recordReader struct {
+	r      io.Reader
+	buf    []byte // input from offset on
+	offset int64  // input offset of buf[0]
+	line   int    // line number at offset
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// discard drops the input before offset, once no record starts before it.
+func (r *recordReader) discard(offset int64) {
+	n := int(min(offset-r.offset, int64(len(r.buf))))
+	if n <= 0 {
+		return
+	}
+	r.line += bytes.Count(r.buf[:n], []byte("\n"))
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.offset += int64(n)
+}
+
+func (r *recordReader) end() int64 {
+	return r.offset + int64(len(r.buf))
+}
+
+// recordStart skips the whitespace and commas between records.
+func (r *recordReader) recordStart(offset int64) int64 {
+	for offset < r.end() && bytes.IndexByte([]byte(" \t\r\n,"), r.buf[offset-r.offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+func (r *recordReader) lineOf(offset int64) int {
+	return r.line + bytes.Count(r.buf[:offset-r.offset], []byte("\n"))
+}
+
+// decodeError locates a decoding error in the record starting at start. For
+// syntax errors the offset points at the invalid character, and the email is
+// taken from the raw record text if present.
+func (r *recordReader) decodeError(start int64, err error) *ValidationError {
+	offset := start
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr) && syntaxErr.Offset > r.offset && syntaxErr.Offset < r.end():
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		offset = r.end()
+	}
+
+	if offset < start {
+		start = offset
+	}
+
+	end := int(offset - r.offset)
+	if i := bytes.IndexByte(r.buf[end:], '\n'); i >= 0 {
+		end += i
+	} else {
+		end = len(r.buf)
+	}
+
+	validationErr := &ValidationError{Line: r.lineOf(offset), Offset: offset, Err: err}
+	if match := emailPattern.FindSubmatch(r.buf[start-r.offset : end]); match != nil {
+		validationErr.Email = string(match[1])
+	}
+	return validationErr
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeUsers(t *testing.T) {
+	inputs := map[string]string{
+		"ndjson":       "{\"user_id\": \"1\"}\n\n{\"user_id\": \"2\"}\n",
+		"array":        "[\n  {\"user_id\": \"1\"},\n  {\"user_id\": \"2\"}\n]\n",
+		"concatenated": "{\n  \"user_id\": \"1\"\n}\n{\n  \"user_id\": \"2\"\n}",
+	}
+	expectedLines := map[string][]int{
+		"ndjson":       {1, 3},
+		"array":        {2, 3},
+		"concatenated": {1, 4},
+	}
+
+	for name, input := range inputs {
+		records, err := decodeUsers([]byte(input))
+		if err != nil {
+			t.Fatalf("%s: failed to decode users: %v", name, err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("%s: expected 2 users, got %d", name, len(records))
+		}
+		for i, record := range records {
+			if record.user["user_id"] != []string{"1", "2"}[i] || record.line != expectedLines[name][i] {
+				t.Errorf("%s: unexpected record %d: line %d, %v", name, i, record.line, record.user)
+			}
+		}
+	}
+}
+
+func TestDecodeUsersInvalid(t *testing.T) {
+	inputs := map[string]int{
+		"[\n{\"user_id\": \"1\"},\n42\n]":  3,
+		"[\n{\"user_id\": \"1\"}\n":        3,
+		"{\"user_id\": \"1\"}\nnull\n":     2,
+		"[{\"user_id\": \"1\"}]\n{}":       1,
+		"{\"user_id\": \"1\"}\n{not json}": 2,
+	}
+
+	for input, line := range inputs {
+		_, err := decodeUsers([]byte(input))
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Line != line {
+			t.Errorf("Expected a validation error on line %d for %q, got %v", line, input, err)
+		}
+	}
+}
+
+func TestReadInputFile(t *testing.T) {
+	content := "{\"user_id\": \"1\"}\n"
+
+	plain := "input_plain.json"
+	if err := os.WriteFile(plain, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	defer os.Remove(plain)
+
+	compressed := "input_compressed.json"
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	gzWriter.Write([]byte(content))
+	gzWriter.Close()
+	if err := os.WriteFile(compressed, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	defer os.Remove(compressed)
+
+	for _, filename := range []string{plain, compressed} {
+		data, err := readInputFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", filename, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %q from %s, got %q", content, filename, data)
+		}
+	}
+}
+
+func TestCountFileUsers(t *testing.T) {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(gzWriter, "{\"user_id\": \"%d\"}\n", i)
+	}
+	gzWriter.Close()
+	filename := filepath.Join(t.TempDir(), "exported_users.json.gz")
+	os.WriteFile(filename, buf.Bytes(), 0644)
+
+	if count, err := countFileUsers(filename); err != nil || count != 3 {
+		t.Errorf("Expected 3 users, got %d %v", count, err)
+	}
+
+	os.WriteFile(filename, []byte("{\"user_id\": \"1\"}\n{"), 0644)
+	if _, err := countFileUsers(filename); err == nil {
+		t.Errorf("Expected an error for a truncated file")
+	}
+}
+
+func TestDecodeUsersErrorLocation(t *testing.T) {
+	data := []byte("{\"email\": \"a@example.com\"}\n{\"email\": \"b@example.com\", \"name\": oops}\n")
+
+	_, err := decodeUsers(data)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if validationErr.Line != 2 || validationErr.Offset != 62 || validationErr.Email != "b@example.com" {
+		t.Errorf("Expected line 2, byte 62, b@example.com, got line %d, byte %d, %q", validationErr.Line, validationErr.Offset, validationErr.Email)
+	}
+
+	expected := "line 2, byte 62 (b@example.com): invalid character 'o' looking for beginning of value"
+	if validationErr.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, validationErr.Error())
+	}
+}
+
+func TestReadUsersStreamsLargeInput(t *testing.T) {
+	var buf bytes.Buffer
+	for i := 1; i <= 2000; i++ {
+		if i == 1500 {
+			fmt.Fprintf(&buf, "{\"email\": \"user%d@example.com\", \"name\": oops}\n", i)
+			continue
+		}
+		fmt.Fprintf(&buf, "{\"email\": \"user%d@example.com\"}\n", i)
+	}
+	data := buf.Bytes()
+
+	read := 0
+	err := readUsers(bytes.NewReader(data), func(record userRecord) error {
+		read++
+		if record.line != read {
+			t.Fatalf("Expected record %d on line %d, got line %d", read, read, record.line)
+		}
+		return nil
+	})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	offset := int64(bytes.Index(data, []byte("oops")))
+	if read != 1499 || validationErr.Line != 1500 || validationErr.Offset != offset || validationErr.Email != "user1500@example.com" {
+		t.Errorf("Expected 1499 users and an error on line 1500, byte %d, user1500@example.com, got %d users and line %d, byte %d, %q",
+			offset, read, validationErr.Line, validationErr.Offset, validationErr.Email)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// defaultPauseFile is the control file that pauses a running import.
+const defaultPauseFile = "auth0-tools.pause"
+
+type pendingImportJob struct {
+	id      string
+	chunk   int
+	chunkID string
+	hash    string
+	users   int
+	started time.Time
+}
+
+// chunkResult records how the import job of a chunk finished.
+type chunkResult struct {
+	chunk   int
+	chunkID string
+	jobID   string
+	status  string
+	summary *management.JobSummary
+	reasons []string
+}
+
+// importCounts holds the summary counters of one or more import jobs.
+type importCounts struct {
+	Total    int `json:"total"`
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Failed   int `json:"failed"`
+}
+
+func (c *importCounts) add(summary *management.JobSummary) {
+	c.Total += summary.GetTotal()
+	c.Inserted += summary.GetInserted()
+	c.Updated += summary.GetUpdated()
+	c.Failed += summary.GetFailed()
+}
+
+// importTotals adds up the job summaries of all chunks.
+func importTotals(results []chunkResult) importCounts {
+	var totals importCounts
+	for _, result := range results {
+		totals.add(result.summary)
+	}
+	return totals
+}
+
+// importScheduler keeps at most maxPending import jobs pending on the tenant.
+// Auth0 rejects new import jobs while too many are pending, so submissions
+// are held until a tracked job completes. A job still pending after
+// jobTimeout (if set) makes the scheduler give up on the import. While
+// pauseFile exists, no further jobs are submitted. With a state, the job of
+// every chunk is recorded in stateFile and chunks it shows as imported are
+// skipped. With a ledger, chunks imported to destination by any earlier run
+// are skipped as well. The progress of the import is shown over chunks, the
+// number of chunks of the whole import.
+type importScheduler struct {
+	m           *management.Management
+	maxPending  int
+	poller      poller
+	jobTimeout  time.Duration
+	pauseFile   string
+	state       *importState
+	stateFile   string
+	pending     []pendingImportJob
+	results     []chunkResult
+	skipped     int
+	jobErrors   []management.JobError
+	chunks      int
+	progress    *progress
+	ledger      *chunkLedger
+	destination string
+}
+
+// jobSlotPoller backs off while the tenant refuses new import jobs because
+// too many are pending, for example the jobs of another import.
+var jobSlotPoller = poller{initial: 5 * time.Second, max: 2 * time.Minute, jitter: 0.2}
+
+func newImportScheduler(m *management.Management, maxPending int) *importScheduler {
+	return &importScheduler{m: m, maxPending: maxPending, poller: newPoller()}
+}
+
+// refresh reads the status of every pending job and drops the finished ones.
+func (s *importScheduler) refresh(ctx context.Context) error {
+	var stillPending []pendingImportJob
+	var states []string
+	percentDone := 0
+
+	for _, job := range s.pending {
+		status, err := s.m.Job.Read(ctx, job.id)
+		if err != nil {
+			return fmt.Errorf("failed to read job status: %w", apiError(err))
+		}
+
+		switch status.GetStatus() {
+		case "completed", "failed":
+			s.results = append(s.results, chunkResult{chunk: job.chunk, chunkID: job.chunkID, jobID: job.id, status: status.GetStatus(), summary: status.Summary})
+			if err := s.record(job.chunk, job.chunkID, job.id, status.GetStatus()); err != nil {
+				return err
+			}
+		}
+
+		switch status.GetStatus() {
+		case "completed":
+			summary := status.GetSummary()
+			progressPrintf("Chunk %d imported successfully (job %s): %d total, %d inserted, %d updated, %d failed.\n",
+				job.chunk, job.id, summary.GetTotal(), summary.GetInserted(), summary.GetUpdated(), summary.GetFailed())
+			if summary.GetFailed() > 0 {
+				s.results[len(s.results)-1].reasons = s.readJobErrors(ctx, job.id)
+			} else if err := s.ledger.record(s.destination, job.hash, job.id, job.users); err != nil {
+				return err
+			}
+			emitEvent("chunk_completed", map[string]interface{}{"chunk": job.chunk, "chunk_id": job.chunkID, "job_id": job.id, "users": summary.GetTotal(), "summary": status.Summary, "duration": seconds(time.Since(job.started))})
+		case "failed":
+			jobFailed := &JobFailedError{JobID: job.id, Reasons: s.readJobErrors(ctx, job.id)}
+			s.results[len(s.results)-1].reasons = jobFailed.Reasons
+			emitEvent("job_failed", map[string]interface{}{"chunk": job.chunk, "chunk_id": job.chunkID, "job_id": job.id, "reasons": jobFailed.Reasons, "duration": seconds(time.Since(job.started))})
+			return fmt.Errorf("import of chunk %d failed: %w", job.chunk, jobFailed)
+		default:
+			if waited := time.Since(job.started); s.jobTimeout > 0 && waited > s.jobTimeout {
+				return fmt.Errorf("import of chunk %d timed out: %w", job.chunk, &JobTimeoutError{JobID: job.id, Status: status.GetStatus(), Waited: waited.Round(time.Second)})
+			}
+			stillPending = append(stillPending, job)
+			percentDone += status.GetPercentageDone()
+			states = append(states, fmt.Sprintf("chunk %d: %s %s %d%%", job.chunk, job.id, status.GetStatus(), status.GetPercentageDone()))
+		}
+	}
+
+	s.pending = stillPending
+	if len(states) > 0 {
+		if s.progress == nil {
+			s.progress = newProgress("Importing", int64(s.chunks)*100, false)
+		}
+		done := len(s.results) + s.skipped
+		status := fmt.Sprintf("%d/%d chunks imported, queue %d/%d pending [%s]", done, s.chunks, len(s.pending), s.maxPending, strings.Join(states, ", "))
+		s.progress.update(int64(done*100+percentDone), status)
+	}
+	return nil
+}
+
+// readJobErrors reads which users of a job failed and why, prints the
+// reasons and keeps the users for writeFailedUsers.
+func (s *importScheduler) readJobErrors(ctx context.Context, jobID string) []string {
+	jobErrors, err := s.m.Job.ReadErrors(ctx, jobID)
+	if err != nil {
+		progressPrintf("Failed to read the errors of job %s: %v\n", jobID, apiError(err))
+		return nil
+	}
+	s.jobErrors = append(s.jobErrors, jobErrors...)
+
+	reasons := jobErrorReasons(jobErrors)
+	for _, reason := range reasons {
+		progressPrintf("  %s\n", reason)
+	}
+	return reasons
+}
+
+// writeFailedUsers writes the users that failed to import as
+// newline-delimited JSON, so the file can be fixed and imported again. It is
+// encrypted if the PII policy requires it for the fields of the users.
+func (s *importScheduler) writeFailedUsers(filename string) (int, error) {
+	var buf bytes.Buffer
+	var records []userRecord
+	encoder := json.NewEncoder(&buf)
+	for _, jobError := range s.jobErrors {
+		if err := encoder.Encode(jobError.User); err != nil {
+			return 0, fmt.Errorf("failed to encode failed user: %w", err)
+		}
+		records = append(records, userRecord{user: jobError.User})
+	}
+
+	data := buf.Bytes()
+	if pii.requiresEncryption(userFields(records)) {
+		key, err := encryptionKey()
+		if err != nil {
+			return 0, err
+		}
+		if data, err = encryptData(data, key); err != nil {
+			return 0, fmt.Errorf("failed to encrypt failed users: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		return 0, fmt.Errorf("failed to write failed users: %w", err)
+	}
+	return len(s.jobErrors), nil
+}
+
+func (s *importScheduler) waitUntil(ctx context.Context, maxPending int) error {
+	return s.poller.poll(ctx, func(ctx context.Context) (bool, error) {
+		if err := s.refresh(ctx); err != nil {
+			return false, err
+		}
+		return len(s.pending) <= maxPending, nil
+	})
+}
+
+// chunkID identifies a chunk by its content, so re-running with the same
+// input yields the same IDs.
+func chunkID(users []map[string]interface{}) string {
+	return chunkHash(users)[:12]
+}
+
+// chunkHash is the SHA-256 of the content of a chunk, by which the chunk
+// ledger recognizes chunks imported by earlier runs.
+func chunkHash(users []map[string]interface{}) string {
+	data, _ := json.Marshal(users) // map keys are marshalled in sorted order
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *importScheduler) paused() bool {
+	if s.pauseFile == "" {
+		return false
+	}
+	_, err := os.Stat(s.pauseFile)
+	return err == nil
+}
+
+// waitWhilePaused lets the pending jobs finish and then holds until the
+// pause file is removed, so the tenant is quiet while paused.
+func (s *importScheduler) waitWhilePaused(ctx context.Context, chunk int) error {
+	if !s.paused() {
+		return nil
+	}
+
+	progressPrintf("Pause requested, finishing %d pending jobs before chunk %d...\n", len(s.pending), chunk)
+	if err := s.drain(ctx); err != nil {
+		return err
+	}
+
+	progressPrintf("Import paused before chunk %d. Remove %s to continue.\n", chunk, s.pauseFile)
+	emitEvent("paused", map[string]interface{}{"chunk": chunk})
+	pausePoller := poller{initial: time.Second, max: 5 * time.Second}
+	if err := pausePoller.poll(ctx, func(ctx context.Context) (bool, error) {
+		return !s.paused(), nil
+	}); err != nil {
+		return err
+	}
+
+	progressPrintf("Import resumed at chunk %d.\n", chunk)
+	emitEvent("resumed", map[string]interface{}{"chunk": chunk})
+	return nil
+}
+
+// submit waits for a free slot and then creates the import job for a chunk.
+func (s *importScheduler) submit(ctx context.Context, chunk int, users []map[string]interface{}) error {
+	hash := chunkHash(users)
+	id := hash[:12]
+	if done, err := s.resume(ctx, chunk, id, hash, len(users)); err != nil || done {
+		return err
+	}
+	if entry := s.ledger.imported(s.destination, hash); entry != nil {
+		progressPrintf("Skipping chunk %d (%s): the chunk ledger shows it was imported to %s by job %s at %s. Pass --force to import it again.\n", chunk, id, s.destination, entry.JobID, entry.ImportedAt.Format(time.RFC3339))
+		emitEvent("chunk_skipped", map[string]interface{}{"chunk": chunk, "chunk_id": id, "job_id": entry.JobID, "reason": "ledger"})
+		s.skipped++
+		return s.record(chunk, id, entry.JobID, "completed")
+	}
+
+	if err := s.waitWhilePaused(ctx, chunk); err != nil {
+		return err
+	}
+	if err := s.waitUntil(ctx, s.maxPending-1); err != nil {
+		return err
+	}
+
+	jobID, err := s.createJob(ctx, chunk, users)
+	if err != nil {
+		return err
+	}
+
+	progressPrintf("Import job %s started for chunk %d (%s).\n", jobID, chunk, id)
+	emitEvent("chunk_started", map[string]interface{}{"chunk": chunk, "chunk_id": id, "job_id": jobID, "users": len(users)})
+	s.pending = append(s.pending, pendingImportJob{id: jobID, chunk: chunk, chunkID: id, hash: hash, users: len(users), started: time.Now()})
+	return s.record(chunk, id, jobID, "pending")
+}
+
+// createJob creates the import job for a chunk. While the tenant has too
+// many pending jobs, it waits for a job slot with backoff, up to jobTimeout
+// if set, instead of failing the chunk.
+func (s *importScheduler) createJob(ctx context.Context, chunk int, users []map[string]interface{}) (string, error) {
+	var jobID string
+	waitStarted := time.Time{}
+
+	slotPoller := jobSlotPoller
+	slotPoller.maxWait = s.jobTimeout
+	err := slotPoller.poll(ctx, func(ctx context.Context) (bool, error) {
+		id, err := submitImportJob(ctx, s.m, users)
+		if err == nil || !isJobQuotaError(err) {
+			jobID = id
+			return true, err
+		}
+
+		if waitStarted.IsZero() {
+			waitStarted = time.Now()
+			progressPrintf("Waiting for job slot: the tenant has too many pending jobs, chunk %d is retried with backoff.\n", chunk)
+		}
+		emitEvent("waiting_for_job_slot", map[string]interface{}{"chunk": chunk, "pending": len(s.pending), "waited": seconds(time.Since(waitStarted))})
+
+		// Jobs of this import finishing free slots as well.
+		if err := s.refresh(ctx); err != nil {
+			return false, err
+		}
+		if s.progress != nil {
+			s.progress.update(s.progress.current, fmt.Sprintf("Waiting for job slot for chunk %d (%s)", chunk, time.Since(waitStarted).Round(time.Second)))
+		}
+		return false, nil
+	})
+	if errors.Is(err, errPollTimeout) {
+		return "", fmt.Errorf("no job slot freed up for chunk %d within %s: the tenant still has too many pending jobs", chunk, s.jobTimeout)
+	}
+	if err == nil && !waitStarted.IsZero() {
+		progressPrintf("Job slot free after %s, chunk %d submitted.\n", time.Since(waitStarted).Round(time.Second), chunk)
+	}
+	return jobID, err
+}
+
+// drain waits for all pending jobs to finish.
+func (s *importScheduler) drain(ctx context.Context) error {
+	err := s.waitUntil(ctx, 0)
+	if s.progress != nil {
+		s.progress.done()
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+func newTestManagement(t *testing.T, handler http.Handler) *management.Management {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	m, err := management.New(strings.TrimPrefix(server.URL, "http://"), management.WithInsecure(), management.WithNoRetries())
+	if err != nil {
+		t.Fatalf("Failed to create management client: %v", err)
+	}
+	return m
+}
+
+func TestImportSchedulerHoldsSubmissions(t *testing.T) {
+	var mu sync.Mutex
+	submitted := 0
+	reads := map[string]int{}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			submitted++
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": fmt.Sprintf("job_%d", submitted), "status": "pending"})
+			return
+		}
+
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		reads[id]++
+		status := "pending"
+		if reads[id] > 1 {
+			status = "completed"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": status})
+	}))
+
+	scheduler := newImportScheduler(m, 1)
+	scheduler.poller = poller{}
+
+	ctx := context.Background()
+	users := []map[string]interface{}{{"email": "user1@example.com"}}
+
+	if err := scheduler.submit(ctx, 1, users); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+	if err := scheduler.submit(ctx, 2, users); err != nil {
+		t.Fatalf("Failed to submit chunk 2: %v", err)
+	}
+
+	if reads["job_1"] < 2 {
+		t.Errorf("Expected the second submission to wait for job_1 to complete, read it %d times", reads["job_1"])
+	}
+
+	if err := scheduler.drain(ctx); err != nil {
+		t.Fatalf("Failed to drain scheduler: %v", err)
+	}
+	if submitted != 2 || len(scheduler.pending) != 0 {
+		t.Errorf("Expected 2 submitted and 0 pending jobs, got %d and %d", submitted, len(scheduler.pending))
+	}
+}
+
+func TestImportSchedulerJobTimeout(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "processing"})
+	}))
+
+	scheduler := newImportScheduler(m, 2)
+	scheduler.poller = poller{}
+	scheduler.jobTimeout = time.Millisecond
+
+	ctx := context.Background()
+	if err := scheduler.submit(ctx, 1, []map[string]interface{}{{"email": "user1@example.com"}}); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	err := scheduler.drain(ctx)
+
+	var timeoutErr *JobTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrJobTimeout) {
+		t.Fatalf("Expected a job timeout error, got %v", err)
+	}
+	if timeoutErr.JobID != "job_1" || timeoutErr.Status != "processing" {
+		t.Errorf("Expected job_1 still processing, got %s %s", timeoutErr.JobID, timeoutErr.Status)
+	}
+	if len(scheduler.pending) != 1 {
+		t.Errorf("Expected the timed out job to stay pending, got %d pending jobs", len(scheduler.pending))
+	}
+}
+
+func TestImportTotals(t *testing.T) {
+	total, inserted, updated, failed := 3, 2, 1, 0
+	results := []chunkResult{
+		{chunk: 1, summary: &management.JobSummary{Total: &total, Inserted: &inserted, Updated: &updated, Failed: &failed}},
+		{chunk: 2, summary: &management.JobSummary{Total: &total, Inserted: &total}},
+		{chunk: 3},
+	}
+
+	totals := importTotals(results)
+	if totals != (importCounts{Total: 6, Inserted: 5, Updated: 1}) {
+		t.Errorf("Unexpected totals: %+v", totals)
+	}
+}
+
+func TestImportSchedulerPause(t *testing.T) {
+	var mu sync.Mutex
+	pauseFile := filepath.Join(t.TempDir(), "pause")
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "pending"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "completed"})
+	}))
+
+	scheduler := newImportScheduler(m, 2)
+	scheduler.poller = poller{}
+	scheduler.pauseFile = pauseFile
+
+	ctx := context.Background()
+	users := []map[string]interface{}{{"email": "user1@example.com"}}
+	if err := scheduler.submit(ctx, 1, users); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+
+	os.WriteFile(pauseFile, nil, 0644)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		os.Remove(pauseFile)
+	}()
+
+	start := time.Now()
+	if err := scheduler.waitWhilePaused(ctx, 2); err != nil {
+		t.Fatalf("Failed to wait while paused: %v", err)
+	}
+
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Expected the scheduler to hold until the pause file was removed")
+	}
+	if len(scheduler.pending) != 0 {
+		t.Errorf("Expected pending jobs to finish while paused, %d still pending", len(scheduler.pending))
+	}
+}
+
+func TestImportSchedulerFailedUsers(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "pending"})
+		case strings.HasSuffix(r.URL.Path, "/errors"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"user":   map[string]interface{}{"email": "dup@example.com", "user_id": "auth0|2"},
+				"errors": []map[string]interface{}{{"code": "DUPLICATED_USER", "message": "The user already exists."}},
+			}})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "completed", "summary": map[string]int{"total": 2, "inserted": 1, "failed": 1}})
+		}
+	}))
+
+	scheduler := newImportScheduler(m, 1)
+	scheduler.poller = poller{}
+
+	ctx := context.Background()
+	if err := scheduler.submit(ctx, 1, []map[string]interface{}{{"email": "ok@example.com"}, {"email": "dup@example.com"}}); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+	if err := scheduler.drain(ctx); err != nil {
+		t.Fatalf("Failed to drain scheduler: %v", err)
+	}
+
+	reasons := scheduler.results[0].reasons
+	if len(reasons) != 1 || reasons[0] != "dup@example.com: DUPLICATED_USER The user already exists." {
+		t.Errorf("Expected the failed user with its reason, got %v", reasons)
+	}
+
+	filename := filepath.Join(t.TempDir(), "failed_users.json")
+	count, err := scheduler.writeFailedUsers(filename)
+	if err != nil || count != 1 {
+		t.Fatalf("Failed to write failed users: %d %v", count, err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read failed users: %v", err)
+	}
+	records, err := decodeUsers(data)
+	if err != nil || len(records) != 1 || records[0].user["user_id"] != "auth0|2" {
+		t.Errorf("Expected the failed user to be importable again, got %v %v", records, err)
+	}
+}
+
+func TestImportSchedulerWaitsForJobSlot(t *testing.T) {
+	defer func(p poller) { jobSlotPoller = p }(jobSlotPoller)
+	jobSlotPoller = poller{}
+
+	var mu sync.Mutex
+	attempts := 0
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"statusCode":429,"error":"Too Many Requests","message":"There are too many pending jobs. Please try again later."}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "pending"})
+	}))
+
+	scheduler := newImportScheduler(m, 2)
+	scheduler.poller = poller{}
+	users := []map[string]interface{}{{"email": "user1@example.com"}}
+	if err := scheduler.submit(context.Background(), 1, users); err != nil {
+		t.Fatalf("Failed to submit chunk: %v", err)
+	}
+	if attempts != 3 || len(scheduler.pending) != 1 || scheduler.pending[0].id != "job_1" {
+		t.Errorf("Expected the job to be created on the third attempt, got %d attempts and %+v", attempts, scheduler.pending)
+	}
+
+	// Other errors still fail the chunk.
+	m = newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"statusCode":400,"error":"Bad Request","message":"Invalid connection"}`))
+	}))
+	scheduler = newImportScheduler(m, 2)
+	if err := scheduler.submit(context.Background(), 1, users); err == nil || isJobQuotaError(err) {
+		t.Errorf("Expected the chunk to fail, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonPatchOp is a single RFC 6902 JSON Patch operation.
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// jsonPatch returns the RFC 6902 operations turning from into to. Objects
+// are diffed key by key; arrays and scalars that differ are replaced whole.
+func jsonPatch(from, to interface{}) []jsonPatchOp {
+	return appendJSONPatch(nil, "", from, to)
+}
+
+func appendJSONPatch(ops []jsonPatchOp, path string, from, to interface{}) []jsonPatchOp {
+	if reflect.DeepEqual(from, to) {
+		return ops
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if !fromIsMap || !toIsMap {
+		return append(ops, jsonPatchOp{Op: "replace", Path: path, Value: to})
+	}
+
+	keys := map[string]bool{}
+	for key := range fromMap {
+		keys[key] = true
+	}
+	for key := range toMap {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		childPath := path + "/" + escapeJSONPointer(key)
+		fromValue, inFrom := fromMap[key]
+		toValue, inTo := toMap[key]
+
+		switch {
+		case !inTo:
+			ops = append(ops, jsonPatchOp{Op: "remove", Path: childPath})
+		case !inFrom:
+			ops = append(ops, jsonPatchOp{Op: "add", Path: childPath, Value: toValue})
+		default:
+			ops = appendJSONPatch(ops, childPath, fromValue, toValue)
+		}
+	}
+
+	return ops
+}
+
+// mergePatch returns the RFC 7396 JSON merge patch turning from into to.
+// Removed keys are set to null.
+func mergePatch(from, to interface{}) interface{} {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if !fromIsMap || !toIsMap {
+		return to
+	}
+
+	patch := map[string]interface{}{}
+	for key, toValue := range toMap {
+		fromValue, ok := fromMap[key]
+		if !ok {
+			patch[key] = toValue
+		} else if !reflect.DeepEqual(fromValue, toValue) {
+			patch[key] = mergePatch(fromValue, toValue)
+		}
+	}
+	for key := range fromMap {
+		if _, ok := toMap[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
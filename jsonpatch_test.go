@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPatch(t *testing.T) {
+	var from, to map[string]interface{}
+	json.Unmarshal([]byte(`{"name": "app", "callbacks": ["https://a"], "jwt_configuration": {"alg": "RS256", "lifetime_in_seconds": 36000}, "legacy": true}`), &from)
+	json.Unmarshal([]byte(`{"name": "app", "callbacks": ["https://b"], "jwt_configuration": {"alg": "RS256", "lifetime_in_seconds": 600}, "a/b": 1}`), &to)
+
+	expected := []jsonPatchOp{
+		{Op: "add", Path: "/a~1b", Value: float64(1)},
+		{Op: "replace", Path: "/callbacks", Value: []interface{}{"https://b"}},
+		{Op: "replace", Path: "/jwt_configuration/lifetime_in_seconds", Value: float64(600)},
+		{Op: "remove", Path: "/legacy"},
+	}
+
+	if ops := jsonPatch(from, to); !reflect.DeepEqual(ops, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, ops)
+	}
+
+	expectedMerge := map[string]interface{}{
+		"a/b":               float64(1),
+		"callbacks":         []interface{}{"https://b"},
+		"jwt_configuration": map[string]interface{}{"lifetime_in_seconds": float64(600)},
+		"legacy":            nil,
+	}
+
+	if patch := mergePatch(from, to); !reflect.DeepEqual(patch, expectedMerge) {
+		t.Errorf("Expected %v, got %v", expectedMerge, patch)
+	}
+}
+
+func TestDiffResources(t *testing.T) {
+	source := map[string]map[string]interface{}{
+		"app-a": {"name": "app-a", "app_type": "spa"},
+		"app-b": {"name": "app-b", "app_type": "spa"},
+	}
+	target := map[string]map[string]interface{}{
+		"app-b": {"name": "app-b", "app_type": "regular_web"},
+		"app-c": {"name": "app-c"},
+	}
+
+	diffs := diffResources("clients", source, target)
+
+	var actions []string
+	for _, d := range diffs {
+		actions = append(actions, d.action+" "+d.resource())
+	}
+
+	expected := []string{"create clients/app-a", "update clients/app-b", "delete clients/app-c"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("Expected %v, got %v", expected, actions)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultChunkLedgerFile records the chunks imported by every run.
+const defaultChunkLedgerFile = ".auth0-chunk-ledger.json"
+
+type chunkLedgerEntry struct {
+	JobID      string    `json:"job_id"`
+	Users      int       `json:"users"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// chunkLedger records, per destination, the content hash of every chunk an
+// import job imported without failed users. Unlike the state file it is
+// kept across runs with different inputs, so a chunk imported by any earlier
+// run isn't submitted again.
+type chunkLedger struct {
+	filename     string
+	Destinations map[string]map[string]*chunkLedgerEntry `json:"destinations"`
+
+	// reimport makes every chunk look new, for --force, while chunks are
+	// still recorded.
+	reimport bool
+}
+
+// loadChunkLedger reads a ledger file, or starts a new one if it doesn't
+// exist. An empty filename disables the ledger and returns nil.
+func loadChunkLedger(filename string) (*chunkLedger, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	ledger := &chunkLedger{filename: filename, Destinations: map[string]map[string]*chunkLedgerEntry{}}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk ledger: %w", err)
+	}
+	if ledger.Destinations == nil {
+		ledger.Destinations = map[string]map[string]*chunkLedgerEntry{}
+	}
+	return ledger, nil
+}
+
+// imported returns how a chunk was imported to a destination, or nil if it
+// wasn't.
+func (l *chunkLedger) imported(destination, hash string) *chunkLedgerEntry {
+	if l == nil || l.reimport {
+		return nil
+	}
+	return l.Destinations[destination][hash]
+}
+
+// record adds an imported chunk to the ledger and writes the ledger file.
+func (l *chunkLedger) record(destination, hash, jobID string, users int) error {
+	if l == nil {
+		return nil
+	}
+	if l.Destinations[destination] == nil {
+		l.Destinations[destination] = map[string]*chunkLedgerEntry{}
+	}
+	l.Destinations[destination][hash] = &chunkLedgerEntry{JobID: jobID, Users: users, ImportedAt: time.Now().UTC()}
+	return l.save()
+}
+
+// forget removes the chunks imported to a destination from the ledger, e.g.
+// after its users were deleted, and writes the ledger file. It returns how
+// many chunks were removed.
+func (l *chunkLedger) forget(destination string) (int, error) {
+	if l == nil || len(l.Destinations[destination]) == 0 {
+		return 0, nil
+	}
+	chunks := len(l.Destinations[destination])
+	delete(l.Destinations, destination)
+	return chunks, l.save()
+}
+
+func (l *chunkLedger) save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk ledger: %w", err)
+	}
+	if err := os.WriteFile(l.filename, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write chunk ledger: %w", err)
+	}
+	return nil
+}
+
+// importDestination identifies the target connection imports go to.
+func importDestination() string {
+	return os.Getenv("DESTINATION_DOMAIN") + "/" + os.Getenv("DESTINATION_CONNECTION_ID")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestImportSchedulerSkipsChunksInLedger(t *testing.T) {
+	var mu sync.Mutex
+	submitted := 0
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			submitted++
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": fmt.Sprintf("job_%d", submitted), "status": "pending"})
+			return
+		}
+
+		// job_2 has a failed user.
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		failed := 0
+		if id == "job_2" {
+			failed = 1
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": "completed", "summary": map[string]int{"total": 1, "failed": failed}})
+	}))
+
+	ledgerFile := filepath.Join(t.TempDir(), "ledger.json")
+	ctx := context.Background()
+	run := func(reimport bool, chunks ...[]map[string]interface{}) *importScheduler {
+		t.Helper()
+		ledger, err := loadChunkLedger(ledgerFile)
+		if err != nil {
+			t.Fatalf("Failed to load ledger: %v", err)
+		}
+		ledger.reimport = reimport
+
+		scheduler := newImportScheduler(m, 1)
+		scheduler.poller = poller{}
+		scheduler.ledger, scheduler.destination = ledger, "tenant.auth0.com/con_1"
+		for i, chunk := range chunks {
+			if err := scheduler.submit(ctx, i+1, chunk); err != nil {
+				t.Fatalf("Failed to submit chunk %d: %v", i+1, err)
+			}
+		}
+		if err := scheduler.drain(ctx); err != nil {
+			t.Fatalf("Failed to drain scheduler: %v", err)
+		}
+		return scheduler
+	}
+
+	user1 := []map[string]interface{}{{"email": "user1@example.com"}}
+	user2 := []map[string]interface{}{{"email": "user2@example.com"}}
+	user3 := []map[string]interface{}{{"email": "user3@example.com"}}
+
+	// job_1 imports user1, job_2 fails to import user3.
+	run(false, user1, user3)
+
+	// A later run with a different input skips user1 but not user3: job_3
+	// imports user2 and job_4 user3.
+	second := run(false, user2, user1, user3)
+	if submitted != 4 || second.skipped != 1 {
+		t.Errorf("Expected 4 jobs submitted in total and 1 chunk skipped, got %d and %d", submitted, second.skipped)
+	}
+
+	// With --force, all chunks are imported again.
+	third := run(true, user1, user2)
+	if submitted != 6 || third.skipped != 0 {
+		t.Errorf("Expected 6 jobs submitted in total and no chunk skipped, got %d and %d", submitted, third.skipped)
+	}
+
+	ledger, err := loadChunkLedger(ledgerFile)
+	if err != nil {
+		t.Fatalf("Failed to load ledger: %v", err)
+	}
+	entries := ledger.Destinations["tenant.auth0.com/con_1"]
+	if len(entries) != 3 || entries[chunkHash(user1)].JobID != "job_5" || entries[chunkHash(user3)].JobID != "job_4" {
+		t.Errorf("Unexpected ledger entries %+v", entries)
+	}
+	if ledger.imported("other.auth0.com/con_1", chunkHash(user1)) != nil {
+		t.Errorf("Expected chunks to be recorded per destination")
+	}
+}
+
+func TestChunkLedgerForget(t *testing.T) {
+	ledgerFile := filepath.Join(t.TempDir(), "ledger.json")
+	ledger, _ := loadChunkLedger(ledgerFile)
+	ledger.record("staging.eu.auth0.com/con_1", "hash1", "job_1", 10)
+	ledger.record("staging.eu.auth0.com/con_1", "hash2", "job_2", 10)
+	ledger.record("staging.eu.auth0.com/con_2", "hash1", "job_3", 10)
+
+	chunks, err := ledger.forget("staging.eu.auth0.com/con_1")
+	if err != nil || chunks != 2 {
+		t.Fatalf("Expected 2 chunks to be removed, got %d, %v", chunks, err)
+	}
+
+	reloaded, err := loadChunkLedger(ledgerFile)
+	if err != nil {
+		t.Fatalf("Failed to reload the ledger: %v", err)
+	}
+	if reloaded.imported("staging.eu.auth0.com/con_1", "hash1") != nil || reloaded.imported("staging.eu.auth0.com/con_2", "hash1") == nil {
+		t.Errorf("Expected only the chunks of con_1 to be removed, got %+v", reloaded.Destinations)
+	}
+	if chunks, err := reloaded.forget("staging.eu.auth0.com/con_1"); err != nil || chunks != 0 {
+		t.Errorf("Expected nothing to remove, got %d, %v", chunks, err)
+	}
+}
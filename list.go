@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// resourceTable lists the resources of one type, printed as a table or as
+// JSON objects keyed by column name.
+type resourceTable struct {
+	columns []string
+	rows    [][]string
+}
+
+func (t resourceTable) write(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.columns, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// objects returns the rows as objects whose keys are the column names in
+// lower case, e.g. client_id for CLIENT ID.
+func (t resourceTable) objects() []map[string]string {
+	objects := []map[string]string{}
+	for _, row := range t.rows {
+		object := map[string]string{}
+		for i, column := range t.columns {
+			object[strings.ReplaceAll(strings.ToLower(column), " ", "_")] = row[i]
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func connectionsTable(connections map[string]*management.Connection) resourceTable {
+	table := resourceTable{columns: []string{"NAME", "STRATEGY", "ID", "ENABLED CLIENTS"}}
+	for _, name := range sortedKeys(connections) {
+		connection := connections[name]
+		table.rows = append(table.rows, []string{name, connection.GetStrategy(), connection.GetID(), strconv.Itoa(len(connection.GetEnabledClients()))})
+	}
+	return table
+}
+
+func clientsTable(applications map[string]*management.Client) resourceTable {
+	table := resourceTable{columns: []string{"NAME", "APP TYPE", "CLIENT ID", "CALLBACKS"}}
+	for _, name := range sortedKeys(applications) {
+		client := applications[name]
+		table.rows = append(table.rows, []string{name, client.GetAppType(), client.GetClientID(), strconv.Itoa(len(client.GetCallbacks()))})
+	}
+	return table
+}
+
+func rolesTable(roles []*management.Role) resourceTable {
+	table := resourceTable{columns: []string{"NAME", "ID", "DESCRIPTION"}}
+	for _, role := range roles {
+		table.rows = append(table.rows, []string{role.GetName(), role.GetID(), role.GetDescription()})
+	}
+	return table
+}
+
+func apisTable(apis map[string]*management.ResourceServer) resourceTable {
+	table := resourceTable{columns: []string{"NAME", "IDENTIFIER", "SCOPES", "RBAC"}}
+	for _, identifier := range sortedKeys(apis) {
+		api := apis[identifier]
+		table.rows = append(table.rows, []string{api.GetName(), identifier, strconv.Itoa(len(api.GetScopes())), strconv.FormatBool(api.GetEnforcePolicies())})
+	}
+	return table
+}
+
+func newListCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the connections, clients, roles or APIs of a tenant",
+	}
+
+	var (
+		tenant string
+		format string
+	)
+
+	// Each subcommand reads one type of resource into a table.
+	for _, kind := range []struct {
+		name  string
+		short string
+		read  func(ctx context.Context, m *management.Management) (resourceTable, error)
+	}{
+		{"connections", "List the connections of a tenant with their strategies", func(ctx context.Context, m *management.Management) (resourceTable, error) {
+			connections, err := listConnections(ctx, m)
+			return connectionsTable(connections), err
+		}},
+		{"clients", "List the applications of a tenant with their client IDs", func(ctx context.Context, m *management.Management) (resourceTable, error) {
+			applications, err := listClients(ctx, m)
+			return clientsTable(applications), err
+		}},
+		{"roles", "List the roles of a tenant", func(ctx context.Context, m *management.Management) (resourceTable, error) {
+			roles, err := listRoles(ctx, m)
+			return rolesTable(roles), err
+		}},
+		{"apis", "List the APIs of a tenant with their identifiers", func(ctx context.Context, m *management.Management) (resourceTable, error) {
+			apis, err := listAPIs(ctx, m)
+			return apisTable(apis), err
+		}},
+	} {
+		kind := kind
+		listCmd.AddCommand(&cobra.Command{
+			Use:   kind.name,
+			Short: kind.short,
+			Run: func(cmd *cobra.Command, args []string) {
+				if format != "table" && format != "json" {
+					log.Fatalf("Invalid --format %q, expected table or json", format)
+				}
+				m, err := selectTenant(tenant, clients)
+				if err != nil {
+					log.Fatalf("Failed to select tenant: %v", err)
+				}
+
+				table, err := kind.read(ctx, m)
+				if err != nil {
+					log.Fatalf("Failed to list %s: %v", kind.name, err)
+				}
+
+				if format == "json" {
+					json.NewEncoder(resultOutput).Encode(table.objects())
+					return
+				}
+				table.write(messageOutput)
+				reportResult("list "+kind.name, map[string]interface{}{"tenant": tenant, kind.name: table.objects()})
+			},
+		})
+	}
+
+	listCmd.PersistentFlags().StringVar(&tenant, "tenant", "source", "Tenant to list: source, target or a profile from the config")
+	listCmd.PersistentFlags().StringVar(&format, "format", "table", "Output format: table or json")
+
+	return listCmd
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestListConnectionsTable(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/connections") {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"start": 0, "limit": 100, "total": 2,
+			"connections": []map[string]interface{}{
+				{"id": "con_2", "name": "google-oauth2", "strategy": "google-oauth2", "enabled_clients": []string{"c1"}},
+				{"id": "con_1", "name": "Username-Password-Authentication", "strategy": "auth0", "enabled_clients": []string{"c1", "c2"}},
+			},
+		})
+	}))
+
+	connections, err := listConnections(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Failed to list connections: %v", err)
+	}
+	table := connectionsTable(connections)
+
+	var buf bytes.Buffer
+	table.write(&buf)
+	expected := "NAME                              STRATEGY       ID     ENABLED CLIENTS\n" +
+		"Username-Password-Authentication  auth0          con_1  2\n" +
+		"google-oauth2                     google-oauth2  con_2  1\n"
+	if buf.String() != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	objects := table.objects()
+	if len(objects) != 2 || objects[0]["enabled_clients"] != "2" || objects[1]["name"] != "google-oauth2" {
+		t.Errorf("Unexpected objects %v", objects)
+	}
+}
+
+func TestListTablesJSONKeys(t *testing.T) {
+	empty := clientsTable(nil).objects()
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("Expected an empty list for no clients, got %v", empty)
+	}
+
+	table := resourceTable{columns: []string{"NAME", "CLIENT ID"}, rows: [][]string{{"Web App", "abc"}}}
+	data, _ := json.Marshal(table.objects())
+	if string(data) != `[{"client_id":"abc","name":"Web App"}]` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// logFormat is the --log-format flag: text or json.
+var logFormat string
+
+// verbose and debug lower the level of the structured log to info and
+// debug. With --log-format json the level is info by default.
+var verbose, debug bool
+
+// logger writes the structured log to stderr: the events of a run (see
+// emitEvent) with their job IDs, chunks, user counts and durations, for log
+// pipelines such as Datadog or CloudWatch. It discards everything until
+// setupLogging enables it.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// setupLogging configures the structured log from the logging flags.
+func setupLogging() error {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch logFormat {
+	case "text":
+		if verbose || debug {
+			logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+		}
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+		// Fatal errors become error records of the same log.
+		log.SetFlags(0)
+		log.SetOutput(slogWriter{})
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", logFormat)
+	}
+	return nil
+}
+
+// slogWriter writes the messages of the standard logger as error records.
+type slogWriter struct{}
+
+func (slogWriter) Write(p []byte) (int, error) {
+	logger.Error(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// logEvent writes an event to the structured log. Failures are logged as
+// warnings, everything else as info.
+func logEvent(event string, fields map[string]interface{}) {
+	level := slog.LevelInfo
+	switch event {
+	case "job_failed", "rate_limited":
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+
+	attrs := make([]any, 0, len(fields))
+	for _, key := range sortedKeys(fields) {
+		attrs = append(attrs, slog.Any(key, fields[key]))
+	}
+	logger.Log(context.Background(), level, event, attrs...)
+}
+
+// seconds converts a duration into the seconds logged and emitted with
+// events, to the millisecond.
+func seconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// loggingTransport logs every Management API request at debug level.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	attrs := []any{"method", req.Method, "url", req.URL.Redacted(), "duration", seconds(time.Since(started))}
+	if err != nil {
+		logger.Debug("api_request", append(attrs, "error", err.Error())...)
+		return resp, err
+	}
+	logger.Debug("api_request", append(attrs, "status", resp.StatusCode)...)
+	return resp, nil
+}
+
+// logTransport makes a transport log its requests when running with --debug.
+func logTransport(base http.RoundTripper) http.RoundTripper {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return base
+	}
+	return loggingTransport{base: base}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withTestLogger(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	t.Cleanup(func() { logger = previous })
+	return &buf
+}
+
+func TestLogEvent(t *testing.T) {
+	buf := withTestLogger(t, slog.LevelInfo)
+
+	emitEvent("chunk_completed", map[string]interface{}{"chunk": 2, "job_id": "job_1", "users": 500, "duration": 12.5})
+	emitEvent("rate_limited", map[string]interface{}{"error": "too many requests"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %q", buf.String())
+	}
+	var record map[string]interface{}
+	json.Unmarshal([]byte(lines[0]), &record)
+	if record["msg"] != "chunk_completed" || record["level"] != "INFO" || record["job_id"] != "job_1" || record["users"] != 500.0 || record["duration"] != 12.5 {
+		t.Errorf("Unexpected record %v", record)
+	}
+	json.Unmarshal([]byte(lines[1]), &record)
+	if record["msg"] != "rate_limited" || record["level"] != "WARN" {
+		t.Errorf("Expected a warning, got %v", record)
+	}
+}
+
+func TestLogTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	withTestLogger(t, slog.LevelInfo)
+	if _, ok := logTransport(http.DefaultTransport).(loggingTransport); ok {
+		t.Errorf("Expected requests to be logged only with --debug")
+	}
+
+	buf := withTestLogger(t, slog.LevelDebug)
+	client := &http.Client{Transport: logTransport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/api/v2/jobs/job_1")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	var record map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &record)
+	if record["msg"] != "api_request" || record["method"] != "GET" || record["status"] != 418.0 || !strings.HasSuffix(record["url"].(string), "/api/v2/jobs/job_1") {
+		t.Errorf("Unexpected record %v", record)
+	}
+}
+
+func TestSetupLoggingFormat(t *testing.T) {
+	previous := logFormat
+	defer func() { logFormat = previous }()
+
+	logFormat = "xml"
+	if err := setupLogging(); err == nil {
+		t.Errorf("Expected an unknown log format to fail")
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,7 +33,7 @@ func getSourceAuth0Client(ctx context.Context) (*management.Management, error) {
 		return nil, fmt.Errorf("source Auth0 credentials are missing. Please check your .env file")
 	}
 
-	return management.New(domain, management.WithClientCredentials(ctx, clientID, clientSecret))
+	return newManagementClient(ctx, domain, clientID, clientSecret, os.Getenv("SOURCE_API_URL"), os.Getenv("SOURCE_AUDIENCE"))
 }
 
 func getTargetAuth0Client(ctx context.Context) (*management.Management, error) {
@@ -39,26 +45,119 @@ func getTargetAuth0Client(ctx context.Context) (*management.Management, error) {
 		return nil, fmt.Errorf("target Auth0 credentials are missing. Please check your .env file")
 	}
 
-	return management.New(domain, management.WithClientCredentials(ctx, clientID, clientSecret))
+	return newManagementClient(ctx, domain, clientID, clientSecret, os.Getenv("DESTINATION_API_URL"), os.Getenv("DESTINATION_AUDIENCE"))
 }
 
-func exportUsers(ctx context.Context, m *management.Management) (string, error) {
-	exportFields := []map[string]interface{}{
-		{"name": "user_id"},
-		{"name": "email"},
-		{"name": "name"},
-		{"name": "user_metadata"},
-		{"name": "app_metadata"},
-		{"name": "created_at"},
-		{"name": "updated_at"},
-		{"name": "email_verified"},
+// getProfileAuth0Client creates a client for a tenant profile from the
+// config file.
+func getProfileAuth0Client(ctx context.Context, name string) (*management.Management, error) {
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %q, expected source, target or a profile from the config", name)
+	}
+	if profile.Domain == "" || profile.ClientID == "" || profile.ClientSecret == "" {
+		return nil, fmt.Errorf("credentials of profile %s are missing. Please check your config file", name)
+	}
+
+	return newManagementClient(ctx, profile.Domain, profile.ClientID, profile.ClientSecret, profile.APIURL, profile.Audience)
+}
+
+// tenantClients creates the Management API clients on first use, so commands
+// that only touch one tenant (or none) don't need both sets of credentials.
+type tenantClients struct {
+	ctx      context.Context
+	source   *management.Management
+	target   *management.Management
+	profiles map[string]*management.Management
+}
+
+func (t *tenantClients) sourceClient() *management.Management {
+	if t.source == nil {
+		client, err := getSourceAuth0Client(t.ctx)
+		if err != nil {
+			log.Fatalf("Failed to create Auth0 source client: %v", err)
+		}
+		t.source = client
+	}
+	return t.source
+}
+
+func (t *tenantClients) targetClient() *management.Management {
+	if t.target == nil {
+		client, err := getTargetAuth0Client(t.ctx)
+		if err != nil {
+			log.Fatalf("Failed to create Auth0 target client: %v", err)
+		}
+		t.target = client
+	}
+	return t.target
+}
+
+// guardTarget refuses destructive actions against a protected target tenant.
+func (t *tenantClients) guardTarget(action string) {
+	guardProtected(os.Getenv("DESTINATION_DOMAIN"), action)
+}
+
+// tenantDomain returns the domain of a --tenant: source, target or the name
+// of a profile in the config file.
+func tenantDomain(tenant string) string {
+	switch tenant {
+	case "source":
+		return os.Getenv("SOURCE_DOMAIN")
+	case "target":
+		return os.Getenv("DESTINATION_DOMAIN")
+	}
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		return ""
+	}
+	return cfg.Profiles[tenant].Domain
+}
+
+// selectTenant returns the client for --tenant: source, target or the name
+// of a profile in the config file.
+func selectTenant(tenant string, clients *tenantClients) (*management.Management, error) {
+	switch tenant {
+	case "source":
+		return clients.sourceClient(), nil
+	case "target":
+		return clients.targetClient(), nil
 	}
 
+	if client, ok := clients.profiles[tenant]; ok {
+		return client, nil
+	}
+
+	client, err := getProfileAuth0Client(clients.ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if clients.profiles == nil {
+		clients.profiles = map[string]*management.Management{}
+	}
+	clients.profiles[tenant] = client
+	return client, nil
+}
+
+func exportUsers(ctx context.Context, m *management.Management, format string, limit int, fields []map[string]interface{}) (string, error) {
+	return exportConnectionUsers(ctx, m, os.Getenv("SOURCE_CONNECTION_ID"), format, limit, fields)
+}
+
+// exportConnectionUsers starts an export job of the users of a connection.
+func exportConnectionUsers(ctx context.Context, m *management.Management, connectionID, format string, limit int, fields []map[string]interface{}) (string, error) {
 	exportJob := &management.Job{
-		ConnectionID: auth0.String(os.Getenv("SOURCE_CONNECTION_ID")),
-		Format:       auth0.String("json"),
-		Limit:        auth0.Int(50000),
-		Fields:       exportFields,
+		ConnectionID: auth0.String(connectionID),
+		Format:       auth0.String(format),
+		Fields:       fields,
+	}
+	// Without a limit the job exports every user of the connection.
+	if limit > 0 {
+		exportJob.Limit = auth0.Int(limit)
 	}
 
 	err := m.Job.ExportUsers(ctx, exportJob)
@@ -66,20 +165,94 @@ func exportUsers(ctx context.Context, m *management.Management) (string, error)
 		return "", err
 	}
 
+	emitEvent("export_started", map[string]interface{}{"job_id": exportJob.GetID(), "format": format})
 	return *exportJob.ID, nil
 }
 
-func checkJobStatus(ctx context.Context, m *management.Management, jobID string) (string, error) {
+// checkJobStatus reads a job, returning a JobFailedError if it failed.
+func checkJobStatus(ctx context.Context, m *management.Management, jobID string) (*management.Job, error) {
 	job, err := m.Job.Read(ctx, jobID)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to read job status: %w", apiError(err))
+	}
+
+	if job.GetStatus() == "failed" {
+		return nil, &JobFailedError{JobID: jobID}
+	}
+	return job, nil
+}
+
+// waitForExport polls an export job until it completes, fails or timeout
+// passes.
+func waitForExport(ctx context.Context, m *management.Management, jobID string, timeout time.Duration) (*management.Job, error) {
+	var job *management.Job
+	exportPoller := newPoller()
+	exportPoller.maxWait = timeout
+	jobProgress := newProgress("Export job "+jobID, 100, false)
+	defer jobProgress.done()
+	started := time.Now()
+	err := exportPoller.poll(ctx, func(ctx context.Context) (bool, error) {
+		var err error
+		job, err = checkJobStatus(ctx, m, jobID)
+		if err != nil || job.GetStatus() == "completed" {
+			var jobFailed *JobFailedError
+			if errors.As(err, &jobFailed) {
+				emitEvent("job_failed", map[string]interface{}{"job_id": jobID})
+			}
+			if err == nil {
+				emitEvent("export_completed", map[string]interface{}{"job_id": jobID, "duration": seconds(time.Since(started))})
+			}
+			return true, err
+		}
+		jobProgress.update(int64(job.GetPercentageDone()), job.GetStatus())
+		return false, nil
+	})
+	if errors.Is(err, errPollTimeout) {
+		return nil, &JobTimeoutError{JobID: jobID, Status: job.GetStatus(), Waited: timeout}
 	}
+	return job, err
+}
 
-	if *job.Status == "completed" {
-		return *job.Location, nil
+// connectionUserCount returns how many users the connection has, according
+// to the user search totals. As those stop at searchResultLimit, a count of
+// searchResultLimit means the connection has at least that many users.
+func connectionUserCount(ctx context.Context, m *management.Management, connectionID string) (int, error) {
+	connection, err := m.Connection.Read(ctx, connectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read connection: %w", apiError(err))
 	}
 
-	return "", fmt.Errorf("job not completed yet")
+	list, err := m.User.Search(ctx,
+		management.Query(fmt.Sprintf(`identities.connection:"%s"`, connection.GetName())),
+		management.PerPage(1),
+		management.IncludeTotals(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", apiError(err))
+	}
+	return list.Total, nil
+}
+
+// checkExportComplete compares the number of exported users with the
+// connection's user count. With a limit, only limit users are expected.
+func checkExportComplete(exported, total, limit int) error {
+	expected := total
+	if limit > 0 && limit < expected {
+		expected = limit
+	}
+	if exported < expected {
+		return fmt.Errorf("export contains %d users but %d were expected (the connection has %d), the export appears to be truncated", exported, expected, total)
+	}
+	return nil
+}
+
+// checkMaxUsers guards against running on a much larger connection than
+// expected. A max of 0 disables the check.
+func checkMaxUsers(count, max int) error {
+	if max > 0 && count > max {
+		return fmt.Errorf("%d users exceed --max-users %d, check that the right connection is configured", count, max)
+	}
+	return nil
 }
 
 func downloadFile(url string, filename string) error {
@@ -89,109 +262,151 @@ func downloadFile(url string, filename string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return newDownloadError(resp)
+	}
+
+	// Checked before anything is written, so a full disk fails the download
+	// up front instead of half way through.
+	if err := checkDiskSpace(filename, resp.ContentLength); err != nil {
+		return err
+	}
+
 	out, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	started := time.Now()
+	downloadProgress := newProgress("Downloading "+filepath.Base(filename), resp.ContentLength, true)
+	written, err := io.Copy(io.MultiWriter(out, downloadProgress), throttle(resp.Body))
+	downloadProgress.done()
 	if err != nil {
 		return fmt.Errorf("failed to write to file: %w", err)
 	}
+	emitEvent("download_completed", map[string]interface{}{"file": filename, "bytes": written, "duration": seconds(time.Since(started))})
 
-	fmt.Printf("File downloaded successfully as: %s\n", filename)
+	fmt.Fprintf(messageOutput, "File downloaded successfully as: %s\n", filename)
 	return nil
 }
 
-func unzipGZFile(gzFile string) ([]byte, error) {
-	file, err := os.Open(gzFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open gz file: %w", err)
+// splitUsers streams the users from r into chunks of at most maxChunkSize
+// bytes, written to the spool directory dir. Each user is passed through
+// transform (if set), which drops it by returning nil. email_verified is set
+// to emailVerified, unless it is nil. The encoded users are spooled to disk
+// as they are read, so only their user IDs are kept in memory to sort them.
+func splitUsers(r io.Reader, maxChunkSize int, emailVerified *bool, transform func(userRecord) (map[string]interface{}, error), dir string) (*userChunks, error) {
+	if err := prepareSpoolDir(dir); err != nil {
+		return nil, err
 	}
-	defer file.Close()
-
-	gzReader, err := gzip.NewReader(file)
+	spool, err := os.CreateTemp(dir, "users-*.spool")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
 	}
-	defer gzReader.Close()
+	defer os.Remove(spool.Name())
+	defer spool.Close()
 
-	var result strings.Builder
-	_, err = io.Copy(&result, gzReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read gz file: %w", err)
+	type spooledUser struct {
+		userID string
+		offset int64
+		size   int
 	}
+	var users []spooledUser
+	var offset int64
+	w := bufio.NewWriter(spool)
 
-	return []byte(result.String()), nil
-}
-
-func splitJSONData(data []byte, maxChunkSize int, email_verify bool) ([][]map[string]interface{}, error) {
-	var chunks [][]map[string]interface{}
-	chunk := []map[string]interface{}{}
-	chunkSize := 0
-
-	lines := strings.Split(string(data), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line) // Remove leading/trailing whitespace
-		if line == "" {
-			continue // Skip empty lines
+	err = readUsers(r, func(record userRecord) error {
+		user := record.user
+		if transform != nil {
+			var err error
+			if user, err = transform(record); err != nil || user == nil {
+				return err
+			}
+		}
+		if emailVerified != nil {
+			user["email_verified"] = *emailVerified
 		}
+		delete(user, "identities") // exported for sync identities, not accepted by imports
 
-		var user map[string]interface{}
-		err := json.Unmarshal([]byte(line), &user)
+		data, err := json.Marshal(user)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+			return fmt.Errorf("failed to marshal user: %w", err)
 		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write spool file: %w", err)
+		}
+		userID, _ := user["user_id"].(string)
+		users = append(users, spooledUser{userID: userID, offset: offset, size: len(data)})
+		offset += int64(len(data))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write spool file: %w", err)
+	}
 
-		user["email_verified"] = email_verify
+	// Sort by user_id so the same input always produces the same chunks.
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].userID < users[j].userID
+	})
 
-		userData, err := json.Marshal(user)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal user: %w", err)
+	chunks := &userChunks{dir: dir, users: len(users)}
+	var chunk [][]byte
+	chunkSize := 0
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
 		}
-
-		userSize := len(userData)
-		if chunkSize+userSize > maxChunkSize {
-			chunks = append(chunks, chunk)
-			chunk = []map[string]interface{}{}
-			chunkSize = 0
+		err := writeChunkFile(chunks.chunkFile(chunks.chunks), chunk)
+		chunks.chunks++
+		chunk, chunkSize = nil, 0
+		return err
+	}
+	for _, user := range users {
+		if chunkSize+user.size > maxChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
 		}
-
-		chunk = append(chunk, user)
-		chunkSize += userSize
+		data := make([]byte, user.size)
+		if _, err := spool.ReadAt(data, user.offset); err != nil {
+			return nil, fmt.Errorf("failed to read spool file: %w", err)
+		}
+		chunk = append(chunk, data)
+		chunkSize += user.size
 	}
-
-	if len(chunk) > 0 {
-		chunks = append(chunks, chunk)
+	if err := flush(); err != nil {
+		return nil, err
 	}
 
 	return chunks, nil
 }
 
-func checkImportJobStatus(ctx context.Context, m *management.Management, jobID string) error {
-	for {
-		job, err := m.Job.Read(ctx, jobID)
-		if err != nil {
-			return fmt.Errorf("failed to read job status: %w", err)
-		}
+func splitJSONData(data []byte, maxChunkSize int, email_verify bool) ([][]map[string]interface{}, error) {
+	dir, err := os.MkdirTemp("", "auth0-tools-spool-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
 
-		if *job.Status == "completed" {
-			fmt.Printf("Import job %s completed successfully.\n", jobID)
-			return nil
-		}
+	users, err := splitUsers(bytes.NewReader(data), maxChunkSize, &email_verify, nil, dir)
+	if err != nil {
+		return nil, err
+	}
 
-		if *job.Status == "failed" {
-			return fmt.Errorf("import job %s failed", jobID)
+	chunks := make([][]map[string]interface{}, users.count())
+	for i := range chunks {
+		if chunks[i], err = users.chunk(i); err != nil {
+			return nil, err
 		}
-
-		fmt.Printf("Import job %s still in progress. Waiting...\n", jobID)
-		time.Sleep(10 * time.Second)
 	}
+	return chunks, nil
 }
 
-func importUsersChunk(ctx context.Context, m *management.Management, users []map[string]interface{}) error {
+func submitImportJob(ctx context.Context, m *management.Management, users []map[string]interface{}) (string, error) {
 	importJob := &management.Job{
 		ConnectionID: auth0.String(os.Getenv("DESTINATION_CONNECTION_ID")),
 		Users:        users,
@@ -200,91 +415,679 @@ func importUsersChunk(ctx context.Context, m *management.Management, users []map
 
 	err := m.Job.ImportUsers(ctx, importJob)
 	if err != nil {
-		return fmt.Errorf("failed to import users: %w", err)
+		return "", fmt.Errorf("failed to import users: %w", apiError(err))
 	}
 
-	return checkImportJobStatus(ctx, m, *importJob.ID)
+	return *importJob.ID, nil
 }
 
-func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error loading .env file")
+// envFiles are the environment files given with --env-file.
+var envFiles []string
+
+// loadEnvFiles loads the given environment files, or .env if it exists when
+// none are given. Variables already set in the environment are kept, and
+// earlier files take precedence over later ones.
+func loadEnvFiles(files []string) error {
+	if len(files) == 0 {
+		err := godotenv.Load()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
 	}
+	return godotenv.Load(files...)
+}
 
+func main() {
 	ctx := context.Background()
+	clients := &tenantClients{ctx: ctx}
 
-	sourceClient, err := getSourceAuth0Client(ctx)
-	if err != nil {
-		log.Fatalf("Failed to create Auth0 source client: %v", err)
-	}
-
-	targetClient, err := getTargetAuth0Client(ctx)
-	if err != nil {
-		log.Fatalf("Failed to create Auth0 target client: %v", err)
+	var rootCmd = &cobra.Command{Use: "auth0-cli"}
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file (default ~/.auth0-tools.yaml)")
+	rootCmd.PersistentFlags().StringVar(&sourceProfile, "source", "", "Config profile to use as the source tenant instead of the SOURCE_* variables")
+	rootCmd.PersistentFlags().StringVar(&targetProfile, "target", "", "Config profile to use as the target tenant instead of the DESTINATION_* variables")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Fail instead of prompting and print machine-readable JSON results on stdout")
+	rootCmd.PersistentFlags().BoolVar(&events, "events", false, "Stream progress as one JSON event per line on stdout, with messages on stderr")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "Fail any command that would modify a tenant")
+	rootCmd.PersistentFlags().BoolVar(&allowProtected, "allow-protected", false, "Allow destructive commands against tenants marked as protected in the config")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Don't print progress updates of export jobs, downloads and imports")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Print progress as plain lines instead of progress bars, as when the output isn't a terminal")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the structured log on stderr: text (only with --verbose or --debug) or json")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Write the events of the run, such as started and completed jobs, to the structured log")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Also log every Management API request")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 5, "How often to retry Management API requests that were rate limited or failed with a transient error")
+	rootCmd.PersistentFlags().IntVar(&rateLimitBuffer, "rate-limit-buffer", 0, "Pause Management API requests until the rate limit resets when this many requests are left (0 to disable)")
+	rootCmd.PersistentFlags().StringVar(&bwlimit, "bwlimit", "", "Limit the bandwidth of export downloads and import uploads, e.g. 10MB/s")
+	rootCmd.PersistentFlags().StringSliceVar(&envFiles, "env-file", nil, "Environment files to load instead of .env; earlier files take precedence")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		setupNonInteractive()
+		if err := setupLogging(); err != nil {
+			log.Fatalf("Invalid --log-format: %v", err)
+		}
+		if err := loadEnvFiles(envFiles); err != nil {
+			log.Fatalf("Error loading environment file: %v", err)
+		}
+		// config lint reports the problems of an invalid config itself.
+		if cmd != lintCommand {
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			pii = cfg.PII
+		}
+		if bwlimit != "" {
+			rate, err := parseBandwidth(bwlimit)
+			if err != nil {
+				log.Fatalf("Invalid --bwlimit: %v", err)
+			}
+			bandwidth = newBandwidthLimiter(rate)
+		}
+		if maxRetries < 0 || rateLimitBuffer < 0 {
+			log.Fatalf("--max-retries and --rate-limit-buffer must not be negative")
+		}
+		if sourceProfile != "" || targetProfile != "" {
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			if err := applyProfiles(cfg, sourceProfile, targetProfile); err != nil {
+				log.Fatalf("Invalid tenant profile: %v", err)
+			}
+		}
 	}
 
-	var rootCmd = &cobra.Command{Use: "auth0-cli"}
+	var (
+		exportFormat    string
+		exportTimeout   time.Duration
+		exportMaxUsers  int
+		exportPreset    string
+		exportLimit     int
+		exportRole      string
+		exportFlatten   []string
+		exportSeparator string
+		exportFields    []string
+		exportExclude   []string
+		exportPasswords bool
+		exportOutput    string
+		exportActive    string
+		exportDormant   string
+	)
 
 	var exportCmd = &cobra.Command{
 		Use:   "export",
 		Short: "Export users from the source Auth0 tenant",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Starting user export from source tenant...")
+			if exportFormat != "json" && exportFormat != "csv" {
+				log.Fatalf("Unknown format %q, expected json or csv", exportFormat)
+			}
+			if exportRole != "" && exportFormat != "json" {
+				log.Fatalf("--role only supports the json format")
+			}
+			if err := checkFlatten(exportFlatten, exportSeparator, exportFormat); err != nil {
+				log.Fatalf("Invalid --flatten: %v", err)
+			}
 
-			jobID, err := exportUsers(ctx, sourceClient)
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			if len(exportFields) > 0 && cmd.Flags().Changed("preset") {
+				log.Fatalf("--fields and --preset can't be combined")
+			}
+			fields, err := exportFieldPreset(cfg, exportPreset)
 			if err != nil {
-				log.Fatalf("Failed to export users: %v", err)
+				log.Fatalf("Invalid preset: %v", err)
+			}
+			if fields, err = selectExportFields(fields, exportFields, exportExclude); err != nil {
+				log.Fatalf("Invalid field selection: %v", err)
+			}
+			if exportPasswords && !slices.Contains(fields, passwordHashField) {
+				fields = append(slices.Clone(fields), passwordHashField)
+			}
+			var activeWithin time.Duration
+			if exportActive != "" {
+				if exportFormat != "json" {
+					log.Fatalf("--active-within only supports the json format")
+				}
+				if activeWithin, err = parseAge(exportActive); err != nil {
+					log.Fatalf("Invalid --active-within: %v", err)
+				}
+				fields = withActivityFields(fields)
+			} else if exportDormant != "" {
+				log.Fatalf("--dormant-output requires --active-within")
 			}
 
-			fmt.Printf("Export job started in source tenant. Job ID: %s\n", jobID)
+			hooks := loadHooks("export")
+			hooks.mustRun("pre_export", nil)
+			hooks.mustApprove(ctx, "pre_export", nil)
 
-			for {
-				time.Sleep(10 * time.Second)
-				location, err := checkJobStatus(ctx, sourceClient, jobID)
-				if err == nil {
-					fmt.Printf("Export completed. Download file at: %s\n", location)
+			fmt.Fprintln(messageOutput, "Starting user export from source tenant...")
 
-					err = downloadFile(location, "exported_users.json.gz")
-					if err != nil {
-						log.Fatalf("Failed to download the file: %v", err)
+			sourceClient := clients.sourceClient()
+
+			// Export one user more than allowed to detect a too large connection
+			// without exporting all of it.
+			limit := exportLimit
+			if exportMaxUsers > 0 && (limit == 0 || exportMaxUsers < limit) {
+				limit = exportMaxUsers + 1
+			}
+
+			// Fail before exporting if the file has to be encrypted but can't be.
+			var encryptKey []byte
+			if cfg.PII.requiresEncryption(append(fields, exportFlatten...)) {
+				if encryptKey, err = encryptionKey(); err != nil {
+					hooks.fatalf("PII policy: %v", err)
+				}
+			}
+
+			output := exportOutput
+			if output == "" {
+				output = "exported_users." + exportFormat + ".gz"
+			}
+			if isStorageURL(output) {
+				if _, _, err := openStorageURL(output); err != nil {
+					hooks.fatalf("Invalid --output: %v", err)
+				}
+			}
+			filename := localOutput(output)
+			result := map[string]interface{}{"file": output}
+
+			if exportRole != "" {
+				skipped, err := exportRoleUsers(ctx, sourceClient, exportRole, os.Getenv("SOURCE_CONNECTION_ID"), fields, limit, filename)
+				if err != nil {
+					hooks.fatalf("Failed to export users of role %s: %v", exportRole, err)
+				}
+				if skipped > 0 {
+					fmt.Fprintf(messageOutput, "%d users of the role belong to another connection and were not exported.\n", skipped)
+				}
+				result["role"] = exportRole
+				result["skipped"] = skipped
+			} else {
+				jobID, err := exportUsers(ctx, sourceClient, exportFormat, limit, exportJobFields(fields, exportFlatten, exportSeparator))
+				if err != nil {
+					hooks.fatalf("Failed to export users: %v", err)
+				}
+
+				fmt.Fprintf(messageOutput, "Export job started in source tenant. Job ID: %s\n", jobID)
+				fmt.Fprintf(messageOutput, "If this run is interrupted, fetch the file later with: export download %s\n", jobID)
+
+				job, err := waitForExport(ctx, sourceClient, jobID, exportTimeout)
+				if err != nil {
+					hooks.fatalf("Failed to export users: %v", err)
+				}
+
+				location := job.GetLocation()
+				fmt.Fprintf(messageOutput, "Export completed. Download file at: %s\n", location)
+
+				err = downloadExport(ctx, sourceClient, jobID, location, filename)
+				if err != nil {
+					hooks.fatalf("Failed to download the file: %v", err)
+				}
+				result["job_id"] = jobID
+			}
+
+			dormant := 0
+			if activeWithin > 0 {
+				var active int
+				active, dormant, err = splitDormantUsers(filename, time.Now().Add(-activeWithin), exportDormant)
+				if err != nil {
+					hooks.fatalf("Failed to filter dormant users: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Kept %d users active within %s, left out %d dormant users.\n", active, exportActive, dormant)
+				result["dormant"] = dormant
+				if exportDormant != "" {
+					fmt.Fprintf(messageOutput, "Wrote the dormant users to %s.\n", exportDormant)
+					result["dormant_file"] = exportDormant
+					if encryptKey != nil {
+						if err := encryptFile(exportDormant, encryptKey); err != nil {
+							hooks.fatalf("Failed to encrypt the dormant users: %v", err)
+						}
 					}
-					break
 				}
-				fmt.Println("Export job not completed yet, checking again...")
 			}
+
+			if encryptKey != nil {
+				if err := encryptFile(filename, encryptKey); err != nil {
+					hooks.fatalf("Failed to encrypt the exported file: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Encrypted %s as required by the PII policy.\n", filename)
+				result["encrypted"] = true
+			}
+
+			exported, err := countFileUsers(filename)
+			if err != nil {
+				hooks.fatalf("Failed to read the exported file: %v", err)
+			}
+			if err := checkMaxUsers(exported, exportMaxUsers); err != nil {
+				os.Remove(filename)
+				hooks.fatalf("Aborting export: %v", err)
+			}
+
+			result["users"] = exported
+
+			// A role export is a deliberate subset of the connection, so it
+			// isn't checked for completeness.
+			if exportRole != "" {
+				fmt.Fprintf(messageOutput, "Exported %d users of role %s.\n", exported, exportRole)
+			} else if total, err := connectionUserCount(ctx, sourceClient, os.Getenv("SOURCE_CONNECTION_ID")); err != nil {
+				fmt.Fprintf(messageOutput, "Could not check the export for completeness: %v\n", err)
+			} else if total >= searchResultLimit && exported+dormant >= total {
+				// The search count is only a lower bound here, so there is
+				// nothing to compare the export with.
+				fmt.Fprintf(messageOutput, "Exported %d users. The connection has at least %d users, too many for the search to count, so the export isn't checked for completeness.\n", exported, total)
+			} else {
+				result["connection_users"] = total
+				if err := checkExportComplete(exported+dormant, total, limit); err != nil {
+					fmt.Fprintf(messageOutput, "WARNING: %v\n", err)
+					annotate("warning", "Export may be incomplete", output, err)
+					result["incomplete"] = true
+				} else {
+					fmt.Fprintf(messageOutput, "Exported %d of %d users in the connection.\n", exported, total)
+				}
+			}
+			if filename != output {
+				err := uploadStorageFile(ctx, filename, output)
+				os.Remove(filename)
+				if err != nil {
+					hooks.fatalf("Failed to upload the exported file: %v", err)
+				}
+				fmt.Fprintf(messageOutput, "Uploaded the export to %s.\n", output)
+			}
+			hooks.mustApprove(ctx, "post_export", result)
+			if err := hooks.run("post_export", result); err != nil {
+				fmt.Fprintln(messageOutput, err)
+			}
+			reportResult("export", result)
 		},
 	}
 
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Export format: json or csv")
+	exportCmd.Flags().StringVar(&exportPreset, "preset", defaultFieldPreset, "Export field preset: minimal, standard, full, analytics or one from the config")
+	exportCmd.Flags().StringSliceVar(&exportFields, "fields", nil, "Fields to export instead of the preset, e.g. user_id,email,user_metadata.plan")
+	exportCmd.Flags().StringSliceVar(&exportExclude, "exclude-fields", nil, "Fields left out of the export, e.g. identities")
+	exportCmd.Flags().BoolVar(&exportPasswords, "password-hashes", false, "Also export the password hashes, where the connection allows it")
+	exportCmd.Flags().IntVar(&exportLimit, "limit", 0, "Export at most this many users (0 exports the whole connection)")
+	exportCmd.Flags().IntVar(&exportMaxUsers, "max-users", 0, "Abort if the connection has more users than this (0 for no limit)")
+	exportCmd.Flags().StringSliceVar(&exportFlatten, "flatten", nil, "Nested metadata attributes exported as their own CSV columns, e.g. user_metadata.plan")
+	exportCmd.Flags().StringVar(&exportSeparator, "flatten-separator", ".", "Separator joining the path of a flattened attribute into its column name")
+	exportCmd.Flags().StringVar(&exportRole, "role", "", "Only export the users assigned to this role ID")
+	exportCmd.Flags().StringVar(&exportActive, "active-within", "", "Leave out dormant users who haven't logged in within this time, e.g. 180d")
+	exportCmd.Flags().StringVar(&exportDormant, "dormant-output", "", "Write the users left out by --active-within to this file, e.g. dormant_users.json.gz")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "File or s3://, gs:// or azblob:// URL to write the export to (default exported_users.<format>.gz)")
+	exportCmd.Flags().DurationVar(&exportTimeout, "job-timeout", time.Hour, "How long to wait for the export job before giving up (0 to wait indefinitely)")
+
+	var (
+		input         string
+		maxPending    int
+		maxUsers      int
+		jobTimeout    time.Duration
+		pluginNames   []string
+		skipConfig    bool
+		pauseFile     string
+		stateFile     string
+		spoolDir      string
+		ledgerFile    string
+		skipConnCheck bool
+		resume        bool
+		force         bool
+		dryRun        bool
+		failedUsers   string
+		hashAlgorithm string
+		transformFile string
+		activeWithin  string
+	)
+
 	var importCmd = &cobra.Command{
 		Use:   "import",
 		Short: "Import users into the target Auth0 tenant after splitting into chunks",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Starting user import into target tenant...")
+			hooks := loadHooks("import")
+			if dryRun {
+				fmt.Fprintln(messageOutput, "Validating users for import (dry run, nothing is imported)...")
+			} else {
+				checkWritable("import users")
+				hooks.mustRun("pre_import", nil)
+				hooks.mustApprove(ctx, "pre_import", nil)
+				fmt.Fprintln(messageOutput, "Starting user import into target tenant...")
+			}
 
-			jsonData, err := unzipGZFile("exported_users.json.gz")
+			// Files in cloud storage are copied to a temporary file first.
+			inputFile := input
+			if isStorageURL(input) {
+				downloaded, err := downloadStorageFile(ctx, input)
+				if err != nil {
+					hooks.fatalf("Failed to read the input file: %v", err)
+				}
+				defer os.Remove(downloaded)
+				inputFile = downloaded
+			}
+
+			file, err := openInputFile(inputFile)
 			if err != nil {
-				log.Fatalf("Failed to unzip the file: %v", err)
+				hooks.fatalf("Failed to read the input file: %v", err)
 			}
+			defer file.Close()
 
-			chunks, err := splitJSONData(jsonData, 500000, true) // 500KB size chunks
+			cfg, err := loadOptionalConfig()
 			if err != nil {
-				log.Fatalf("Failed to split the JSON data: %v", err)
+				hooks.fatalf("Failed to load config: %v", err)
+			}
+
+			chunkSize := maxImportFileSize // 500KB size chunks
+			if cfg.ChunkSize > 0 {
+				chunkSize = cfg.ChunkSize
 			}
 
-			for i, chunk := range chunks {
-				fmt.Printf("Importing chunk %d/%d...\n", i+1, len(chunks))
-				err := importUsersChunk(ctx, targetClient, chunk)
+			var activeSince time.Time
+			if activeWithin != "" {
+				age, err := parseAge(activeWithin)
 				if err != nil {
-					log.Fatalf("Failed to import chunk %d: %v", i+1, err)
+					hooks.fatalf("Invalid --active-within: %v", err)
 				}
-				fmt.Printf("Chunk %d imported successfully.\n", i+1)
+				activeSince = time.Now().Add(-age)
 			}
 
-			fmt.Println("All chunks imported successfully into the target tenant.")
+			var transform *transformSpec
+			if transformFile != "" {
+				if transform, err = loadTransformSpec(transformFile); err != nil {
+					hooks.fatalf("Invalid --transform: %v", err)
+				}
+			}
+
+			importOptions := map[string]interface{}{"chunk_size": chunkSize, "plugins": pluginNames, "skip_config": skipConfig, "password_hash_algorithm": hashAlgorithm, "transform": transformFile, "active_within": activeWithin}
+			spool := spoolDir
+			if dryRun {
+				if spool, err = os.MkdirTemp("", "auth0-tools-spool-"); err != nil {
+					hooks.fatalf("Failed to create spool directory: %v", err)
+				}
+				defer os.RemoveAll(spool)
+			}
+
+			// With --resume, the chunks an earlier run of the same import
+			// spooled are imported without reading the users again.
+			var chunks *userChunks
+			var manifest *chunkManifest
+			var key string
+			if resume && !dryRun {
+				digest := sha256.New()
+				if _, err := io.Copy(digest, file); err != nil {
+					hooks.fatalf("Failed to read the input file: %v", err)
+				}
+				key = importKey(digest.Sum(nil), importOptions)
+				if chunks, manifest = loadSpooledChunks(spool, key); chunks == nil {
+					if file, err = openInputFile(inputFile); err != nil {
+						hooks.fatalf("Failed to read the input file: %v", err)
+					}
+					defer file.Close()
+				}
+			}
+
+			skipped, dormant := 0, 0
+			var rejected []*ValidationError
+			if chunks != nil {
+				skipped, dormant = manifest.Skipped, manifest.Dormant
+				fmt.Fprintf(messageOutput, "Using the %d chunks spooled to %s by an earlier run.\n", chunks.count(), spool)
+			} else {
+				var plugins []*plugin
+				if len(pluginNames) > 0 {
+					plugins, err = loadPlugins(pluginNames)
+					if err != nil {
+						hooks.fatalf("Failed to load plugins: %v", err)
+					}
+				}
+
+				anonymized, err := anonymizedFields(cfg, os.Getenv("DESTINATION_DOMAIN"))
+				if err != nil {
+					hooks.fatalf("Failed to anonymize users: %v", err)
+				}
+				if err := checkSpoolSpace(inputFile, spool); err != nil {
+					hooks.fatalf("Failed to spool the users: %v", err)
+				}
+
+				// The input is decoded one user at a time while it is read,
+				// and hashed on the way for the idempotency key.
+				digest := sha256.New()
+				chunks, err = splitUsers(io.TeeReader(file, digest), chunkSize, auth0.Bool(true), func(record userRecord) (map[string]interface{}, error) {
+					if !activeSince.IsZero() && !userActiveSince(record.user, activeSince) {
+						dormant++
+						return nil, nil
+					}
+					if transform != nil {
+						if err := transform.apply(record.user); err != nil {
+							return nil, record.validationError(err)
+						}
+					}
+					user, err := pluginUser(record, plugins)
+					if err != nil {
+						return nil, err
+					}
+					if user == nil {
+						skipped++
+						return nil, nil
+					}
+					if err := mapPasswordHash(user, hashAlgorithm); err != nil {
+						return nil, record.validationError(err)
+					}
+					anonymizeUser(user, anonymized, cfg.PIISalt)
+					if dryRun {
+						if err := validateImportUser(user); err != nil {
+							rejected = append(rejected, record.validationError(err))
+							return nil, nil
+						}
+					}
+					return user, nil
+				}, spool)
+				if closeErr := closePlugins(plugins); err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					annotate("error", "Invalid user record", input, err)
+					hooks.fatalf("Failed to read the users: %v", err)
+				}
+				if len(anonymized) > 0 {
+					fmt.Fprintf(messageOutput, "Anonymized %s for the non-production target as required by the PII policy.\n", strings.Join(anonymized, ", "))
+				}
+
+				key = importKey(digest.Sum(nil), importOptions)
+				if !dryRun {
+					if err := chunks.saveManifest(chunkManifest{Key: key, Dormant: dormant, Skipped: skipped}); err != nil {
+						hooks.fatalf("Failed to spool the chunks: %v", err)
+					}
+				}
+			}
+			if skipped > 0 {
+				fmt.Fprintf(messageOutput, "%d users skipped by plugins.\n", skipped)
+			}
+			if dormant > 0 {
+				fmt.Fprintf(messageOutput, "%d dormant users not active within %s skipped.\n", dormant, activeWithin)
+			}
+
+			destination := importDestination()
+			if err := checkMaxUsers(chunks.users, maxUsers); err != nil {
+				hooks.fatalf("Aborting import: %v", err)
+			}
+
+			if dryRun {
+				rejections := make([]string, len(rejected))
+				for i, err := range rejected {
+					rejections[i] = err.Error()
+					fmt.Fprintf(messageOutput, "Would be rejected: %v\n", err)
+					annotate("warning", "User would be rejected", input, err)
+				}
+				fmt.Fprintf(messageOutput, "%d users in %d chunks would be imported, %d users would be rejected.\n", chunks.users, chunks.count(), len(rejected))
+				reportResult("import", map[string]interface{}{
+					"dry_run": true, "users": chunks.users, "chunks": chunks.count(), "rejected": len(rejected), "rejections": rejections, "dormant": dormant,
+				})
+				return
+			}
+
+			if maxPending <= 0 {
+				hooks.fatalf("Max pending jobs must be greater than zero")
+			}
+			clients.guardTarget("upsert users")
+
+			if !skipConfig {
+				b, err := readBundleFile(inputFile)
+				if err != nil {
+					hooks.fatalf("Failed to read the bundle: %v", err)
+				}
+				if b != nil && len(b.manifest.Resources) > 0 {
+					fmt.Fprintln(messageOutput, "Applying config resources from the bundle...")
+					applied, err := applyBundleResources(ctx, clients.targetClient(), b)
+					if err != nil {
+						hooks.fatalf("Failed to apply config resources: %v", err)
+					}
+					fmt.Fprintf(messageOutput, "%d config changes applied.\n", applied)
+				}
+			}
+
+			if !skipConnCheck {
+				problems, err := checkTargetConnection(ctx, clients.targetClient(), os.Getenv("DESTINATION_CONNECTION_ID"), chunks)
+				if err != nil {
+					hooks.fatalf("Failed to check the target connection: %v", err)
+				}
+				if err := reportConnectionProblems(problems); err != nil {
+					hooks.fatalf("Aborting import: %v. Fix the connection or the users, or re-run with --skip-connection-check", err)
+				}
+			}
+
+			scheduler := newImportScheduler(clients.targetClient(), maxPending)
+			scheduler.jobTimeout = jobTimeout
+			scheduler.chunks = chunks.count()
+			scheduler.pauseFile = pauseFile
+			scheduler.stateFile = stateFile
+			scheduler.state, err = loadImportState(stateFile)
+			if err != nil {
+				hooks.fatalf("Failed to load the import state: %v", err)
+			}
+			if err := scheduler.state.alreadyApplied(key, destination); err != nil && !force {
+				hooks.fatalf("Aborting: %v. Re-run with --force to import it again", err)
+			} else if err != nil {
+				fmt.Fprintf(messageOutput, "Warning: %v, importing again because of --force.\n", err)
+			}
+			if !resume {
+				scheduler.state = &importState{Chunks: map[string]*importStateChunk{}}
+			}
+			scheduler.state.Key, scheduler.state.Destination, scheduler.state.CompletedAt = key, destination, nil
+			scheduler.destination = destination
+			if scheduler.ledger, err = loadChunkLedger(ledgerFile); err != nil {
+				hooks.fatalf("Failed to load the chunk ledger: %v", err)
+			}
+			if scheduler.ledger != nil {
+				scheduler.ledger.reimport = force
+			}
+
+			saveFailedUsers := func() {
+				if failedUsers == "" || len(scheduler.jobErrors) == 0 {
+					return
+				}
+				count, err := scheduler.writeFailedUsers(failedUsers)
+				if err != nil {
+					fmt.Fprintln(messageOutput, err)
+					return
+				}
+				fmt.Fprintf(messageOutput, "%d failed users written to %s, fix them and import the file again.\n", count, failedUsers)
+			}
+
+			failImport := func(format string, args ...interface{}) {
+				err := fmt.Errorf(format, args...)
+				annotate("error", "Chunk import failed", "", err)
+				for _, job := range scheduler.pending {
+					fmt.Fprintf(messageOutput, "Job %s (chunk %d) is still pending on Auth0 and was not waited for.\n", job.id, job.chunk)
+				}
+				if err := writeStepSummary(importSummaryMarkdown(scheduler.results)); err != nil {
+					fmt.Fprintln(messageOutput, err)
+				}
+				saveFailedUsers()
+				fmt.Fprintf(messageOutput, "Re-run with --resume to skip the chunks already imported.\n")
+				hooks.fatalf("%v", err)
+			}
+
+			for i := 0; i < chunks.count(); i++ {
+				fmt.Fprintf(messageOutput, "Importing chunk %d/%d...\n", i+1, chunks.count())
+				users, err := chunks.chunk(i)
+				if err != nil {
+					failImport("Failed to read chunk %d: %w", i+1, err)
+				}
+				if err := scheduler.submit(ctx, i+1, users); err != nil {
+					failImport("Failed to import chunk %d: %w", i+1, err)
+				}
+			}
+
+			if err := scheduler.drain(ctx); err != nil {
+				failImport("Failed to import chunks: %w", err)
+			}
+
+			for _, result := range scheduler.results {
+				if failed := result.summary.GetFailed(); failed > 0 {
+					annotate("warning", "Users failed to import", "", fmt.Errorf("chunk %d (job %s): %d users failed to import", result.chunk, result.jobID, failed))
+				}
+			}
+
+			if err := writeStepSummary(importSummaryMarkdown(scheduler.results)); err != nil {
+				fmt.Fprintln(messageOutput, err)
+			}
+
+			if err := scheduler.state.complete(stateFile); err != nil {
+				fmt.Fprintln(messageOutput, err)
+			}
+			if err := removeSpoolDir(spool); err != nil {
+				fmt.Fprintln(messageOutput, err)
+			}
+
+			saveFailedUsers()
+
+			totals := importTotals(scheduler.results)
+			fmt.Fprintln(messageOutput, "All chunks imported successfully into the target tenant.")
+			if scheduler.skipped > 0 {
+				fmt.Fprintf(messageOutput, "%d chunks were skipped, they were imported by an earlier run.\n", scheduler.skipped)
+			}
+			fmt.Fprintf(messageOutput, "%d users processed: %d inserted, %d updated, %d failed.\n", totals.Total, totals.Inserted, totals.Updated, totals.Failed)
+
+			chunkIDs := make([]string, len(scheduler.results))
+			jobs := make([]map[string]interface{}, len(scheduler.results))
+			for i, result := range scheduler.results {
+				chunkIDs[i] = result.chunkID
+
+				var counts importCounts
+				counts.add(result.summary)
+				jobs[i] = map[string]interface{}{"chunk": result.chunk, "chunk_id": result.chunkID, "job_id": result.jobID, "status": result.status, "summary": counts}
+				if len(result.reasons) > 0 {
+					jobs[i]["errors"] = result.reasons
+				}
+			}
+
+			result := map[string]interface{}{"chunks": chunks.count(), "chunk_ids": chunkIDs, "jobs": jobs, "summary": totals, "skipped_chunks": scheduler.skipped, "dormant": dormant, "key": key}
+			hooks.mustApprove(ctx, "post_import", result)
+			if err := hooks.run("post_import", result); err != nil {
+				fmt.Fprintln(messageOutput, err)
+			}
+			reportResult("import", result)
 		},
 	}
 
-	rootCmd.AddCommand(exportCmd, importCmd)
+	importCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Users file to import (JSON, NDJSON or CSV), optionally gzipped, or a migration bundle; also an s3://, gs:// or azblob:// URL")
+	importCmd.Flags().IntVar(&maxPending, "max-pending", 2, "Maximum number of import jobs pending on the target tenant at once")
+	importCmd.Flags().IntVar(&maxPending, "workers", 2, "Number of import jobs run in parallel (alias of --max-pending)")
+	importCmd.MarkFlagsMutuallyExclusive("max-pending", "workers")
+	importCmd.Flags().IntVar(&maxUsers, "max-users", 0, "Abort before importing if the file has more users than this (0 for no limit)")
+	importCmd.Flags().DurationVar(&jobTimeout, "job-timeout", time.Hour, "How long to wait for a single import job before giving up (0 to wait indefinitely)")
+	importCmd.Flags().StringVar(&pauseFile, "pause-file", defaultPauseFile, "While this file exists, pending jobs are finished and no new ones are started")
+	importCmd.Flags().StringVar(&stateFile, "state-file", defaultImportStateFile, "File recording the import job of every chunk")
+	importCmd.Flags().StringVar(&spoolDir, "spool-dir", defaultSpoolDir, "Directory the chunks are written to before they are imported, kept until the import completes for --resume")
+	importCmd.Flags().BoolVar(&resume, "resume", false, "Skip the chunks the state file shows as imported by an earlier run")
+	importCmd.Flags().StringVar(&hashAlgorithm, "password-hash-algorithm", "", "Algorithm of exported password hashes that aren't recognised from their format, e.g. sha256")
+	importCmd.Flags().StringVar(&failedUsers, "failed-users", "", "Write the users that failed to import to this file (e.g. failed_users.json) for reprocessing")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate and chunk the users and report what would be imported, without calling the Management API")
+	importCmd.Flags().BoolVar(&force, "force", false, "Import even if the state file or the chunk ledger shows the users were already imported to the target")
+	importCmd.Flags().BoolVar(&skipConnCheck, "skip-connection-check", false, "Import without checking that the target connection accepts the users")
+	importCmd.Flags().StringVar(&ledgerFile, "ledger-file", defaultChunkLedgerFile, "File recording the chunks imported by every run, which later runs skip (empty to disable)")
+	importCmd.Flags().BoolVar(&skipConfig, "skip-config", false, "Only import the users of a migration bundle, not its config resources")
+	importCmd.Flags().StringSliceVar(&pluginNames, "plugin", nil, "Plugins from the config file to transform and validate users with, in order")
+	importCmd.Flags().StringVar(&activeWithin, "active-within", "", "Skip dormant users who haven't logged in within this time, e.g. 180d")
+	importCmd.Flags().StringVar(&transformFile, "transform", "", "YAML or JSON file of renames, drops, defaults and connection mappings applied to every user before the plugins")
+
+	exportCmd.AddCommand(newExportDownloadCmd(ctx, clients))
+	rootCmd.AddCommand(exportCmd, importCmd, newCampaignCmd(ctx), newUsersCmd(ctx, clients), newSyncCmd(ctx, clients), newConfigCmd(), newGenerateCmd(), newDiffCmd(ctx, clients), newReportCmd(ctx, clients), newCustomDomainsCmd(ctx, clients), newBundleCmd(ctx, clients), newMigrateCmd(ctx, clients), newCloneCmd(ctx, clients), newVerifyCmd(ctx, clients), newMonitorCmd(ctx, clients), newSetupCmd(ctx, clients), newListCmd(ctx, clients), newAnalyzeCmd(), newApproveCmd())
 	rootCmd.Execute()
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -33,8 +35,10 @@ func TestDownloadFile(t *testing.T) {
 	}
 }
 
-func TestUnzipGZFile(t *testing.T) {
-	content := "This is a test file for unzipping."
+func TestSplitUsersFromGzip(t *testing.T) {
+	content := `{"user_id": "2", "email": "user2@example.com", "identities": []}
+{"user_id": "1", "email": "user1@example.com"}
+{"user_id": "3", "email": "user3@example.com"}`
 	gzFile := "testfile.gz"
 
 	file, err := os.Create(gzFile)
@@ -51,13 +55,39 @@ func TestUnzipGZFile(t *testing.T) {
 	writer.Close()
 	file.Close()
 
-	data, err := unzipGZFile(gzFile)
+	input, err := openInputFile(gzFile)
 	if err != nil {
-		t.Fatalf("Failed to unzip file: %v", err)
+		t.Fatalf("Failed to open file: %v", err)
 	}
+	defer input.Close()
 
-	if strings.TrimSpace(string(data)) != content {
-		t.Errorf("Expected %s, but got %s", content, string(data))
+	verified := true
+	chunks, err := splitUsers(input, 100, &verified, func(record userRecord) (map[string]interface{}, error) {
+		if record.user["user_id"] == "3" {
+			return nil, nil
+		}
+		return record.user, nil
+	}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to split users: %v", err)
+	}
+	if chunks.count() != 2 || chunks.users != 2 {
+		t.Fatalf("Expected 2 users in 2 chunks, got %d in %d", chunks.users, chunks.count())
+	}
+
+	first, err := chunks.chunk(0)
+	if err != nil {
+		t.Fatalf("Failed to decode chunk: %v", err)
+	}
+	if first[0]["user_id"] != "1" || first[0]["email_verified"] != true {
+		t.Errorf("Expected user 1 first with email_verified set, got %v", first[0])
+	}
+	second, err := chunks.chunk(1)
+	if err != nil {
+		t.Fatalf("Failed to decode chunk: %v", err)
+	}
+	if _, ok := second[0]["identities"]; ok {
+		t.Errorf("Expected identities to be removed, got %v", second[0])
 	}
 }
 
@@ -83,3 +113,163 @@ func TestSplitJSONData(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectTenantProfile(t *testing.T) {
+	filename := "test_config.yaml"
+	config := `profiles:
+  dev:
+    domain: dev.eu.auth0.com
+    client_id: abc
+    client_secret: secret
+  broken:
+    domain: broken.eu.auth0.com
+`
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	defer os.Remove(filename)
+
+	configFile = filename
+	defer func() { configFile = "" }()
+
+	clients := &tenantClients{ctx: context.Background()}
+
+	client, err := selectTenant("dev", clients)
+	if err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	if again, _ := selectTenant("dev", clients); again != client {
+		t.Errorf("Expected the profile client to be reused")
+	}
+
+	if _, err := selectTenant("broken", clients); err == nil || !strings.Contains(err.Error(), "credentials of profile broken are missing") {
+		t.Errorf("Expected missing credentials error, got %v", err)
+	}
+	if _, err := selectTenant("staging", clients); err == nil || !strings.Contains(err.Error(), "unknown tenant") {
+		t.Errorf("Expected unknown tenant error, got %v", err)
+	}
+}
+
+func TestLoadEnvFiles(t *testing.T) {
+	for _, name := range []string{"TEST_ENV_FILE_A", "TEST_ENV_FILE_B"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	os.WriteFile("first.env", []byte("TEST_ENV_FILE_A=first\n"), 0644)
+	defer os.Remove("first.env")
+	os.WriteFile("second.env", []byte("TEST_ENV_FILE_A=second\nTEST_ENV_FILE_B=second\n"), 0644)
+	defer os.Remove("second.env")
+
+	if err := loadEnvFiles([]string{"first.env", "second.env"}); err != nil {
+		t.Fatalf("Failed to load env files: %v", err)
+	}
+	if os.Getenv("TEST_ENV_FILE_A") != "first" || os.Getenv("TEST_ENV_FILE_B") != "second" {
+		t.Errorf("Expected A from first.env and B from second.env, got %q and %q", os.Getenv("TEST_ENV_FILE_A"), os.Getenv("TEST_ENV_FILE_B"))
+	}
+
+	if err := loadEnvFiles([]string{"missing.env"}); err == nil {
+		t.Errorf("Expected an error for a missing env file")
+	}
+}
+
+func TestSplitJSONDataDeterministic(t *testing.T) {
+	first := []byte(`{"user_id": "3", "email": "user3@example.com"}
+{"user_id": "1", "email": "user1@example.com"}
+{"user_id": "2", "email": "user2@example.com"}`)
+	second := []byte(`[{"email": "user2@example.com", "user_id": "2"}, {"user_id": "1", "email": "user1@example.com"}, {"user_id": "3", "email": "user3@example.com"}]`)
+
+	a, err := splitJSONData(first, 100, true)
+	if err != nil {
+		t.Fatalf("Failed to split JSON data: %v", err)
+	}
+	b, err := splitJSONData(second, 100, true)
+	if err != nil {
+		t.Fatalf("Failed to split JSON data: %v", err)
+	}
+
+	if len(a) != len(b) {
+		t.Fatalf("Expected the same number of chunks, got %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if chunkID(a[i]) != chunkID(b[i]) {
+			t.Errorf("Expected chunk %d to have the same ID, got %s and %s", i+1, chunkID(a[i]), chunkID(b[i]))
+		}
+	}
+	if a[0][0]["user_id"] != "1" {
+		t.Errorf("Expected users sorted by user_id, first chunk starts with %v", a[0][0]["user_id"])
+	}
+}
+
+func TestCheckMaxUsers(t *testing.T) {
+	if err := checkMaxUsers(100, 0); err != nil {
+		t.Errorf("Expected no limit with 0, got %v", err)
+	}
+	if err := checkMaxUsers(100, 100); err != nil {
+		t.Errorf("Expected 100 users to be allowed, got %v", err)
+	}
+	if err := checkMaxUsers(101, 100); err == nil {
+		t.Errorf("Expected 101 users to exceed the limit")
+	}
+}
+
+func TestExportUsersLimit(t *testing.T) {
+	var requests []map[string]interface{}
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_1", "status": "pending"})
+	}))
+
+	ctx := context.Background()
+	fields := exportJobFields([]string{"user_id"}, nil, ".")
+	if _, err := exportUsers(ctx, m, "json", 0, fields); err != nil {
+		t.Fatalf("Failed to export users: %v", err)
+	}
+	if _, err := exportUsers(ctx, m, "json", 101, fields); err != nil {
+		t.Fatalf("Failed to export users: %v", err)
+	}
+
+	if _, ok := requests[0]["limit"]; ok {
+		t.Errorf("Expected no limit for a full export, got %v", requests[0]["limit"])
+	}
+	if requests[1]["limit"] != float64(101) {
+		t.Errorf("Expected limit 101, got %v", requests[1]["limit"])
+	}
+}
+
+func TestCheckExportComplete(t *testing.T) {
+	if err := checkExportComplete(100, 100, 0); err != nil {
+		t.Errorf("Expected a complete export, got %v", err)
+	}
+	if err := checkExportComplete(50, 100, 50); err != nil {
+		t.Errorf("Expected a limited export to be complete, got %v", err)
+	}
+	if err := checkExportComplete(50000, 120000, 0); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Expected a truncated export, got %v", err)
+	}
+}
+
+func TestConnectionUserCount(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/connections/con_1"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "con_1", "name": "Username-Password-Authentication"})
+		case strings.HasSuffix(r.URL.Path, "/users"):
+			if q := r.URL.Query().Get("q"); q != `identities.connection:"Username-Password-Authentication"` {
+				t.Errorf("Unexpected query %q", q)
+			}
+			// Like Auth0, the total stops at the 1000 results a search
+			// returns.
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []interface{}{}, "total": 1000})
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+
+	total, err := connectionUserCount(context.Background(), m, "con_1")
+	if err != nil || total != searchResultLimit {
+		t.Errorf("Expected the count to stop at %d users, got %d, %v", searchResultLimit, total, err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// userMatcher correlates a user record from one tenant with users in another.
+// Supported strategies are:
+//
+//	email                      match on the email address (default)
+//	username                   match on the username
+//	user_id                    match on the user ID, which imported users keep
+//	metadata:<path>            match on a metadata key, e.g. metadata:app_metadata.legacy_id
+//	expr:<template>            match with a Lucene query rendered from the record,
+//	                           e.g. expr:email:"{{.email}}" AND app_metadata.tenant:"acme"
+//
+// Values rendered into an expr query are escaped, so they can't change the
+// query.
+type userMatcher struct {
+	strategy string
+	path     []string
+	tmpl     *template.Template
+}
+
+func parseMatcher(spec string) (*userMatcher, error) {
+	strategy, arg, _ := strings.Cut(spec, ":")
+
+	switch strategy {
+	case "email", "username", "user_id":
+		if arg != "" {
+			return nil, fmt.Errorf("match strategy %q takes no argument", strategy)
+		}
+		return &userMatcher{strategy: strategy, path: []string{strategy}}, nil
+	case "metadata":
+		if !strings.HasPrefix(arg, "app_metadata.") && !strings.HasPrefix(arg, "user_metadata.") {
+			return nil, fmt.Errorf("metadata match key %q must start with app_metadata. or user_metadata.", arg)
+		}
+		return &userMatcher{strategy: strategy, path: strings.Split(arg, ".")}, nil
+	case "expr":
+		if arg == "" {
+			return nil, fmt.Errorf("expr match strategy requires a query template")
+		}
+		tmpl, err := template.New("match").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse match expression: %w", err)
+		}
+		return &userMatcher{strategy: strategy, tmpl: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown match strategy %q, expected email, username, user_id, metadata:<key> or expr:<query>", strategy)
+	}
+}
+
+func (m *userMatcher) String() string {
+	if m.strategy == "metadata" {
+		return "metadata:" + strings.Join(m.path, ".")
+	}
+	return m.strategy
+}
+
+// field returns the exported field the matcher reads, or "" for an expr
+// matcher, whose fields are only known to its template.
+func (m *userMatcher) field() string {
+	return strings.Join(m.path, ".")
+}
+
+// value returns the record's value the matcher reads, as it is, or false
+// when the record does not have it.
+func (m *userMatcher) value(user map[string]interface{}) (string, bool) {
+	if m.tmpl != nil {
+		var rendered strings.Builder
+		if err := m.tmpl.Execute(&rendered, escapeLuceneValues(user)); err != nil {
+			return "", false
+		}
+		return rendered.String(), true
+	}
+
+	value, ok := getPath(user, m.path)
+	if !ok || value == nil {
+		return "", false
+	}
+	s := fmt.Sprint(value)
+	return s, s != ""
+}
+
+// key returns the value used to correlate the record, or false when the
+// record does not have it. Emails are compared case-insensitively.
+func (m *userMatcher) key(user map[string]interface{}) (string, bool) {
+	key, ok := m.value(user)
+	if ok && m.strategy == "email" {
+		key = strings.ToLower(key)
+	}
+	return key, ok
+}
+
+// query returns the Lucene query finding the record's counterpart.
+func (m *userMatcher) query(user map[string]interface{}) (string, bool) {
+	key, ok := m.key(user)
+	if !ok {
+		return "", false
+	}
+	if m.tmpl != nil {
+		return key, true
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key)
+	return fmt.Sprintf(`%s:"%s"`, strings.Join(m.path, "."), escaped), true
+}
+
+// find looks up the users in the given tenant matching the record. Emails
+// are looked up as they are, since the lookup by email is case-sensitive.
+func (m *userMatcher) find(ctx context.Context, client *management.Management, user map[string]interface{}) ([]*management.User, error) {
+	if m.strategy == "email" {
+		email, ok := m.value(user)
+		if !ok {
+			return nil, nil
+		}
+		return client.User.ListByEmail(ctx, email)
+	}
+
+	query, ok := m.query(user)
+	if !ok {
+		return nil, nil
+	}
+
+	userList, err := client.User.Search(ctx, management.Query(query))
+	if err != nil {
+		return nil, err
+	}
+	return userList.Users, nil
+}
+
+// luceneEscaper escapes the characters with a meaning in a Lucene query,
+// whitespace included so that words like OR stay part of a term.
+var luceneEscaper = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `&`, `\&`, `|`, `\|`, `!`, `\!`, `(`, `\(`, `)`, `\)`,
+	`{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`, `^`, `\^`, `"`, `\"`, `~`, `\~`, `*`, `\*`,
+	`?`, `\?`, `:`, `\:`, `/`, `\/`, ` `, `\ `, "\t", "\\\t",
+)
+
+// escapeLuceneValues returns a copy of a record with every string value
+// escaped for a Lucene query, for rendering into an expr query.
+func escapeLuceneValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return luceneEscaper.Replace(v)
+	case map[string]interface{}:
+		escaped := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			escaped[key] = escapeLuceneValues(value)
+		}
+		return escaped
+	case []interface{}:
+		escaped := make([]interface{}, len(v))
+		for i, value := range v {
+			escaped[i] = escapeLuceneValues(value)
+		}
+		return escaped
+	}
+	return value
+}
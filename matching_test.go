@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestUserMatcherQuery(t *testing.T) {
+	user := map[string]interface{}{
+		"user_id":      "auth0|1",
+		"email":        "User1@Example.com",
+		"username":     `jo"e`,
+		"name":         `x" OR email:*`,
+		"app_metadata": map[string]interface{}{"legacy_id": float64(42)},
+	}
+
+	tests := []struct {
+		spec      string
+		wantKey   string
+		wantQuery string
+	}{
+		{"email", "user1@example.com", `email:"user1@example.com"`},
+		{"username", `jo"e`, `username:"jo\"e"`},
+		{"user_id", "auth0|1", `user_id:"auth0|1"`},
+		{"metadata:app_metadata.legacy_id", "42", `app_metadata.legacy_id:"42"`},
+		{`expr:email:"{{.email}}" AND app_metadata.tenant:"acme"`, `email:"User1@Example.com" AND app_metadata.tenant:"acme"`, `email:"User1@Example.com" AND app_metadata.tenant:"acme"`},
+	}
+
+	for _, tt := range tests {
+		matcher, err := parseMatcher(tt.spec)
+		if err != nil {
+			t.Fatalf("Failed to parse matcher %s: %v", tt.spec, err)
+		}
+
+		key, ok := matcher.key(user)
+		if !ok || key != tt.wantKey {
+			t.Errorf("%s: expected key %s, got %s", tt.spec, tt.wantKey, key)
+		}
+
+		query, ok := matcher.query(user)
+		if !ok || query != tt.wantQuery {
+			t.Errorf("%s: expected query %s, got %s", tt.spec, tt.wantQuery, query)
+		}
+	}
+
+	// Rendered values are escaped, so they can't end the phrase or add
+	// clauses.
+	matcher, _ := parseMatcher(`expr:name:"{{.name}}"`)
+	if query, _ := matcher.query(user); query != `name:"x\"\ OR\ email\:\*"` {
+		t.Errorf("Expected the rendered value to be escaped, got %s", query)
+	}
+
+	for _, spec := range []string{"phone", "metadata:legacy_id", "expr:"} {
+		if _, err := parseMatcher(spec); err == nil {
+			t.Errorf("Expected an error for match strategy %s", spec)
+		}
+	}
+}
+
+func TestUserMatcherFindByEmail(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if email := r.URL.Query().Get("email"); email != "User1@Example.com" {
+			t.Errorf("Expected the email to be looked up as it is, got %s", email)
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"user_id": "auth0|1"}})
+	}))
+
+	matcher, _ := parseMatcher("email")
+	users, err := matcher.find(context.Background(), m, map[string]interface{}{"email": "User1@Example.com"})
+	if err != nil || len(users) != 1 {
+		t.Errorf("Expected one user, got %d, %v", len(users), err)
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// cohort is one wave of a phased migration. Its users are selected by
+// exactly one of a search query, a role or a list of emails.
+type cohort struct {
+	Name       string   `yaml:"name"`
+	Query      string   `yaml:"query"`
+	Role       string   `yaml:"role"`
+	Emails     []string `yaml:"emails"`
+	EmailsFile string   `yaml:"emails_file"`
+}
+
+// cohortPlan is the cohort file given to migrate --cohort-file.
+type cohortPlan struct {
+	Pause   time.Duration `yaml:"pause"`
+	Cohorts []cohort      `yaml:"cohorts"`
+}
+
+func loadCohortPlan(filename string) (*cohortPlan, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cohort file: %w", err)
+	}
+
+	var plan cohortPlan
+	if err := yaml.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse cohort file: %w", err)
+	}
+	if len(plan.Cohorts) == 0 {
+		return nil, fmt.Errorf("cohort file defines no cohorts")
+	}
+
+	names := map[string]bool{}
+	for i, c := range plan.Cohorts {
+		if c.Name == "" {
+			return nil, fmt.Errorf("cohort %d has no name", i+1)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("cohort %s is defined twice", c.Name)
+		}
+		names[c.Name] = true
+
+		selectors := 0
+		for _, set := range []bool{c.Query != "", c.Role != "", len(c.Emails) > 0 || c.EmailsFile != ""} {
+			if set {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return nil, fmt.Errorf("cohort %s must select users by exactly one of query, role or emails", c.Name)
+		}
+	}
+
+	return &plan, nil
+}
+
+// readEmailList reads one email per line, ignoring blank lines and comments.
+func readEmailList(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open email list: %w", err)
+	}
+	defer file.Close()
+
+	var emails []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			emails = append(emails, line)
+		}
+	}
+	return emails, scanner.Err()
+}
+
+// cohortUsers reads the source users of a cohort from the connection.
+func cohortUsers(ctx context.Context, m *management.Management, connection string, c cohort) ([]*management.User, error) {
+	switch {
+	case c.Role != "":
+		ids, err := roleUserIDs(ctx, m, c.Role, 0)
+		if err != nil {
+			return nil, err
+		}
+		return lookupUsers(ctx, m, connection, "user_id", ids)
+
+	case c.Query != "":
+		return searchUsers(ctx, m, fmt.Sprintf(`identities.connection:"%s" AND (%s)`, connection, c.Query))
+
+	default:
+		emails := c.Emails
+		if c.EmailsFile != "" {
+			fromFile, err := readEmailList(c.EmailsFile)
+			if err != nil {
+				return nil, err
+			}
+			emails = append(emails, fromFile...)
+		}
+		return lookupUsers(ctx, m, connection, "email", emails)
+	}
+}
+
+// cohortData encodes the users of a cohort not migrated by an earlier cohort
+// as newline-delimited JSON, keeping only the given fields.
+func cohortData(users []*management.User, fields []string, migrated map[string]bool) ([]byte, int, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	count := 0
+
+	for _, user := range users {
+		if migrated[user.GetID()] {
+			continue
+		}
+		migrated[user.GetID()] = true
+
+		projected, err := projectUser(user, fields)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode user %s: %w", user.GetID(), err)
+		}
+		if err := encoder.Encode(projected); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode user %s: %w", user.GetID(), err)
+		}
+		count++
+	}
+
+	return buf.Bytes(), count, nil
+}
+
+// migrateUsersOptions are the flags of migrate users.
+type migrateUsersOptions struct {
+	output        string
+	chunkSize     int
+	emailVerified *bool
+	maxPending    int
+	jobTimeout    time.Duration
+	pauseFile     string
+	ledgerFile    string
+	// skipConnectionCheck imports without checking the target connection
+	// against the users first.
+	skipConnectionCheck bool
+	// hashAlgorithm is the algorithm of password hashes that aren't
+	// recognised from their format.
+	hashAlgorithm string
+	// transform rewrites every user before the import, if set.
+	transform *transformSpec
+	// activeSince leaves out users who haven't been active since then, if
+	// set.
+	activeSince time.Time
+}
+
+// parseEmailVerified maps --email-verified to the value set on the imported
+// users, nil keeping the exported value.
+func parseEmailVerified(value string) (*bool, error) {
+	switch value {
+	case "true":
+		return auth0.Bool(true), nil
+	case "false":
+		return auth0.Bool(false), nil
+	case "keep":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown value %q, expected true, false or keep", value)
+}
+
+// migrateUsers exports the users of the source connection, downloads the
+// export and imports it into the target connection in chunks.
+func migrateUsers(ctx context.Context, source, target *management.Management, cfg *config, fields []string, o migrateUsersOptions) (map[string]interface{}, error) {
+	// Fail before exporting if the file has to be encrypted but can't be.
+	var encryptKey []byte
+	if cfg.PII.requiresEncryption(fields) {
+		key, err := encryptionKey()
+		if err != nil {
+			return nil, fmt.Errorf("PII policy: %w", err)
+		}
+		encryptKey = key
+	}
+
+	fmt.Fprintln(messageOutput, "Starting user export from source tenant...")
+	jobID, err := exportUsers(ctx, source, "json", 0, exportJobFields(fields, nil, "."))
+	if err != nil {
+		return nil, fmt.Errorf("failed to export users: %w", apiError(err))
+	}
+	fmt.Fprintf(messageOutput, "Export job started in source tenant. Job ID: %s\n", jobID)
+
+	job, err := waitForExport(ctx, source, jobID, o.jobTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export users: %w", err)
+	}
+	if err := downloadExport(ctx, source, jobID, job.GetLocation(), o.output); err != nil {
+		return nil, fmt.Errorf("failed to download the export: %w", err)
+	}
+	if encryptKey != nil {
+		if err := encryptFile(o.output, encryptKey); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(messageOutput, "Encrypted %s as required by the PII policy.\n", o.output)
+	}
+
+	file, err := openInputFile(o.output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	spool, err := os.MkdirTemp("", "auth0-tools-spool-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	defer os.RemoveAll(spool)
+	if err := checkSpoolSpace(o.output, spool); err != nil {
+		return nil, err
+	}
+
+	anonymized, err := anonymizedFields(cfg, os.Getenv("DESTINATION_DOMAIN"))
+	if err != nil {
+		return nil, err
+	}
+	dormant := 0
+	chunks, err := splitUsers(file, o.chunkSize, o.emailVerified, func(record userRecord) (map[string]interface{}, error) {
+		if !o.activeSince.IsZero() && !userActiveSince(record.user, o.activeSince) {
+			dormant++
+			return nil, nil
+		}
+		if o.transform != nil {
+			if err := o.transform.apply(record.user); err != nil {
+				return nil, record.validationError(err)
+			}
+		}
+		if err := mapPasswordHash(record.user, o.hashAlgorithm); err != nil {
+			return nil, record.validationError(err)
+		}
+		anonymizeUser(record.user, anonymized, cfg.PIISalt)
+		return record.user, nil
+	}, spool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the exported users: %w", err)
+	}
+	if len(anonymized) > 0 {
+		fmt.Fprintf(messageOutput, "Anonymized %s for the non-production target as required by the PII policy.\n", strings.Join(anonymized, ", "))
+	}
+	if dormant > 0 {
+		fmt.Fprintf(messageOutput, "%d dormant users left out.\n", dormant)
+	}
+	fmt.Fprintf(messageOutput, "Exported %d users, importing them in %d chunks...\n", chunks.users, chunks.count())
+
+	if !o.skipConnectionCheck {
+		problems, err := checkTargetConnection(ctx, target, os.Getenv("DESTINATION_CONNECTION_ID"), chunks)
+		if err != nil {
+			return nil, err
+		}
+		if err := reportConnectionProblems(problems); err != nil {
+			return nil, err
+		}
+	}
+
+	scheduler := newImportScheduler(target, o.maxPending)
+	scheduler.jobTimeout = o.jobTimeout
+	scheduler.chunks = chunks.count()
+	scheduler.pauseFile = o.pauseFile
+	scheduler.destination = importDestination()
+	if scheduler.ledger, err = loadChunkLedger(o.ledgerFile); err != nil {
+		return nil, err
+	}
+	for i := 0; i < chunks.count(); i++ {
+		users, err := chunks.chunk(i)
+		if err != nil {
+			return nil, err
+		}
+		if err := scheduler.submit(ctx, i+1, users); err != nil {
+			return nil, fmt.Errorf("failed to import chunk %d: %w", i+1, err)
+		}
+	}
+	if err := scheduler.drain(ctx); err != nil {
+		return nil, fmt.Errorf("failed to import chunks: %w", err)
+	}
+
+	if err := writeStepSummary(importSummaryMarkdown(scheduler.results)); err != nil {
+		fmt.Fprintln(messageOutput, err)
+	}
+
+	return map[string]interface{}{
+		"export_job_id": jobID,
+		"file":          o.output,
+		"users":         chunks.users,
+		"dormant":       dormant,
+		"chunks":        chunks.count(),
+		"summary":       importTotals(scheduler.results),
+	}, nil
+}
+
+func newMigrateUsersCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		o             migrateUsersOptions
+		preset        string
+		emailVerified string
+		passwords     bool
+		transformFile string
+		activeWithin  string
+	)
+
+	var usersCmd = &cobra.Command{
+		Use:   "users",
+		Short: "Export the source users and import them into the target tenant in one run",
+		Run: func(cmd *cobra.Command, args []string) {
+			checkWritable("migrate users")
+			if o.maxPending <= 0 {
+				log.Fatalf("Max pending jobs must be greater than zero")
+			}
+			var err error
+			if o.emailVerified, err = parseEmailVerified(emailVerified); err != nil {
+				log.Fatalf("Invalid --email-verified: %v", err)
+			}
+
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			fields, err := exportFieldPreset(cfg, preset)
+			if err != nil {
+				log.Fatalf("Invalid preset: %v", err)
+			}
+			if passwords {
+				fields = append(slices.Clone(fields), passwordHashField)
+			}
+			if o.chunkSize == 0 {
+				o.chunkSize = maxImportFileSize
+				if cfg.ChunkSize > 0 {
+					o.chunkSize = cfg.ChunkSize
+				}
+			}
+			if o.chunkSize < 0 || o.chunkSize > maxImportFileSize {
+				log.Fatalf("Chunk size must be between 1 and %d bytes", maxImportFileSize)
+			}
+			if transformFile != "" {
+				if o.transform, err = loadTransformSpec(transformFile); err != nil {
+					log.Fatalf("Invalid --transform: %v", err)
+				}
+			}
+			if activeWithin != "" {
+				age, err := parseAge(activeWithin)
+				if err != nil {
+					log.Fatalf("Invalid --active-within: %v", err)
+				}
+				o.activeSince = time.Now().Add(-age)
+				fields = withActivityFields(fields)
+			}
+
+			sourceClient := clients.sourceClient()
+			clients.guardTarget("upsert users")
+
+			result, err := migrateUsers(ctx, sourceClient, clients.targetClient(), cfg, fields, o)
+			if err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+
+			totals := result["summary"].(importCounts)
+			fmt.Fprintf(messageOutput, "Migration completed: %d users exported, %d chunks imported, %d inserted, %d updated, %d failed.\n",
+				result["users"], result["chunks"], totals.Inserted, totals.Updated, totals.Failed)
+			reportResult("migrate users", result)
+		},
+	}
+
+	usersCmd.Flags().StringVar(&preset, "preset", defaultFieldPreset, "Field preset of the migrated profiles: minimal, standard, full, analytics or one from the config")
+	usersCmd.Flags().StringVar(&o.output, "output", "exported_users.json.gz", "File the export is downloaded to")
+	usersCmd.Flags().IntVar(&o.chunkSize, "chunk-size", 0, "Maximum size of an import chunk in bytes (default chunk_size from the config, or 500000)")
+	usersCmd.Flags().StringVar(&emailVerified, "email-verified", "true", "email_verified of the imported users: true, false, or keep to import the exported value")
+	usersCmd.Flags().BoolVar(&passwords, "password-hashes", false, "Migrate the password hashes, where the source connection allows exporting them")
+	usersCmd.Flags().StringVar(&o.hashAlgorithm, "password-hash-algorithm", "", "Algorithm of password hashes that aren't recognised from their format, e.g. sha256")
+	usersCmd.Flags().StringVar(&activeWithin, "active-within", "", "Leave out dormant users who haven't logged in within this time, e.g. 180d")
+	usersCmd.Flags().StringVar(&transformFile, "transform", "", "YAML or JSON file of renames, drops, defaults and connection mappings applied to every user before the import")
+	usersCmd.Flags().IntVar(&o.maxPending, "max-pending", 2, "Maximum number of import jobs pending on the target tenant at once")
+	usersCmd.Flags().IntVar(&o.maxPending, "workers", 2, "Number of import jobs run in parallel (alias of --max-pending)")
+	usersCmd.MarkFlagsMutuallyExclusive("max-pending", "workers")
+	usersCmd.Flags().DurationVar(&o.jobTimeout, "job-timeout", time.Hour, "How long to wait for the export job and each import job before giving up (0 to wait indefinitely)")
+	usersCmd.Flags().StringVar(&o.pauseFile, "pause-file", defaultPauseFile, "While this file exists, pending jobs are finished and no new ones are started")
+	usersCmd.Flags().BoolVar(&o.skipConnectionCheck, "skip-connection-check", false, "Import without checking that the target connection accepts the users")
+	usersCmd.Flags().StringVar(&o.ledgerFile, "ledger-file", defaultChunkLedgerFile, "File recording the chunks imported by every run, which later runs skip (empty to disable)")
+
+	return usersCmd
+}
+
+func newMigrateCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		cohortFile string
+		preset     string
+		pause      time.Duration
+		yes        bool
+		dryRun     bool
+		maxPending int
+		jobTimeout time.Duration
+		pauseFile  string
+		ledgerFile string
+	)
+
+	var migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate users from the source to the target tenant in cohorts",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate users")
+			}
+			if maxPending <= 0 {
+				log.Fatalf("Max pending jobs must be greater than zero")
+			}
+
+			plan, err := loadCohortPlan(cohortFile)
+			if err != nil {
+				log.Fatalf("Invalid cohort file: %v", err)
+			}
+			if cmd.Flags().Changed("pause") {
+				plan.Pause = pause
+			}
+
+			cfg, err := loadOptionalConfig()
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+			fields, err := exportFieldPreset(cfg, preset)
+			if err != nil {
+				log.Fatalf("Invalid preset: %v", err)
+			}
+			chunkSize := maxImportFileSize
+			if cfg.ChunkSize > 0 {
+				chunkSize = cfg.ChunkSize
+			}
+
+			sourceClient := clients.sourceClient()
+			connection, err := sourceClient.Connection.Read(ctx, os.Getenv("SOURCE_CONNECTION_ID"))
+			if err != nil {
+				log.Fatalf("Failed to read the source connection: %v", apiError(err))
+			}
+			if !dryRun {
+				clients.guardTarget("upsert users")
+			}
+
+			migrated := map[string]bool{}
+			var reports []map[string]interface{}
+
+			for i, c := range plan.Cohorts {
+				if i > 0 && !dryRun {
+					if plan.Pause > 0 {
+						fmt.Fprintf(messageOutput, "Pausing %s before cohort %s...\n", plan.Pause, c.Name)
+						time.Sleep(plan.Pause)
+					}
+					if !yes {
+						ok, err := confirm(fmt.Sprintf("Start cohort %s (%d/%d)?", c.Name, i+1, len(plan.Cohorts)))
+						if err != nil {
+							log.Fatalf("%v (re-run with --yes to migrate all cohorts)", err)
+						}
+						if !ok {
+							fmt.Fprintf(messageOutput, "Stopped before cohort %s.\n", c.Name)
+							break
+						}
+					}
+				}
+
+				users, err := cohortUsers(ctx, sourceClient, connection.GetName(), c)
+				if err != nil {
+					log.Fatalf("Failed to read users of cohort %s: %v", c.Name, err)
+				}
+				data, count, err := cohortData(users, fields, migrated)
+				if err != nil {
+					log.Fatalf("Failed to prepare cohort %s: %v", c.Name, err)
+				}
+
+				report := map[string]interface{}{"name": c.Name, "users": count}
+				reports = append(reports, report)
+				fmt.Fprintf(messageOutput, "Cohort %s: %d users (%d already migrated by an earlier cohort).\n", c.Name, count, len(users)-count)
+				if dryRun || count == 0 {
+					continue
+				}
+
+				data, anonymized, err := anonymizeForTarget(cfg, os.Getenv("DESTINATION_DOMAIN"), data)
+				if err != nil {
+					log.Fatalf("Failed to anonymize cohort %s: %v", c.Name, err)
+				}
+				if len(anonymized) > 0 {
+					fmt.Fprintf(messageOutput, "Anonymized %s for the non-production target as required by the PII policy.\n", strings.Join(anonymized, ", "))
+				}
+
+				chunks, err := splitJSONData(data, chunkSize, true)
+				if err != nil {
+					log.Fatalf("Failed to split cohort %s: %v", c.Name, err)
+				}
+
+				scheduler := newImportScheduler(clients.targetClient(), maxPending)
+				scheduler.jobTimeout = jobTimeout
+				scheduler.chunks = len(chunks)
+				scheduler.pauseFile = pauseFile
+				scheduler.destination = importDestination()
+				if scheduler.ledger, err = loadChunkLedger(ledgerFile); err != nil {
+					log.Fatalf("Failed to load the chunk ledger: %v", err)
+				}
+				for j, chunk := range chunks {
+					if err := scheduler.submit(ctx, j+1, chunk); err != nil {
+						log.Fatalf("Failed to import chunk %d of cohort %s: %v", j+1, c.Name, err)
+					}
+				}
+				if err := scheduler.drain(ctx); err != nil {
+					log.Fatalf("Failed to import cohort %s: %v", c.Name, err)
+				}
+
+				totals := importTotals(scheduler.results)
+				fmt.Fprintf(messageOutput, "Cohort %s migrated: %d users processed, %d inserted, %d updated, %d failed.\n",
+					c.Name, totals.Total, totals.Inserted, totals.Updated, totals.Failed)
+				if err := writeStepSummary(fmt.Sprintf("## Cohort %s\n\n", c.Name) + importSummaryMarkdown(scheduler.results)); err != nil {
+					fmt.Fprintln(messageOutput, err)
+				}
+				report["chunks"] = len(chunks)
+				report["summary"] = totals
+			}
+
+			reportResult("migrate", map[string]interface{}{"cohorts": reports, "dry_run": dryRun})
+		},
+	}
+
+	migrateCmd.Flags().StringVar(&cohortFile, "cohort-file", "cohorts.yaml", "YAML file defining the ordered cohorts")
+	migrateCmd.Flags().StringVar(&preset, "preset", defaultFieldPreset, "Field preset of the migrated profiles: minimal, standard, full, analytics or one from the config")
+	migrateCmd.Flags().DurationVar(&pause, "pause", 0, "Pause between cohorts, overriding the cohort file")
+	migrateCmd.Flags().BoolVar(&yes, "yes", false, "Start each cohort without asking")
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report how many users each cohort has")
+	migrateCmd.Flags().IntVar(&maxPending, "max-pending", 2, "Maximum number of import jobs pending on the target tenant at once")
+	migrateCmd.Flags().IntVar(&maxPending, "workers", 2, "Number of import jobs run in parallel (alias of --max-pending)")
+	migrateCmd.MarkFlagsMutuallyExclusive("max-pending", "workers")
+	migrateCmd.Flags().DurationVar(&jobTimeout, "job-timeout", time.Hour, "How long to wait for a single import job before giving up (0 to wait indefinitely)")
+	migrateCmd.Flags().StringVar(&pauseFile, "pause-file", defaultPauseFile, "While this file exists, pending jobs are finished and no new ones are started")
+	migrateCmd.Flags().StringVar(&ledgerFile, "ledger-file", defaultChunkLedgerFile, "File recording the chunks imported by every run, which later runs skip (empty to disable)")
+
+	migrateCmd.AddCommand(newMigrateRolesCmd(ctx, clients), newMigrateUsersCmd(ctx, clients), newMigrateOrganizationsCmd(ctx, clients), newMigrateActionsCmd(ctx, clients), newMigrateClientsCmd(ctx, clients), newMigrateAPIsCmd(ctx, clients), newMigrateConnectionsCmd(ctx, clients), newMigrateEmailsCmd(ctx, clients), newMigrateBrandingCmd(ctx, clients), newMigrateTenantSettingsCmd(ctx, clients))
+	return migrateCmd
+}
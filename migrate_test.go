@@ -0,0 +1,151 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestLoadCohortPlan(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cohorts.yaml")
+	os.WriteFile(filename, []byte(`pause: 15m
+cohorts:
+  - name: admins
+    role: rol_1
+  - name: pilot
+    emails: [a@example.com]
+  - name: pro
+    query: 'app_metadata.plan:"pro"'
+`), 0644)
+
+	plan, err := loadCohortPlan(filename)
+	if err != nil {
+		t.Fatalf("Failed to load cohort plan: %v", err)
+	}
+	if plan.Pause != 15*time.Minute || len(plan.Cohorts) != 3 || plan.Cohorts[1].Emails[0] != "a@example.com" {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+
+	os.WriteFile(filename, []byte("cohorts:\n  - name: mixed\n    role: rol_1\n    query: 'email:*'\n"), 0644)
+	if _, err := loadCohortPlan(filename); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Errorf("Expected an error for a cohort with two selectors, got %v", err)
+	}
+}
+
+func TestReadEmailList(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pilot.txt")
+	os.WriteFile(filename, []byte("# pilot users\na@example.com\n\n b@example.com \n"), 0644)
+
+	emails, err := readEmailList(filename)
+	if err != nil || len(emails) != 2 || emails[1] != "b@example.com" {
+		t.Errorf("Unexpected emails %v %v", emails, err)
+	}
+}
+
+func TestCohortDataSkipsMigratedUsers(t *testing.T) {
+	migrated := map[string]bool{"auth0|1": true}
+	users := []*management.User{
+		{ID: auth0.String("auth0|1"), Email: auth0.String("a@example.com")},
+		{ID: auth0.String("auth0|2"), Email: auth0.String("b@example.com"), Name: auth0.String("B")},
+	}
+
+	data, count, err := cohortData(users, []string{"user_id", "email"}, migrated)
+	if err != nil {
+		t.Fatalf("Failed to encode cohort: %v", err)
+	}
+	if count != 1 || strings.TrimSpace(string(data)) != `{"email":"b@example.com","user_id":"auth0|2"}` {
+		t.Errorf("Unexpected cohort data %d %s", count, data)
+	}
+	if !migrated["auth0|2"] {
+		t.Errorf("Expected auth0|2 to be marked as migrated")
+	}
+}
+
+func TestMigrateWorkersAlias(t *testing.T) {
+	cmd := newMigrateCmd(context.Background(), &tenantClients{})
+	if err := cmd.ParseFlags([]string{"--workers", "3"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if value := cmd.Flags().Lookup("max-pending").Value.String(); value != "3" {
+		t.Errorf("Expected --workers to set the pending job limit, got %s", value)
+	}
+}
+
+func TestMigrateUsers(t *testing.T) {
+	var mu sync.Mutex
+	var imported []map[string]interface{}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/api/v2/jobs/users-exports":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_export", "status": "pending"})
+		case r.URL.Path == "/api/v2/jobs/job_export":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_export", "status": "completed", "location": "http://" + r.Host + "/export.json.gz"})
+		case r.URL.Path == "/export.json.gz":
+			gzWriter := gzip.NewWriter(w)
+			gzWriter.Write([]byte("{\"user_id\": \"auth0|2\", \"email\": \"b@example.com\", \"email_verified\": false}\n{\"user_id\": \"auth0|1\", \"email\": \"a@example.com\", \"email_verified\": true}\n"))
+			gzWriter.Close()
+		case strings.HasPrefix(r.URL.Path, "/api/v2/connections/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "con_1", "name": "Username-Password-Authentication", "strategy": "auth0", "options": map[string]interface{}{}})
+		case r.URL.Path == "/api/v2/jobs/users-imports":
+			file, _, err := r.FormFile("users")
+			if err != nil {
+				t.Errorf("Expected a users file in the import job: %v", err)
+				return
+			}
+			var users []map[string]interface{}
+			json.NewDecoder(file).Decode(&users)
+			imported = append(imported, users...)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": fmt.Sprintf("job_import_%d", len(imported)), "status": "pending"})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_import", "status": "completed", "summary": map[string]int{"total": 1, "inserted": 1}})
+		}
+	}))
+
+	emailVerified, err := parseEmailVerified("keep")
+	if err != nil {
+		t.Fatalf("Failed to parse --email-verified: %v", err)
+	}
+	o := migrateUsersOptions{
+		output:        filepath.Join(t.TempDir(), "exported_users.json.gz"),
+		chunkSize:     80,
+		emailVerified: emailVerified,
+		maxPending:    2,
+	}
+
+	result, err := migrateUsers(context.Background(), m, m, &config{}, []string{"user_id", "email", "email_verified"}, o)
+	if err != nil {
+		t.Fatalf("Failed to migrate users: %v", err)
+	}
+
+	if result["users"] != 2 || result["chunks"] != 2 || result["summary"].(importCounts).Inserted != 2 {
+		t.Errorf("Unexpected result %v", result)
+	}
+	if len(imported) != 2 || imported[0]["user_id"] != "auth0|1" || imported[1]["email_verified"] != false {
+		t.Errorf("Expected the users imported in user_id order with email_verified kept, got %v", imported)
+	}
+}
+
+func TestParseEmailVerified(t *testing.T) {
+	if value, err := parseEmailVerified("false"); err != nil || value == nil || *value {
+		t.Errorf("Expected false, got %v %v", value, err)
+	}
+	if _, err := parseEmailVerified("yes"); err == nil {
+		t.Errorf("Expected an error for an unknown value")
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// driftResult is one check of the drift monitor.
+type driftResult struct {
+	Time       time.Time        `json:"time"`
+	Compared   int              `json:"compared"`
+	Mismatches []sampleMismatch `json:"mismatches"`
+	Drift      float64          `json:"drift"`
+}
+
+// driftMonitor samples users of both tenants while they are live side by
+// side and alerts when the share of users that differ exceeds threshold.
+// Alerts go to webhook as JSON and to slackWebhook as a Slack message, once
+// when the drift exceeds the threshold and once when it is back within it.
+type driftMonitor struct {
+	source       *management.Management
+	target       *management.Management
+	sample       int
+	fields       []string
+	matcher      *userMatcher
+	threshold    float64
+	webhook      string
+	slackWebhook string
+	rng          *rand.Rand
+	drifting     bool
+}
+
+// check samples users of the source and of the target tenant and compares
+// each with its user on the other tenant.
+func (d *driftMonitor) check(ctx context.Context) (driftResult, error) {
+	result := driftResult{Time: time.Now().UTC(), Mismatches: []sampleMismatch{}}
+
+	for _, side := range []struct {
+		from, to *management.Management
+		reverse  bool
+	}{{d.source, d.target, false}, {d.target, d.source, true}} {
+		users, _, err := sampleUsers(ctx, side.from, d.sample, d.rng)
+		if err != nil {
+			return result, err
+		}
+		mismatches, skipped, err := compareSampledUsers(ctx, side.to, d.matcher, users, d.fields)
+		if err != nil {
+			return result, err
+		}
+		if side.reverse {
+			mismatches = reverseMismatches(mismatches)
+		}
+		result.Compared += len(users) - skipped
+		result.Mismatches = append(result.Mismatches, mismatches...)
+	}
+
+	if result.Compared > 0 {
+		result.Drift = float64(len(result.Mismatches)) / float64(result.Compared)
+	}
+	return result, nil
+}
+
+// reverseMismatches turns the mismatches of target users compared with the
+// source into mismatches from the source's point of view.
+func reverseMismatches(mismatches []sampleMismatch) []sampleMismatch {
+	for i, mismatch := range mismatches {
+		if mismatch.Fields == nil {
+			mismatches[i].Problem = "missing in source"
+			continue
+		}
+		for j, field := range mismatch.Fields {
+			mismatches[i].Fields[j].Source, mismatches[i].Fields[j].Target = field.Target, field.Source
+		}
+	}
+	return mismatches
+}
+
+// observe alerts if the drift of a check crossed the threshold in either
+// direction since the last check.
+func (d *driftMonitor) observe(result driftResult) error {
+	drifting := result.Drift > d.threshold
+	if drifting == d.drifting {
+		return nil
+	}
+	d.drifting = drifting
+
+	status := "drift_recovered"
+	text := fmt.Sprintf("Tenant drift is back within %.1f%%: %d of %d sampled users differ.", d.threshold*100, len(result.Mismatches), result.Compared)
+	if drifting {
+		status = "drift_detected"
+		text = fmt.Sprintf("Tenant drift of %.1f%% exceeds %.1f%%: %d of %d sampled users differ.", result.Drift*100, d.threshold*100, len(result.Mismatches), result.Compared)
+		for i, mismatch := range result.Mismatches {
+			if i == 5 {
+				text += fmt.Sprintf("\n…and %d more", len(result.Mismatches)-i)
+				break
+			}
+			text += fmt.Sprintf("\n• %s (%s): %s", mismatch.User, mismatch.Connection, mismatch.Problem)
+		}
+	}
+
+	emitEvent(status, map[string]interface{}{"drift": result.Drift, "compared": result.Compared, "mismatches": len(result.Mismatches)})
+	if d.webhook != "" {
+		if err := postAlert(d.webhook, map[string]interface{}{"status": status, "threshold": d.threshold, "result": result}); err != nil {
+			return err
+		}
+	}
+	if d.slackWebhook != "" {
+		if err := postAlert(d.slackWebhook, map[string]interface{}{"text": text}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postAlert POSTs a JSON alert to a webhook.
+func postAlert(url string, payload interface{}) error {
+	data, _ := json.Marshal(payload)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert failed with HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newMonitorCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var monitorCmd = &cobra.Command{
+		Use:   "monitor",
+		Short: "Watch the source and target tenants while both are live",
+	}
+
+	var (
+		interval time.Duration
+		duration time.Duration
+		match    string
+		d        driftMonitor
+	)
+
+	var driftCmd = &cobra.Command{
+		Use:   "drift",
+		Short: "Repeatedly compare sampled users of both tenants and alert when they drift apart",
+		Run: func(cmd *cobra.Command, args []string) {
+			if interval <= 0 {
+				log.Fatalf("Interval must be greater than zero")
+			}
+			if d.sample <= 0 {
+				log.Fatalf("Sample size must be greater than zero")
+			}
+			if d.threshold < 0 || d.threshold >= 1 {
+				log.Fatalf("Threshold must be between 0 and 1")
+			}
+			var err error
+			if d.matcher, err = parseMatcher(match); err != nil {
+				log.Fatalf("Invalid --match: %v", err)
+			}
+			d.source, d.target = clients.sourceClient(), clients.targetClient()
+			d.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+			var deadline <-chan time.Time
+			if duration > 0 {
+				deadline = time.After(duration)
+			}
+
+			checks, alerts := 0, 0
+			for {
+				result, err := d.check(ctx)
+				if err != nil {
+					// A failed check is retried at the next interval.
+					progressPrintf("%s drift check failed: %v\n", time.Now().Format(time.RFC3339), err)
+				} else {
+					checks++
+					fmt.Fprintf(messageOutput, "%s %d of %d sampled users differ (%.1f%% drift)\n", result.Time.Format(time.RFC3339), len(result.Mismatches), result.Compared, result.Drift*100)
+					emitEvent("drift_checked", map[string]interface{}{"drift": result.Drift, "compared": result.Compared, "mismatches": len(result.Mismatches)})
+
+					drifting := d.drifting
+					if err := d.observe(result); err != nil {
+						progressPrintf("Failed to alert: %v\n", err)
+					} else if d.drifting && !drifting {
+						alerts++
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-deadline:
+					reportResult("monitor drift", map[string]interface{}{"checks": checks, "alerts": alerts, "drifting": d.drifting})
+					return
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	driftCmd.Flags().DurationVar(&interval, "interval", time.Hour, "Time between checks")
+	driftCmd.Flags().DurationVar(&duration, "duration", 0, "Stop after this long (0 to run until stopped)")
+	driftCmd.Flags().IntVar(&d.sample, "sample", 50, "Number of random users sampled from each tenant per check")
+	driftCmd.Flags().StringSliceVar(&d.fields, "fields", defaultVerifyFields, "User fields compared for the sampled users")
+	driftCmd.Flags().StringVar(&match, "match", "email", "How sampled users are matched across tenants: email, username, user_id, metadata:<key> or expr:<query>")
+	driftCmd.Flags().Float64Var(&d.threshold, "threshold", 0.01, "Share of differing sampled users above which an alert is sent")
+	driftCmd.Flags().StringVar(&d.webhook, "webhook", "", "URL that gets a JSON POST with the check result when drift is detected or recovers")
+	driftCmd.Flags().StringVar(&d.slackWebhook, "slack-webhook", "", "Slack incoming webhook URL to post alerts to")
+
+	monitorCmd.AddCommand(driftCmd)
+	return monitorCmd
+}
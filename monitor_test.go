@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDriftMonitor(t *testing.T) {
+	identity := []map[string]interface{}{{"connection": "Username-Password-Authentication"}}
+	tenant := func(tenantUsers *[]map[string]interface{}) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			users := *tenantUsers
+			if strings.HasSuffix(r.URL.Path, "/users-by-email") {
+				matches := []map[string]interface{}{}
+				for _, user := range users {
+					if user["email"] == r.URL.Query().Get("email") {
+						matches = append(matches, user)
+					}
+				}
+				json.NewEncoder(w).Encode(matches)
+				return
+			}
+			page := 0
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": users[page : page+1], "start": page, "limit": 1, "length": 1, "total": len(users)})
+		})
+	}
+
+	sourceUsers := []map[string]interface{}{
+		{"user_id": "auth0|1", "email": "a@example.com", "name": "A", "identities": identity},
+		{"user_id": "auth0|2", "email": "b@example.com", "name": "B", "identities": identity},
+	}
+	targetUsers := []map[string]interface{}{
+		{"user_id": "auth0|1", "email": "a@example.com", "name": "A", "identities": identity},
+		{"user_id": "auth0|2", "email": "b@example.com", "name": "B", "identities": identity},
+	}
+
+	var alerts []map[string]interface{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&alert)
+		alerts = append(alerts, alert)
+	}))
+	defer webhook.Close()
+
+	matcher, _ := parseMatcher("email")
+	d := driftMonitor{
+		source:       newTestManagement(t, tenant(&sourceUsers)),
+		target:       newTestManagement(t, tenant(&targetUsers)),
+		sample:       10,
+		fields:       defaultVerifyFields,
+		matcher:      matcher,
+		threshold:    0.1,
+		webhook:      webhook.URL,
+		slackWebhook: webhook.URL,
+		rng:          rand.New(rand.NewSource(1)),
+	}
+	ctx := context.Background()
+
+	check := func() driftResult {
+		t.Helper()
+		result, err := d.check(ctx)
+		if err != nil {
+			t.Fatalf("Failed to check drift: %v", err)
+		}
+		if err := d.observe(result); err != nil {
+			t.Fatalf("Failed to alert: %v", err)
+		}
+		return result
+	}
+
+	if result := check(); result.Compared != 4 || len(result.Mismatches) != 0 || len(alerts) != 0 {
+		t.Fatalf("Expected no drift and no alert, got %+v and %d alerts", result, len(alerts))
+	}
+
+	// The name changes on the target and a user signs up on the target only.
+	targetUsers[1]["name"] = "Changed"
+	targetUsers = append(targetUsers, map[string]interface{}{"user_id": "auth0|3", "email": "c@example.com", "identities": identity})
+
+	result := check()
+	if result.Compared != 5 || len(result.Mismatches) != 3 {
+		t.Fatalf("Expected 3 of 5 sampled users to differ, got %+v", result)
+	}
+	for _, mismatch := range result.Mismatches {
+		if mismatch.User == "b@example.com" && (mismatch.Fields[0].Source != "B" || mismatch.Fields[0].Target != "Changed") {
+			t.Errorf("Expected the fields of target users to be reported from the source's point of view, got %+v", mismatch.Fields)
+		}
+		if mismatch.User == "c@example.com" && mismatch.Problem != "missing in source" {
+			t.Errorf("Expected c@example.com to be missing in source, got %s", mismatch.Problem)
+		}
+	}
+	if len(alerts) != 2 || alerts[0]["status"] != "drift_detected" || !strings.Contains(alerts[1]["text"].(string), "exceeds 10.0%") {
+		t.Fatalf("Expected a webhook and a Slack alert, got %v", alerts)
+	}
+
+	// No further alert while the drift persists, and one once it recovers.
+	check()
+	if len(alerts) != 2 {
+		t.Errorf("Expected no repeated alert, got %d alerts", len(alerts))
+	}
+	targetUsers = sourceUsers
+	check()
+	if len(alerts) != 4 || alerts[2]["status"] != "drift_recovered" {
+		t.Errorf("Expected recovery alerts, got %v", alerts)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// organizationMemberBatch is how many members are added to an organization
+// per request, the most Auth0 accepts.
+const organizationMemberBatch = 10
+
+// listOrganizations returns all organizations of a tenant, sorted by name.
+// Checkpoint pagination isn't capped at 1000 organizations.
+func listOrganizations(ctx context.Context, m *management.Management) ([]*management.Organization, error) {
+	var organizations []*management.Organization
+	options := []management.RequestOption{management.Take(100)}
+
+	for {
+		list, err := m.Organization.List(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", apiError(err))
+		}
+		organizations = append(organizations, list.Organizations...)
+		if list.Next == "" || len(list.Organizations) == 0 {
+			break
+		}
+		options = []management.RequestOption{management.Take(100), management.From(list.Next)}
+	}
+
+	sort.SliceStable(organizations, func(i, j int) bool { return organizations[i].GetName() < organizations[j].GetName() })
+	return organizations, nil
+}
+
+// organizationConnections returns the connections enabled for an
+// organization.
+func organizationConnections(ctx context.Context, m *management.Management, id string) ([]*management.OrganizationConnection, error) {
+	var connections []*management.OrganizationConnection
+
+	for page := 0; ; page++ {
+		list, err := m.Organization.Connections(ctx, id, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list connections of organization %s: %w", id, apiError(err))
+		}
+		connections = append(connections, list.OrganizationConnections...)
+		if !list.HasNext() {
+			return connections, nil
+		}
+	}
+}
+
+// organizationMembers returns the members of an organization with the names
+// of their roles in it, by user ID.
+func organizationMembers(ctx context.Context, m *management.Management, id string) (map[string][]string, error) {
+	members := map[string][]string{}
+	options := []management.RequestOption{management.Take(100), management.IncludeFields("user_id", "roles")}
+
+	for {
+		list, err := m.Organization.Members(ctx, id, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of organization %s: %w", id, apiError(err))
+		}
+		for _, member := range list.Members {
+			roles := []string{}
+			for _, role := range member.Roles {
+				roles = append(roles, role.GetName())
+			}
+			members[member.GetUserID()] = roles
+		}
+		if list.Next == "" || len(list.Members) == 0 {
+			return members, nil
+		}
+		options = []management.RequestOption{management.Take(100), management.From(list.Next), management.IncludeFields("user_id", "roles")}
+	}
+}
+
+// existingUserIDs returns which of the user IDs exist in a tenant, in any
+// connection.
+func existingUserIDs(ctx context.Context, m *management.Management, ids []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+
+	for start := 0; start < len(ids); start += lookupBatch {
+		end := min(start+lookupBatch, len(ids))
+		quoted := make([]string, end-start)
+		for i, id := range ids[start:end] {
+			quoted[i] = fmt.Sprintf("%q", id)
+		}
+
+		list, err := m.User.Search(ctx, management.Query("user_id:("+strings.Join(quoted, " OR ")+")"), management.PerPage(lookupBatch), management.IncludeFields("user_id"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up users: %w", apiError(err))
+		}
+		for _, user := range list.Users {
+			existing[user.GetID()] = true
+		}
+	}
+	return existing, nil
+}
+
+// organizationTargets maps the connection and role names of the source to
+// the IDs of the target connections and roles with the same (or remapped)
+// names.
+type organizationTargets struct {
+	connections map[string]string
+	roles       map[string]string
+	remap       nameRemap
+}
+
+// organizationMigration counts what migrating one organization changed.
+type organizationMigration struct {
+	created            bool
+	updated            bool
+	connections        int
+	missingConnections []string
+	members            int
+	missingMembers     int
+	roles              int
+	missingRoles       []string
+}
+
+// organizationChanged reports whether the display name, branding or
+// metadata of the target organization differ from the source.
+func organizationChanged(source, target *management.Organization) bool {
+	return source.GetDisplayName() != target.GetDisplayName() ||
+		!reflect.DeepEqual(source.Branding, target.Branding) ||
+		!reflect.DeepEqual(source.Metadata, target.Metadata)
+}
+
+// migrateOrganization creates or updates a source organization in the
+// target tenant, enables its connections there, adds its members that exist
+// in the target and assigns them their roles in the organization. Imported
+// users keep their user_id, so members are mapped by user_id; connections
+// and roles are mapped by name. Nothing is removed, and nothing is changed
+// on a dry run.
+func migrateOrganization(ctx context.Context, source, target *management.Management, organization, existing *management.Organization, targets organizationTargets, dryRun bool) (organizationMigration, error) {
+	var result organizationMigration
+
+	switch {
+	case existing == nil:
+		result.created = true
+		if !dryRun {
+			existing = &management.Organization{
+				Name:        organization.Name,
+				DisplayName: organization.DisplayName,
+				Branding:    organization.Branding,
+				Metadata:    organization.Metadata,
+			}
+			if err := target.Organization.Create(ctx, existing); err != nil {
+				return result, fmt.Errorf("failed to create organization: %w", apiError(err))
+			}
+		}
+	case organizationChanged(organization, existing):
+		result.updated = true
+		if !dryRun {
+			update := &management.Organization{DisplayName: organization.DisplayName, Branding: organization.Branding, Metadata: organization.Metadata}
+			if err := target.Organization.Update(ctx, existing.GetID(), update); err != nil {
+				return result, fmt.Errorf("failed to update organization: %w", apiError(err))
+			}
+		}
+	}
+
+	// A created organization has no connections, members or roles yet, and
+	// on a dry run it has no ID to read them with.
+	var enabled []*management.OrganizationConnection
+	targetMembers := map[string][]string{}
+	if !result.created {
+		var err error
+		if enabled, err = organizationConnections(ctx, target, existing.GetID()); err != nil {
+			return result, err
+		}
+		if targetMembers, err = organizationMembers(ctx, target, existing.GetID()); err != nil {
+			return result, err
+		}
+	}
+
+	connections, err := organizationConnections(ctx, source, organization.GetID())
+	if err != nil {
+		return result, err
+	}
+	for _, connection := range connections {
+		name := targets.remap.name("connections", connection.GetConnection().GetName())
+		connectionID, ok := targets.connections[name]
+		if !ok {
+			result.missingConnections = append(result.missingConnections, name)
+			continue
+		}
+		if slices.ContainsFunc(enabled, func(c *management.OrganizationConnection) bool { return c.GetConnectionID() == connectionID }) {
+			continue
+		}
+		result.connections++
+		if !dryRun {
+			err := target.Organization.AddConnection(ctx, existing.GetID(), &management.OrganizationConnection{
+				ConnectionID:            &connectionID,
+				AssignMembershipOnLogin: connection.AssignMembershipOnLogin,
+				ShowAsButton:            connection.ShowAsButton,
+				IsSignupEnabled:         connection.IsSignupEnabled,
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to enable connection %s: %w", name, apiError(err))
+			}
+		}
+	}
+
+	members, err := organizationMembers(ctx, source, organization.GetID())
+	if err != nil {
+		return result, err
+	}
+	ids := sortedKeys(members)
+	existingUsers, err := existingUserIDs(ctx, target, ids)
+	if err != nil {
+		return result, err
+	}
+
+	var add []string
+	for _, id := range ids {
+		if !existingUsers[id] {
+			result.missingMembers++
+			continue
+		}
+		if _, ok := targetMembers[id]; !ok {
+			add = append(add, id)
+		}
+	}
+	for start := 0; start < len(add); start += organizationMemberBatch {
+		end := min(start+organizationMemberBatch, len(add))
+		if !dryRun {
+			if err := target.Organization.AddMembers(ctx, existing.GetID(), add[start:end]); err != nil {
+				return result, fmt.Errorf("failed to add members: %w", apiError(err))
+			}
+		}
+		result.members += end - start
+	}
+
+	missingRoles := map[string]bool{}
+	for _, id := range ids {
+		if !existingUsers[id] {
+			continue
+		}
+		var assign []string
+		for _, role := range members[id] {
+			name := targets.remap.name("roles", role)
+			roleID, ok := targets.roles[name]
+			if !ok {
+				missingRoles[name] = true
+				continue
+			}
+			if !slices.Contains(targetMembers[id], name) {
+				assign = append(assign, roleID)
+			}
+		}
+		if len(assign) == 0 {
+			continue
+		}
+		if !dryRun {
+			if err := target.Organization.AssignMemberRoles(ctx, existing.GetID(), id, assign); err != nil {
+				return result, fmt.Errorf("failed to assign roles to member %s: %w", id, apiError(err))
+			}
+		}
+		result.roles += len(assign)
+	}
+	result.missingRoles = sortedKeys(missingRoles)
+
+	return result, nil
+}
+
+// organizationPlanEntry describes what migrating an organization would do,
+// from the result of a dry run.
+func organizationPlanEntry(name string, result organizationMigration) planEntry {
+	entry := planEntry{Resource: "organizations/" + name, Action: planSkip}
+	switch {
+	case result.created:
+		entry.Action = diffActionCreate
+	case result.updated || result.connections > 0 || result.members > 0 || result.roles > 0:
+		entry.Action = diffActionUpdate
+	}
+	entry.Reason = fmt.Sprintf("%d connections to enable, %d members to add, %d member roles to assign, %d members not found in target", result.connections, result.members, result.roles, result.missingMembers)
+	return entry
+}
+
+func newMigrateOrganizationsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		remapFile string
+		dryRun    bool
+	)
+
+	var organizationsCmd = &cobra.Command{
+		Use:   "organizations",
+		Short: "Copy organizations, their connections, members and member roles to the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate organizations")
+				clients.guardTarget("migrate organizations")
+			}
+			var remap nameRemap
+			var err error
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			sourceClient := clients.sourceClient()
+			targetClient := clients.targetClient()
+
+			targets := organizationTargets{connections: map[string]string{}, roles: map[string]string{}, remap: remap}
+			connections, err := listConnections(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target connections: %v", err)
+			}
+			for name, connection := range connections {
+				targets.connections[name] = connection.GetID()
+			}
+			roles, err := listRoles(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target roles: %v", err)
+			}
+			for _, role := range roles {
+				targets.roles[role.GetName()] = role.GetID()
+			}
+
+			organizations, err := listOrganizations(ctx, sourceClient)
+			if err != nil {
+				log.Fatalf("Failed to read source organizations: %v", err)
+			}
+			targetOrganizations, err := listOrganizations(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target organizations: %v", err)
+			}
+			byName := map[string]*management.Organization{}
+			for _, organization := range targetOrganizations {
+				byName[organization.GetName()] = organization
+			}
+
+			created, members, missing, failed := 0, 0, 0, 0
+			missingConnections, missingRoles := map[string]bool{}, map[string]bool{}
+			var plan []planEntry
+			for _, organization := range organizations {
+				result, err := migrateOrganization(ctx, sourceClient, targetClient, organization, byName[organization.GetName()], targets, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate organization %s: %v\n", organization.GetName(), err)
+					failed++
+					continue
+				}
+				for _, name := range result.missingConnections {
+					missingConnections[name] = true
+				}
+				for _, name := range result.missingRoles {
+					missingRoles[name] = true
+				}
+				if dryRun {
+					plan = append(plan, organizationPlanEntry(organization.GetName(), result))
+					continue
+				}
+
+				action := "unchanged"
+				switch {
+				case result.created:
+					action = "created"
+					created++
+				case result.updated:
+					action = "updated"
+				}
+				fmt.Fprintf(messageOutput, "Organization %s: %s, %d connections enabled, %d members added, %d member roles assigned (%d members not found in target).\n",
+					organization.GetName(), action, result.connections, result.members, result.roles, result.missingMembers)
+				members += result.members
+				missing += result.missingMembers
+			}
+
+			for _, name := range sortedKeys(missingConnections) {
+				fmt.Fprintf(messageOutput, "Connection %s doesn't exist in the target and wasn't enabled.\n", name)
+			}
+			for _, name := range sortedKeys(missingRoles) {
+				fmt.Fprintf(messageOutput, "Role %s doesn't exist in the target and wasn't assigned; run migrate roles first.\n", name)
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate organizations", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Organizations migrated: %d organizations, %d created, %d failed; %d members added, %d not found in target.\n",
+				len(organizations), created, failed, members, missing)
+			reportResult("migrate organizations", map[string]interface{}{
+				"organizations": len(organizations), "created": created, "failed": failed,
+				"members": members, "missing": missing,
+				"missing_connections": sortedKeys(missingConnections), "missing_roles": sortedKeys(missingRoles),
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	organizationsCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source connection and role names to their names in the target")
+	organizationsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return organizationsCmd
+}
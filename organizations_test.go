@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestMigrateOrganization(t *testing.T) {
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/org_src/enabled_connections":
+			json.NewEncoder(w).Encode(map[string]interface{}{"enabled_connections": []map[string]interface{}{
+				{"connection_id": "con_src_db", "assign_membership_on_login": true, "connection": map[string]interface{}{"name": "legacy-db"}},
+				{"connection_id": "con_src_saml", "connection": map[string]interface{}{"name": "acme-saml"}},
+			}})
+		case "/api/v2/organizations/org_src/members":
+			json.NewEncoder(w).Encode(map[string]interface{}{"members": []map[string]interface{}{
+				{"user_id": "auth0|1", "roles": []map[string]interface{}{{"id": "rol_src_admin", "name": "admin"}, {"id": "rol_src_billing", "name": "billing"}}},
+				{"user_id": "auth0|2", "roles": []map[string]interface{}{}},
+				{"user_id": "auth0|3", "roles": []map[string]interface{}{{"id": "rol_src_admin", "name": "admin"}}},
+			}})
+		default:
+			t.Errorf("Unexpected source request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	var mu sync.Mutex
+	var added []string
+	assigned := map[string][]string{}
+	var updated map[string]interface{}
+	var enabledConnections []map[string]interface{}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/v2/organizations/org_tgt" && r.Method == http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&updated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "org_tgt"})
+		case r.URL.Path == "/api/v2/organizations/org_tgt/enabled_connections" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"enabled_connections": []map[string]interface{}{{"connection_id": "con_tgt_saml"}}})
+		case r.URL.Path == "/api/v2/organizations/org_tgt/enabled_connections":
+			var connection map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&connection)
+			enabledConnections = append(enabledConnections, connection)
+			json.NewEncoder(w).Encode(connection)
+		case r.URL.Path == "/api/v2/organizations/org_tgt/members" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"members": []map[string]interface{}{
+				{"user_id": "auth0|1", "roles": []map[string]interface{}{{"id": "rol_tgt_admin", "name": "admin"}}},
+			}})
+		case r.URL.Path == "/api/v2/organizations/org_tgt/members":
+			var body map[string][]string
+			json.NewDecoder(r.Body).Decode(&body)
+			added = append(added, body["members"]...)
+		case strings.HasPrefix(r.URL.Path, "/api/v2/organizations/org_tgt/members/"):
+			var body map[string][]string
+			json.NewDecoder(r.Body).Decode(&body)
+			member := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/organizations/org_tgt/members/"), "/roles")
+			assigned[member] = append(assigned[member], body["roles"]...)
+		case r.URL.Path == "/api/v2/users":
+			if q := r.URL.Query().Get("q"); q != `user_id:("auth0|1" OR "auth0|2" OR "auth0|3")` {
+				t.Errorf("Unexpected user query %s", q)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|1"}, {"user_id": "auth0|3"}}})
+		default:
+			t.Errorf("Unexpected target request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	organization := &management.Organization{ID: auth0.String("org_src"), Name: auth0.String("acme"), DisplayName: auth0.String("Acme Inc."), Metadata: &map[string]string{"tier": "gold"}}
+	existing := &management.Organization{ID: auth0.String("org_tgt"), Name: auth0.String("acme"), DisplayName: auth0.String("Acme")}
+	targets := organizationTargets{
+		connections: map[string]string{"Username-Password-Authentication": "con_tgt_db", "acme-saml": "con_tgt_saml"},
+		roles:       map[string]string{"admin": "rol_tgt_admin"},
+		remap:       nameRemap{"connections": {"legacy-db": "Username-Password-Authentication"}},
+	}
+
+	plan, err := migrateOrganization(context.Background(), source, target, organization, existing, targets, true)
+	if err != nil {
+		t.Fatalf("Failed to plan the migration: %v", err)
+	}
+	if entry := organizationPlanEntry("acme", plan); entry.Action != diffActionUpdate || updated != nil || len(added) != 0 {
+		t.Errorf("Expected an update to be planned without changes, got %+v", entry)
+	}
+
+	result, err := migrateOrganization(context.Background(), source, target, organization, existing, targets, false)
+	if err != nil {
+		t.Fatalf("Failed to migrate organization: %v", err)
+	}
+	if !result.updated || updated["display_name"] != "Acme Inc." {
+		t.Errorf("Expected the display name to be updated, got %v", updated)
+	}
+	if result.connections != 1 || len(enabledConnections) != 1 || enabledConnections[0]["connection_id"] != "con_tgt_db" || enabledConnections[0]["assign_membership_on_login"] != true {
+		t.Errorf("Expected the remapped database connection to be enabled, got %v", enabledConnections)
+	}
+	if result.members != 1 || result.missingMembers != 1 || !reflect.DeepEqual(added, []string{"auth0|3"}) {
+		t.Errorf("Expected auth0|3 to be added and auth0|2 to be missing, got %v and %+v", added, result)
+	}
+	if result.roles != 1 || !reflect.DeepEqual(assigned, map[string][]string{"auth0|3": {"rol_tgt_admin"}}) {
+		t.Errorf("Expected admin to be assigned to auth0|3 only, got %v", assigned)
+	}
+	if !reflect.DeepEqual(result.missingRoles, []string{"billing"}) {
+		t.Errorf("Expected billing to be missing in the target, got %v", result.missingRoles)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// passwordHashField is the field an export job writes the password hash of a
+// user to, on database connections whose hashes the tenant may export.
+const passwordHashField = "passwordHash"
+
+// passwordHashFormats recognise the algorithm of a password hash from its
+// prefix. Hashes in these formats carry their salt and parameters.
+var passwordHashFormats = []struct{ prefix, algorithm string }{
+	{"$2a$", "bcrypt"},
+	{"$2b$", "bcrypt"},
+	{"$2y$", "bcrypt"},
+	{"$argon2", "argon2"},
+	{"$pbkdf2", "pbkdf2"},
+	{"{SSHA}", "ldap"},
+	{"{SHA}", "ldap"},
+	{"{SSHA256}", "ldap"},
+	{"{SSHA512}", "ldap"},
+	{"{MD5}", "ldap"},
+	{"{SMD5}", "ldap"},
+}
+
+// mapPasswordHash moves the exported passwordHash of a user into
+// custom_password_hash, so that the user keeps their password in the target
+// connection. The algorithm is recognised from the hash, or else taken from
+// algorithm, the --password-hash-algorithm flag. Users without a hash are
+// left alone.
+func mapPasswordHash(user map[string]interface{}, algorithm string) error {
+	value, ok := user[passwordHashField]
+	if !ok {
+		return nil
+	}
+	delete(user, passwordHashField)
+
+	hash, isString := value.(string)
+	if value != nil && !isString {
+		return fmt.Errorf("%s must be a string", passwordHashField)
+	}
+	if hash == "" {
+		return nil
+	}
+	if _, ok := user["custom_password_hash"]; ok {
+		return nil // set explicitly, e.g. by a plugin
+	}
+
+	detected := ""
+	for _, format := range passwordHashFormats {
+		if strings.HasPrefix(hash, format.prefix) {
+			detected = format.algorithm
+			break
+		}
+	}
+
+	hashObject := map[string]interface{}{"value": hash}
+	switch {
+	case detected != "":
+		algorithm = detected
+	case algorithm == "":
+		return fmt.Errorf("can't recognise the algorithm of the password hash, set --password-hash-algorithm")
+	case !slices.Contains(passwordHashAlgorithms, algorithm):
+		return fmt.Errorf("unknown password hash algorithm %q, expected one of %s", algorithm, strings.Join(passwordHashAlgorithms, ", "))
+	default:
+		// Plain digests are stored as hex or base64.
+		hashObject["encoding"] = "base64"
+		if _, err := hex.DecodeString(hash); err == nil {
+			hashObject["encoding"] = "hex"
+		}
+	}
+
+	user["custom_password_hash"] = map[string]interface{}{"algorithm": algorithm, "hash": hashObject}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapPasswordHash(t *testing.T) {
+	tests := []struct {
+		name      string
+		hash      interface{}
+		algorithm string
+		expected  interface{}
+		err       string
+	}{
+		{
+			name:     "bcrypt",
+			hash:     "$2b$10$abcdefghijklmnopqrstuv",
+			expected: map[string]interface{}{"algorithm": "bcrypt", "hash": map[string]interface{}{"value": "$2b$10$abcdefghijklmnopqrstuv"}},
+		},
+		{
+			name:     "ldap",
+			hash:     "{SSHA}c2FsdGVkaGFzaA==",
+			expected: map[string]interface{}{"algorithm": "ldap", "hash": map[string]interface{}{"value": "{SSHA}c2FsdGVkaGFzaA=="}},
+		},
+		{
+			name:      "hex digest",
+			hash:      "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+			algorithm: "sha256",
+			expected: map[string]interface{}{"algorithm": "sha256", "hash": map[string]interface{}{
+				"value": "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8", "encoding": "hex",
+			}},
+		},
+		{
+			name:      "base64 digest",
+			hash:      "XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=",
+			algorithm: "sha256",
+			expected: map[string]interface{}{"algorithm": "sha256", "hash": map[string]interface{}{
+				"value": "XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=", "encoding": "base64",
+			}},
+		},
+		{name: "empty", hash: ""},
+		{name: "unrecognised", hash: "5e884898da", err: "--password-hash-algorithm"},
+		{name: "unknown algorithm", hash: "5e884898da", algorithm: "rot13", err: "unknown password hash algorithm"},
+		{name: "not a string", hash: 42, err: "must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := map[string]interface{}{"email": "user@example.com", passwordHashField: tt.hash}
+			err := mapPasswordHash(user, tt.algorithm)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to map password hash: %v", err)
+			}
+			if _, ok := user[passwordHashField]; ok {
+				t.Errorf("Expected %s to be removed", passwordHashField)
+			}
+			if got := user["custom_password_hash"]; !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected custom_password_hash %v, got %v", tt.expected, got)
+			}
+			if tt.expected != nil {
+				if err := validateImportUser(user); err != nil {
+					t.Errorf("Expected the mapped user to pass validation, got %v", err)
+				}
+			}
+		})
+	}
+}
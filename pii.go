@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// PII policies that can be set on a field under pii.fields in the config.
+const (
+	piiEncrypt   = "encrypt"   // files containing the field are encrypted
+	piiAnonymize = "anonymize" // values are pseudonymized for non-production targets
+	piiNoLog     = "no_log"    // values are never printed
+)
+
+var piiPolicyNames = []string{piiEncrypt, piiAnonymize, piiNoLog}
+
+// encryptedFileMagic starts every file encrypted because of the encrypt
+// policy. It is followed by the AES-GCM nonce and the ciphertext.
+var encryptedFileMagic = []byte("AUTH0TOOLS-ENC1\n")
+
+// piiConfig classifies fields (or metadata paths such as
+// user_metadata.address) as PII and sets the policies enforced for them:
+//
+//	pii:
+//	  fields:
+//	    email: [anonymize, no_log]
+//	    phone_number: [encrypt, anonymize, no_log]
+type piiConfig struct {
+	Fields map[string][]string `yaml:"fields"`
+}
+
+// pii is the PII classification of the loaded config.
+var pii piiConfig
+
+// fieldsWith returns the fields that have the given policy, sorted.
+func (p piiConfig) fieldsWith(policy string) []string {
+	var fields []string
+	for _, field := range sortedKeys(p.Fields) {
+		if slices.Contains(p.Fields[field], policy) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// redactPII hides the value of a field with the no_log policy.
+func redactPII(field, value string) string {
+	if value != "" && slices.Contains(pii.Fields[field], piiNoLog) {
+		return "[redacted]"
+	}
+	return value
+}
+
+// redactValue hides a value of any type of a field with the no_log policy.
+func redactValue(field string, value interface{}) interface{} {
+	if value != nil && slices.Contains(pii.Fields[field], piiNoLog) {
+		return "[redacted]"
+	}
+	return value
+}
+
+// requiresEncryption reports whether any of the exported fields must be
+// encrypted at rest. Metadata paths count if their object is exported.
+func (p piiConfig) requiresEncryption(fields []string) bool {
+	for _, field := range p.fieldsWith(piiEncrypt) {
+		for _, exported := range fields {
+			if field == exported || strings.HasPrefix(field, exported+".") || strings.HasPrefix(exported, field+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// userFields returns the top-level fields present in any of the records.
+func userFields(records []userRecord) []string {
+	present := map[string]bool{}
+	for _, record := range records {
+		for field := range record.user {
+			present[field] = true
+		}
+	}
+	return sortedKeys(present)
+}
+
+// encryptionKey reads the AES-256 key for the encrypt policy from the
+// encryption_key config key or AUTH0_TOOLS_ENCRYPTION_KEY, given as 32 bytes
+// in base64 or hex.
+func encryptionKey() ([]byte, error) {
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	value := cfg.EncryptionKey
+	if value == "" {
+		return nil, fmt.Errorf("AUTH0_TOOLS_ENCRYPTION_KEY is not set, it is required because the pii config requires encryption")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		key, err = hex.DecodeString(value)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("AUTH0_TOOLS_ENCRYPTION_KEY must be 32 bytes, base64 or hex encoded")
+	}
+	return key, nil
+}
+
+func encryptData(plain, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedFileMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, encryptedFileMagic), nil
+}
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedFileMagic)
+}
+
+func decryptData(data, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[len(encryptedFileMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], encryptedFileMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt file, check AUTH0_TOOLS_ENCRYPTION_KEY: %w", err)
+	}
+	return plain, nil
+}
+
+// decryptedReader decrypts a file encrypted because of the encrypt policy.
+func decryptedReader(r io.Reader) (*bufio.Reader, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	plain, err := decryptData(data, key)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(bytes.NewReader(plain)), nil
+}
+
+// encryptFile encrypts a file in place.
+func encryptFile(filename string, key []byte) error {
+	plain, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	encrypted, err := encryptData(plain, key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	if err := os.WriteFile(filename, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	return nil
+}
+
+// pseudonym derives a stable replacement for a value, keyed with the PII
+// salt so values can't be recovered by hashing guesses.
+func pseudonym(value, salt string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// anonymizeUser replaces the values of the given fields with pseudonyms that
+// still pass Auth0's import validation. The same input always gives the same
+// pseudonym, so re-runs and cross references stay consistent. Values that
+// aren't strings are removed.
+func anonymizeUser(user map[string]interface{}, fields []string, salt string) {
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		value, ok := getPath(user, path)
+		if !ok || value == nil {
+			continue
+		}
+
+		text, isString := value.(string)
+		switch {
+		case !isString:
+			parent := user
+			if len(path) > 1 {
+				p, _ := getPath(user, path[:len(path)-1])
+				parent, _ = p.(map[string]interface{})
+			}
+			delete(parent, path[len(path)-1])
+		case field == "phone_number":
+			digits := strings.Map(func(r rune) rune {
+				return '0' + r%10
+			}, pseudonym(text, salt))
+			setPath(user, path, "+1555"+digits[:7])
+		case strings.Contains(text, "@"):
+			setPath(user, path, "anon-"+pseudonym(strings.ToLower(text), salt)+"@example.invalid")
+		default:
+			setPath(user, path, "anon-"+pseudonym(text, salt))
+		}
+	}
+}
+
+// anonymizedFields returns the fields with the anonymize policy, or none if
+// the target domain belongs to a protected (production) profile. Fields
+// can't be anonymized without a salt, as anyone could then recover values
+// by hashing guesses.
+func anonymizedFields(cfg *config, domain string) ([]string, error) {
+	if _, production := protectedProfile(cfg, domain); production {
+		return nil, nil
+	}
+	fields := cfg.PII.fieldsWith(piiAnonymize)
+	if len(fields) > 0 && cfg.PIISalt == "" {
+		return nil, fmt.Errorf("AUTH0_TOOLS_PII_SALT is not set, it is required because the pii config anonymizes %s", strings.Join(fields, ", "))
+	}
+	return fields, nil
+}
+
+// anonymizeForTarget anonymizes the fields with the anonymize policy in the
+// users data, unless the target domain belongs to a protected (production)
+// profile. It returns the data as newline-delimited JSON and the anonymized
+// fields.
+func anonymizeForTarget(cfg *config, domain string, data []byte) ([]byte, []string, error) {
+	fields, err := anonymizedFields(cfg, domain)
+	if err != nil || len(fields) == 0 {
+		return data, nil, err
+	}
+
+	records, err := decodeUsers(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		anonymizeUser(record.user, fields, cfg.PIISalt)
+		if err := encoder.Encode(record.user); err != nil {
+			return nil, nil, record.validationError(err)
+		}
+	}
+	return buf.Bytes(), fields, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedInputFile(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AUTH0_TOOLS_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key))
+
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	gzWriter.Write([]byte(`{"email":"a@example.com"}`))
+	gzWriter.Close()
+
+	filename := filepath.Join(t.TempDir(), "exported_users.json.gz")
+	os.WriteFile(filename, buf.Bytes(), 0644)
+	if err := encryptFile(filename, key); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	raw, _ := os.ReadFile(filename)
+	if !isEncrypted(raw) || bytes.Contains(raw, []byte("example.com")) {
+		t.Fatalf("Expected the file to be encrypted")
+	}
+
+	data, err := readInputFile(filename)
+	if err != nil || string(data) != `{"email":"a@example.com"}` {
+		t.Errorf("Expected the decrypted users, got %q %v", data, err)
+	}
+
+	t.Setenv("AUTH0_TOOLS_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+	if _, err := readInputFile(filename); err == nil {
+		t.Errorf("Expected decryption with the wrong key to fail")
+	}
+}
+
+func TestRequiresEncryption(t *testing.T) {
+	policy := piiConfig{Fields: map[string][]string{
+		"phone_number":          {piiEncrypt},
+		"user_metadata.address": {piiEncrypt, piiAnonymize},
+		"email":                 {piiNoLog},
+	}}
+
+	for _, tc := range []struct {
+		fields   []string
+		expected bool
+	}{
+		{[]string{"user_id", "email"}, false},
+		{[]string{"user_id", "phone_number"}, true},
+		{[]string{"user_metadata"}, true},
+		{[]string{"user_metadata.address.city"}, true},
+		{[]string{"user_metadata.plan"}, false},
+	} {
+		if got := policy.requiresEncryption(tc.fields); got != tc.expected {
+			t.Errorf("requiresEncryption(%v) = %v, expected %v", tc.fields, got, tc.expected)
+		}
+	}
+}
+
+func TestAnonymizeForTarget(t *testing.T) {
+	cfg := &config{
+		Profiles: map[string]profileConfig{"prod": {Domain: "prod.eu.auth0.com", Protected: true}},
+		PII: piiConfig{Fields: map[string][]string{
+			"email":                 {piiAnonymize},
+			"phone_number":          {piiAnonymize},
+			"user_metadata.address": {piiAnonymize},
+		}},
+		PIISalt: "salt",
+	}
+	data := []byte(`{"user_id":"1","email":"A@example.com","phone_number":"+4930123456","user_metadata":{"address":{"city":"Berlin"},"plan":"pro"}}`)
+
+	unchanged, fields, err := anonymizeForTarget(cfg, "PROD.eu.auth0.com", data)
+	if err != nil || fields != nil || !bytes.Equal(unchanged, data) {
+		t.Errorf("Expected no anonymization for a protected target, got %s %v %v", unchanged, fields, err)
+	}
+
+	anonymized, fields, err := anonymizeForTarget(cfg, "dev.eu.auth0.com", data)
+	if err != nil {
+		t.Fatalf("Failed to anonymize: %v", err)
+	}
+	if len(fields) != 3 {
+		t.Errorf("Expected 3 anonymized fields, got %v", fields)
+	}
+
+	records, _ := decodeUsers(anonymized)
+	user := records[0].user
+	email, _ := user["email"].(string)
+	if !strings.HasPrefix(email, "anon-") || !strings.HasSuffix(email, "@example.invalid") {
+		t.Errorf("Unexpected anonymized email %q", email)
+	}
+	if phone, _ := user["phone_number"].(string); len(phone) != 12 || !strings.HasPrefix(phone, "+1555") {
+		t.Errorf("Unexpected anonymized phone number %q", phone)
+	}
+	metadata := user["user_metadata"].(map[string]interface{})
+	if _, ok := metadata["address"]; ok || metadata["plan"] != "pro" {
+		t.Errorf("Expected only the address to be removed, got %v", metadata)
+	}
+
+	again, _, _ := anonymizeForTarget(cfg, "dev.eu.auth0.com", []byte(`{"email":"a@example.com"}`))
+	if !strings.Contains(string(again), email) {
+		t.Errorf("Expected the same pseudonym regardless of case, got %s and %s", again, email)
+	}
+
+	cfg.PIISalt = ""
+	if _, _, err := anonymizeForTarget(cfg, "dev.eu.auth0.com", data); err == nil || !strings.Contains(err.Error(), "AUTH0_TOOLS_PII_SALT") {
+		t.Errorf("Expected anonymizing without a salt to fail, got %v", err)
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	pii = piiConfig{Fields: map[string][]string{"email": {piiNoLog}}}
+	defer func() { pii = piiConfig{} }()
+
+	if got := redactPII("email", "a@example.com"); got != "[redacted]" {
+		t.Errorf("Expected the email to be redacted, got %s", got)
+	}
+	if got := redactPII("username", "alice"); got != "alice" {
+		t.Errorf("Expected the username to be kept, got %s", got)
+	}
+
+	err := &ValidationError{Line: 3, Email: "a@example.com", Err: os.ErrInvalid}
+	if strings.Contains(err.Error(), "a@example.com") {
+		t.Errorf("Expected the email to be redacted from %q", err.Error())
+	}
+}
+
+func TestPIILint(t *testing.T) {
+	cfg := &config{PII: piiConfig{Fields: map[string][]string{"email": {piiNoLog, "hash"}}}}
+	problems := cfg.lint()
+	if len(problems) != 1 || !strings.Contains(problems[0], `pii.fields.email: unknown policy "hash"`) {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+}
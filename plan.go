@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// planSkip marks a resource a config migration leaves alone.
+const planSkip = "skip"
+
+// planEntry is what a config migration would do to one resource: create,
+// update, delete or skip it.
+type planEntry struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// resourcePlan lists what applying the source resources of a kind to the
+// target would do, including the resources it leaves alone. Resources that
+// only exist in the target are deleted only with prune.
+func resourcePlan(kind string, source, target map[string]map[string]interface{}, prune bool) []planEntry {
+	var plan []planEntry
+	changed := map[string]bool{}
+
+	for _, d := range diffResources(kind, source, target) {
+		changed[d.key] = true
+		entry := planEntry{Resource: d.resource(), Action: d.action}
+		switch d.action {
+		case diffActionUpdate:
+			entry.Reason = strings.Join(changedFields(d.from, d.to), ", ")
+		case diffActionDelete:
+			if !prune {
+				entry.Action, entry.Reason = planSkip, "only in target"
+			}
+		}
+		plan = append(plan, entry)
+	}
+
+	for _, key := range sortedKeys(source) {
+		if !changed[key] {
+			plan = append(plan, planEntry{Resource: kind + "/" + key, Action: planSkip, Reason: "unchanged"})
+		}
+	}
+	return plan
+}
+
+// changedFields returns the top-level fields that differ between two
+// versions of a resource.
+func changedFields(from, to map[string]interface{}) []string {
+	fields := map[string]bool{}
+	for _, op := range jsonPatch(from, to) {
+		field := strings.SplitN(strings.TrimPrefix(op.Path, "/"), "/", 2)[0]
+		fields[field] = true
+	}
+	return sortedKeys(fields)
+}
+
+// writePlan prints a plan in the style of terraform plan, followed by a
+// summary line, and returns how many resources would change.
+func writePlan(w io.Writer, plan []planEntry) int {
+	symbols := map[string]string{diffActionCreate: "+", diffActionUpdate: "~", diffActionDelete: "-", planSkip: " "}
+	counts := map[string]int{}
+
+	// Changes first, in plan order, then the skipped resources.
+	sorted := append([]planEntry(nil), plan...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Action != planSkip && sorted[j].Action == planSkip
+	})
+	for _, entry := range sorted {
+		counts[entry.Action]++
+		line := fmt.Sprintf("%s %s %s", symbols[entry.Action], entry.Action, entry.Resource)
+		if entry.Reason != "" {
+			line += " (" + entry.Reason + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete, %d to skip.\n",
+		counts[diffActionCreate], counts[diffActionUpdate], counts[diffActionDelete], counts[planSkip])
+	return counts[diffActionCreate] + counts[diffActionUpdate] + counts[diffActionDelete]
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResourcePlan(t *testing.T) {
+	source := map[string]map[string]interface{}{
+		"Web App":    {"name": "Web App", "app_type": "spa", "callbacks": []interface{}{"https://new.example.com"}},
+		"Mobile App": {"name": "Mobile App", "app_type": "native"},
+		"Same App":   {"name": "Same App"},
+	}
+	target := map[string]map[string]interface{}{
+		"Web App":  {"name": "Web App", "app_type": "regular_web", "callbacks": []interface{}{"https://old.example.com"}},
+		"Same App": {"name": "Same App"},
+		"Old App":  {"name": "Old App"},
+	}
+
+	expected := []planEntry{
+		{Resource: "clients/Mobile App", Action: diffActionCreate},
+		{Resource: "clients/Web App", Action: diffActionUpdate, Reason: "app_type, callbacks"},
+		{Resource: "clients/Old App", Action: planSkip, Reason: "only in target"},
+		{Resource: "clients/Same App", Action: planSkip, Reason: "unchanged"},
+	}
+	if plan := resourcePlan("clients", source, target, false); !reflect.DeepEqual(plan, expected) {
+		t.Errorf("Expected plan %+v, got %+v", expected, plan)
+	}
+
+	plan := resourcePlan("clients", source, target, true)
+	if plan[2].Action != diffActionDelete {
+		t.Errorf("Expected Old App to be deleted with prune, got %+v", plan[2])
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	var out bytes.Buffer
+	changes := writePlan(&out, []planEntry{
+		{Resource: "roles/viewer", Action: planSkip, Reason: "unchanged"},
+		{Resource: "roles/admin", Action: diffActionUpdate, Reason: "description"},
+		{Resource: "roles/support", Action: diffActionCreate},
+	})
+
+	expected := strings.Join([]string{
+		"~ update roles/admin (description)",
+		"+ create roles/support",
+		"  skip roles/viewer (unchanged)",
+		"Plan: 1 to create, 1 to update, 0 to delete, 1 to skip.",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if changes != 2 {
+		t.Errorf("Expected 2 changes, got %d", changes)
+	}
+}
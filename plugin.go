@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// pluginConfig is an external transform or validator executable:
+//
+//	plugins:
+//	  entitlements:
+//	    command: ./plugins/entitlements
+//	    args: ["--env", "prod"]
+//
+// The plugin is started once per run and exchanges one JSON object per line.
+// For every user it reads {"user": {...}} on stdin and must answer with
+// {"user": {...}} (the user, possibly transformed), {"skip": true} to drop
+// the user, or {"error": "..."} to reject the record.
+type pluginConfig struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+type pluginRequest struct {
+	User map[string]interface{} `json:"user"`
+}
+
+type pluginResponse struct {
+	User  map[string]interface{} `json:"user"`
+	Skip  bool                   `json:"skip"`
+	Error string                 `json:"error"`
+}
+
+type plugin struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+func startPlugin(name string, cfg pluginConfig) (*plugin, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "AUTH0_TOOLS_PLUGIN="+name)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", name, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &plugin{name: name, cmd: cmd, stdin: stdin, stdout: scanner}, nil
+}
+
+// loadPlugins starts the named plugins from the config file.
+func loadPlugins(names []string) ([]*plugin, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var plugins []*plugin
+	for _, name := range names {
+		pluginCfg, ok := cfg.Plugins[name]
+		if !ok {
+			closePlugins(plugins)
+			return nil, fmt.Errorf("plugin %q not found in config", name)
+		}
+
+		p, err := startPlugin(name, pluginCfg)
+		if err != nil {
+			closePlugins(plugins)
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// transform sends one user to the plugin. It returns a nil user if the plugin
+// skipped it, and the plugin's reason if it rejected it.
+func (p *plugin) transform(user map[string]interface{}) (map[string]interface{}, string, error) {
+	request, err := json.Marshal(pluginRequest{User: user})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal user: %w", err)
+	}
+	if _, err := p.stdin.Write(append(request, '\n')); err != nil {
+		return nil, "", fmt.Errorf("failed to write to plugin %s: %w", p.name, err)
+	}
+
+	if !p.stdout.Scan() {
+		if err := p.stdout.Err(); err != nil {
+			return nil, "", fmt.Errorf("failed to read from plugin %s: %w", p.name, err)
+		}
+		return nil, "", fmt.Errorf("plugin %s exited without answering", p.name)
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(p.stdout.Bytes(), &response); err != nil {
+		return nil, "", fmt.Errorf("invalid response from plugin %s: %w", p.name, err)
+	}
+
+	switch {
+	case response.Error != "":
+		return nil, response.Error, nil
+	case response.Skip:
+		return nil, "", nil
+	case response.User == nil:
+		return nil, "", fmt.Errorf("invalid response from plugin %s: missing user", p.name)
+	}
+	return response.User, "", nil
+}
+
+func (p *plugin) close() error {
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("plugin %s failed: %w", p.name, err)
+	}
+	return nil
+}
+
+func closePlugins(plugins []*plugin) error {
+	var errs []error
+	for _, p := range plugins {
+		if err := p.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pluginUser passes a user through the plugins in order. It returns nil if
+// a plugin drops the user.
+func pluginUser(record userRecord, plugins []*plugin) (map[string]interface{}, error) {
+	user := record.user
+	for _, p := range plugins {
+		transformed, reason, err := p.transform(user)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			return nil, record.validationError(fmt.Errorf("rejected by plugin %s: %s", p.name, reason))
+		}
+		if user = transformed; user == nil {
+			break
+		}
+	}
+	return user, nil
+}
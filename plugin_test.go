@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testPluginScript = `while IFS= read -r line; do
+	case "$line" in
+	*blocked*) echo '{"error": "user is blocked"}' ;;
+	*skip*) echo '{"skip": true}' ;;
+	*) echo "$line" | sed 's/"plan":"legacy"/"plan":"standard"/' ;;
+	esac
+done`
+
+// runPlugins passes the users of an export through the plugins like the
+// import does, and returns the resulting NDJSON.
+func runPlugins(data []byte, plugins []*plugin) (string, error) {
+	var out strings.Builder
+	err := readUsers(bytes.NewReader(data), func(record userRecord) error {
+		user, err := pluginUser(record, plugins)
+		if err != nil || user == nil {
+			return err
+		}
+		userData, err := json.Marshal(user)
+		out.Write(userData)
+		out.WriteByte('\n')
+		return err
+	})
+	return out.String(), err
+}
+
+func TestPluginUser(t *testing.T) {
+	p, err := startPlugin("test", pluginConfig{Command: "sh", Args: []string{"-c", testPluginScript}})
+	if err != nil {
+		t.Fatalf("Failed to start plugin: %v", err)
+	}
+
+	data := []byte(`{"email":"a@example.com","plan":"legacy"}
+
+{"email":"skip@example.com"}
+{"email":"b@example.com","plan":"pro"}
+`)
+
+	out, err := runPlugins(data, []*plugin{p})
+	if err != nil {
+		t.Fatalf("Failed to apply plugins: %v", err)
+	}
+	if err := p.close(); err != nil {
+		t.Fatalf("Failed to close plugin: %v", err)
+	}
+
+	expected := `{"email":"a@example.com","plan":"standard"}
+{"email":"b@example.com","plan":"pro"}
+`
+	if out != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestPluginUserRejected(t *testing.T) {
+	p, err := startPlugin("test", pluginConfig{Command: "sh", Args: []string{"-c", testPluginScript}})
+	if err != nil {
+		t.Fatalf("Failed to start plugin: %v", err)
+	}
+	defer p.close()
+
+	data := []byte(`{"email":"a@example.com"}
+{"email":"blocked@example.com"}
+`)
+
+	_, err = runPlugins(data, []*plugin{p})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Line != 2 {
+		t.Fatalf("Expected a validation error on line 2, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "user is blocked") {
+		t.Errorf("Expected the plugin's reason in the error, got %v", err)
+	}
+}
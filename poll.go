@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// errPollTimeout is returned by poller.poll when maxWait has passed.
+var errPollTimeout = errors.New("timed out waiting")
+
+// poller calls a check function until it reports done, waiting between
+// attempts with exponential backoff. The interval starts at initial, doubles
+// up to max, and is varied by up to jitter (a fraction) so parallel runs
+// don't poll in lockstep. A zero maxWait waits indefinitely.
+type poller struct {
+	initial time.Duration
+	max     time.Duration
+	jitter  float64
+	maxWait time.Duration
+}
+
+func newPoller() poller {
+	return poller{initial: 2 * time.Second, max: 30 * time.Second, jitter: 0.2}
+}
+
+func (p poller) poll(ctx context.Context, check func(ctx context.Context) (bool, error)) error {
+	start := time.Now()
+	interval := p.initial
+
+	for {
+		done, err := check(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		delay := interval
+		if p.jitter > 0 && delay > 0 {
+			delay += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(delay))
+		}
+		if p.maxWait > 0 && time.Since(start)+delay > p.maxWait {
+			return errPollTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		if interval *= 2; interval > p.max {
+			interval = p.max
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollerPoll(t *testing.T) {
+	p := poller{initial: time.Millisecond, max: 2 * time.Millisecond}
+
+	calls := 0
+	err := p.poll(context.Background(), func(ctx context.Context) (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected 3 checks and no error, got %d and %v", calls, err)
+	}
+
+	checkErr := errors.New("check failed")
+	err = p.poll(context.Background(), func(ctx context.Context) (bool, error) {
+		return false, checkErr
+	})
+	if !errors.Is(err, checkErr) {
+		t.Errorf("Expected the check error, got %v", err)
+	}
+}
+
+func TestPollerMaxWait(t *testing.T) {
+	p := poller{initial: time.Millisecond, max: 5 * time.Millisecond, jitter: 0.5, maxWait: 20 * time.Millisecond}
+
+	start := time.Now()
+	err := p.poll(context.Background(), func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, errPollTimeout) {
+		t.Errorf("Expected a poll timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected polling to stop after max wait, took %s", elapsed)
+	}
+}
+
+func TestPollerContext(t *testing.T) {
+	p := poller{initial: time.Hour, max: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := p.poll(ctx, func(ctx context.Context) (bool, error) {
+		cancel()
+		return false, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
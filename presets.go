@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultFieldPreset is the export field set used without --preset.
+const defaultFieldPreset = "standard"
+
+// fieldPresets are the built-in export field sets. The config file can
+// override them or add new ones under presets.
+var fieldPresets = map[string][]string{
+	"minimal": {"user_id", "email", "email_verified", "name"},
+	"standard": {
+		"user_id", "email", "name", "user_metadata", "app_metadata",
+		"created_at", "updated_at", "email_verified", "identities",
+	},
+	"full": {
+		"user_id", "email", "email_verified", "username", "phone_number", "phone_verified",
+		"name", "given_name", "family_name", "nickname", "picture", "blocked",
+		"user_metadata", "app_metadata", "identities",
+		"created_at", "updated_at", "last_login", "last_ip", "logins_count",
+	},
+	"analytics": {
+		"user_id", "created_at", "updated_at", "last_login", "logins_count",
+		"email_verified", "blocked", "app_metadata", "identities",
+	},
+}
+
+// exportFieldPreset returns the fields of a preset, preferring presets from
+// the config file over the built-in ones.
+func exportFieldPreset(cfg *config, name string) ([]string, error) {
+	if fields, ok := cfg.Presets[name]; ok {
+		return fields, nil
+	}
+	if fields, ok := fieldPresets[name]; ok {
+		return fields, nil
+	}
+
+	names := map[string]bool{}
+	for preset := range fieldPresets {
+		names[preset] = true
+	}
+	for preset := range cfg.Presets {
+		names[preset] = true
+	}
+	return nil, fmt.Errorf("unknown preset %q, expected one of %s", name, strings.Join(sortedKeys(names), ", "))
+}
+
+// selectExportFields applies --fields and --exclude-fields to the fields of
+// the preset: fields, if set, replaces them and exclude then removes fields
+// from the result. Nested metadata attributes such as user_metadata.plan can
+// be selected like any other field.
+func selectExportFields(preset, fields, exclude []string) ([]string, error) {
+	selected := preset
+	if len(fields) > 0 {
+		selected = nil
+		seen := map[string]bool{}
+		for _, field := range fields {
+			field = strings.TrimSpace(field)
+			if field == "" || strings.ContainsAny(field, " \t") {
+				return nil, fmt.Errorf("invalid field %q", field)
+			}
+			if strings.Contains(field, ".") && flattenedPath(field) == nil {
+				return nil, fmt.Errorf("%q is not an attribute of %s", field, strings.Join(csvFlattenPrefixes, " or "))
+			}
+			if !seen[field] {
+				seen[field] = true
+				selected = append(selected, field)
+			}
+		}
+	}
+
+	excluded := map[string]bool{}
+	for _, field := range exclude {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(selected, field) {
+			return nil, fmt.Errorf("can't exclude %q, it is not exported", field)
+		}
+		excluded[field] = true
+	}
+
+	var result []string
+	for _, field := range selected {
+		if !excluded[field] {
+			result = append(result, field)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no fields left to export")
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportFieldPreset(t *testing.T) {
+	cfg := &config{Presets: map[string][]string{
+		"minimal":   {"user_id", "email"},
+		"marketing": {"user_id", "email", "user_metadata"},
+	}}
+
+	fields, err := exportFieldPreset(cfg, "minimal")
+	if err != nil || !reflect.DeepEqual(fields, []string{"user_id", "email"}) {
+		t.Errorf("Expected the config to override minimal, got %v, %v", fields, err)
+	}
+
+	fields, err = exportFieldPreset(cfg, "analytics")
+	if err != nil || !reflect.DeepEqual(fields, fieldPresets["analytics"]) {
+		t.Errorf("Expected the built-in analytics preset, got %v, %v", fields, err)
+	}
+
+	if _, err := exportFieldPreset(cfg, "marketing"); err != nil {
+		t.Errorf("Expected the config preset marketing, got %v", err)
+	}
+
+	_, err = exportFieldPreset(cfg, "everything")
+	if err == nil || !strings.Contains(err.Error(), "analytics, full, marketing, minimal, standard") {
+		t.Errorf("Expected an error listing the presets, got %v", err)
+	}
+}
+
+func TestSelectExportFields(t *testing.T) {
+	preset := fieldPresets["standard"]
+
+	fields, err := selectExportFields(preset, nil, nil)
+	if err != nil || !reflect.DeepEqual(fields, preset) {
+		t.Errorf("Expected the preset without flags, got %v, %v", fields, err)
+	}
+
+	fields, err = selectExportFields(preset, []string{"user_id", "email", "user_metadata.plan", "email"}, nil)
+	if err != nil || !reflect.DeepEqual(fields, []string{"user_id", "email", "user_metadata.plan"}) {
+		t.Errorf("Expected --fields to replace the preset, got %v, %v", fields, err)
+	}
+
+	fields, err = selectExportFields(preset, nil, []string{"identities", "updated_at"})
+	if err != nil || len(fields) != len(preset)-2 || strings.Contains(strings.Join(fields, ","), "identities") {
+		t.Errorf("Expected identities and updated_at to be excluded, got %v, %v", fields, err)
+	}
+
+	for _, tc := range []struct {
+		fields, exclude []string
+		err             string
+	}{
+		{[]string{"profile.plan"}, nil, "not an attribute"},
+		{[]string{""}, nil, "invalid field"},
+		{nil, []string{"last_ip"}, "not exported"},
+		{[]string{"email"}, []string{"email"}, "no fields left"},
+	} {
+		if _, err := selectExportFields(preset, tc.fields, tc.exclude); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected an error containing %q for %v/%v, got %v", tc.err, tc.fields, tc.exclude, err)
+		}
+	}
+}
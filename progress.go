@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// quiet hides progress updates: the state of export and import jobs and of
+// downloads. Messages about started and finished jobs, summaries and errors
+// are still printed.
+var quiet bool
+
+// noProgress prints progress updates as plain lines instead of a bar redrawn
+// in place, for CI logs. It is implied when stdout isn't a terminal.
+var noProgress bool
+
+// progressInterval is how often a transfer prints a plain progress line.
+var progressInterval = 10 * time.Second
+
+// progressRedraw is how often a progress bar is redrawn at most.
+const progressRedraw = 100 * time.Millisecond
+
+// activeProgress is the progress bar currently drawn, which progressPrintf
+// moves below the messages printed while it is shown.
+var activeProgress *progress
+
+// progress reports how far a long-running step is, with an ETA once part of
+// it is done. On a terminal it is drawn as a bar on the last line, otherwise
+// it is printed as plain lines. Transfers count bytes and print their plain
+// lines every progressInterval.
+type progress struct {
+	label   string
+	total   int64
+	current int64
+	bytes   bool
+	status  string
+	started time.Time
+	printed time.Time
+	bar     bool
+}
+
+func newProgress(label string, total int64, bytes bool) *progress {
+	return &progress{label: label, total: total, bytes: bytes, started: time.Now(), bar: progressBars()}
+}
+
+// progressBars reports whether progress is drawn as bars, which is only done
+// on a terminal and while stdout isn't machine-readable.
+func progressBars() bool {
+	if quiet || noProgress || nonInteractive || events {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Write counts the bytes of a transfer, so that the progress of io.Copy can
+// be followed through an io.MultiWriter.
+func (p *progress) Write(b []byte) (int, error) {
+	p.update(p.current+int64(len(b)), p.status)
+	return len(b), nil
+}
+
+func (p *progress) update(current int64, status string) {
+	p.current, p.status = current, status
+	if quiet {
+		return
+	}
+
+	interval := time.Duration(0)
+	switch {
+	case p.bar:
+		interval = progressRedraw
+	case p.bytes:
+		interval = progressInterval
+	}
+	if time.Since(p.printed) < interval && (p.total <= 0 || p.current < p.total) {
+		return
+	}
+	p.printed = time.Now()
+
+	if p.bar {
+		activeProgress = p
+		fmt.Fprint(messageOutput, "\r\033[K"+p.line())
+	} else {
+		fmt.Fprintln(messageOutput, p.line())
+	}
+}
+
+// done ends the line of a progress bar, so that the following output starts
+// on a new line.
+func (p *progress) done() {
+	if activeProgress == p {
+		fmt.Fprintln(messageOutput)
+		activeProgress = nil
+	}
+}
+
+// line formats the progress, e.g.
+//
+//	Downloading users.json.gz [=======             ] 38% of 31.5 MB, ETA 20s
+func (p *progress) line() string {
+	var parts []string
+	if p.status != "" {
+		parts = append(parts, p.status)
+	}
+	fraction := 0.0
+	switch {
+	case p.total > 0:
+		fraction = min(float64(p.current)/float64(p.total), 1)
+		amount := fmt.Sprintf("%d%%", int(fraction*100))
+		if p.bytes {
+			amount += " of " + formatSize(uint64(p.total))
+		}
+		parts = append(parts, amount)
+	case p.bytes:
+		parts = append(parts, formatSize(uint64(p.current)))
+	}
+	if eta := p.eta(); eta > 0 {
+		parts = append(parts, "ETA "+eta.String())
+	}
+
+	separator := ": "
+	if p.bar {
+		separator = " "
+		if p.total > 0 {
+			width := 20
+			filled := int(fraction * float64(width))
+			separator = " [" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "] "
+		}
+	}
+	return p.label + separator + strings.Join(parts, ", ")
+}
+
+// eta extrapolates the time left from the rate so far.
+func (p *progress) eta() time.Duration {
+	if p.total <= 0 || p.current <= 0 || p.current >= p.total {
+		return 0
+	}
+	elapsed := time.Since(p.started)
+	left := time.Duration(float64(elapsed) * float64(p.total-p.current) / float64(p.current))
+	return left.Round(time.Second)
+}
+
+// progressPrintf prints a message while a progress bar may be drawn, above
+// the bar.
+func progressPrintf(format string, args ...interface{}) {
+	if activeProgress != nil {
+		fmt.Fprint(messageOutput, "\r\033[K")
+	}
+	fmt.Fprintf(messageOutput, format, args...)
+	if activeProgress != nil {
+		fmt.Fprint(messageOutput, activeProgress.line())
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	p := &progress{label: "Downloading users.json.gz", total: 4000000, bytes: true, started: time.Now().Add(-10 * time.Second)}
+	p.current = 1000000
+	if line := p.line(); line != "Downloading users.json.gz: 25% of 4.0 MB, ETA 30s" {
+		t.Errorf("Unexpected line %q", line)
+	}
+
+	p.bar = true
+	if line := p.line(); line != "Downloading users.json.gz [=====               ] 25% of 4.0 MB, ETA 30s" {
+		t.Errorf("Unexpected bar %q", line)
+	}
+
+	// Without Content-Length only the bytes so far are known.
+	p = &progress{label: "Downloading users.json.gz", total: -1, current: 1500, bytes: true, started: time.Now()}
+	if line := p.line(); line != "Downloading users.json.gz: 1.5 KB" {
+		t.Errorf("Unexpected line %q", line)
+	}
+
+	p = &progress{label: "Export job job_1", total: 100, status: "processing", started: time.Now()}
+	if line := p.line(); line != "Export job job_1: processing, 0%" {
+		t.Errorf("Unexpected line %q", line)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := &progress{total: 300, current: 100, started: time.Now().Add(-time.Minute)}
+	if eta := p.eta(); eta < 119*time.Second || eta > 121*time.Second {
+		t.Errorf("Expected two minutes left, got %s", eta)
+	}
+	p.current = 300
+	if eta := p.eta(); eta != 0 {
+		t.Errorf("Expected no ETA once done, got %s", eta)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// nonInteractive turns prompts into errors and keeps stdout machine-readable:
+// progress messages go to stderr, log messages are written as JSON lines and
+// each command prints a single JSON result object on stdout.
+var nonInteractive bool
+
+// events streams one JSON event per line on stdout (chunk_started,
+// chunk_completed, job_failed, rate_limited, result) while progress messages
+// go to stderr.
+var events bool
+
+// resultOutput is where command results and events are written.
+var resultOutput io.Writer = os.Stdout
+
+// messageOutput is where messages for people are written: stdout, or stderr
+// when stdout is machine-readable.
+var messageOutput io.Writer = os.Stdout
+
+// promptInput reads answers to prompts. It is shared by all prompts, so input
+// buffered while reading one answer is kept for the next.
+var promptInput = bufio.NewReader(os.Stdin)
+
+var errNonInteractive = errors.New("confirmation required but running with --non-interactive")
+
+func setupNonInteractive() {
+	if !nonInteractive && !events {
+		return
+	}
+
+	messageOutput = os.Stderr
+	if nonInteractive {
+		log.SetFlags(0)
+		log.SetOutput(&jsonLogWriter{w: os.Stderr})
+	}
+}
+
+// confirm asks a yes/no question on stdin and defaults to no.
+func confirm(question string) (bool, error) {
+	if nonInteractive {
+		return false, fmt.Errorf("%w: %s", errNonInteractive, question)
+	}
+
+	fmt.Fprintf(messageOutput, "%s [y/N]: ", question)
+
+	answer, err := promptInput.ReadString('\n')
+	if err != nil {
+		return false, nil
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// promptText asks for a line of input on stdin.
+func promptText(question string) (string, error) {
+	if nonInteractive {
+		return "", fmt.Errorf("%w: %s", errNonInteractive, question)
+	}
+
+	fmt.Fprintf(messageOutput, "%s: ", question)
+	answer, err := promptInput.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// reportResult prints the outcome of a command as JSON in non-interactive
+// mode, or as a result event with --events. In interactive mode the commands
+// print their own summaries.
+func reportResult(command string, result map[string]interface{}) {
+	output := map[string]interface{}{"command": command}
+	for key, value := range result {
+		output[key] = value
+	}
+
+	if events {
+		emitEvent("result", output)
+		return
+	}
+	logEvent("result", output)
+	if nonInteractive {
+		json.NewEncoder(resultOutput).Encode(output)
+	}
+}
+
+// emitEvent writes an event line when running with --events, and the event
+// to the structured log.
+func emitEvent(event string, fields map[string]interface{}) {
+	logEvent(event, fields)
+	if !events {
+		return
+	}
+
+	output := map[string]interface{}{
+		"event": event,
+		"time":  time.Now().UTC().Format(time.RFC3339),
+	}
+	for key, value := range fields {
+		output[key] = value
+	}
+
+	json.NewEncoder(resultOutput).Encode(output)
+}
+
+type jsonLogWriter struct {
+	w io.Writer
+}
+
+func (l *jsonLogWriter) Write(p []byte) (int, error) {
+	entry := map[string]string{
+		"time":    time.Now().UTC().Format(time.RFC3339),
+		"level":   "error",
+		"message": strings.TrimSpace(string(p)),
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
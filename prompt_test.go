@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNonInteractive(t *testing.T) {
+	var buf bytes.Buffer
+	nonInteractive, resultOutput = true, &buf
+	defer func() { nonInteractive, resultOutput = false, os.Stdout }()
+
+	if _, err := confirm("Delete everything?"); !errors.Is(err, errNonInteractive) {
+		t.Errorf("Expected confirm to fail in non-interactive mode, got %v", err)
+	}
+
+	reportResult("import", map[string]interface{}{"chunks": 3})
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("Expected JSON result, got %q: %v", buf.String(), err)
+	}
+	if result["command"] != "import" || result["chunks"] != float64(3) {
+		t.Errorf("Unexpected result: %v", result)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	var buf bytes.Buffer
+	events, resultOutput = true, &buf
+	defer func() { events, resultOutput = false, os.Stdout }()
+
+	emitEvent("chunk_started", map[string]interface{}{"chunk": 1, "job_id": "job_1"})
+	reportResult("import", map[string]interface{}{"chunks": 1})
+
+	decoder := json.NewDecoder(&buf)
+	var started, result map[string]interface{}
+	if err := decoder.Decode(&started); err != nil {
+		t.Fatalf("Expected an event line: %v", err)
+	}
+	if err := decoder.Decode(&result); err != nil {
+		t.Fatalf("Expected a result event: %v", err)
+	}
+
+	if started["event"] != "chunk_started" || started["job_id"] != "job_1" || started["time"] == nil {
+		t.Errorf("Unexpected event: %v", started)
+	}
+	if result["event"] != "result" || result["command"] != "import" {
+		t.Errorf("Unexpected result event: %v", result)
+	}
+}
+
+func TestSetupNonInteractive(t *testing.T) {
+	stdout := os.Stdout
+	nonInteractive = true
+	defer func() {
+		nonInteractive, messageOutput = false, os.Stdout
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(os.Stderr)
+	}()
+
+	setupNonInteractive()
+	if os.Stdout != stdout || messageOutput != os.Stderr || resultOutput != os.Stdout {
+		t.Errorf("Expected messages to go to stderr and os.Stdout to be left alone")
+	}
+}
+
+func TestPromptsShareInput(t *testing.T) {
+	promptInput, messageOutput = bufio.NewReader(strings.NewReader("yes\nstaging.eu.auth0.com\n")), io.Discard
+	defer func() { promptInput, messageOutput = bufio.NewReader(os.Stdin), os.Stdout }()
+
+	if ok, err := confirm("Continue?"); !ok || err != nil {
+		t.Errorf("Expected the first answer to confirm, got %v %v", ok, err)
+	}
+	if answer, err := promptText("Type the tenant domain"); answer != "staging.eu.auth0.com" || err != nil {
+		t.Errorf("Expected the second answer, got %q %v", answer, err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// allowProtected must be set to run destructive commands against a tenant
+// whose profile is marked as protected in the config file.
+var allowProtected bool
+
+// readOnly makes every command that would modify a tenant fail before it
+// starts. It can also be enabled with read_only in the config file.
+var readOnly bool
+
+// checkWritable stops the command when running in read-only mode.
+func checkWritable(action string) {
+	if readOnly {
+		log.Fatalf("Refusing to %s: running with --read-only", action)
+	}
+
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.ReadOnly {
+		log.Fatalf("Refusing to %s: read_only is set in the config", action)
+	}
+}
+
+// protectedProfile returns the name of the protected profile for domain, if
+// any.
+func protectedProfile(cfg *config, domain string) (string, bool) {
+	for _, name := range sortedKeys(cfg.Profiles) {
+		profile := cfg.Profiles[name]
+		if profile.Protected && strings.EqualFold(profile.Domain, domain) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// guardProtected stops a destructive action against a protected tenant unless
+// --allow-protected is set and the user types the tenant domain to confirm.
+func guardProtected(domain, action string) {
+	cfg, err := loadOptionalConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	name, ok := protectedProfile(cfg, domain)
+	if !ok {
+		return
+	}
+
+	if !allowProtected {
+		log.Fatalf("Refusing to %s: tenant %s (profile %s) is protected. Re-run with --allow-protected", action, domain, name)
+	}
+
+	answer, err := promptText(fmt.Sprintf("Tenant %s is protected. Type its domain to %s", domain, action))
+	if err != nil {
+		log.Fatalf("Failed to confirm %s on protected tenant: %v", action, err)
+	}
+	if answer != domain {
+		log.Fatalf("Confirmation did not match %s, aborting", domain)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestProtectedProfile(t *testing.T) {
+	cfg := &config{Profiles: map[string]profileConfig{
+		"dev":  {Domain: "dev.eu.auth0.com"},
+		"prod": {Domain: "prod.eu.auth0.com", Protected: true},
+	}}
+
+	if name, ok := protectedProfile(cfg, "PROD.eu.auth0.com"); !ok || name != "prod" {
+		t.Errorf("Expected prod to be protected, got %s %v", name, ok)
+	}
+
+	if _, ok := protectedProfile(cfg, "dev.eu.auth0.com"); ok {
+		t.Errorf("Expected dev not to be protected")
+	}
+}
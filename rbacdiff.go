@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// rbacRole is a role with its permission bindings and user assignments, as
+// compared by diff rbac.
+type rbacRole struct {
+	description string
+	permissions []*management.Permission
+	users       []string
+}
+
+// fetchRBAC reads the roles of a tenant by name, with their permissions and,
+// unless withUsers is false, the IDs of their users.
+func fetchRBAC(ctx context.Context, m *management.Management, withUsers bool) (map[string]rbacRole, error) {
+	roles, err := listRoles(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	rbac := map[string]rbacRole{}
+	for _, role := range roles {
+		permissions, err := rolePermissions(ctx, m, role.GetID())
+		if err != nil {
+			return nil, err
+		}
+
+		var users []string
+		if withUsers {
+			if users, err = roleUserIDs(ctx, m, role.GetID(), 0); err != nil {
+				return nil, err
+			}
+		}
+
+		rbac[role.GetName()] = rbacRole{description: role.GetDescription(), permissions: permissions, users: users}
+	}
+	return rbac, nil
+}
+
+// diffRBAC compares role definitions, role permissions and role users,
+// source against target. Roles are matched by name, permissions by API
+// identifier and name, and users by user_id, which imported users keep.
+func diffRBAC(source, target map[string]rbacRole) []resourceDiff {
+	resources := func(rbac map[string]rbacRole) (roles, permissions, users map[string]map[string]interface{}) {
+		roles, permissions, users = map[string]map[string]interface{}{}, map[string]map[string]interface{}{}, map[string]map[string]interface{}{}
+		for name, role := range rbac {
+			roles[name] = map[string]interface{}{"name": name, "description": role.description}
+			for _, permission := range role.permissions {
+				key := name + "/" + permission.GetResourceServerIdentifier() + "/" + permission.GetName()
+				permissions[key] = map[string]interface{}{
+					"role":                       name,
+					"resource_server_identifier": permission.GetResourceServerIdentifier(),
+					"permission_name":            permission.GetName(),
+				}
+			}
+			for _, id := range role.users {
+				users[name+"/"+id] = map[string]interface{}{"role": name, "user_id": id}
+			}
+		}
+		return roles, permissions, users
+	}
+
+	sourceRoles, sourcePermissions, sourceUsers := resources(source)
+	targetRoles, targetPermissions, targetUsers := resources(target)
+
+	diffs := diffResources("roles", sourceRoles, targetRoles)
+	diffs = append(diffs, diffResources("role-permissions", sourcePermissions, targetPermissions)...)
+	return append(diffs, diffResources("role-users", sourceUsers, targetUsers)...)
+}
+
+func newDiffRBACCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		format    string
+		skipUsers bool
+	)
+
+	var rbacCmd = &cobra.Command{
+		Use:   "rbac",
+		Short: "Compare roles, their permissions and their user assignments between tenants",
+		Run: func(cmd *cobra.Command, args []string) {
+			source, err := fetchRBAC(ctx, clients.sourceClient(), !skipUsers)
+			if err != nil {
+				log.Fatalf("Failed to read source roles: %v", err)
+			}
+			target, err := fetchRBAC(ctx, clients.targetClient(), !skipUsers)
+			if err != nil {
+				log.Fatalf("Failed to read target roles: %v", err)
+			}
+
+			diffs := diffRBAC(source, target)
+			if err := writeDiffs(resultOutput, diffs, format); err != nil {
+				log.Fatalf("Failed to write differences: %v", err)
+			}
+
+			counts := map[string]map[string]int{}
+			for _, d := range diffs {
+				if counts[d.kind] == nil {
+					counts[d.kind] = map[string]int{}
+				}
+				counts[d.kind][d.action]++
+			}
+			for _, kind := range []string{"roles", "role-permissions", "role-users"} {
+				fmt.Fprintf(messageOutput, "%s: %d missing in target, %d only in target, %d different.\n",
+					kind, counts[kind][diffActionCreate], counts[kind][diffActionDelete], counts[kind][diffActionUpdate])
+			}
+		},
+	}
+
+	rbacCmd.Flags().StringVar(&format, "format", "text", "Output format: text, json-patch (RFC 6902) or merge-patch (RFC 7396)")
+	rbacCmd.Flags().BoolVar(&skipUsers, "skip-users", false, "Only compare roles and permissions, not user assignments")
+
+	return rbacCmd
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestDiffRBAC(t *testing.T) {
+	permission := func(name string) *management.Permission {
+		return &management.Permission{ResourceServerIdentifier: auth0.String("https://api.example.com"), Name: auth0.String(name)}
+	}
+
+	source := map[string]rbacRole{
+		"admin":  {description: "Administrators", permissions: []*management.Permission{permission("read:users"), permission("write:users")}, users: []string{"auth0|1", "auth0|2"}},
+		"viewer": {description: "Viewers", permissions: []*management.Permission{permission("read:users")}},
+	}
+	target := map[string]rbacRole{
+		"admin":   {description: "Admins", permissions: []*management.Permission{permission("read:users")}, users: []string{"auth0|1", "auth0|3"}},
+		"support": {description: "Support"},
+	}
+
+	expected := []struct{ resource, action string }{
+		{"roles/admin", diffActionUpdate},
+		{"roles/viewer", diffActionCreate},
+		{"roles/support", diffActionDelete},
+		{"role-permissions/admin/https://api.example.com/write:users", diffActionCreate},
+		{"role-permissions/viewer/https://api.example.com/read:users", diffActionCreate},
+		{"role-users/admin/auth0|2", diffActionCreate},
+		{"role-users/admin/auth0|3", diffActionDelete},
+	}
+
+	diffs := diffRBAC(source, target)
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d differences, got %d: %+v", len(expected), len(diffs), diffs)
+	}
+	for i, e := range expected {
+		if diffs[i].resource() != e.resource || diffs[i].action != e.action {
+			t.Errorf("Expected difference %d to be %s of %s, got %s of %s", i, e.action, e.resource, diffs[i].action, diffs[i].resource())
+		}
+	}
+}
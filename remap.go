@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// nameRemap maps the names of source resources to the names they get in the
+// target tenant, per resource type, as read from a --remap file:
+//
+//	clients:
+//	  Web App: Web App (EU)
+//	connections:
+//	  legacy-db: Username-Password-Authentication
+//	roles:
+//	  admin: Administrator
+//
+// Resources not in the file keep their names.
+type nameRemap map[string]map[string]string
+
+func loadNameRemap(filename string) (nameRemap, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remap file: %w", err)
+	}
+
+	var remap nameRemap
+	if err := yaml.Unmarshal(data, &remap); err != nil {
+		return nil, fmt.Errorf("failed to parse remap file: %w", err)
+	}
+	for kindName, names := range remap {
+		kind, err := findResourceKind(kindName)
+		if err != nil {
+			return nil, err
+		}
+		if kind.keyField == "" {
+			return nil, fmt.Errorf("%s have no name to remap", kind.name)
+		}
+		for from, to := range names {
+			if to == "" {
+				return nil, fmt.Errorf("%s/%s is remapped to an empty name", kind.name, from)
+			}
+		}
+	}
+	return remap, nil
+}
+
+// name returns the target name of a source resource.
+func (r nameRemap) name(kind, name string) string {
+	if to, ok := r[kind][name]; ok {
+		return to
+	}
+	return name
+}
+
+// apply renames the source resources of a kind to their target names. Two
+// resources remapped to the same name are an error.
+func (r nameRemap) apply(kind resourceKind, resources map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	if len(r[kind.name]) == 0 {
+		return resources, nil
+	}
+
+	remapped := map[string]map[string]interface{}{}
+	for _, key := range sortedKeys(resources) {
+		name := r.name(kind.name, key)
+		if _, ok := remapped[name]; ok {
+			return nil, fmt.Errorf("%s/%s is remapped to %s, which another %s already has", kind.name, key, name, kind.name)
+		}
+
+		resource := resources[key]
+		if name != key {
+			resource = make(map[string]interface{}, len(resources[key]))
+			for field, value := range resources[key] {
+				resource[field] = value
+			}
+			resource[kind.keyField] = name
+		}
+		remapped[name] = resource
+	}
+	return remapped, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNameRemap(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "remap.yaml")
+	os.WriteFile(filename, []byte("clients:\n  Web App: Web App (EU)\nroles:\n  admin: Administrator\n"), 0644)
+	remap, err := loadNameRemap(filename)
+	if err != nil {
+		t.Fatalf("Failed to load the remap file: %v", err)
+	}
+
+	if remap.name("roles", "admin") != "Administrator" || remap.name("roles", "support") != "support" {
+		t.Errorf("Expected only admin to be renamed")
+	}
+
+	clients, _ := findResourceKind("clients")
+	source := map[string]map[string]interface{}{
+		"Web App": {"name": "Web App", "app_type": "spa"},
+		"CLI":     {"name": "CLI"},
+	}
+	remapped, err := remap.apply(clients, source)
+	if err != nil {
+		t.Fatalf("Failed to remap: %v", err)
+	}
+	expected := map[string]map[string]interface{}{
+		"Web App (EU)": {"name": "Web App (EU)", "app_type": "spa"},
+		"CLI":          {"name": "CLI"},
+	}
+	if !reflect.DeepEqual(remapped, expected) {
+		t.Errorf("Expected %v, got %v", expected, remapped)
+	}
+	if source["Web App"]["name"] != "Web App" {
+		t.Errorf("Expected the source resources to be left unchanged")
+	}
+
+	source["Web App (EU)"] = map[string]interface{}{"name": "Web App (EU)"}
+	if _, err := remap.apply(clients, source); err == nil {
+		t.Errorf("Expected two clients remapped to the same name to fail")
+	}
+}
+
+func TestInvalidNameRemap(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown type": "widgets:\n  a: b\n",
+		"no name":      "tenant-settings:\n  a: b\n",
+		"empty name":   "roles:\n  admin: ''\n",
+	} {
+		filename := filepath.Join(t.TempDir(), "remap.yaml")
+		os.WriteFile(filename, []byte(content), 0644)
+		if _, err := loadNameRemap(filename); err == nil {
+			t.Errorf("%s: expected an invalid remap file", name)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// authenticatorReportRow lists the credentials of a user that are bound to
+// the source tenant and cannot be migrated.
+type authenticatorReportRow struct {
+	userID             string
+	email              string
+	deviceCredentials  []string
+	authenticatorTypes []string
+}
+
+// userAuthenticators returns the types of the device credentials and MFA
+// authenticators (including WebAuthn keys and passkeys) of a user.
+func userAuthenticators(ctx context.Context, m *management.Management, userID string) ([]string, []string, error) {
+	var deviceTypes, authenticatorTypes []string
+
+	for page := 0; ; page++ {
+		list, err := m.DeviceCredentials.List(ctx, management.Parameter("user_id", userID), management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list device credentials: %w", apiError(err))
+		}
+		for _, credential := range list.DeviceCredentials {
+			deviceTypes = append(deviceTypes, credential.GetType())
+		}
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	for page := 0; ; page++ {
+		list, err := m.User.ListAuthenticationMethods(ctx, userID, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list authentication methods: %w", apiError(err))
+		}
+		for _, method := range list.Authenticators {
+			authenticatorTypes = append(authenticatorTypes, method.GetType())
+		}
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	return deviceTypes, authenticatorTypes, nil
+}
+
+func writeAuthenticatorReport(w io.Writer, rows []authenticatorReportRow) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user_id", "email", "device_credentials", "device_credential_types", "authenticators", "authenticator_types"})
+	for _, row := range rows {
+		writer.Write([]string{
+			row.userID,
+			row.email,
+			strconv.Itoa(len(row.deviceCredentials)),
+			strings.Join(row.deviceCredentials, ";"),
+			strconv.Itoa(len(row.authenticatorTypes)),
+			strings.Join(row.authenticatorTypes, ";"),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// listGrants returns every grant on the tenant, i.e. the scopes each user
+// consented to for each application and audience.
+func listGrants(ctx context.Context, m *management.Management) ([]*management.Grant, error) {
+	var grants []*management.Grant
+
+	for page := 0; ; page++ {
+		list, err := m.Grant.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list grants: %w", apiError(err))
+		}
+		grants = append(grants, list.Grants...)
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		if grants[i].GetUserID() != grants[j].GetUserID() {
+			return grants[i].GetUserID() < grants[j].GetUserID()
+		}
+		return grants[i].GetClientID() < grants[j].GetClientID()
+	})
+	return grants, nil
+}
+
+// clientNames maps the client IDs of the tenant to application names.
+func clientNames(ctx context.Context, m *management.Management) (map[string]string, error) {
+	names := map[string]string{}
+
+	for page := 0; ; page++ {
+		list, err := m.Client.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clients: %w", apiError(err))
+		}
+		for _, client := range list.Clients {
+			names[client.GetClientID()] = client.GetName()
+		}
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	return names, nil
+}
+
+// writeGrantsReport writes one row per grant. emails maps user IDs to email
+// addresses and may be empty.
+func writeGrantsReport(w io.Writer, grants []*management.Grant, names, emails map[string]string) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user_id", "email", "client_id", "application", "audience", "scopes"})
+	for _, grant := range grants {
+		scopes := make([]string, len(grant.Scope))
+		for i, scope := range grant.Scope {
+			scopes[i] = fmt.Sprint(scope)
+		}
+
+		writer.Write([]string{
+			grant.GetUserID(),
+			emails[grant.GetUserID()],
+			grant.GetClientID(),
+			names[grant.GetClientID()],
+			grant.GetAudience(),
+			strings.Join(scopes, " "),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func newReportCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Report on tenant data that needs attention before a migration",
+	}
+
+	var (
+		tenant string
+		input  string
+		output string
+		rate   float64
+	)
+
+	var authenticatorsCmd = &cobra.Command{
+		Use:   "authenticators",
+		Short: "List exported users with device credentials or MFA/WebAuthn authenticators, which cannot be migrated",
+		Run: func(cmd *cobra.Command, args []string) {
+			jsonData, err := readInputFile(input)
+			if err != nil {
+				log.Fatalf("Failed to read the input file: %v", err)
+			}
+
+			users, err := parseUsers(jsonData)
+			if err != nil {
+				log.Fatalf("Failed to parse users: %v", err)
+			}
+
+			var interval time.Duration
+			if rate > 0 {
+				interval = time.Duration(float64(time.Second) / rate)
+			}
+
+			client, err := selectTenant(tenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+			byType := map[string]int{}
+			var rows []authenticatorReportRow
+
+			for i, user := range users {
+				userID, _ := user["user_id"].(string)
+				if userID == "" {
+					continue
+				}
+
+				deviceTypes, authenticatorTypes, err := userAuthenticators(ctx, client, userID)
+				if err != nil {
+					log.Fatalf("Failed to read credentials of %s: %v", userID, err)
+				}
+				if interval > 0 {
+					time.Sleep(2 * interval)
+				}
+				if (i+1)%100 == 0 {
+					fmt.Fprintf(messageOutput, "Checked %d/%d users...\n", i+1, len(users))
+				}
+
+				if len(deviceTypes) == 0 && len(authenticatorTypes) == 0 {
+					continue
+				}
+				for _, t := range append(deviceTypes, authenticatorTypes...) {
+					byType[t]++
+				}
+
+				email, _ := user["email"].(string)
+				rows = append(rows, authenticatorReportRow{userID: userID, email: email, deviceCredentials: deviceTypes, authenticatorTypes: authenticatorTypes})
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				log.Fatalf("Failed to create report: %v", err)
+			}
+			defer file.Close()
+			if err := writeAuthenticatorReport(file, rows); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d of %d users have credentials that cannot be migrated and will be re-prompted. Report written to %s.\n", len(rows), len(users), output)
+			for _, t := range sortedKeys(byType) {
+				fmt.Fprintf(messageOutput, "  %s: %d\n", t, byType[t])
+			}
+
+			reportResult("report authenticators", map[string]interface{}{"users": len(users), "affected": len(rows), "by_type": byType, "file": output})
+		},
+	}
+
+	authenticatorsCmd.Flags().StringVar(&tenant, "tenant", "source", "Tenant to report on: source, target or a profile from the config")
+	authenticatorsCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Exported source users file, optionally gzipped")
+	authenticatorsCmd.Flags().StringVar(&output, "output", "authenticators_report.csv", "CSV report file")
+	authenticatorsCmd.Flags().Float64Var(&rate, "rate", 10, "Maximum API requests per second (0 for unlimited)")
+
+	var (
+		grantsTenant string
+		grantsInput  string
+		grantsOutput string
+	)
+
+	var grantsCmd = &cobra.Command{
+		Use:   "grants",
+		Short: "List which users granted which scopes to which applications",
+		Run: func(cmd *cobra.Command, args []string) {
+			emails := map[string]string{}
+			if grantsInput != "" {
+				jsonData, err := readInputFile(grantsInput)
+				if err != nil {
+					log.Fatalf("Failed to read the input file: %v", err)
+				}
+
+				users, err := parseUsers(jsonData)
+				if err != nil {
+					log.Fatalf("Failed to parse users: %v", err)
+				}
+				for _, user := range users {
+					userID, _ := user["user_id"].(string)
+					email, _ := user["email"].(string)
+					emails[userID] = email
+				}
+			}
+
+			client, err := selectTenant(grantsTenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+
+			grants, err := listGrants(ctx, client)
+			if err != nil {
+				log.Fatalf("Failed to read grants: %v", err)
+			}
+
+			names, err := clientNames(ctx, client)
+			if err != nil {
+				log.Fatalf("Failed to read applications: %v", err)
+			}
+
+			file, err := os.Create(grantsOutput)
+			if err != nil {
+				log.Fatalf("Failed to create report: %v", err)
+			}
+			defer file.Close()
+			if err := writeGrantsReport(file, grants, names, emails); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+
+			users := map[string]bool{}
+			byApplication := map[string]int{}
+			for _, grant := range grants {
+				users[grant.GetUserID()] = true
+				application := names[grant.GetClientID()]
+				if application == "" {
+					application = grant.GetClientID()
+				}
+				byApplication[application]++
+			}
+
+			fmt.Fprintf(messageOutput, "%d grants by %d users. Report written to %s.\n", len(grants), len(users), grantsOutput)
+			for _, application := range sortedKeys(byApplication) {
+				fmt.Fprintf(messageOutput, "  %s: %d\n", application, byApplication[application])
+			}
+
+			reportResult("report grants", map[string]interface{}{"grants": len(grants), "users": len(users), "by_application": byApplication, "file": grantsOutput})
+		},
+	}
+
+	grantsCmd.Flags().StringVar(&grantsTenant, "tenant", "source", "Tenant to report on: source, target or a profile from the config")
+	grantsCmd.Flags().StringVar(&grantsInput, "input", "", "Exported source users file to add email addresses to the report")
+	grantsCmd.Flags().StringVar(&grantsOutput, "output", "grants_report.csv", "CSV report file")
+
+	var (
+		dormantTenant    string
+		dormantInput     string
+		dormantOutput    string
+		dormantThreshold string
+		dormantArchive   string
+		dormantDelete    bool
+		dormantYes       bool
+		dormantRate      float64
+	)
+
+	var dormantCmd = &cobra.Command{
+		Use:   "dormant",
+		Short: "List exported users who haven't logged in within a threshold, and optionally archive and delete them",
+		Run: func(cmd *cobra.Command, args []string) {
+			threshold, err := parseAge(dormantThreshold)
+			if err != nil {
+				log.Fatalf("Invalid --threshold: %v", err)
+			}
+			if dormantDelete && dormantArchive == "" {
+				log.Fatalf("--delete requires --archive, so that the deleted users can be restored")
+			}
+			if dormantDelete {
+				checkWritable("delete dormant users")
+				guardProtected(tenantDomain(dormantTenant), "delete dormant users")
+			}
+
+			archiveFile := localOutput(dormantArchive)
+			now := time.Now()
+			users, total, err := findDormantUsers(dormantInput, now.Add(-threshold), archiveFile)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+
+			file, err := os.Create(dormantOutput)
+			if err != nil {
+				log.Fatalf("Failed to create report: %v", err)
+			}
+			defer file.Close()
+			if err := writeDormantReport(file, users, now); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d of %d users haven't logged in within %s. Report written to %s.\n", len(users), total, dormantThreshold, dormantOutput)
+			result := map[string]interface{}{"users": total, "dormant": len(users), "file": dormantOutput}
+
+			if dormantArchive != "" {
+				if len(pii.fieldsWith(piiEncrypt)) > 0 {
+					key, err := encryptionKey()
+					if err == nil {
+						err = encryptFile(archiveFile, key)
+					}
+					if err != nil {
+						os.Remove(archiveFile)
+						log.Fatalf("PII policy: %v", err)
+					}
+				}
+				if archiveFile != dormantArchive {
+					err := uploadStorageFile(ctx, archiveFile, dormantArchive)
+					os.Remove(archiveFile)
+					if err != nil {
+						log.Fatalf("Failed to upload the archive: %v", err)
+					}
+				}
+				fmt.Fprintf(messageOutput, "Archived the dormant users to %s.\n", dormantArchive)
+				result["archive"] = dormantArchive
+			}
+
+			if dormantDelete && len(users) > 0 {
+				if !dormantYes {
+					ok, err := confirm(fmt.Sprintf("Delete %d dormant users from the %s tenant?", len(users), dormantTenant))
+					if err != nil {
+						log.Fatalf("%v (re-run with --yes to confirm)", err)
+					}
+					if !ok {
+						fmt.Fprintln(messageOutput, "Aborted.")
+						reportResult("report dormant", result)
+						return
+					}
+				}
+
+				client, err := selectTenant(dormantTenant, clients)
+				if err != nil {
+					log.Fatalf("Failed to select tenant: %v", err)
+				}
+				var interval time.Duration
+				if dormantRate > 0 {
+					interval = time.Duration(float64(time.Second) / dormantRate)
+				}
+
+				deleted, failed := deleteDormantUsers(ctx, client, users, interval)
+				fmt.Fprintf(messageOutput, "Deleted %d dormant users, %d failed.\n", deleted, failed)
+				result["deleted"] = deleted
+				result["failed"] = failed
+				reportResult("report dormant", result)
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			reportResult("report dormant", result)
+		},
+	}
+
+	dormantCmd.Flags().StringVar(&dormantTenant, "tenant", "source", "Tenant to delete the dormant users from: source, target or a profile from the config")
+	dormantCmd.Flags().StringVar(&dormantInput, "input", "exported_users.json.gz", "Exported users file with last_login and created_at, optionally gzipped")
+	dormantCmd.Flags().StringVar(&dormantOutput, "output", "dormant_report.csv", "CSV report file")
+	dormantCmd.Flags().StringVar(&dormantThreshold, "threshold", "365d", "Users who haven't logged in within this time are dormant, e.g. 365d")
+	dormantCmd.Flags().StringVar(&dormantArchive, "archive", "", "File or s3://, gs:// or azblob:// URL to archive the full records of the dormant users to, e.g. dormant_users.json.gz")
+	dormantCmd.Flags().BoolVar(&dormantDelete, "delete", false, "Delete the archived users from the tenant")
+	dormantCmd.Flags().BoolVar(&dormantYes, "yes", false, "Skip the confirmation prompt")
+	dormantCmd.Flags().Float64Var(&dormantRate, "rate", 10, "Maximum delete requests per second (0 for unlimited)")
+
+	var (
+		deliverabilityInput   string
+		deliverabilityOutput  string
+		deliverabilityExclude string
+		deliverabilityMX      bool
+		deliverabilityWorkers int
+	)
+
+	var deliverabilityCmd = &cobra.Command{
+		Use:   "deliverability",
+		Short: "List exported email addresses with an invalid syntax or a domain that can't receive mail",
+		Run: func(cmd *cobra.Command, args []string) {
+			results, total, err := checkDeliverability(ctx, deliverabilityInput, deliverabilityMX, deliverabilityWorkers)
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+
+			file, err := os.Create(deliverabilityOutput)
+			if err != nil {
+				log.Fatalf("Failed to create report: %v", err)
+			}
+			defer file.Close()
+			if err := writeDeliverabilityReport(file, results); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+
+			counts := map[string]int{}
+			for _, result := range results {
+				counts[result.status]++
+			}
+			if deliverabilityExclude != "" {
+				exclusions, err := os.Create(deliverabilityExclude)
+				if err != nil {
+					log.Fatalf("Failed to create exclusion list: %v", err)
+				}
+				defer exclusions.Close()
+				if err := writeExclusionList(exclusions, results); err != nil {
+					log.Fatalf("Failed to write exclusion list: %v", err)
+				}
+			}
+
+			fmt.Fprintf(messageOutput, "%d of %d email addresses are undeliverable, %d couldn't be checked. Report written to %s.\n",
+				counts[deliverabilityUndeliverable], total, counts[deliverabilityUnknown], deliverabilityOutput)
+			if deliverabilityExclude != "" {
+				fmt.Fprintf(messageOutput, "Pass %s to campaign --exclude to skip the undeliverable addresses.\n", deliverabilityExclude)
+			}
+			reportResult("report deliverability", map[string]interface{}{
+				"emails": total, "undeliverable": counts[deliverabilityUndeliverable], "unknown": counts[deliverabilityUnknown],
+				"file": deliverabilityOutput, "exclusion_list": deliverabilityExclude,
+			})
+		},
+	}
+
+	deliverabilityCmd.Flags().StringVar(&deliverabilityInput, "input", "exported_users.json.gz", "Exported users file, optionally gzipped")
+	deliverabilityCmd.Flags().StringVar(&deliverabilityOutput, "output", "deliverability_report.csv", "CSV report file")
+	deliverabilityCmd.Flags().StringVar(&deliverabilityExclude, "exclude-output", "undeliverable.txt", "File the undeliverable addresses are written to, one per line, for campaign --exclude; empty to skip")
+	deliverabilityCmd.Flags().BoolVar(&deliverabilityMX, "mx", true, "Look up the MX records of every domain; --mx=false only checks the syntax")
+	deliverabilityCmd.Flags().IntVar(&deliverabilityWorkers, "workers", 10, "Number of concurrent DNS lookups")
+
+	reportCmd.AddCommand(authenticatorsCmd, grantsCmd, dormantCmd, deliverabilityCmd)
+	return reportCmd
+}
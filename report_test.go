@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUserAuthenticators(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/device-credentials"):
+			if r.URL.Query().Get("user_id") != "auth0|1" {
+				t.Errorf("Expected device credentials of auth0|1, got %q", r.URL.Query().Get("user_id"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"device_credentials": []map[string]interface{}{{"type": "public_key"}}})
+		case strings.HasSuffix(r.URL.Path, "/users/auth0|1/authentication-methods"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"authenticators": []map[string]interface{}{{"type": "webauthn-roaming"}, {"type": "totp"}}})
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+
+	deviceTypes, authenticatorTypes, err := userAuthenticators(context.Background(), m, "auth0|1")
+	if err != nil {
+		t.Fatalf("Failed to read authenticators: %v", err)
+	}
+	if !reflect.DeepEqual(deviceTypes, []string{"public_key"}) || !reflect.DeepEqual(authenticatorTypes, []string{"webauthn-roaming", "totp"}) {
+		t.Errorf("Unexpected credentials: %v, %v", deviceTypes, authenticatorTypes)
+	}
+
+	var buf bytes.Buffer
+	rows := []authenticatorReportRow{{userID: "auth0|1", email: "a@example.com", deviceCredentials: deviceTypes, authenticatorTypes: authenticatorTypes}}
+	if err := writeAuthenticatorReport(&buf, rows); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	expected := "user_id,email,device_credentials,device_credential_types,authenticators,authenticator_types\nauth0|1,a@example.com,1,public_key,2,webauthn-roaming;totp\n"
+	if buf.String() != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestGrantsReport(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/grants"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"grants": []map[string]interface{}{
+				{"id": "g2", "clientID": "client_2", "user_id": "auth0|2", "audience": "https://api.example.com", "scope": []string{"read:orders"}},
+				{"id": "g1", "clientID": "client_1", "user_id": "auth0|1", "audience": "https://api.example.com", "scope": []string{"openid", "read:orders"}},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/clients"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"clients": []map[string]interface{}{{"client_id": "client_1", "name": "Shop"}}})
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+
+	grants, err := listGrants(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Failed to list grants: %v", err)
+	}
+	names, err := clientNames(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Failed to list clients: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeGrantsReport(&buf, grants, names, map[string]string{"auth0|1": "a@example.com"}); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	expected := `user_id,email,client_id,application,audience,scopes
+auth0|1,a@example.com,client_1,Shop,https://api.example.com,openid read:orders
+auth0|2,,client_2,,https://api.example.com,read:orders
+`
+	if buf.String() != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
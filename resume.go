@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultImportStateFile records the progress of an import for --resume.
+const defaultImportStateFile = ".auth0-import-state.json"
+
+type importStateChunk struct {
+	Chunk     int       `json:"chunk"`
+	JobID     string    `json:"job_id"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// importState tracks the import job of every chunk by chunk ID. Chunk IDs
+// are derived from the chunk content, so a re-run with the same input can
+// tell which chunks were already imported. Key and Destination identify the
+// import as a whole; CompletedAt is set once all chunks were imported.
+type importState struct {
+	Key         string                       `json:"key,omitempty"`
+	Destination string                       `json:"destination,omitempty"`
+	CompletedAt *time.Time                   `json:"completed_at,omitempty"`
+	Chunks      map[string]*importStateChunk `json:"chunks"`
+}
+
+// importKey is the idempotency key of an import: a hash of the digest of the
+// input data and the options that change what gets imported.
+func importKey(digest []byte, options map[string]interface{}) string {
+	hash := sha256.New()
+	hash.Write(digest)
+	encoded, _ := json.Marshal(options) // map keys are marshalled in sorted order
+	hash.Write(encoded)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// alreadyApplied returns an error if the state shows the import with the same
+// key was already completed against the destination.
+func (s *importState) alreadyApplied(key, destination string) error {
+	if s.CompletedAt == nil || s.Key != key || s.Destination != destination {
+		return nil
+	}
+	return fmt.Errorf("this import (key %s) was already applied to %s at %s", key, destination, s.CompletedAt.Format(time.RFC3339))
+}
+
+func (s *importState) complete(filename string) error {
+	now := time.Now().UTC()
+	s.CompletedAt = &now
+	return s.save(filename)
+}
+
+func loadImportState(filename string) (*importState, error) {
+	state := &importState{Chunks: map[string]*importStateChunk{}}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse import state: %w", err)
+	}
+	if state.Chunks == nil {
+		state.Chunks = map[string]*importStateChunk{}
+	}
+
+	return state, nil
+}
+
+func (s *importState) save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal import state: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write import state: %w", err)
+	}
+	return nil
+}
+
+// record stores the job status of a chunk and writes the state file, if the
+// scheduler keeps one.
+func (s *importScheduler) record(chunk int, id, jobID, status string) error {
+	if s.state == nil {
+		return nil
+	}
+	s.state.Chunks[id] = &importStateChunk{Chunk: chunk, JobID: jobID, Status: status, UpdatedAt: time.Now().UTC()}
+	return s.state.save(s.stateFile)
+}
+
+// resume reports whether a chunk needs no new job because the state file
+// shows it was imported by an earlier run. A job the earlier run left
+// pending is read again: if it is still running it is tracked like a job
+// of this run, if it failed the chunk is imported again.
+func (s *importScheduler) resume(ctx context.Context, chunk int, id, hash string, users int) (bool, error) {
+	if s.state == nil {
+		return false, nil
+	}
+	previous, ok := s.state.Chunks[id]
+	if !ok {
+		return false, nil
+	}
+
+	status := previous.Status
+	if status == "pending" {
+		job, err := s.m.Job.Read(ctx, previous.JobID)
+		if err != nil {
+			return false, fmt.Errorf("failed to read job status: %w", apiError(err))
+		}
+		status = job.GetStatus()
+	}
+
+	switch status {
+	case "completed":
+		progressPrintf("Skipping chunk %d (%s), already imported by job %s.\n", chunk, id, previous.JobID)
+		s.skipped++
+		return true, s.record(chunk, id, previous.JobID, "completed")
+	case "failed":
+		return false, nil
+	default:
+		progressPrintf("Chunk %d (%s) is still being imported by job %s, waiting for it.\n", chunk, id, previous.JobID)
+		s.pending = append(s.pending, pendingImportJob{id: previous.JobID, chunk: chunk, chunkID: id, hash: hash, users: users, started: time.Now()})
+		return true, nil
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestImportSchedulerResume(t *testing.T) {
+	var mu sync.Mutex
+	submitted := 0
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			submitted++
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": fmt.Sprintf("job_%d", submitted), "status": "pending"})
+			return
+		}
+
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": "completed"})
+	}))
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	ctx := context.Background()
+	chunks := [][]map[string]interface{}{
+		{{"email": "user1@example.com"}},
+		{{"email": "user2@example.com"}},
+	}
+
+	// The first run imports only the first chunk.
+	first := newImportScheduler(m, 1)
+	first.poller = poller{}
+	first.state, first.stateFile = &importState{Chunks: map[string]*importStateChunk{}}, stateFile
+	if err := first.submit(ctx, 1, chunks[0]); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+	if err := first.drain(ctx); err != nil {
+		t.Fatalf("Failed to drain scheduler: %v", err)
+	}
+
+	state, err := loadImportState(stateFile)
+	if err != nil {
+		t.Fatalf("Failed to load import state: %v", err)
+	}
+	recorded := state.Chunks[chunkID(chunks[0])]
+	if recorded == nil || recorded.JobID != "job_1" || recorded.Status != "completed" {
+		t.Fatalf("Expected chunk 1 recorded as completed by job_1, got %+v", recorded)
+	}
+
+	// The resumed run skips it and only imports the second chunk.
+	second := newImportScheduler(m, 1)
+	second.poller = poller{}
+	second.state, second.stateFile = state, stateFile
+	for i, chunk := range chunks {
+		if err := second.submit(ctx, i+1, chunk); err != nil {
+			t.Fatalf("Failed to submit chunk %d: %v", i+1, err)
+		}
+	}
+	if err := second.drain(ctx); err != nil {
+		t.Fatalf("Failed to drain scheduler: %v", err)
+	}
+
+	if submitted != 2 || second.skipped != 1 {
+		t.Errorf("Expected 2 jobs submitted in total and 1 chunk skipped, got %d and %d", submitted, second.skipped)
+	}
+	if len(second.results) != 1 || second.results[0].jobID != "job_2" {
+		t.Errorf("Expected only job_2 in the results of the resumed run, got %+v", second.results)
+	}
+}
+
+func TestImportSchedulerResumePendingJob(t *testing.T) {
+	var mu sync.Mutex
+	submitted := 0
+	status := map[string]string{"job_old": "pending", "job_failed": "failed"}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method == http.MethodPost {
+			submitted++
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_new", "status": "pending"})
+			return
+		}
+
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		s := status[id]
+		if s == "" || s == "pending" {
+			// Pending once, then done.
+			status[id] = "completed"
+			s = "pending"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": s})
+	}))
+
+	running := []map[string]interface{}{{"email": "user1@example.com"}}
+	failed := []map[string]interface{}{{"email": "user2@example.com"}}
+
+	scheduler := newImportScheduler(m, 2)
+	scheduler.poller = poller{}
+	scheduler.stateFile = filepath.Join(t.TempDir(), "state.json")
+	scheduler.state = &importState{Chunks: map[string]*importStateChunk{
+		chunkID(running): {Chunk: 1, JobID: "job_old", Status: "pending"},
+		chunkID(failed):  {Chunk: 2, JobID: "job_failed", Status: "failed"},
+	}}
+
+	ctx := context.Background()
+	if err := scheduler.submit(ctx, 1, running); err != nil {
+		t.Fatalf("Failed to submit chunk 1: %v", err)
+	}
+	if err := scheduler.submit(ctx, 2, failed); err != nil {
+		t.Fatalf("Failed to submit chunk 2: %v", err)
+	}
+	if err := scheduler.drain(ctx); err != nil {
+		t.Fatalf("Failed to drain scheduler: %v", err)
+	}
+
+	if submitted != 1 {
+		t.Errorf("Expected only the failed chunk to be imported again, %d jobs submitted", submitted)
+	}
+	if got := scheduler.state.Chunks[chunkID(running)]; got.JobID != "job_old" || got.Status != "completed" {
+		t.Errorf("Expected the running job to be waited for, got %+v", got)
+	}
+	if got := scheduler.state.Chunks[chunkID(failed)]; got.JobID != "job_new" || got.Status != "completed" {
+		t.Errorf("Expected the failed chunk to be imported by a new job, got %+v", got)
+	}
+}
+
+func TestImportStateAlreadyApplied(t *testing.T) {
+	data := []byte(`{"email":"user1@example.com"}`)
+	key := importKey(data, map[string]interface{}{"chunk_size": 1000})
+	if other := importKey(data, map[string]interface{}{"chunk_size": 2000}); other == key {
+		t.Errorf("Expected different options to give a different key")
+	}
+	if again := importKey(data, map[string]interface{}{"chunk_size": 1000}); again != key {
+		t.Errorf("Expected the same input and options to give the same key, got %s and %s", key, again)
+	}
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	state := &importState{Key: key, Destination: "target.auth0.com/con_1", Chunks: map[string]*importStateChunk{}}
+	if err := state.alreadyApplied(key, "target.auth0.com/con_1"); err != nil {
+		t.Errorf("Expected an unfinished import not to count as applied, got %v", err)
+	}
+	if err := state.complete(stateFile); err != nil {
+		t.Fatalf("Failed to complete import state: %v", err)
+	}
+
+	loaded, err := loadImportState(stateFile)
+	if err != nil {
+		t.Fatalf("Failed to load import state: %v", err)
+	}
+	if err := loaded.alreadyApplied(key, "target.auth0.com/con_1"); err == nil {
+		t.Errorf("Expected the completed import to be detected as applied")
+	}
+	if err := loaded.alreadyApplied(key, "other.auth0.com/con_1"); err != nil {
+		t.Errorf("Expected another destination not to count as applied, got %v", err)
+	}
+}
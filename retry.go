@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRetries is the --max-retries flag: how often a Management API request
+// that was rate limited or failed with a transient error is retried.
+var maxRetries int
+
+// rateLimitBuffer is the --rate-limit-buffer flag: when a response says no
+// more than this many requests are left in the rate limit window, further
+// requests wait for the window to reset instead of running into 429s.
+var rateLimitBuffer int
+
+// retryBackoff is the wait before the first retry of a request when the
+// response doesn't say when to retry. It doubles with every attempt.
+var retryBackoff = time.Second
+
+// maxRetryWait caps the wait before a retry.
+const maxRetryWait = time.Minute
+
+// retryTransport retries Management API requests that were rate limited or
+// failed with a 5xx status or a network error, with exponential backoff. Rate
+// limited requests wait until X-RateLimit-Reset. It is shared by all requests
+// to a tenant, so that all of them hold off while the tenant's rate limit is
+// nearly used up.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	buffer     int
+
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{base: base, maxRetries: maxRetries, buffer: rateLimitBuffer}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		if err := t.waitForWindow(req.Context()); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("cannot retry a request whose body can't be replayed")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			t.trackWindow(resp)
+		}
+		if attempt == t.maxRetries || !retryableResponse(req, resp, err) {
+			return resp, err
+		}
+
+		wait := backoff
+		backoff *= 2
+		fields := map[string]interface{}{"method": req.Method, "url": req.URL.Redacted(), "attempt": attempt + 1}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["status"] = resp.StatusCode
+			if resp.StatusCode == http.StatusTooManyRequests {
+				if reset := rateLimitReset(resp); reset > 0 {
+					wait = reset
+				} else if after := retryAfter(resp.Header.Get("Retry-After")); after > 0 {
+					wait = after
+				}
+			}
+			resp.Body.Close()
+		}
+		wait = min(wait, maxRetryWait)
+		fields["retry_in"] = seconds(wait)
+
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			emitEvent("rate_limited", fields)
+		} else {
+			logEvent("api_retry", fields)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// waitForWindow holds a request back while the rate limit window is nearly
+// used up.
+func (t *retryTransport) waitForWindow(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.resumeAt)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(min(wait, maxRetryWait)):
+		return nil
+	}
+}
+
+// trackWindow reads the rate limit headers of a response and pauses further
+// requests until the window resets if at most buffer requests are left.
+func (t *retryTransport) trackWindow(resp *http.Response) {
+	if t.buffer <= 0 {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > t.buffer {
+		return
+	}
+	reset := rateLimitReset(resp)
+	if reset <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if resumeAt := time.Now().Add(reset); resumeAt.After(t.resumeAt) {
+		t.resumeAt = resumeAt
+		logEvent("rate_limit_pause", map[string]interface{}{"remaining": remaining, "resume_in": seconds(reset)})
+	}
+}
+
+// retryableResponse reports whether a request should be retried: rate limited
+// requests, and transient 5xx errors and network errors of idempotent
+// requests. A POST or PATCH may have been applied before its response was
+// lost, and retrying it could e.g. start a second import job or send a
+// second email. Requests cancelled by their context aren't retried.
+func retryableResponse(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := false
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		idempotent = true
+	}
+
+	if err != nil {
+		return idempotent && req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// rateLimitReset returns the time until the rate limit window resets, from
+// the X-RateLimit-Reset header in Unix seconds.
+func rateLimitReset(resp *http.Response) time.Duration {
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Until(time.Unix(reset, 0))
+}
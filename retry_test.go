@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+// newRetryTestManagement creates a client for a test server that retries like
+// the clients of newManagementClient.
+func newRetryTestManagement(t *testing.T, handler http.Handler, transport *retryTransport) *management.Management {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	transport.base = http.DefaultTransport
+	m, err := management.New(strings.TrimPrefix(server.URL, "http://"), management.WithInsecure(), management.WithNoRetries(), management.WithClient(&http.Client{Transport: transport}))
+	if err != nil {
+		t.Fatalf("Failed to create management client: %v", err)
+	}
+	return m
+}
+
+func TestRetryTransportRetriesTransientErrors(t *testing.T) {
+	retryBackoff = 0
+	defer func() { retryBackoff = time.Second }()
+
+	var bodies []string
+	m := newRetryTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		switch len(bodies) {
+		case 1:
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"id":"rol_1","name":"admin"}`))
+		}
+	}), &retryTransport{maxRetries: 5})
+
+	role := &management.Role{Name: auth0.String("admin")}
+	if err := m.Role.Create(context.Background(), role); err != nil {
+		t.Fatalf("Expected the request to be retried, got %v", err)
+	}
+	if len(bodies) != 3 || bodies[2] != bodies[0] || !strings.Contains(bodies[2], "admin") {
+		t.Errorf("Expected the body to be sent 3 times, got %q", bodies)
+	}
+
+	requests := 0
+	m = newRetryTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"rol_1","name":"admin"}`))
+	}), &retryTransport{maxRetries: 5})
+	if _, err := m.Role.Read(context.Background(), "rol_1"); err != nil || requests != 2 {
+		t.Errorf("Expected a GET to be retried after a 503, got %d requests and %v", requests, err)
+	}
+}
+
+func TestRetryTransportDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	retryBackoff = 0
+	defer func() { retryBackoff = time.Second }()
+
+	requests := 0
+	m := newRetryTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"statusCode":503,"message":"Service Unavailable"}`))
+	}), &retryTransport{maxRetries: 5})
+
+	// The job may have been created before the response was lost.
+	job := &management.Job{ConnectionID: auth0.String("con_1"), Format: auth0.String("json")}
+	if err := m.Job.ExportUsers(context.Background(), job); err == nil || requests != 1 {
+		t.Errorf("Expected a POST failing with a 503 not to be retried, got %d requests and %v", requests, err)
+	}
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	retryBackoff = 0
+	defer func() { retryBackoff = time.Second }()
+
+	requests := 0
+	m := newRetryTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"statusCode":429,"message":"Too Many Requests"}`))
+	}), &retryTransport{maxRetries: 2})
+
+	_, err := m.Role.Read(context.Background(), "rol_1")
+	if !errors.Is(apiError(err), ErrRateLimited) {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+}
+
+func TestRetryTransportDoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	m := newRetryTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"statusCode":404,"message":"Not Found"}`))
+	}), &retryTransport{maxRetries: 5})
+
+	if _, err := m.Role.Read(context.Background(), "rol_1"); err == nil || requests != 1 {
+		t.Errorf("Expected a single failed request, got %d and %v", requests, err)
+	}
+}
+
+func TestRetryTransportPausesNearRateLimit(t *testing.T) {
+	transport := &retryTransport{buffer: 2}
+	reset := time.Now().Add(time.Hour)
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "5")
+	resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	transport.trackWindow(resp)
+	if !transport.resumeAt.IsZero() {
+		t.Errorf("Expected no pause with 5 requests left, got one until %s", transport.resumeAt)
+	}
+
+	resp.Header.Set("X-RateLimit-Remaining", "2")
+	transport.trackWindow(resp)
+	if transport.resumeAt.Unix() != reset.Unix() {
+		t.Errorf("Expected a pause until %s, got %s", reset, transport.resumeAt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := transport.waitForWindow(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// lookupBatch is how many users are looked up per search request when
+// reading full profiles by user ID or email.
+const lookupBatch = 50
+
+// roleUserIDs pages through the users assigned to a role using checkpoint
+// pagination, which unlike page numbers isn't capped at 1000 users. A limit
+// of 0 reads all of them.
+func roleUserIDs(ctx context.Context, m *management.Management, roleID string, limit int) ([]string, error) {
+	var ids []string
+	options := []management.RequestOption{management.Take(100)}
+
+	for {
+		list, err := m.Role.Users(ctx, roleID, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users of role %s: %w", roleID, apiError(err))
+		}
+
+		for _, user := range list.Users {
+			ids = append(ids, user.GetID())
+			if limit > 0 && len(ids) == limit {
+				return ids, nil
+			}
+		}
+
+		if list.Next == "" || len(list.Users) == 0 {
+			return ids, nil
+		}
+		options = []management.RequestOption{management.Take(100), management.From(list.Next)}
+	}
+}
+
+// lookupUsers reads the full profiles of the users whose field (user_id or
+// email) has one of the given values, keeping only users with an identity in
+// the connection.
+func lookupUsers(ctx context.Context, m *management.Management, connection, field string, values []string) ([]*management.User, error) {
+	var users []*management.User
+
+	for start := 0; start < len(values); start += lookupBatch {
+		end := start + lookupBatch
+		if end > len(values) {
+			end = len(values)
+		}
+
+		quoted := make([]string, end-start)
+		for i, value := range values[start:end] {
+			quoted[i] = fmt.Sprintf("%q", value)
+		}
+		query := fmt.Sprintf(`identities.connection:"%s" AND %s:(%s)`, connection, field, strings.Join(quoted, " OR "))
+
+		list, err := m.User.Search(ctx, management.Query(query), management.PerPage(lookupBatch))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user profiles: %w", apiError(err))
+		}
+		users = append(users, list.Users...)
+	}
+
+	return users, nil
+}
+
+// matchUsers finds the target users, in the connection, of the source users
+// with the given IDs. Users matched by ID are looked up in batches; with
+// other matchers every source user is read and looked up on its own.
+func matchUsers(ctx context.Context, source, target *management.Management, connection string, matcher *userMatcher, ids []string) ([]*management.User, error) {
+	if matcher.strategy == "user_id" {
+		return lookupUsers(ctx, target, connection, "user_id", ids)
+	}
+
+	var users []*management.User
+	for _, id := range ids {
+		user, err := source.User.Read(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user %s: %w", id, apiError(err))
+		}
+		candidates, err := matcher.find(ctx, target, userData(user))
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up a target user: %w", apiError(err))
+		}
+		for _, candidate := range candidates {
+			if userConnection(candidate) == connection {
+				users = append(users, candidate)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+// projectUser keeps only the given fields of a user, like an export job does.
+func projectUser(user *management.User, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	projected := map[string]interface{}{}
+	for _, field := range fields {
+		// Nested attributes are exported under their path, as export jobs do.
+		var value interface{} = all
+		for _, name := range strings.Split(field, ".") {
+			object, _ := value.(map[string]interface{})
+			value = object[name]
+		}
+		if value != nil {
+			projected[field] = value
+		}
+	}
+	return projected, nil
+}
+
+// exportRoleUsers writes the users assigned to a role, from the connection,
+// to a gzipped newline-delimited JSON file in the format of an export job.
+// It returns how many role members were skipped because they belong to
+// another connection.
+func exportRoleUsers(ctx context.Context, m *management.Management, roleID, connectionID string, fields []string, limit int, filename string) (int, error) {
+	connection, err := m.Connection.Read(ctx, connectionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read connection: %w", apiError(err))
+	}
+
+	ids, err := roleUserIDs(ctx, m, roleID, limit)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(messageOutput, "Role %s has %d users, reading their profiles...\n", roleID, len(ids))
+
+	users, err := lookupUsers(ctx, m, connection.GetName(), "user_id", ids)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	gzWriter := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gzWriter)
+	for _, user := range users {
+		projected, err := projectUser(user, fields)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode user %s: %w", user.GetID(), err)
+		}
+		if err := encoder.Encode(projected); err != nil {
+			return 0, fmt.Errorf("failed to write to file: %w", err)
+		}
+	}
+	if err := gzWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write to file: %w", err)
+	}
+
+	return len(ids) - len(users), nil
+}
+
+// listRoles returns all roles of a tenant, sorted by name.
+func listRoles(ctx context.Context, m *management.Management) ([]*management.Role, error) {
+	var roles []*management.Role
+
+	for page := 0; ; page++ {
+		list, err := m.Role.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles: %w", apiError(err))
+		}
+		roles = append(roles, list.Roles...)
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	sort.SliceStable(roles, func(i, j int) bool { return roles[i].GetName() < roles[j].GetName() })
+	return roles, nil
+}
+
+// rolePermissions returns the permissions granted by a role.
+func rolePermissions(ctx context.Context, m *management.Management, roleID string) ([]*management.Permission, error) {
+	var permissions []*management.Permission
+
+	for page := 0; ; page++ {
+		list, err := m.Role.Permissions(ctx, roleID, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list permissions of role %s: %w", roleID, apiError(err))
+		}
+		permissions = append(permissions, list.Permissions...)
+		if !list.HasNext() {
+			break
+		}
+	}
+
+	return permissions, nil
+}
+
+// missingPermissions returns the source permissions the target role lacks.
+// Permissions are matched by API identifier and name, which are the same in
+// both tenants when the APIs have been migrated.
+func missingPermissions(source, target []*management.Permission) []*management.Permission {
+	existing := map[string]bool{}
+	for _, permission := range target {
+		existing[permission.GetResourceServerIdentifier()+" "+permission.GetName()] = true
+	}
+
+	var missing []*management.Permission
+	for _, permission := range source {
+		if !existing[permission.GetResourceServerIdentifier()+" "+permission.GetName()] {
+			missing = append(missing, &management.Permission{
+				ResourceServerIdentifier: permission.ResourceServerIdentifier,
+				Name:                     permission.Name,
+			})
+		}
+	}
+	return missing
+}
+
+// roleMigration counts what migrating one role changed.
+type roleMigration struct {
+	created     bool
+	updated     bool
+	permissions int
+	assigned    int
+	missing     int
+}
+
+// migrateRole creates or updates a source role in the target tenant, adds
+// its missing permissions and assigns it to the role's users that exist in
+// the target connection, matched with matcher. Imported users keep their
+// user_id, so matching by user_id is the default. API identifiers of permissions are mapped through the
+// apis section of remap. Nothing is changed on a dry run.
+func migrateRole(ctx context.Context, source, target *management.Management, role, existing *management.Role, connection string, matcher *userMatcher, remap nameRemap, dryRun bool) (roleMigration, error) {
+	var result roleMigration
+
+	permissions, err := rolePermissions(ctx, source, role.GetID())
+	if err != nil {
+		return result, err
+	}
+	for _, permission := range permissions {
+		identifier := remap.name("apis", permission.GetResourceServerIdentifier())
+		permission.ResourceServerIdentifier = &identifier
+	}
+
+	var targetPermissions []*management.Permission
+	switch {
+	case existing == nil:
+		result.created = true
+		if !dryRun {
+			existing = &management.Role{Name: role.Name, Description: role.Description}
+			if err := target.Role.Create(ctx, existing); err != nil {
+				return result, fmt.Errorf("failed to create role: %w", apiError(err))
+			}
+		}
+	default:
+		if existing.GetDescription() != role.GetDescription() {
+			result.updated = true
+			if !dryRun {
+				if err := target.Role.Update(ctx, existing.GetID(), &management.Role{Description: role.Description}); err != nil {
+					return result, fmt.Errorf("failed to update role: %w", apiError(err))
+				}
+			}
+		}
+		if targetPermissions, err = rolePermissions(ctx, target, existing.GetID()); err != nil {
+			return result, err
+		}
+	}
+
+	missing := missingPermissions(permissions, targetPermissions)
+	result.permissions = len(missing)
+	if len(missing) > 0 && !dryRun {
+		if err := target.Role.AssociatePermissions(ctx, existing.GetID(), missing); err != nil {
+			return result, fmt.Errorf("failed to add permissions: %w", apiError(err))
+		}
+	}
+
+	ids, err := roleUserIDs(ctx, source, role.GetID(), 0)
+	if err != nil {
+		return result, err
+	}
+	users, err := matchUsers(ctx, source, target, connection, matcher, ids)
+	if err != nil {
+		return result, err
+	}
+	result.missing = len(ids) - len(users)
+
+	for start := 0; start < len(users); start += lookupBatch {
+		end := start + lookupBatch
+		if end > len(users) {
+			end = len(users)
+		}
+
+		if !dryRun {
+			assign := make([]*management.User, end-start)
+			for i, user := range users[start:end] {
+				assign[i] = &management.User{ID: user.ID}
+			}
+			if err := target.Role.AssignUsers(ctx, existing.GetID(), assign); err != nil {
+				return result, fmt.Errorf("failed to assign users: %w", apiError(err))
+			}
+		}
+		result.assigned += end - start
+	}
+
+	return result, nil
+}
+
+// rolePlanEntry describes what migrating a role would do, from the result of
+// a dry run.
+func rolePlanEntry(name string, result roleMigration) planEntry {
+	entry := planEntry{Resource: "roles/" + name, Action: planSkip}
+	switch {
+	case result.created:
+		entry.Action = diffActionCreate
+	case result.updated || result.permissions > 0:
+		entry.Action = diffActionUpdate
+	}
+	entry.Reason = fmt.Sprintf("%d permissions to add, %d users to assign, %d not found in target", result.permissions, result.assigned, result.missing)
+	return entry
+}
+
+func newMigrateRolesCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		include   []string
+		exclude   []string
+		remapFile string
+		match     string
+		dryRun    bool
+	)
+
+	var rolesCmd = &cobra.Command{
+		Use:   "roles",
+		Short: "Copy roles, their permissions and their user assignments to the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("migrate roles")
+				clients.guardTarget("migrate roles")
+			}
+			filter, err := newResourceFilter(include, exclude)
+			if err != nil {
+				log.Fatalf("Invalid filter: %v", err)
+			}
+			matcher, err := parseMatcher(match)
+			if err != nil {
+				log.Fatalf("Invalid --match: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			sourceClient := clients.sourceClient()
+			targetClient := clients.targetClient()
+
+			connection, err := targetClient.Connection.Read(ctx, os.Getenv("DESTINATION_CONNECTION_ID"))
+			if err != nil {
+				log.Fatalf("Failed to read the target connection: %v", apiError(err))
+			}
+
+			roles, err := listRoles(ctx, sourceClient)
+			if err != nil {
+				log.Fatalf("Failed to read source roles: %v", err)
+			}
+			roles = slices.DeleteFunc(roles, func(role *management.Role) bool {
+				return !filter.allows("roles", role.GetName())
+			})
+			// Renamed roles are created and matched under their target names.
+			for _, role := range roles {
+				name := remap.name("roles", role.GetName())
+				role.Name = &name
+			}
+			targetRoles, err := listRoles(ctx, targetClient)
+			if err != nil {
+				log.Fatalf("Failed to read target roles: %v", err)
+			}
+			byName := map[string]*management.Role{}
+			for _, role := range targetRoles {
+				byName[role.GetName()] = role
+			}
+
+			created, assigned, missing, failed := 0, 0, 0, 0
+			var plan []planEntry
+			for _, role := range roles {
+				result, err := migrateRole(ctx, sourceClient, targetClient, role, byName[role.GetName()], connection.GetName(), matcher, remap, dryRun)
+				if err != nil {
+					fmt.Fprintf(messageOutput, "Failed to migrate role %s: %v\n", role.GetName(), err)
+					failed++
+					continue
+				}
+				if dryRun {
+					plan = append(plan, rolePlanEntry(role.GetName(), result))
+					continue
+				}
+
+				action := "unchanged"
+				switch {
+				case result.created:
+					action = "created"
+					created++
+				case result.updated:
+					action = "updated"
+				}
+				fmt.Fprintf(messageOutput, "Role %s: %s, %d permissions added, %d users assigned (%d not found in target).\n",
+					role.GetName(), action, result.permissions, result.assigned, result.missing)
+				assigned += result.assigned
+				missing += result.missing
+			}
+
+			if dryRun {
+				writePlan(messageOutput, plan)
+				reportResult("migrate roles", map[string]interface{}{"dry_run": true, "plan": plan, "failed": failed})
+				if failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+
+			fmt.Fprintf(messageOutput, "Roles migrated: %d roles, %d created, %d failed; %d users assigned, %d not found in target.\n",
+				len(roles), created, failed, assigned, missing)
+			reportResult("migrate roles", map[string]interface{}{
+				"roles": len(roles), "created": created, "failed": failed,
+				"assigned": assigned, "missing": missing,
+			})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	rolesCmd.Flags().StringSliceVar(&include, "include", nil, "Only migrate the roles matching these roles:glob patterns, e.g. roles:app-*")
+	rolesCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "Leave the roles matching these roles:glob patterns alone")
+	rolesCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source role names and API identifiers to their names in the target, e.g. the mapping written by migrate apis")
+	rolesCmd.Flags().StringVar(&match, "match", "user_id", "How role members are matched with target users: user_id, email, username, metadata:<key> or expr:<query>")
+	rolesCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print the plan of what would be created, updated or skipped")
+
+	return rolesCmd
+}
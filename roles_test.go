@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestExportRoleUsers(t *testing.T) {
+	var queries []string
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v2/connections/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "con_1", "name": "Username-Password-Authentication"})
+		case r.URL.Path == "/api/v2/roles/rol_1/users" && r.URL.Query().Get("from") == "":
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|1"}, {"user_id": "auth0|2"}}, "next": "page2"})
+		case r.URL.Path == "/api/v2/roles/rol_1/users":
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "google-oauth2|3"}}})
+		case r.URL.Path == "/api/v2/users":
+			queries = append(queries, r.URL.Query().Get("q"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{
+				{"user_id": "auth0|1", "email": "a@example.com", "last_ip": "10.0.0.1"},
+				{"user_id": "auth0|2", "email": "b@example.com"},
+			}})
+		default:
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+	}))
+
+	filename := filepath.Join(t.TempDir(), "exported_users.json.gz")
+	skipped, err := exportRoleUsers(context.Background(), m, "rol_1", "con_1", []string{"user_id", "email"}, 0, filename)
+	if err != nil {
+		t.Fatalf("Failed to export role users: %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("Expected 1 user from another connection, got %d", skipped)
+	}
+
+	expectedQuery := `identities.connection:"Username-Password-Authentication" AND user_id:("auth0|1" OR "auth0|2" OR "google-oauth2|3")`
+	if len(queries) != 1 || queries[0] != expectedQuery {
+		t.Errorf("Unexpected search queries %q", queries)
+	}
+
+	data, err := readInputFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	records, err := decodeUsers(data)
+	if err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if len(records) != 2 || records[0].user["email"] != "a@example.com" || records[0].user["last_ip"] != nil {
+		t.Errorf("Unexpected exported users: %v", records)
+	}
+}
+
+func TestRoleUserIDsLimit(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|1"}, {"user_id": "auth0|2"}}, "next": "more"})
+	}))
+
+	ids, err := roleUserIDs(context.Background(), m, "rol_1", 1)
+	if err != nil || len(ids) != 1 || ids[0] != "auth0|1" {
+		t.Errorf("Expected only the first user, got %v %v", ids, err)
+	}
+}
+
+func TestMigrateRole(t *testing.T) {
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/roles/rol_src/permissions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"permissions": []map[string]interface{}{
+				{"resource_server_identifier": "https://api.example.com", "permission_name": "read:orders"},
+				{"resource_server_identifier": "https://api.example.com", "permission_name": "write:orders"},
+			}})
+		case "/api/v2/roles/rol_src/users":
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|1"}, {"user_id": "auth0|2"}}})
+		default:
+			t.Errorf("Unexpected source request %s %s", r.Method, r.URL)
+		}
+	}))
+
+	var added, assigned map[string]interface{}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "PATCH /api/v2/roles/rol_dst":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "rol_dst"})
+		case "GET /api/v2/roles/rol_dst/permissions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"permissions": []map[string]interface{}{
+				{"resource_server_identifier": "https://api.example.com", "permission_name": "read:orders"},
+			}})
+		case "POST /api/v2/roles/rol_dst/permissions":
+			json.NewDecoder(r.Body).Decode(&added)
+		case "GET /api/v2/users":
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|1"}}})
+		case "POST /api/v2/roles/rol_dst/users":
+			json.NewDecoder(r.Body).Decode(&assigned)
+		default:
+			t.Errorf("Unexpected target request %s %s", r.Method, r.URL)
+		}
+	}))
+
+	role := &management.Role{ID: auth0.String("rol_src"), Name: auth0.String("admin"), Description: auth0.String("Administrators")}
+	existing := &management.Role{ID: auth0.String("rol_dst"), Name: auth0.String("admin"), Description: auth0.String("Admins")}
+
+	byUserID, _ := parseMatcher("user_id")
+	result, err := migrateRole(context.Background(), source, target, role, existing, "Username-Password-Authentication", byUserID, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to migrate role: %v", err)
+	}
+
+	if result != (roleMigration{updated: true, permissions: 1, assigned: 1, missing: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if permissions, _ := added["permissions"].([]interface{}); len(permissions) != 1 {
+		t.Errorf("Expected only write:orders to be added, got %v", added)
+	}
+	if users, _ := assigned["users"].([]interface{}); len(users) != 1 || users[0] != "auth0|1" {
+		t.Errorf("Expected auth0|1 to be assigned, got %v", assigned)
+	}
+}
+
+func TestMigrateRoleDryRun(t *testing.T) {
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"permissions": []interface{}{}, "users": []interface{}{}})
+	}))
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected target request %s %s", r.Method, r.URL)
+	}))
+
+	role := &management.Role{ID: auth0.String("rol_src"), Name: auth0.String("support")}
+	byUserID, _ := parseMatcher("user_id")
+	result, err := migrateRole(context.Background(), source, target, role, nil, "Username-Password-Authentication", byUserID, nil, true)
+	if err != nil || !result.created {
+		t.Errorf("Expected the role to be reported as created, got %+v %v", result, err)
+	}
+}
+
+func TestMatchUsers(t *testing.T) {
+	identity := []map[string]interface{}{{"connection": "Username-Password-Authentication"}}
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/users/")
+		json.NewEncoder(w).Encode(map[string]interface{}{"user_id": id, "email": strings.TrimPrefix(id, "legacy|") + "@example.com"})
+	}))
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("email") {
+		case "a@example.com":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"user_id": "google-oauth2|9", "identities": []map[string]interface{}{{"connection": "google-oauth2"}}},
+				{"user_id": "auth0|1", "identities": identity},
+			})
+		default:
+			json.NewEncoder(w).Encode([]interface{}{})
+		}
+	}))
+
+	byEmail, _ := parseMatcher("email")
+	users, err := matchUsers(context.Background(), source, target, "Username-Password-Authentication", byEmail, []string{"legacy|a", "legacy|b"})
+	if err != nil {
+		t.Fatalf("Failed to match users: %v", err)
+	}
+	if len(users) != 1 || users[0].GetID() != "auth0|1" {
+		t.Errorf("Expected legacy|a to match auth0|1 in the connection, got %v", users)
+	}
+}
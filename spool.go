@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultSpoolDir is where import writes its chunk files, so that --resume
+// can import them without reading the input again.
+const defaultSpoolDir = ".auth0-import-spool"
+
+// chunkManifestFile describes the chunks in a spool directory.
+const chunkManifestFile = "manifest.json"
+
+// chunkManifest records which import the chunks of a spool directory belong
+// to, by its idempotency key, and what splitting the input found.
+type chunkManifest struct {
+	Key     string `json:"key"`
+	Users   int    `json:"users"`
+	Chunks  int    `json:"chunks"`
+	Dormant int    `json:"dormant,omitempty"`
+	Skipped int    `json:"skipped,omitempty"`
+}
+
+// userChunks are the chunks of an import, written to a spool directory as
+// one JSON array file per chunk. A chunk is only read and decoded when it is
+// imported, so large exports aren't held in memory.
+type userChunks struct {
+	dir    string
+	users  int
+	chunks int
+}
+
+func (c *userChunks) count() int {
+	return c.chunks
+}
+
+func (c *userChunks) chunkFile(i int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("chunk-%06d.json", i+1))
+}
+
+func (c *userChunks) chunk(i int) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(c.chunkFile(i))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	var users []map[string]interface{}
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode chunk: %w", err)
+	}
+	return users, nil
+}
+
+// prepareSpoolDir creates a spool directory, or empties it of the chunks of
+// an earlier import.
+func prepareSpoolDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return clearSpoolDir(dir)
+}
+
+// clearSpoolDir removes the chunks and the manifest from a spool directory.
+func clearSpoolDir(dir string) error {
+	old, _ := filepath.Glob(filepath.Join(dir, "chunk-*.json"))
+	for _, name := range append(old, filepath.Join(dir, chunkManifestFile)) {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear spool directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeSpoolDir removes a spool directory once its import completed. The
+// directory itself is kept if it holds other files.
+func removeSpoolDir(dir string) error {
+	if err := clearSpoolDir(dir); err != nil {
+		return err
+	}
+	os.Remove(dir)
+	return nil
+}
+
+// writeChunkFile writes the encoded users of a chunk as a JSON array.
+func writeChunkFile(name string, users [][]byte) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create chunk file: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	w.WriteByte('[')
+	for i, user := range users {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.Write(user)
+	}
+	w.WriteByte(']')
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write chunk file: %w", err)
+	}
+	return file.Close()
+}
+
+func (c *userChunks) saveManifest(manifest chunkManifest) error {
+	manifest.Users, manifest.Chunks = c.users, c.chunks
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal chunk manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, chunkManifestFile), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write chunk manifest: %w", err)
+	}
+	return nil
+}
+
+// loadSpooledChunks returns the chunks an earlier run spooled for the import
+// with the given key, or nil if the spool directory doesn't hold all of
+// them.
+func loadSpooledChunks(dir, key string) (*userChunks, *chunkManifest) {
+	data, err := os.ReadFile(filepath.Join(dir, chunkManifestFile))
+	if err != nil {
+		return nil, nil
+	}
+	var manifest chunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Key != key {
+		return nil, nil
+	}
+
+	chunks := &userChunks{dir: dir, users: manifest.Users, chunks: manifest.Chunks}
+	for i := 0; i < chunks.count(); i++ {
+		if _, err := os.Stat(chunks.chunkFile(i)); err != nil {
+			return nil, nil
+		}
+	}
+	return chunks, &manifest
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitUsersSpoolsChunks(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	os.MkdirAll(dir, 0o700)
+	os.WriteFile(filepath.Join(dir, "chunk-000009.json"), []byte("[]"), 0o600)
+
+	input := strings.NewReader(`{"user_id": "3", "email": "user3@example.com"}
+{"user_id": "1", "email": "user1@example.com"}
+{"user_id": "2", "email": "user2@example.com"}`)
+	chunks, err := splitUsers(input, 100, nil, nil, dir)
+	if err != nil {
+		t.Fatalf("Failed to split users: %v", err)
+	}
+	if chunks.users != 3 || chunks.count() != 2 {
+		t.Fatalf("Expected 3 users in 2 chunks, got %d in %d", chunks.users, chunks.count())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 || filepath.Base(files[0]) != "chunk-000001.json" || filepath.Base(files[1]) != "chunk-000002.json" {
+		t.Errorf("Expected only the 2 chunk files in the spool directory, got %v", files)
+	}
+	first, err := chunks.chunk(0)
+	if err != nil {
+		t.Fatalf("Failed to read chunk: %v", err)
+	}
+	if len(first) != 2 || first[0]["user_id"] != "1" || first[1]["user_id"] != "2" {
+		t.Errorf("Expected users 1 and 2 in the first chunk, got %v", first)
+	}
+
+	if err := chunks.saveManifest(chunkManifest{Key: "abc", Dormant: 4}); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+	if spooled, _ := loadSpooledChunks(dir, "other"); spooled != nil {
+		t.Errorf("Expected the chunks of another import not to be reused")
+	}
+	spooled, manifest := loadSpooledChunks(dir, "abc")
+	if spooled == nil || spooled.count() != 2 || spooled.users != 3 || manifest.Dormant != 4 {
+		t.Fatalf("Expected the spooled chunks to be reused, got %v %v", spooled, manifest)
+	}
+
+	os.Remove(chunks.chunkFile(1))
+	if spooled, _ := loadSpooledChunks(dir, "abc"); spooled != nil {
+		t.Errorf("Expected incomplete spooled chunks not to be reused")
+	}
+
+	if err := removeSpoolDir(dir); err != nil {
+		t.Fatalf("Failed to remove spool directory: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the spool directory to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// objectStore is a cloud storage bucket export files are written to and
+// import files read from.
+type objectStore interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+}
+
+// storageError is returned when a storage service answers with an error
+// status.
+type storageError struct {
+	service string
+	status  string
+	body    string
+}
+
+func (e *storageError) Error() string {
+	if e.body != "" {
+		return fmt.Sprintf("%s returned %s: %s", e.service, e.status, e.body)
+	}
+	return fmt.Sprintf("%s returned %s", e.service, e.status)
+}
+
+func newStorageError(service string, resp *http.Response) *storageError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &storageError{service: service, status: resp.Status, body: strings.TrimSpace(string(body))}
+}
+
+// doStorageRequest sends a request and returns the response body, or an
+// error for a non-2xx status.
+func doStorageRequest(service string, req *http.Request) (io.ReadCloser, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", service, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newStorageError(service, resp)
+	}
+	return resp.Body, nil
+}
+
+// isStorageURL reports whether a file argument refers to cloud storage
+// rather than the local filesystem.
+func isStorageURL(name string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "azblob://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// openStorageURL returns the store of a s3://, gs:// or azblob:// URL and
+// the object key in it. Credentials are read from the environment
+// variables of each provider's own tools.
+func openStorageURL(rawURL string) (objectStore, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid storage URL: %w", err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, "", fmt.Errorf("invalid storage URL %q, expected %s://<bucket>/<path>", rawURL, u.Scheme)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == "" {
+			return nil, "", fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for %s", rawURL)
+		}
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		return &s3Store{
+			bucket:       u.Host,
+			region:       region,
+			endpoint:     strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL_S3"), "/"),
+			accessKey:    accessKey,
+			secretKey:    secretKey,
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}, key, nil
+	case "gs":
+		endpoint := "https://storage.googleapis.com"
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			endpoint = strings.TrimSuffix(host, "/")
+		}
+		return &gcsStore{bucket: u.Host, endpoint: endpoint, token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")}, key, nil
+	case "azblob":
+		account, sas := os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+		if account == "" || sas == "" {
+			return nil, "", fmt.Errorf("AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN are required for %s", rawURL)
+		}
+		endpoint := strings.TrimSuffix(os.Getenv("AZURE_STORAGE_ENDPOINT"), "/")
+		if endpoint == "" {
+			endpoint = "https://" + account + ".blob.core.windows.net"
+		}
+		return &azureStore{container: u.Host, endpoint: endpoint, sas: strings.TrimPrefix(sas, "?")}, key, nil
+	}
+	return nil, "", fmt.Errorf("unknown storage scheme %q, expected s3, gs or azblob", u.Scheme)
+}
+
+// downloadStorageFile copies a storage URL into a temporary file for the
+// commands that read local files, and returns the file name. The caller
+// removes the file. The download is limited to the --bwlimit bandwidth.
+func downloadStorageFile(ctx context.Context, rawURL string) (string, error) {
+	store, key, err := openStorageURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp("", "auth0-tools-*-"+path.Base(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, throttle(body)); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	return file.Name(), nil
+}
+
+// uploadStorageFile uploads a local file to a storage URL, limited to the
+// --bwlimit bandwidth.
+func uploadStorageFile(ctx context.Context, filename, rawURL string) error {
+	store, key, err := openStorageURL(rawURL)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if err := store.Put(ctx, key, throttle(file), info.Size()); err != nil {
+		return fmt.Errorf("failed to upload to %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// localOutput returns the local file a command writes before uploading it
+// to output, a temporary file if output is a storage URL.
+func localOutput(output string) string {
+	if !isStorageURL(output) {
+		return output
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("auth0-tools-%d-%s", os.Getpid(), path.Base(output)))
+}
+
+// s3Store is an S3 bucket, or a bucket of an S3 compatible service at
+// endpoint. Requests are signed with AWS Signature Version 4.
+type s3Store struct {
+	bucket       string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// objectURL returns the URL of an object. The key is escaped the way the
+// signature expects, every byte but unreserved characters and slashes.
+func (s *s3Store) objectURL(key string) string {
+	var escaped strings.Builder
+	escaped.WriteString("/")
+	for _, b := range []byte(key) {
+		if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || strings.IndexByte("-._~/", b) >= 0 {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + escaped.String()
+	}
+	return "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com" + escaped.String()
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, time.Now())
+	return doStorageRequest("S3", req)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	s.sign(req, time.Now())
+	resp, err := doStorageRequest("S3", req)
+	if err != nil {
+		return err
+	}
+	return resp.Close()
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a request.
+// The payload isn't hashed, so that uploads can be streamed.
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := sortedKeys(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Object requests have no query string.
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), "",
+		canonicalHeaders.String(), signedHeaders, "UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// gcsMetadataTokenURL is where a Google Cloud VM or runner gets the access
+// token of its service account.
+var gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsStore is a Google Cloud Storage bucket, accessed through the JSON API
+// with an OAuth access token.
+type gcsStore struct {
+	bucket   string
+	endpoint string
+	token    string
+}
+
+// accessToken returns GOOGLE_OAUTH_ACCESS_TOKEN, or else the token of the
+// service account from the metadata server.
+func (s *gcsStore) accessToken(ctx context.Context) (string, error) {
+	if s.token != "" {
+		return s.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := doStorageRequest("the metadata server", req)
+	if err != nil {
+		return "", fmt.Errorf("no Google Cloud credentials, set GOOGLE_OAUTH_ACCESS_TOKEN: %w", err)
+	}
+	defer body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse the access token: %w", err)
+	}
+	s.token = token.AccessToken
+	return s.token, nil
+}
+
+func (s *gcsStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objectURL := s.endpoint + "/storage/v1/b/" + url.PathEscape(s.bucket) + "/o/" + url.PathEscape(key) + "?alt=media"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doStorageRequest("Cloud Storage", req)
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	uploadURL := s.endpoint + "/upload/storage/v1/b/" + url.PathEscape(s.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := doStorageRequest("Cloud Storage", req)
+	if err != nil {
+		return err
+	}
+	return resp.Close()
+}
+
+// azureStore is an Azure Blob Storage container, accessed with a shared
+// access signature.
+type azureStore struct {
+	container string
+	endpoint  string
+	sas       string
+}
+
+func (s *azureStore) blobURL(key string) string {
+	return s.endpoint + "/" + s.container + (&url.URL{Path: "/" + key}).EscapedPath() + "?" + s.sas
+}
+
+func (s *azureStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.blobURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Version", "2021-08-06")
+	return doStorageRequest("Azure Blob Storage", req)
+}
+
+func (s *azureStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.blobURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("X-Ms-Version", "2021-08-06")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	resp, err := doStorageRequest("Azure Blob Storage", req)
+	if err != nil {
+		return err
+	}
+	return resp.Close()
+}
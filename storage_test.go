@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStorage is an in-memory object store server recording the requests
+// it gets.
+type fakeStorage struct {
+	objects  map[string][]byte
+	requests []*http.Request
+}
+
+func newFakeStorage(t *testing.T, handler func(f *fakeStorage, w http.ResponseWriter, r *http.Request)) (*fakeStorage, *httptest.Server) {
+	f := &fakeStorage{objects: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests = append(f.requests, r)
+		handler(f, w, r)
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestStorageRoundTrip(t *testing.T) {
+	pathHandler := func(f *fakeStorage, w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			f.objects[r.URL.EscapedPath()], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := f.objects[r.URL.EscapedPath()]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}
+	gcsHandler := func(f *fakeStorage, w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			f.objects[r.URL.Query().Get("name")], _ = io.ReadAll(r.Body)
+			return
+		}
+		w.Write(f.objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")])
+	}
+
+	for _, tc := range []struct {
+		url     string
+		handler func(f *fakeStorage, w http.ResponseWriter, r *http.Request)
+		env     func(endpoint string)
+		check   func(r *http.Request) bool
+	}{
+		{
+			url:     "s3://bucket/exports/users 1.json.gz",
+			handler: pathHandler,
+			env: func(endpoint string) {
+				t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+				t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+				t.Setenv("AWS_ENDPOINT_URL_S3", endpoint)
+			},
+			check: func(r *http.Request) bool {
+				return r.URL.EscapedPath() == "/bucket/exports/users%201.json.gz" &&
+					strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") &&
+					r.Header.Get("X-Amz-Content-Sha256") == "UNSIGNED-PAYLOAD"
+			},
+		},
+		{
+			url:     "gs://bucket/exports/users.json.gz",
+			handler: gcsHandler,
+			env: func(endpoint string) {
+				t.Setenv("STORAGE_EMULATOR_HOST", endpoint)
+				t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token")
+			},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer token"
+			},
+		},
+		{
+			url:     "azblob://container/exports/users.json.gz",
+			handler: pathHandler,
+			env: func(endpoint string) {
+				t.Setenv("AZURE_STORAGE_ACCOUNT", "account")
+				t.Setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2021&sig=abc")
+				t.Setenv("AZURE_STORAGE_ENDPOINT", endpoint)
+			},
+			check: func(r *http.Request) bool {
+				return r.URL.Query().Get("sig") == "abc" && (r.Method != http.MethodPut || r.Header.Get("X-Ms-Blob-Type") == "BlockBlob")
+			},
+		},
+	} {
+		f, server := newFakeStorage(t, tc.handler)
+		tc.env(server.URL)
+
+		filename := filepath.Join(t.TempDir(), "users.json.gz")
+		os.WriteFile(filename, []byte("users"), 0644)
+		if err := uploadStorageFile(context.Background(), filename, tc.url); err != nil {
+			t.Fatalf("%s: failed to upload: %v", tc.url, err)
+		}
+		downloaded, err := downloadStorageFile(context.Background(), tc.url)
+		if err != nil {
+			t.Fatalf("%s: failed to download: %v", tc.url, err)
+		}
+		data, _ := os.ReadFile(downloaded)
+		os.Remove(downloaded)
+		if !bytes.Equal(data, []byte("users")) {
+			t.Errorf("%s: expected the uploaded file, got %q", tc.url, data)
+		}
+
+		for _, r := range f.requests {
+			if !tc.check(r) {
+				t.Errorf("%s: unexpected %s request %s %v", tc.url, r.Method, r.URL, r.Header)
+			}
+		}
+	}
+}
+
+func TestStorageThrottled(t *testing.T) {
+	defer func() { bandwidth = nil }()
+	bandwidth = newBandwidthLimiter(100000)
+
+	_, server := newFakeStorage(t, func(f *fakeStorage, w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			f.objects[r.URL.EscapedPath()], _ = io.ReadAll(r.Body)
+			return
+		}
+		w.Write(f.objects[r.URL.EscapedPath()])
+	})
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+
+	filename := filepath.Join(t.TempDir(), "users.json.gz")
+	os.WriteFile(filename, make([]byte, 20000), 0644)
+
+	start := time.Now()
+	if err := uploadStorageFile(context.Background(), filename, "s3://bucket/users.json.gz"); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the upload to be throttled, took %s", elapsed)
+	}
+
+	start = time.Now()
+	downloaded, err := downloadStorageFile(context.Background(), "s3://bucket/users.json.gz")
+	if err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	}
+	defer os.Remove(downloaded)
+	if info, _ := os.Stat(downloaded); info == nil || info.Size() != 20000 {
+		t.Errorf("Expected 20000 bytes downloaded, got %v", info)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the download to be throttled, took %s", elapsed)
+	}
+}
+
+func TestStorageErrors(t *testing.T) {
+	_, server := newFakeStorage(t, func(f *fakeStorage, w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	})
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+
+	_, err := downloadStorageFile(context.Background(), "s3://bucket/users.json")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected the error of the service, got %v", err)
+	}
+
+	for _, url := range []string{"s3://bucket", "ftp://host/users.json", "azblob://container/users.json"} {
+		if _, _, err := openStorageURL(url); err == nil {
+			t.Errorf("%s: expected an error", url)
+		}
+	}
+	if isStorageURL("exported_users.json.gz") || !isStorageURL("gs://bucket/users.json") {
+		t.Errorf("Expected only URLs to be storage URLs")
+	}
+}
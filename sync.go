@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// metadataPatch returns the changes needed to make target equal to source.
+// Keys only present on the target are set to nil so Auth0 removes them.
+func metadataPatch(source, target map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+
+	for key, value := range source {
+		if existing, ok := target[key]; !ok || !reflect.DeepEqual(existing, value) {
+			patch[key] = value
+		}
+	}
+
+	for key := range target {
+		if _, ok := source[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	return patch
+}
+
+func metadataMap(value interface{}) map[string]interface{} {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+func userMetadata(user *management.User, field string) map[string]interface{} {
+	var metadata *map[string]interface{}
+	if field == "app_metadata" {
+		metadata = user.AppMetadata
+	} else {
+		metadata = user.UserMetadata
+	}
+
+	if metadata == nil {
+		return map[string]interface{}{}
+	}
+	return *metadata
+}
+
+// planMetadataSync looks up each exported source user in the target tenant
+// and builds patches for the users whose metadata differs.
+func planMetadataSync(ctx context.Context, m *management.Management, matcher *userMatcher, users []map[string]interface{}, fields []string, interval time.Duration) ([]userPatch, error) {
+	var patches []userPatch
+	missing, ambiguous := 0, 0
+
+	for _, user := range users {
+		key, ok := matcher.key(user)
+		if !ok {
+			continue
+		}
+
+		matches, err := matcher.find(ctx, m, user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s in target tenant: %w", key, err)
+		}
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+
+		switch {
+		case len(matches) == 0:
+			missing++
+			continue
+		case len(matches) > 1:
+			fmt.Fprintf(messageOutput, "Skipping %s: %d matching users in target tenant\n", redactPII(strings.Join(matcher.path, "."), key), len(matches))
+			ambiguous++
+			continue
+		}
+
+		target := matches[0]
+		patch := &management.User{}
+		changed := false
+
+		for _, field := range fields {
+			diff := metadataPatch(metadataMap(user[field]), userMetadata(target, field))
+			if len(diff) == 0 {
+				continue
+			}
+
+			changed = true
+			if field == "app_metadata" {
+				patch.AppMetadata = &diff
+			} else {
+				patch.UserMetadata = &diff
+			}
+		}
+
+		if changed {
+			patches = append(patches, userPatch{userID: target.GetID(), user: patch})
+		}
+	}
+
+	fmt.Fprintf(messageOutput, "%d users not found in target tenant, %d ambiguous.\n", missing, ambiguous)
+	return patches, nil
+}
+
+func newSyncCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Keep data aligned between the source and target tenants",
+	}
+
+	var (
+		input      string
+		match      string
+		fields     []string
+		dryRun     bool
+		batchSize  int
+		batchDelay time.Duration
+		rate       float64
+	)
+
+	var metadataCmd = &cobra.Command{
+		Use:   "metadata",
+		Short: "Copy app_metadata/user_metadata from exported source users to matching target users",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("sync metadata")
+			}
+			for _, field := range fields {
+				if field != "app_metadata" && field != "user_metadata" {
+					log.Fatalf("Unknown metadata field %q, expected app_metadata or user_metadata", field)
+				}
+			}
+			if batchSize <= 0 {
+				log.Fatalf("Batch size must be greater than zero")
+			}
+
+			matcher, err := parseMatcher(match)
+			if err != nil {
+				log.Fatalf("Invalid match strategy: %v", err)
+			}
+
+			jsonData, err := readInputFile(input)
+			if err != nil {
+				log.Fatalf("Failed to read the input file: %v", err)
+			}
+
+			users, err := parseUsers(jsonData)
+			if err != nil {
+				log.Fatalf("Failed to parse users: %v", err)
+			}
+
+			var interval time.Duration
+			if rate > 0 {
+				interval = time.Duration(float64(time.Second) / rate)
+			}
+
+			targetClient := clients.targetClient()
+
+			fmt.Fprintf(messageOutput, "Comparing %s for %d users...\n", strings.Join(fields, ", "), len(users))
+			patches, err := planMetadataSync(ctx, targetClient, matcher, users, fields, interval)
+			if err != nil {
+				log.Fatalf("Failed to compare metadata: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d users need a metadata update.\n", len(patches))
+			if dryRun || len(patches) == 0 {
+				reportResult("sync metadata", map[string]interface{}{"pending": len(patches), "dry_run": dryRun})
+				return
+			}
+
+			updated, failed := applyUserPatches(ctx, targetClient, patches, batchSize, interval, batchDelay)
+			fmt.Fprintf(messageOutput, "Sync finished: %d updated, %d failed.\n", updated, failed)
+			reportResult("sync metadata", map[string]interface{}{"updated": updated, "failed": failed})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	metadataCmd.Flags().StringVar(&input, "input", "exported_users.json.gz", "Exported source users file, optionally gzipped")
+	metadataCmd.Flags().StringVar(&match, "match", "email", "How users are matched across tenants: email, username, user_id, metadata:<key> or expr:<query>")
+	metadataCmd.Flags().StringSliceVar(&fields, "fields", []string{"app_metadata", "user_metadata"}, "Metadata fields to sync")
+	metadataCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report how many users would be updated")
+	metadataCmd.Flags().IntVar(&batchSize, "batch-size", 50, "Number of users updated per batch")
+	metadataCmd.Flags().DurationVar(&batchDelay, "batch-delay", time.Second, "Delay between batches")
+	metadataCmd.Flags().Float64Var(&rate, "rate", 10, "Maximum API requests per second (0 for unlimited)")
+
+	syncCmd.AddCommand(metadataCmd, newSyncIdentitiesCmd(ctx, clients))
+	return syncCmd
+}
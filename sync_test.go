@@ -0,0 +1,22 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataPatch(t *testing.T) {
+	source := map[string]interface{}{"plan": "pro", "locale": "en"}
+	target := map[string]interface{}{"plan": "free", "locale": "en", "legacy_id": "42"}
+
+	patch := metadataPatch(source, target)
+
+	expected := map[string]interface{}{"plan": "pro", "legacy_id": nil}
+	if !reflect.DeepEqual(patch, expected) {
+		t.Errorf("Expected %v, got %v", expected, patch)
+	}
+
+	if patch := metadataPatch(source, source); len(patch) != 0 {
+		t.Errorf("Expected no changes for identical metadata, got %v", patch)
+	}
+}
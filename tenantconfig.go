@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// resourceKind describes a tenant configuration resource that can be
+// compared between tenants. Resources are matched by keyField because IDs
+// differ between tenants, and the ignored fields are tenant-specific.
+// Fields in readOnly are accepted on create but rejected on update. Kinds
+// without a keyField, such as the tenant settings, have exactly one resource
+// per tenant.
+type resourceKind struct {
+	name     string
+	keyField string
+	idField  string
+	ignore   []string
+	readOnly []string
+	list     func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error)
+	create   func(ctx context.Context, m *management.Management, data []byte) error
+	update   func(ctx context.Context, m *management.Management, id string, data []byte) error
+	delete   func(ctx context.Context, m *management.Management, id string) error
+	// lint, if set, warns about resource content that won't work on the
+	// target.
+	lint func(resource map[string]interface{}) []string
+}
+
+var resourceKinds = []resourceKind{
+	{
+		name:     "clients",
+		keyField: "name",
+		idField:  "client_id",
+		ignore:   []string{"client_id", "client_secret", "signing_keys", "tenant", "global"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.Client.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			var items []interface{}
+			for _, client := range list.Clients {
+				if client.GetName() == "All Applications" {
+					continue // the tenant's global client
+				}
+				items = append(items, client)
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var client management.Client
+			if err := json.Unmarshal(data, &client); err != nil {
+				return err
+			}
+			return m.Client.Create(ctx, &client)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var client management.Client
+			if err := json.Unmarshal(data, &client); err != nil {
+				return err
+			}
+			return m.Client.Update(ctx, id, &client)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.Client.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "apis",
+		keyField: "identifier",
+		idField:  "id",
+		ignore:   []string{"id"},
+		readOnly: []string{"identifier"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.ResourceServer.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			var items []interface{}
+			for _, resourceServer := range list.ResourceServers {
+				if strings.HasSuffix(resourceServer.GetIdentifier(), "/api/v2/") {
+					continue // the tenant's Management API
+				}
+				items = append(items, resourceServer)
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var resourceServer management.ResourceServer
+			if err := json.Unmarshal(data, &resourceServer); err != nil {
+				return err
+			}
+			return m.ResourceServer.Create(ctx, &resourceServer)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var resourceServer management.ResourceServer
+			if err := json.Unmarshal(data, &resourceServer); err != nil {
+				return err
+			}
+			return m.ResourceServer.Update(ctx, id, &resourceServer)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.ResourceServer.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "roles",
+		keyField: "name",
+		idField:  "id",
+		ignore:   []string{"id"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.Role.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			items := make([]interface{}, len(list.Roles))
+			for i, role := range list.Roles {
+				items[i] = role
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var role management.Role
+			if err := json.Unmarshal(data, &role); err != nil {
+				return err
+			}
+			return m.Role.Create(ctx, &role)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var role management.Role
+			if err := json.Unmarshal(data, &role); err != nil {
+				return err
+			}
+			return m.Role.Update(ctx, id, &role)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.Role.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "connections",
+		keyField: "name",
+		idField:  "id",
+		ignore:   []string{"id", "enabled_clients"},
+		readOnly: []string{"name", "strategy"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.Connection.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			items := make([]interface{}, len(list.Connections))
+			for i, connection := range list.Connections {
+				items[i] = connection
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var connection management.Connection
+			if err := json.Unmarshal(data, &connection); err != nil {
+				return err
+			}
+			return m.Connection.Create(ctx, &connection)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var connection management.Connection
+			if err := json.Unmarshal(data, &connection); err != nil {
+				return err
+			}
+			return m.Connection.Update(ctx, id, &connection)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.Connection.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "actions",
+		keyField: "name",
+		idField:  "id",
+		// Secret values can't be read back, so secrets are set by hand.
+		ignore: []string{
+			"id", "secrets", "status", "deployed_version", "all_changes_deployed",
+			"built_at", "created_at", "updated_at", "installed_integration_id", "integration",
+		},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.Action.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			items := make([]interface{}, len(list.Actions))
+			for i, action := range list.Actions {
+				items[i] = action
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var action management.Action
+			if err := json.Unmarshal(data, &action); err != nil {
+				return err
+			}
+			return m.Action.Create(ctx, &action)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var action management.Action
+			if err := json.Unmarshal(data, &action); err != nil {
+				return err
+			}
+			return m.Action.Update(ctx, id, &action)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.Action.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "rules",
+		keyField: "name",
+		idField:  "id",
+		ignore:   []string{"id"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			list, err := m.Rule.List(ctx, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, false, err
+			}
+			items := make([]interface{}, len(list.Rules))
+			for i, rule := range list.Rules {
+				items[i] = rule
+			}
+			return items, list.HasNext(), nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var rule management.Rule
+			if err := json.Unmarshal(data, &rule); err != nil {
+				return err
+			}
+			return m.Rule.Create(ctx, &rule)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var rule management.Rule
+			if err := json.Unmarshal(data, &rule); err != nil {
+				return err
+			}
+			return m.Rule.Update(ctx, id, &rule)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return m.Rule.Delete(ctx, id)
+		},
+	},
+	{
+		name:     "email-templates",
+		keyField: "template",
+		idField:  "template",
+		readOnly: []string{"template"},
+		// Email templates can't be listed, so every known template is read.
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			var items []interface{}
+			for _, name := range emailTemplateNames {
+				template, err := m.EmailTemplate.Read(ctx, name)
+				var mErr management.Error
+				if errors.As(err, &mErr) && mErr.Status() == http.StatusNotFound {
+					continue // not customized on this tenant
+				}
+				if err != nil {
+					return nil, false, err
+				}
+				items = append(items, template)
+			}
+			return items, false, nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			var template management.EmailTemplate
+			if err := json.Unmarshal(data, &template); err != nil {
+				return err
+			}
+			return m.EmailTemplate.Create(ctx, &template)
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var template management.EmailTemplate
+			if err := json.Unmarshal(data, &template); err != nil {
+				return err
+			}
+			return m.EmailTemplate.Update(ctx, id, &template)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return fmt.Errorf("email templates can't be deleted, disable the template instead")
+		},
+		lint: lintEmailTemplate,
+	},
+	{
+		name:   "tenant-settings",
+		ignore: []string{"sandbox_versions_available"},
+		list: func(ctx context.Context, m *management.Management, page int) ([]interface{}, bool, error) {
+			tenant, err := m.Tenant.Read(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			return []interface{}{tenant}, false, nil
+		},
+		create: func(ctx context.Context, m *management.Management, data []byte) error {
+			return fmt.Errorf("tenant settings can't be created")
+		},
+		update: func(ctx context.Context, m *management.Management, id string, data []byte) error {
+			var tenant management.Tenant
+			if err := json.Unmarshal(data, &tenant); err != nil {
+				return err
+			}
+			return m.Tenant.Update(ctx, &tenant)
+		},
+		delete: func(ctx context.Context, m *management.Management, id string) error {
+			return fmt.Errorf("tenant settings can't be deleted")
+		},
+	},
+}
+
+// emailTemplateNames are the email templates a tenant can customize.
+var emailTemplateNames = []string{
+	"verify_email", "verify_email_by_code", "reset_email", "reset_email_by_code", "welcome_email",
+	"blocked_account", "stolen_credentials", "enrollment_email", "mfa_oob_code", "user_invitation",
+	"change_password", "password_reset",
+}
+
+func findResourceKind(name string) (resourceKind, error) {
+	for _, kind := range resourceKinds {
+		if kind.name == name {
+			return kind, nil
+		}
+	}
+
+	names := make([]string, len(resourceKinds))
+	for i, kind := range resourceKinds {
+		names[i] = kind.name
+	}
+	return resourceKind{}, fmt.Errorf("unknown resource type %q, expected one of %v", name, names)
+}
+
+// resourceKey returns the key a resource is matched by between tenants.
+func resourceKey(kind resourceKind, resource map[string]interface{}) string {
+	if kind.keyField == "" {
+		return "settings"
+	}
+	key, _ := resource[kind.keyField].(string)
+	return key
+}
+
+// normalizeResource converts an SDK object into a plain JSON map without the
+// ignored fields, and returns the resource ID separately.
+func normalizeResource(kind resourceKind, item interface{}) (map[string]interface{}, string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal %s: %w", kind.name, err)
+	}
+
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal %s: %w", kind.name, err)
+	}
+
+	id, _ := resource[kind.idField].(string)
+	for _, field := range kind.ignore {
+		delete(resource, field)
+	}
+	return resource, id, nil
+}
+
+// resourceSet holds the normalized resources of one kind in a tenant keyed by
+// their key field, and their IDs in that tenant.
+type resourceSet struct {
+	resources map[string]map[string]interface{}
+	ids       map[string]string
+}
+
+// fetchResources lists every resource of a kind.
+func fetchResources(ctx context.Context, m *management.Management, kind resourceKind) (*resourceSet, error) {
+	set := &resourceSet{resources: map[string]map[string]interface{}{}, ids: map[string]string{}}
+
+	for page := 0; ; page++ {
+		items, hasNext, err := kind.list(ctx, m, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", kind.name, apiError(err))
+		}
+
+		for _, item := range items {
+			resource, id, err := normalizeResource(kind, item)
+			if err != nil {
+				return nil, err
+			}
+
+			key := resourceKey(kind, resource)
+			set.resources[key] = resource
+			set.ids[key] = id
+		}
+
+		if !hasNext {
+			break
+		}
+	}
+
+	return set, nil
+}
+
+// lintResource prints the lint warnings of a resource about to be created or
+// updated on the target.
+func lintResource(kind resourceKind, d resourceDiff) {
+	if kind.lint == nil || d.to == nil {
+		return
+	}
+	for _, warning := range kind.lint(d.to) {
+		fmt.Fprintf(messageOutput, "WARNING: %s: %s\n", d.resource(), warning)
+		annotate("warning", "Lint warning for "+d.resource(), "", errors.New(warning))
+	}
+}
+
+// applyResourceDiff makes the target resource match the source resource.
+func applyResourceDiff(ctx context.Context, m *management.Management, kind resourceKind, d resourceDiff, targetID string) error {
+	lintResource(kind, d)
+
+	switch d.action {
+	case diffActionCreate:
+		data, err := json.Marshal(d.to)
+		if err != nil {
+			return err
+		}
+		return apiError(kind.create(ctx, m, data))
+
+	case diffActionUpdate:
+		resource := map[string]interface{}{}
+		for key, value := range d.to {
+			resource[key] = value
+		}
+		for _, field := range kind.readOnly {
+			delete(resource, field)
+		}
+
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return err
+		}
+		return apiError(kind.update(ctx, m, targetID, data))
+
+	case diffActionDelete:
+		return apiError(kind.delete(ctx, m, targetID))
+	}
+
+	return fmt.Errorf("unknown action %q", d.action)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestApplyResourceDiff(t *testing.T) {
+	var method, path string
+	var body map[string]interface{}
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{}`))
+	}))
+
+	kind, err := findResourceKind("connections")
+	if err != nil {
+		t.Fatalf("Failed to find resource kind: %v", err)
+	}
+
+	d := resourceDiff{
+		kind:   "connections",
+		key:    "Username-Password-Authentication",
+		action: diffActionUpdate,
+		to:     map[string]interface{}{"name": "Username-Password-Authentication", "strategy": "auth0", "display_name": "Users"},
+	}
+
+	if err := applyResourceDiff(context.Background(), m, kind, d, "con_123"); err != nil {
+		t.Fatalf("Failed to apply diff: %v", err)
+	}
+
+	if method != http.MethodPatch || path != "/api/v2/connections/con_123" {
+		t.Errorf("Expected PATCH /api/v2/connections/con_123, got %s %s", method, path)
+	}
+	if _, ok := body["name"]; ok {
+		t.Errorf("Expected read-only fields to be dropped from the update, got %v", body)
+	}
+	if body["display_name"] != "Users" {
+		t.Errorf("Expected display_name to be sent, got %v", body)
+	}
+}
+
+func TestFetchEmailTemplatesAndTenantSettings(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/email-templates/welcome_email":
+			w.Write([]byte(`{"template":"welcome_email","subject":"Welcome","enabled":true}`))
+		case "/api/v2/tenants/settings":
+			w.Write([]byte(`{"friendly_name":"Acme","sandbox_versions_available":["18"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"statusCode":404,"message":"Not found"}`))
+		}
+	}))
+
+	kind, err := findResourceKind("email-templates")
+	if err != nil {
+		t.Fatalf("Failed to find resource kind: %v", err)
+	}
+	templates, err := fetchResources(context.Background(), m, kind)
+	if err != nil {
+		t.Fatalf("Failed to fetch email templates: %v", err)
+	}
+	if len(templates.resources) != 1 || templates.resources["welcome_email"]["subject"] != "Welcome" {
+		t.Errorf("Expected only the customized welcome_email template, got %v", templates.resources)
+	}
+
+	kind, err = findResourceKind("tenant-settings")
+	if err != nil {
+		t.Fatalf("Failed to find resource kind: %v", err)
+	}
+	settings, err := fetchResources(context.Background(), m, kind)
+	if err != nil {
+		t.Fatalf("Failed to fetch tenant settings: %v", err)
+	}
+	if len(settings.resources) != 1 || settings.resources["settings"]["friendly_name"] != "Acme" {
+		t.Errorf("Expected the tenant settings keyed as settings, got %v", settings.resources)
+	}
+	if _, ok := settings.resources["settings"]["sandbox_versions_available"]; ok {
+		t.Errorf("Expected read-only tenant fields to be ignored")
+	}
+}
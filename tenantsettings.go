@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// migratedTenantSettings returns the tenant settings that migrate
+// tenant-settings copies: session lifetimes and cookies, flags, the default
+// audience and directory, logout and redirection URLs, the error, change
+// password and MFA pages, the enabled languages, the device flow and the
+// support contacts. URLs have their hosts rewritten, the default audience
+// is mapped like migrate apis maps identifiers and the default directory
+// is a connection name from remap.
+func migratedTenantSettings(tenant *management.Tenant, remap nameRemap, rewrites []hostRewrite) *management.Tenant {
+	migrated := &management.Tenant{
+		SessionLifetime:     tenant.SessionLifetime,
+		IdleSessionLifetime: tenant.IdleSessionLifetime,
+		SessionCookie:       tenant.SessionCookie,
+		Sessions:            tenant.Sessions,
+		Flags:               tenant.Flags,
+		AllowedLogoutURLs:   rewriteURLs(tenant.AllowedLogoutURLs, rewrites),
+		ChangePassword:      tenant.ChangePassword,
+		GuardianMFAPage:     tenant.GuardianMFAPage,
+		EnabledLocales:      tenant.EnabledLocales,
+		DeviceFlow:          tenant.DeviceFlow,
+		FriendlyName:        tenant.FriendlyName,
+		PictureURL:          tenant.PictureURL,
+		SupportEmail:        tenant.SupportEmail,
+		SupportURL:          tenant.SupportURL,
+	}
+	if tenant.DefaultAudience != nil {
+		audience := apiIdentifier(tenant.GetDefaultAudience(), remap, rewrites)
+		migrated.DefaultAudience = &audience
+	}
+	if tenant.DefaultDirectory != nil {
+		directory := remap.name("connections", tenant.GetDefaultDirectory())
+		migrated.DefaultDirectory = &directory
+	}
+	if tenant.DefaultRedirectionURI != nil {
+		uri := rewriteURL(tenant.GetDefaultRedirectionURI(), rewrites)
+		migrated.DefaultRedirectionURI = &uri
+	}
+	if tenant.ErrorPage != nil {
+		errorPage := *tenant.ErrorPage
+		if errorPage.URL != nil {
+			url := rewriteURL(errorPage.GetURL(), rewrites)
+			errorPage.URL = &url
+		}
+		migrated.ErrorPage = &errorPage
+	}
+	return migrated
+}
+
+// tenantSettingsDiff returns the changes migrate tenant-settings makes to
+// the target as a unified diff of the settings as JSON, or "" if the target
+// is up to date. Only the settings it copies are compared.
+func tenantSettingsDiff(current, migrated *management.Tenant) (string, error) {
+	from, err := json.MarshalIndent(migratedTenantSettings(current, nil, nil), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode target settings: %w", err)
+	}
+	to, err := json.MarshalIndent(migrated, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode source settings: %w", err)
+	}
+	return unifiedDiff("target/tenant-settings", "source/tenant-settings", string(from), string(to), 3), nil
+}
+
+// diffLine is a line of a line diff: ' ' for a line both texts have, '-'
+// for a removed and '+' for an added line. from and to are the indexes of
+// the line in each text, or where it would be.
+type diffLine struct {
+	kind     byte
+	text     string
+	from, to int
+}
+
+// unifiedDiff returns the differences between two texts in the unified
+// diff format, with context lines around each change, or "" if they are
+// equal. Lines are matched by their longest common subsequence, which is
+// quadratic but fine for config-sized texts.
+func unifiedDiff(fromName, toName, from, to string, contextLines int) string {
+	if from == to {
+		return ""
+	}
+	a, b := strings.Split(from, "\n"), strings.Split(to, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	var changes []int
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			changes = append(changes, len(lines))
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			changes = append(changes, len(lines))
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", fromName, toName)
+	for k := 0; k < len(changes); {
+		start, end := max(changes[k]-contextLines, 0), min(changes[k]+contextLines+1, len(lines))
+		for k++; k < len(changes) && changes[k]-contextLines <= end; k++ {
+			end = min(changes[k]+contextLines+1, len(lines))
+		}
+
+		hunk := lines[start:end]
+		fromCount, toCount := 0, 0
+		for _, line := range hunk {
+			if line.kind != '+' {
+				fromCount++
+			}
+			if line.kind != '-' {
+				toCount++
+			}
+		}
+		// An empty range starts at the line before it, as in diff -u.
+		fromStart, toStart := hunk[0].from, hunk[0].to
+		if fromCount > 0 {
+			fromStart++
+		}
+		if toCount > 0 {
+			toStart++
+		}
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, line := range hunk {
+			fmt.Fprintf(&diff, "%c%s\n", line.kind, line.text)
+		}
+	}
+	return diff.String()
+}
+
+func newMigrateTenantSettingsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		rewriteHosts []string
+		remapFile    string
+		apply        bool
+	)
+
+	var settingsCmd = &cobra.Command{
+		Use:   "tenant-settings",
+		Short: "Show a diff of the source tenant's settings against the target's, and apply them with --apply",
+		Run: func(cmd *cobra.Command, args []string) {
+			if apply {
+				checkWritable("migrate tenant-settings")
+				clients.guardTarget("migrate tenant-settings")
+			}
+			rewrites, err := parseHostRewrites(rewriteHosts)
+			if err != nil {
+				log.Fatalf("Invalid --rewrite-host: %v", err)
+			}
+			var remap nameRemap
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+
+			source, err := clients.sourceClient().Tenant.Read(ctx)
+			if err != nil {
+				log.Fatalf("Failed to read source tenant settings: %v", apiError(err))
+			}
+			targetClient := clients.targetClient()
+			current, err := targetClient.Tenant.Read(ctx)
+			if err != nil {
+				log.Fatalf("Failed to read target tenant settings: %v", apiError(err))
+			}
+
+			migrated := migratedTenantSettings(source, remap, rewrites)
+			diff, err := tenantSettingsDiff(current, migrated)
+			if err != nil {
+				log.Fatalf("Failed to compare tenant settings: %v", err)
+			}
+			if diff == "" {
+				fmt.Fprintln(messageOutput, "Tenant settings are up to date.")
+				reportResult("migrate tenant-settings", map[string]interface{}{"changed": false})
+				return
+			}
+			fmt.Fprint(messageOutput, diff)
+
+			if !apply {
+				fmt.Fprintln(messageOutput, "Run with --apply to apply these changes to the target tenant.")
+				reportResult("migrate tenant-settings", map[string]interface{}{"changed": true, "applied": false, "diff": diff})
+				return
+			}
+			if err := targetClient.Tenant.Update(ctx, migrated); err != nil {
+				log.Fatalf("Failed to update target tenant settings: %v", apiError(err))
+			}
+			fmt.Fprintln(messageOutput, "Tenant settings applied to the target tenant.")
+			reportResult("migrate tenant-settings", map[string]interface{}{"changed": true, "applied": true, "diff": diff})
+		},
+	}
+
+	settingsCmd.Flags().StringSliceVar(&rewriteHosts, "rewrite-host", nil, "Replace a host in logout, redirection and error page URLs and the default audience, as old.example.com=new.example.com; repeatable")
+	settingsCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping the default audience (under apis) and default directory (under connections) to their target names")
+	settingsCmd.Flags().BoolVar(&apply, "apply", false, "Apply the changes to the target tenant; without it only the diff is shown")
+
+	return settingsCmd
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := unifiedDiff("a", "b", "x\ny", "x\ny", 3); diff != "" {
+		t.Errorf("Expected no diff for equal texts, got:\n%s", diff)
+	}
+
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	to := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	expected := `--- target
++++ source
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -12,1 +12,2 @@
+ 12
++13
+`
+	if diff := unifiedDiff("target", "source", from, to, 1); diff != expected {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	// Changes within twice the context of each other share a hunk.
+	merged := unifiedDiff("target", "source", "a\nb\nc\nd", "A\nb\nc\nD", 1)
+	if strings.Count(merged, "@@ ") != 1 || !strings.Contains(merged, "@@ -1,4 +1,4 @@") {
+		t.Errorf("Expected a single hunk, got:\n%s", merged)
+	}
+
+	added := unifiedDiff("target", "source", "", "a", 3)
+	if !strings.Contains(added, "@@ -1,1 +1,1 @@\n-\n+a\n") {
+		t.Errorf("Unexpected diff of an added line:\n%s", added)
+	}
+}
+
+func TestTenantSettingsDiff(t *testing.T) {
+	source := &management.Tenant{
+		FriendlyName:      auth0.String("Example"),
+		SessionLifetime:   auth0.Float64(72),
+		DefaultAudience:   auth0.String("https://api.old.example.com/"),
+		DefaultDirectory:  auth0.String("Legacy-Users"),
+		AllowedLogoutURLs: &[]string{"https://app.old.example.com/logout"},
+		ErrorPage:         &management.TenantErrorPage{URL: auth0.String("https://app.old.example.com/error"), ShowLogLink: auth0.Bool(false)},
+		SandboxVersion:    auth0.String("18"),
+	}
+	rewrites, _ := parseHostRewrites([]string{"app.old.example.com=app.example.com"})
+	remap := nameRemap{
+		"apis":        {"https://api.old.example.com/": "https://api.example.com/"},
+		"connections": {"Legacy-Users": "Username-Password-Authentication"},
+	}
+
+	migrated := migratedTenantSettings(source, remap, rewrites)
+	if migrated.GetDefaultAudience() != "https://api.example.com/" || migrated.GetDefaultDirectory() != "Username-Password-Authentication" {
+		t.Errorf("Expected the default audience and directory to be mapped, got %s and %s", migrated.GetDefaultAudience(), migrated.GetDefaultDirectory())
+	}
+	if (*migrated.AllowedLogoutURLs)[0] != "https://app.example.com/logout" || migrated.ErrorPage.GetURL() != "https://app.example.com/error" {
+		t.Errorf("Expected the URLs to be rewritten, got %v and %s", *migrated.AllowedLogoutURLs, migrated.ErrorPage.GetURL())
+	}
+	if migrated.SandboxVersion != nil || source.ErrorPage.GetURL() != "https://app.old.example.com/error" {
+		t.Errorf("Expected the sandbox version to be left out and the source to be unchanged")
+	}
+
+	current := &management.Tenant{FriendlyName: auth0.String("Example"), SessionLifetime: auth0.Float64(168), SandboxVersion: auth0.String("22")}
+	diff, err := tenantSettingsDiff(current, migrated)
+	if err != nil {
+		t.Fatalf("Failed to diff tenant settings: %v", err)
+	}
+	for _, line := range []string{`-  "session_lifetime": 168`, `+  "session_lifetime": 72`, `+  "default_audience": "https://api.example.com/",`} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("Expected %q in the diff:\n%s", line, diff)
+		}
+	}
+	if strings.Contains(diff, "sandbox_version") {
+		t.Errorf("Expected only the migrated settings to be compared, got:\n%s", diff)
+	}
+
+	if diff, _ := tenantSettingsDiff(migrated, migrated); diff != "" {
+		t.Errorf("Expected no diff for up to date settings, got:\n%s", diff)
+	}
+}
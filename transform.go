@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// transformSpec is the mapping file given to --transform, in YAML or JSON:
+//
+//	rename:
+//	  user_metadata.lang: user_metadata.locale
+//	drop: [last_ip, user_metadata.internal_notes]
+//	defaults:
+//	  app_metadata.plan: free
+//	set:
+//	  name: '{{ get . "given_name" }} {{ get . "family_name" }}'
+//	connections:
+//	  legacy-db: Username-Password-Authentication
+//
+// The steps run in the order above. Fields are dot-separated paths. The
+// values of set are Go templates evaluated against the user, for rewrites
+// that depend on other fields.
+type transformSpec struct {
+	Rename      map[string]string      `yaml:"rename"`
+	Drop        []string               `yaml:"drop"`
+	Defaults    map[string]interface{} `yaml:"defaults"`
+	Set         map[string]string      `yaml:"set"`
+	Connections map[string]string      `yaml:"connections"`
+
+	templates map[string]*template.Template
+}
+
+// transformFuncs are the functions available in set templates besides the
+// built-in ones. get returns an empty string for missing fields, so that its
+// result can be passed on to the string functions.
+var transformFuncs = template.FuncMap{
+	"get": func(user map[string]interface{}, path string) interface{} {
+		value, ok := getPath(user, strings.Split(path, "."))
+		if !ok || value == nil {
+			return ""
+		}
+		return value
+	},
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+}
+
+func loadTransformSpec(filename string) (*transformSpec, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform file: %w", err)
+	}
+
+	var spec transformSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse transform file: %w", err)
+	}
+
+	for from, to := range spec.Rename {
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("rename %q to %q: both fields are required", from, to)
+		}
+	}
+	spec.templates = map[string]*template.Template{}
+	for field, text := range spec.Set {
+		tmpl, err := template.New(field).Funcs(transformFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for %s: %w", field, err)
+		}
+		spec.templates[field] = tmpl
+	}
+	return &spec, nil
+}
+
+// apply transforms a user in place.
+func (s *transformSpec) apply(user map[string]interface{}) error {
+	for _, from := range sortedKeys(s.Rename) {
+		path := strings.Split(from, ".")
+		if value, ok := getPath(user, path); ok {
+			deletePath(user, path)
+			setPath(user, strings.Split(s.Rename[from], "."), value)
+		}
+	}
+
+	for _, field := range s.Drop {
+		deletePath(user, strings.Split(field, "."))
+	}
+
+	for _, field := range sortedKeys(s.Defaults) {
+		path := strings.Split(field, ".")
+		if value, ok := getPath(user, path); !ok || value == nil {
+			setPath(user, path, s.Defaults[field])
+		}
+	}
+
+	// Templates see the user as it was before any of them ran.
+	values := map[string]string{}
+	for _, field := range sortedKeys(s.templates) {
+		var out strings.Builder
+		if err := s.templates[field].Execute(&out, user); err != nil {
+			return fmt.Errorf("failed to set %s: %w", field, err)
+		}
+		values[field] = strings.ReplaceAll(out.String(), "<no value>", "")
+	}
+	for field, value := range values {
+		setPath(user, strings.Split(field, "."), value)
+	}
+
+	if len(s.Connections) > 0 {
+		if connection, ok := user["connection"].(string); ok && s.Connections[connection] != "" {
+			user["connection"] = s.Connections[connection]
+		}
+		identities, _ := user["identities"].([]interface{})
+		for _, identity := range identities {
+			identity, _ := identity.(map[string]interface{})
+			if connection, ok := identity["connection"].(string); ok && s.Connections[connection] != "" {
+				identity["connection"] = s.Connections[connection]
+			}
+		}
+	}
+	return nil
+}
+
+func deletePath(data map[string]interface{}, path []string) {
+	parent := data
+	if len(path) > 1 {
+		value, _ := getPath(data, path[:len(path)-1])
+		if parent, _ = value.(map[string]interface{}); parent == nil {
+			return
+		}
+	}
+	delete(parent, path[len(path)-1])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTransformSpec(t *testing.T, spec string) *transformSpec {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "transform.yaml")
+	os.WriteFile(filename, []byte(spec), 0644)
+	s, err := loadTransformSpec(filename)
+	if err != nil {
+		t.Fatalf("Failed to load the transform: %v", err)
+	}
+	return s
+}
+
+func TestTransformSpec(t *testing.T) {
+	spec := writeTransformSpec(t, `
+rename:
+  user_metadata.lang: user_metadata.profile.locale
+drop: [last_ip, user_metadata.notes]
+defaults:
+  app_metadata.plan: free
+  user_metadata.theme: dark
+set:
+  name: '{{ get . "given_name" }} {{ upper (get . "family_name") }}'
+  app_metadata.tier: '{{ if eq (get . "app_metadata.plan") "pro" }}gold{{ else }}standard{{ end }}'
+connections:
+  legacy-db: Username-Password-Authentication
+`)
+
+	user := map[string]interface{}{
+		"email":         "a@example.com",
+		"given_name":    "Ada",
+		"family_name":   "Lovelace",
+		"last_ip":       "10.0.0.1",
+		"connection":    "legacy-db",
+		"identities":    []interface{}{map[string]interface{}{"connection": "legacy-db"}, map[string]interface{}{"connection": "google-oauth2"}},
+		"user_metadata": map[string]interface{}{"lang": "en", "notes": "vip", "theme": "light"},
+		"app_metadata":  map[string]interface{}{"plan": "pro"},
+	}
+	if err := spec.apply(user); err != nil {
+		t.Fatalf("Failed to apply the transform: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"email":         "a@example.com",
+		"given_name":    "Ada",
+		"family_name":   "Lovelace",
+		"name":          "Ada LOVELACE",
+		"connection":    "Username-Password-Authentication",
+		"identities":    []interface{}{map[string]interface{}{"connection": "Username-Password-Authentication"}, map[string]interface{}{"connection": "google-oauth2"}},
+		"user_metadata": map[string]interface{}{"profile": map[string]interface{}{"locale": "en"}, "theme": "light"},
+		"app_metadata":  map[string]interface{}{"plan": "pro", "tier": "gold"},
+	}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("Expected %v, got %v", expected, user)
+	}
+
+	// Missing fields get the defaults and render as empty strings.
+	user = map[string]interface{}{"email": "b@example.com"}
+	if err := spec.apply(user); err != nil {
+		t.Fatalf("Failed to apply the transform: %v", err)
+	}
+	expected = map[string]interface{}{
+		"email":         "b@example.com",
+		"name":          " ",
+		"user_metadata": map[string]interface{}{"theme": "dark"},
+		"app_metadata":  map[string]interface{}{"plan": "free", "tier": "standard"},
+	}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("Expected %v, got %v", expected, user)
+	}
+}
+
+func TestInvalidTransformSpec(t *testing.T) {
+	for name, spec := range map[string]string{
+		"template": "set:\n  name: '{{ get . '\n",
+		"rename":   "rename:\n  nickname: ''\n",
+		"yaml":     "drop: {\n",
+	} {
+		filename := filepath.Join(t.TempDir(), "transform.yaml")
+		os.WriteFile(filename, []byte(spec), 0644)
+		if _, err := loadTransformSpec(filename); err == nil {
+			t.Errorf("%s: expected an invalid transform", name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+// searchResultLimit is the number of results Auth0's user search returns
+// at most. Requesting a page beyond it fails with a 400.
+const searchResultLimit = 1000
+
+// deleteSettleAttempts and deleteSettleDelay bound how long wipeUsers waits
+// for the user search, which lags behind deletions, to return the users that
+// are left.
+var (
+	deleteSettleAttempts = 3
+	deleteSettleDelay    = 5 * time.Second
+)
+
+// connectionQuery returns the Lucene query selecting the users of a
+// connection, narrowed down by query if set.
+func connectionQuery(connection, query string) string {
+	q := fmt.Sprintf(`identities.connection:"%s"`, connection)
+	if query != "" {
+		q += " AND (" + query + ")"
+	}
+	return q
+}
+
+// countUsers returns the number of users matching a Lucene query.
+func countUsers(ctx context.Context, m *management.Management, query string) (int, error) {
+	list, err := m.User.List(ctx, management.Query(query), management.PerPage(1), management.IncludeFields("user_id"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", apiError(err))
+	}
+	return list.Total, nil
+}
+
+// listUserIDs pages through the users matching a Lucene query and returns
+// their IDs. As Auth0 only returns the first searchResultLimit results of a
+// search, it stops there.
+func listUserIDs(ctx context.Context, m *management.Management, query string) ([]string, error) {
+	const perPage = 100
+	var ids []string
+
+	for page := 0; page*perPage < searchResultLimit; page++ {
+		list, err := m.User.List(ctx,
+			management.Query(query),
+			management.Page(page),
+			management.PerPage(perPage),
+			management.IncludeFields("user_id"),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", apiError(err))
+		}
+		for _, user := range list.Users {
+			ids = append(ids, user.GetID())
+		}
+		if !list.HasNext() || len(list.Users) == 0 {
+			break
+		}
+	}
+
+	return ids, nil
+}
+
+// exportUserIDs returns the IDs of all users of a connection from a users
+// export job, which unlike the user search isn't limited to
+// searchResultLimit users.
+func exportUserIDs(ctx context.Context, m *management.Management, connectionID string, jobTimeout time.Duration) ([]string, error) {
+	dir, err := os.MkdirTemp("", "auth0-tools-delete-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "users.json.gz")
+	if err := exportTenantUsers(ctx, m, connectionID, []string{"user_id"}, jobTimeout, filename); err != nil {
+		return nil, err
+	}
+
+	input, err := openInputFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var ids []string
+	err = readUsers(input, func(record userRecord) error {
+		id, _ := record.user["user_id"].(string)
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the exported users: %w", err)
+	}
+	return ids, nil
+}
+
+// deleteUsers deletes users on workers goroutines, starting one request per
+// interval across all of them. Users that no longer exist count as deleted.
+// bar, if set, is advanced for every user.
+func deleteUsers(ctx context.Context, m *management.Management, ids []string, workers int, interval time.Duration, bar *progress) (int, int) {
+	var (
+		mu              sync.Mutex
+		deleted, failed int
+		wg              sync.WaitGroup
+	)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	queue := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				var err error
+				if id == "" {
+					err = errors.New("missing user_id")
+				} else {
+					if tick != nil {
+						<-tick
+					}
+					err = m.User.Delete(ctx, id)
+					var mErr management.Error
+					if errors.As(err, &mErr) && mErr.Status() == http.StatusNotFound {
+						err = nil
+					}
+				}
+
+				mu.Lock()
+				if err != nil {
+					progressPrintf("Failed to delete user %s: %v\n", id, apiError(err))
+					failed++
+				} else {
+					deleted++
+				}
+				if bar != nil {
+					bar.update(bar.current+1, fmt.Sprintf("%d deleted, %d failed", deleted, failed))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+
+	return deleted, failed
+}
+
+// wipeUsers deletes all users matching a Lucene query. As a search returns
+// at most searchResultLimit users, it searches again after each batch of deletions until
+// no users are left that it hasn't tried to delete.
+func wipeUsers(ctx context.Context, m *management.Management, query string, workers int, interval time.Duration, bar *progress) (int, int, error) {
+	attempted := map[string]bool{}
+	deleted, failed := 0, 0
+
+	for settle := 0; ; {
+		ids, err := listUserIDs(ctx, m, query)
+		if err != nil {
+			return deleted, failed, err
+		}
+
+		var pending []string
+		for _, id := range ids {
+			if !attempted[id] {
+				attempted[id] = true
+				pending = append(pending, id)
+			}
+		}
+
+		if len(pending) == 0 {
+			if len(ids) == 0 || settle == deleteSettleAttempts {
+				return deleted, failed, nil
+			}
+			// The search may still return users that were just deleted.
+			settle++
+			time.Sleep(deleteSettleDelay)
+			continue
+		}
+		settle = 0
+
+		d, f := deleteUsers(ctx, m, pending, workers, interval, bar)
+		deleted += d
+		failed += f
+	}
+}
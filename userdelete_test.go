@@ -0,0 +1,135 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectionQuery(t *testing.T) {
+	if q := connectionQuery("staging-db", ""); q != `identities.connection:"staging-db"` {
+		t.Errorf("Unexpected query %s", q)
+	}
+	if q := connectionQuery("staging-db", "email:*@test.example.com OR logins_count:0"); q != `identities.connection:"staging-db" AND (email:*@test.example.com OR logins_count:0)` {
+		t.Errorf("Unexpected query %s", q)
+	}
+}
+
+func TestWipeUsers(t *testing.T) {
+	deleteSettleDelay = 0
+	defer func() { deleteSettleDelay = 5 * time.Second }()
+
+	var mu sync.Mutex
+	users := map[string]bool{}
+	for i := 0; i < 1200; i++ {
+		users["auth0|"+strconv.Itoa(i)] = true
+	}
+	// The search lags behind: auth0|stale stays listed after its deletion,
+	// and auth0|locked can't be deleted.
+	users["auth0|stale"] = true
+	users["auth0|locked"] = true
+
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			if q := r.URL.Query().Get("q"); q != `identities.connection:"staging-db"` {
+				t.Errorf("Unexpected query %s", q)
+			}
+			// Like Auth0, the search rejects pages past the first 1000
+			// results.
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+			if page*perPage >= 1000 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"statusCode":400,"message":"You can only page through the first 1000 records"}`))
+				return
+			}
+			var ids []string
+			for id := range users {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if len(ids) > 1000 {
+				ids = ids[:1000]
+			}
+
+			start, end := min(page*perPage, len(ids)), min((page+1)*perPage, len(ids))
+			var list []map[string]interface{}
+			for _, id := range ids[start:end] {
+				list = append(list, map[string]interface{}{"user_id": id})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"users": list, "start": start, "limit": perPage, "length": len(list), "total": len(ids)})
+		case http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, "/api/v2/users/")
+			if id == "auth0|locked" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"statusCode":400,"message":"Bad request"}`))
+				return
+			}
+			if id != "auth0|stale" {
+				delete(users, id)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	query := connectionQuery("staging-db", "")
+	matched, err := countUsers(context.Background(), m, query)
+	if err != nil || matched != 1000 {
+		t.Fatalf("Expected the search to be capped at 1000 users, got %d %v", matched, err)
+	}
+
+	deleted, failed, err := wipeUsers(context.Background(), m, query, 4, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to delete users: %v", err)
+	}
+	if deleted != 1201 || failed != 1 {
+		t.Errorf("Expected 1201 deleted and 1 failed users, got %d and %d", deleted, failed)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected only the stale and locked users to be left, got %d", len(users))
+	}
+}
+
+func TestExportUserIDs(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v2/jobs/users-exports":
+			var job map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&job)
+			if job["connection_id"] != "con_1" {
+				t.Errorf("Expected an export of con_1, got %v", job["connection_id"])
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_export", "status": "pending"})
+		case r.URL.Path == "/api/v2/jobs/job_export":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "job_export", "status": "completed", "location": "http://" + r.Host + "/export.json.gz"})
+		case r.URL.Path == "/export.json.gz":
+			gzWriter := gzip.NewWriter(w)
+			for i := 0; i < 2500; i++ {
+				fmt.Fprintf(gzWriter, "{\"user_id\": \"auth0|%d\"}\n", i)
+			}
+			gzWriter.Close()
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+
+	ids, err := exportUserIDs(context.Background(), m, "con_1", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to export user IDs: %v", err)
+	}
+	if len(ids) != 2500 || ids[0] != "auth0|0" || ids[2499] != "auth0|2499" {
+		t.Errorf("Expected all 2500 users of the connection, got %d", len(ids))
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// defaultDiffIgnore are the user fields that change on every login or
+// import, so they are not compared by default.
+var defaultDiffIgnore = []string{"created_at", "updated_at", "last_login", "last_ip", "logins_count", "identities"}
+
+// bloomFilter is a fixed-size set that may report false positives but never
+// false negatives. It is safe for concurrent use.
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+	seeds  [2]maphash.Seed
+}
+
+// newBloomFilter sizes a filter for n elements with the false positive rate p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	size := uint64(math.Ceil(-float64(max(n, 1)) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	size = max((size+63)/64*64, 64)
+	hashes := max(int(math.Round(float64(size)/float64(max(n, 1))*math.Ln2)), 1)
+	return &bloomFilter{
+		bits:   make([]uint64, size/64),
+		size:   size,
+		hashes: hashes,
+		seeds:  [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+}
+
+func (b *bloomFilter) positions(data []byte, fn func(uint64) bool) {
+	h1, h2 := maphash.Bytes(b.seeds[0], data), maphash.Bytes(b.seeds[1], data)|1
+	for i := 0; i < b.hashes; i++ {
+		if !fn((h1 + uint64(i)*h2) % b.size) {
+			return
+		}
+	}
+}
+
+func (b *bloomFilter) add(data []byte) {
+	b.positions(data, func(bit uint64) bool {
+		atomic.OrUint64(&b.bits[bit/64], 1<<(bit%64))
+		return true
+	})
+}
+
+func (b *bloomFilter) has(data []byte) bool {
+	found := true
+	b.positions(data, func(bit uint64) bool {
+		found = atomic.LoadUint64(&b.bits[bit/64])&(1<<(bit%64)) != 0
+		return found
+	})
+	return found
+}
+
+// expectedUsers estimates the users in an export file from its size, erring
+// on the high side, to size the bloom filters before reading it.
+func expectedUsers(filename string) int {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+
+	bytesPerUser := int64(300)
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(f, header); err == nil && header[0] == 0x1f && header[1] == 0x8b {
+		bytesPerUser = 50
+	}
+	return int(info.Size()/bytesPerUser) + 1000
+}
+
+// eachUser reads the users of a file and calls fn for each of them on
+// workers goroutines.
+func eachUser(filename string, workers int, fn func(user map[string]interface{})) error {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	users := make(chan map[string]interface{}, workers*4)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range users {
+				fn(user)
+			}
+		}()
+	}
+
+	err = readUsers(input, func(record userRecord) error {
+		users <- record.user
+		return nil
+	})
+	close(users)
+	wg.Wait()
+	return err
+}
+
+// userDiffOptions are the flags of diff users.
+type userDiffOptions struct {
+	matcher           *userMatcher
+	ignore            []string
+	workers           int
+	falsePositiveRate float64
+}
+
+// userDiffStats counts what diffUserFiles found.
+type userDiffStats struct {
+	Source    int64 `json:"source"`
+	Target    int64 `json:"target"`
+	Identical int64 `json:"identical"`
+	Different int   `json:"different"`
+	Missing   int   `json:"missing"`
+	Extra     int   `json:"extra"`
+	Skipped   int64 `json:"skipped"`
+}
+
+// comparable returns the key of a user and the user without the ignored
+// fields, with its fingerprint: the key and the canonical JSON of the fields.
+func (o userDiffOptions) comparable(user map[string]interface{}) (string, map[string]interface{}, []byte) {
+	key, _ := o.matcher.key(user)
+	compared := make(map[string]interface{}, len(user))
+	for field, value := range user {
+		compared[field] = value
+	}
+	for _, field := range o.ignore {
+		delete(compared, field)
+	}
+
+	data, _ := json.Marshal(compared) // map keys are marshalled in sorted order
+	return key, compared, append([]byte(key+"\x00"), data...)
+}
+
+// diffUserFiles compares two user exports, source against target, matching
+// users by the key of o.matcher. Both files are streamed, so memory grows with the
+// number of differences rather than the number of users:
+//
+//  1. the fingerprints of the target users go into a bloom filter;
+//  2. source users whose fingerprint is in the filter are identical (up to
+//     the false positive rate) and dropped, the rest are kept as candidates;
+//  3. the target is read again and the candidates are compared field by
+//     field with their target user.
+//
+// Fingerprinting and comparison run on o.workers goroutines.
+func diffUserFiles(sourceFile, targetFile string, o userDiffOptions) ([]resourceDiff, userDiffStats, error) {
+	var stats userDiffStats
+	targetFingerprints := newBloomFilter(expectedUsers(targetFile), o.falsePositiveRate)
+	sourceKeys := newBloomFilter(expectedUsers(sourceFile), o.falsePositiveRate)
+
+	err := eachUser(targetFile, o.workers, func(user map[string]interface{}) {
+		_, _, fingerprint := o.comparable(user)
+		targetFingerprints.add(fingerprint)
+		atomic.AddInt64(&stats.Target, 1)
+	})
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to read target users: %w", err)
+	}
+
+	var mu sync.Mutex
+	candidates := map[string]map[string]interface{}{}
+	err = eachUser(sourceFile, o.workers, func(user map[string]interface{}) {
+		atomic.AddInt64(&stats.Source, 1)
+		key, compared, fingerprint := o.comparable(user)
+		if key == "" {
+			atomic.AddInt64(&stats.Skipped, 1)
+			return
+		}
+		sourceKeys.add([]byte(key))
+		if targetFingerprints.has(fingerprint) {
+			atomic.AddInt64(&stats.Identical, 1)
+			return
+		}
+		mu.Lock()
+		candidates[key] = compared
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to read source users: %w", err)
+	}
+
+	var diffs []resourceDiff
+	matched := map[string]bool{}
+	err = eachUser(targetFile, o.workers, func(user map[string]interface{}) {
+		key, compared, _ := o.comparable(user)
+		if key == "" {
+			return
+		}
+
+		mu.Lock()
+		source, ok := candidates[key]
+		if ok {
+			matched[key] = true
+		}
+		mu.Unlock()
+
+		var d *resourceDiff
+		switch {
+		case ok && !reflect.DeepEqual(source, compared):
+			d = &resourceDiff{kind: "users", key: key, action: diffActionUpdate, from: compared, to: source}
+		case !ok && !sourceKeys.has([]byte(key)):
+			d = &resourceDiff{kind: "users", key: key, action: diffActionDelete, from: compared}
+		}
+		if d != nil {
+			mu.Lock()
+			diffs = append(diffs, *d)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		return nil, stats, fmt.Errorf("failed to read target users: %w", err)
+	}
+
+	for key, source := range candidates {
+		if !matched[key] {
+			diffs = append(diffs, resourceDiff{kind: "users", key: key, action: diffActionCreate, to: source})
+		}
+	}
+
+	// Same order as diffResources: creates and updates by key, then deletes.
+	sort.Slice(diffs, func(i, j int) bool {
+		if deleteI, deleteJ := diffs[i].action == diffActionDelete, diffs[j].action == diffActionDelete; deleteI != deleteJ {
+			return deleteJ
+		}
+		return diffs[i].key < diffs[j].key
+	})
+	for _, d := range diffs {
+		switch d.action {
+		case diffActionCreate:
+			stats.Missing++
+		case diffActionUpdate:
+			stats.Different++
+		case diffActionDelete:
+			stats.Extra++
+		}
+	}
+
+	return diffs, stats, nil
+}
+
+// fieldDifference is a field whose value differs between a source user and
+// its target user. Nested fields such as app_metadata.plan are compared on
+// their own.
+type fieldDifference struct {
+	Field  string      `json:"field"`
+	Source interface{} `json:"source"`
+	Target interface{} `json:"target"`
+}
+
+// fieldDifferences lists the fields that differ between a source and a
+// target user, sorted by field.
+func fieldDifferences(prefix string, source, target map[string]interface{}) []fieldDifference {
+	fields := map[string]bool{}
+	for field := range source {
+		fields[field] = true
+	}
+	for field := range target {
+		fields[field] = true
+	}
+
+	var differences []fieldDifference
+	for _, field := range sortedKeys(fields) {
+		sourceValue, targetValue := source[field], target[field]
+		sourceMap, sourceIsMap := sourceValue.(map[string]interface{})
+		targetMap, targetIsMap := targetValue.(map[string]interface{})
+		switch {
+		case sourceIsMap && targetIsMap:
+			differences = append(differences, fieldDifferences(prefix+field+".", sourceMap, targetMap)...)
+		case !reflect.DeepEqual(sourceValue, targetValue):
+			differences = append(differences, fieldDifference{Field: prefix + field, Source: sourceValue, Target: targetValue})
+		}
+	}
+	return differences
+}
+
+// userDifference lists the differing fields of a user, by the diff key.
+type userDifference struct {
+	Key    string            `json:"key"`
+	Fields []fieldDifference `json:"fields"`
+}
+
+// userDiffReport is the --format json output of diff users. ByField counts
+// the users whose top-level field differs, e.g. app_metadata for metadata
+// drift.
+type userDiffReport struct {
+	Summary         userDiffStats    `json:"summary"`
+	MissingInTarget []string         `json:"missing_in_target"`
+	OnlyInTarget    []string         `json:"only_in_target"`
+	Differences     []userDifference `json:"differences"`
+	ByField         map[string]int   `json:"by_field"`
+}
+
+func newUserDiffReport(diffs []resourceDiff, stats userDiffStats) userDiffReport {
+	report := userDiffReport{Summary: stats, MissingInTarget: []string{}, OnlyInTarget: []string{}, Differences: []userDifference{}, ByField: map[string]int{}}
+	for _, d := range diffs {
+		switch d.action {
+		case diffActionCreate:
+			report.MissingInTarget = append(report.MissingInTarget, d.key)
+		case diffActionDelete:
+			report.OnlyInTarget = append(report.OnlyInTarget, d.key)
+		case diffActionUpdate:
+			fields := fieldDifferences("", d.to, d.from)
+			report.Differences = append(report.Differences, userDifference{Key: d.key, Fields: fields})
+
+			counted := map[string]bool{}
+			for _, field := range fields {
+				top, _, _ := strings.Cut(field.Field, ".")
+				if !counted[top] {
+					counted[top] = true
+					report.ByField[top]++
+				}
+			}
+		}
+	}
+	return report
+}
+
+// exportTenantUsers exports the users of a connection and downloads the
+// export to filename.
+func exportTenantUsers(ctx context.Context, m *management.Management, connectionID string, fields []string, jobTimeout time.Duration, filename string) error {
+	jobID, err := exportConnectionUsers(ctx, m, connectionID, "json", 0, exportJobFields(fields, nil, "."))
+	if err != nil {
+		return fmt.Errorf("failed to export users: %w", apiError(err))
+	}
+	job, err := waitForExport(ctx, m, jobID, jobTimeout)
+	if err != nil {
+		return err
+	}
+	return downloadExport(ctx, m, jobID, job.GetLocation(), filename)
+}
+
+func newDiffUsersCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var (
+		o          userDiffOptions
+		match      string
+		format     string
+		preset     string
+		jobTimeout time.Duration
+	)
+
+	var usersCmd = &cobra.Command{
+		Use:   "users [<source-file> <target-file>]",
+		Short: "Compare the users of the source and target tenants, or of two user exports, e.g. to verify a migration",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("expected no arguments to export both tenants, or a source and a target file, got %d arguments", len(args))
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if o.workers <= 0 {
+				log.Fatalf("Workers must be greater than zero")
+			}
+			var err error
+			if o.matcher, err = parseMatcher(match); err != nil {
+				log.Fatalf("Invalid --match: %v", err)
+			}
+			if o.falsePositiveRate <= 0 || o.falsePositiveRate >= 1 {
+				log.Fatalf("The false positive rate must be between 0 and 1")
+			}
+			switch format {
+			case "text", "json", "json-patch", "merge-patch":
+			default:
+				log.Fatalf("Unknown format %q, expected text, json, json-patch or merge-patch", format)
+			}
+
+			// Without files, both tenants are exported first.
+			if len(args) == 0 {
+				cfg, err := loadOptionalConfig()
+				if err != nil {
+					log.Fatalf("Failed to load config: %v", err)
+				}
+				fields, err := exportFieldPreset(cfg, preset)
+				if err != nil {
+					log.Fatalf("Invalid preset: %v", err)
+				}
+				if field := o.matcher.field(); field != "" && field != "user_id" && !slices.Contains(fields, field) {
+					fields = append(slices.Clone(fields), field)
+				}
+
+				dir, err := os.MkdirTemp("", "auth0-tools-diff-")
+				if err != nil {
+					log.Fatalf("Failed to create a temporary directory: %v", err)
+				}
+				defer os.RemoveAll(dir)
+				args = []string{filepath.Join(dir, "source_users.json.gz"), filepath.Join(dir, "target_users.json.gz")}
+
+				fmt.Fprintln(messageOutput, "Exporting the users of the source tenant...")
+				if err := exportTenantUsers(ctx, clients.sourceClient(), os.Getenv("SOURCE_CONNECTION_ID"), fields, jobTimeout, args[0]); err != nil {
+					log.Fatalf("Failed to export the source users: %v", err)
+				}
+				fmt.Fprintln(messageOutput, "Exporting the users of the target tenant...")
+				if err := exportTenantUsers(ctx, clients.targetClient(), os.Getenv("DESTINATION_CONNECTION_ID"), fields, jobTimeout, args[1]); err != nil {
+					log.Fatalf("Failed to export the target users: %v", err)
+				}
+			}
+
+			diffs, stats, err := diffUserFiles(args[0], args[1], o)
+			if err != nil {
+				log.Fatalf("Failed to compare users: %v", err)
+			}
+
+			report := newUserDiffReport(diffs, stats)
+			if format == "json" {
+				encoder := json.NewEncoder(resultOutput)
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(report)
+			} else {
+				err = writeDiffs(resultOutput, diffs, format)
+			}
+			if err != nil {
+				log.Fatalf("Failed to write differences: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d source and %d target users: %d identical, %d different, %d missing in target, %d only in target.\n",
+				stats.Source, stats.Target, stats.Identical, stats.Different, stats.Missing, stats.Extra)
+			for _, field := range sortedKeys(report.ByField) {
+				fmt.Fprintf(messageOutput, "  %s differs for %d users\n", field, report.ByField[field])
+			}
+			if stats.Skipped > 0 {
+				fmt.Fprintf(messageOutput, "%d source users without %s were skipped.\n", stats.Skipped, o.matcher)
+			}
+		},
+	}
+
+	usersCmd.Flags().StringVar(&match, "match", "email", "How source and target users are matched: email, username, user_id, metadata:<key> or expr:<query>")
+	usersCmd.Flags().StringVar(&match, "key", "email", "Field matching source and target users")
+	usersCmd.Flags().MarkDeprecated("key", "use --match instead")
+	usersCmd.Flags().StringSliceVar(&o.ignore, "ignore", defaultDiffIgnore, "Fields that are not compared")
+	usersCmd.Flags().IntVar(&o.workers, "workers", runtime.NumCPU(), "Number of users fingerprinted and compared in parallel")
+	usersCmd.Flags().Float64Var(&o.falsePositiveRate, "false-positive-rate", 1e-9, "Chance of a different user being taken for identical by the prefilter")
+	usersCmd.Flags().StringVar(&format, "format", "text", "Output format: text, json, json-patch or merge-patch")
+	usersCmd.Flags().StringVar(&preset, "preset", defaultFieldPreset, "Field preset exported from both tenants when no files are given")
+	usersCmd.Flags().DurationVar(&jobTimeout, "job-timeout", time.Hour, "How long to wait for each export job (0 to wait indefinitely)")
+
+	return usersCmd
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		filter.add([]byte(fmt.Sprintf("user_%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !filter.has([]byte(fmt.Sprintf("user_%d", i))) {
+			t.Fatalf("Expected user_%d in the filter", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if filter.has([]byte(fmt.Sprintf("user_%d", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestDiffUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	sourceFile := filepath.Join(dir, "source.json")
+	targetFile := filepath.Join(dir, "target.json")
+
+	source := `{"user_id":"auth0|1","email":"same@example.com","last_login":"2024-01-01"}
+{"user_id":"auth0|2","email":"new@example.com","name":"Changed"}
+{"user_id":"auth0|3","email":"missing@example.com"}
+{"email":"nokey@example.com"}
+`
+	target := `{"user_id":"auth0|1","email":"same@example.com","last_login":"2024-06-01"}
+{"user_id":"auth0|2","email":"old@example.com","name":"Changed"}
+{"user_id":"auth0|4","email":"extra@example.com"}
+`
+	if err := os.WriteFile(sourceFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(targetFile, []byte(target), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	matcher, _ := parseMatcher("user_id")
+	o := userDiffOptions{matcher: matcher, ignore: defaultDiffIgnore, workers: 4, falsePositiveRate: 1e-9}
+	diffs, stats, err := diffUserFiles(sourceFile, targetFile, o)
+	if err != nil {
+		t.Fatalf("Failed to compare users: %v", err)
+	}
+
+	expected := []struct {
+		key    string
+		action string
+	}{
+		{"auth0|2", diffActionUpdate},
+		{"auth0|3", diffActionCreate},
+		{"auth0|4", diffActionDelete},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d differences, got %d: %+v", len(expected), len(diffs), diffs)
+	}
+	for i, e := range expected {
+		if diffs[i].key != e.key || diffs[i].action != e.action {
+			t.Errorf("Expected difference %d to be %s of %s, got %s of %s", i, e.action, e.key, diffs[i].action, diffs[i].key)
+		}
+	}
+	if diffs[0].from["email"] != "old@example.com" || diffs[0].to["email"] != "new@example.com" {
+		t.Errorf("Expected the update to go from the target to the source user, got %+v", diffs[0])
+	}
+
+	if stats.Source != 4 || stats.Target != 3 || stats.Identical != 1 || stats.Skipped != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestUserDiffReport(t *testing.T) {
+	diffs := []resourceDiff{
+		{key: "a@example.com", action: diffActionCreate},
+		{key: "b@example.com", action: diffActionUpdate,
+			from: map[string]interface{}{"email_verified": false, "app_metadata": map[string]interface{}{"plan": "free", "region": "eu"}},
+			to:   map[string]interface{}{"email_verified": true, "app_metadata": map[string]interface{}{"plan": "pro", "region": "eu", "seats": 3.0}}},
+		{key: "c@example.com", action: diffActionUpdate,
+			from: map[string]interface{}{"app_metadata": map[string]interface{}{"plan": "free"}},
+			to:   map[string]interface{}{"app_metadata": map[string]interface{}{"plan": "pro"}}},
+		{key: "d@example.com", action: diffActionDelete},
+	}
+	report := newUserDiffReport(diffs, userDiffStats{Different: 2})
+
+	if len(report.MissingInTarget) != 1 || report.MissingInTarget[0] != "a@example.com" {
+		t.Errorf("Unexpected users missing in target %v", report.MissingInTarget)
+	}
+	if len(report.OnlyInTarget) != 1 || report.OnlyInTarget[0] != "d@example.com" {
+		t.Errorf("Unexpected users only in target %v", report.OnlyInTarget)
+	}
+	if report.ByField["app_metadata"] != 2 || report.ByField["email_verified"] != 1 {
+		t.Errorf("Unexpected field counts %v", report.ByField)
+	}
+
+	fields := report.Differences[0].Fields
+	expected := []fieldDifference{
+		{Field: "app_metadata.plan", Source: "pro", Target: "free"},
+		{Field: "app_metadata.seats", Source: 3.0, Target: nil},
+		{Field: "email_verified", Source: true, Target: false},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected field differences %+v, got %+v", expected, fields)
+	}
+}
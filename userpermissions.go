@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// userPermissions is a line of a permissions file: the permissions assigned
+// directly to a user, not through roles.
+type userPermissions struct {
+	UserID      string                   `json:"user_id"`
+	Permissions []*management.Permission `json:"permissions"`
+}
+
+// directPermissions returns the permissions assigned directly to a user.
+// Users that don't exist are returned as nil without an error, users
+// without permissions as an empty slice.
+func directPermissions(ctx context.Context, m *management.Management, userID string) ([]*management.Permission, error) {
+	permissions := []*management.Permission{}
+
+	for page := 0; ; page++ {
+		list, err := m.User.Permissions(ctx, userID, management.Page(page), management.PerPage(100))
+		var mErr management.Error
+		if errors.As(err, &mErr) && mErr.Status() == http.StatusNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list permissions of user %s: %w", userID, apiError(err))
+		}
+		for _, permission := range list.Permissions {
+			permissions = append(permissions, &management.Permission{
+				ResourceServerIdentifier: permission.ResourceServerIdentifier,
+				Name:                     permission.Name,
+			})
+		}
+		if !list.HasNext() {
+			return permissions, nil
+		}
+	}
+}
+
+// exportUserPermissions writes the direct permissions of the users of an
+// exported users file as newline-delimited JSON, leaving out users without
+// any. It returns the number of users written and of permissions.
+func exportUserPermissions(ctx context.Context, m *management.Management, filename string, w io.Writer) (int, int, error) {
+	input, err := openInputFile(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer input.Close()
+
+	encoder := json.NewEncoder(w)
+	users, permissions := 0, 0
+	err = readUsers(input, func(record userRecord) error {
+		userID, _ := record.user["user_id"].(string)
+		if userID == "" {
+			return nil
+		}
+		assigned, err := directPermissions(ctx, m, userID)
+		if err != nil || len(assigned) == 0 {
+			return err
+		}
+		users++
+		permissions += len(assigned)
+		return encoder.Encode(userPermissions{UserID: userID, Permissions: assigned})
+	})
+	if err != nil {
+		return users, permissions, fmt.Errorf("failed to export permissions: %w", err)
+	}
+	return users, permissions, nil
+}
+
+// loadUserPermissions reads a permissions file written by
+// exportUserPermissions.
+func loadUserPermissions(filename string) ([]userPermissions, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open permissions file: %w", err)
+	}
+	defer file.Close()
+
+	var all []userPermissions
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var user userPermissions
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			return nil, fmt.Errorf("failed to parse permissions: %w", &ValidationError{Line: line, Err: err})
+		}
+		if user.UserID == "" {
+			return nil, fmt.Errorf("invalid permissions: %w", &ValidationError{Line: line, Err: errors.New("missing user_id")})
+		}
+		all = append(all, user)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read permissions file: %w", err)
+	}
+	return all, nil
+}
+
+// apiScopes returns the permissions every API of a tenant defines, by API
+// identifier.
+func apiScopes(ctx context.Context, m *management.Management) (map[string]map[string]bool, error) {
+	scopes := map[string]map[string]bool{}
+	for page := 0; ; page++ {
+		list, err := m.ResourceServer.List(ctx, management.Page(page), management.PerPage(100))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list APIs: %w", apiError(err))
+		}
+		for _, api := range list.ResourceServers {
+			names := map[string]bool{}
+			for _, scope := range api.GetScopes() {
+				names[scope.GetValue()] = true
+			}
+			scopes[api.GetIdentifier()] = names
+		}
+		if !list.HasNext() {
+			return scopes, nil
+		}
+	}
+}
+
+// permissionImport counts what importing direct permissions changed.
+type permissionImport struct {
+	Users    int      `json:"users"`
+	Assigned int      `json:"assigned"`
+	Missing  int      `json:"missing"`
+	Unknown  []string `json:"unknown"`
+	Failed   int      `json:"failed"`
+}
+
+// importUserPermissions assigns the direct permissions of each user to the
+// user with the same user_id in the target tenant, which imported users
+// keep. API identifiers are mapped through the apis section of remap.
+// Permissions the target API doesn't define are reported as unknown and
+// skipped, and only permissions the user lacks are assigned. Nothing is
+// changed on a dry run.
+func importUserPermissions(ctx context.Context, m *management.Management, users []userPermissions, remap nameRemap, dryRun bool) (permissionImport, error) {
+	result := permissionImport{Unknown: []string{}}
+
+	scopes, err := apiScopes(ctx, m)
+	if err != nil {
+		return result, err
+	}
+	unknown := map[string]bool{}
+
+	for _, user := range users {
+		var wanted []*management.Permission
+		for _, permission := range user.Permissions {
+			identifier := remap.name("apis", permission.GetResourceServerIdentifier())
+			if !scopes[identifier][permission.GetName()] {
+				unknown[identifier+" "+permission.GetName()] = true
+				continue
+			}
+			wanted = append(wanted, &management.Permission{ResourceServerIdentifier: &identifier, Name: permission.Name})
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+
+		existing, err := directPermissions(ctx, m, user.UserID)
+		if err != nil {
+			fmt.Fprintf(messageOutput, "Failed to read the permissions of user %s: %v\n", user.UserID, err)
+			result.Failed++
+			continue
+		}
+		if existing == nil {
+			result.Missing++
+			continue
+		}
+
+		missing := missingPermissions(wanted, existing)
+		if len(missing) > 0 && !dryRun {
+			if err := m.User.AssignPermissions(ctx, user.UserID, missing); err != nil {
+				fmt.Fprintf(messageOutput, "Failed to assign permissions to user %s: %v\n", user.UserID, apiError(err))
+				result.Failed++
+				continue
+			}
+		}
+		result.Users++
+		result.Assigned += len(missing)
+	}
+
+	result.Unknown = append(result.Unknown, sortedKeys(unknown)...)
+	return result, nil
+}
+
+func newUserPermissionsCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var permissionsCmd = &cobra.Command{
+		Use:   "permissions",
+		Short: "Export and import the permissions assigned directly to users, not through roles",
+	}
+
+	var (
+		exportTenant string
+		exportInput  string
+		exportOutput string
+	)
+
+	var exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Write the direct permissions of the users of an exported users file",
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := selectTenant(exportTenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+
+			output, err := os.Create(exportOutput)
+			if err != nil {
+				log.Fatalf("Failed to create output file: %v", err)
+			}
+			users, permissions, err := exportUserPermissions(ctx, client, exportInput, output)
+			if closeErr := output.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				log.Fatalf("Failed to export permissions: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "%d permissions of %d users written to %s\n", permissions, users, exportOutput)
+			reportResult("users permissions export", map[string]interface{}{"users": users, "permissions": permissions, "output": exportOutput})
+		},
+	}
+
+	exportCmd.Flags().StringVar(&exportTenant, "tenant", "source", "Tenant to read permissions from: source, target or a profile from the config")
+	exportCmd.Flags().StringVar(&exportInput, "input", "exported_users.json.gz", "Exported users file whose users' permissions are exported")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "user_permissions.json", "File the permissions are written to as newline-delimited JSON")
+
+	var (
+		importTenant string
+		importInput  string
+		remapFile    string
+		dryRun       bool
+	)
+
+	var importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Assign exported direct permissions to the same users in the target tenant",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !dryRun {
+				checkWritable("import permissions")
+				guardProtected(tenantDomain(importTenant), "import permissions")
+			}
+			var remap nameRemap
+			var err error
+			if remapFile != "" {
+				if remap, err = loadNameRemap(remapFile); err != nil {
+					log.Fatalf("Invalid --remap: %v", err)
+				}
+			}
+			users, err := loadUserPermissions(importInput)
+			if err != nil {
+				log.Fatalf("Failed to load permissions: %v", err)
+			}
+			client, err := selectTenant(importTenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+
+			result, err := importUserPermissions(ctx, client, users, remap, dryRun)
+			if err != nil {
+				log.Fatalf("Failed to import permissions: %v", err)
+			}
+			for _, permission := range result.Unknown {
+				fmt.Fprintf(messageOutput, "Skipped %s: the target API doesn't define it.\n", permission)
+			}
+
+			verb := "assigned"
+			if dryRun {
+				verb = "would be assigned"
+			}
+			fmt.Fprintf(messageOutput, "%d permissions %s to %d users; %d users not found in target, %d failed.\n", result.Assigned, verb, result.Users, result.Missing, result.Failed)
+			reportResult("users permissions import", map[string]interface{}{"dry_run": dryRun, "result": result})
+			if result.Failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	importCmd.Flags().StringVar(&importTenant, "tenant", "target", "Tenant to assign permissions in: source, target or a profile from the config")
+	importCmd.Flags().StringVar(&importInput, "input", "user_permissions.json", "Permissions file written by users permissions export")
+	importCmd.Flags().StringVar(&remapFile, "remap", "", "YAML file mapping source API identifiers to their identifiers in the target, under apis")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print how many permissions would be assigned")
+
+	permissionsCmd.AddCommand(exportCmd, importCmd)
+	return permissionsCmd
+}
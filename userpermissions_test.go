@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestExportAndImportUserPermissions(t *testing.T) {
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/api/v2/users/") {
+		case "auth0|1/permissions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"start": 0, "limit": 100, "total": 2, "permissions": []map[string]interface{}{
+				{"resource_server_identifier": "https://api.old.example.com", "permission_name": "read:reports", "resource_server_name": "Reports"},
+				{"resource_server_identifier": "https://billing.example.com", "permission_name": "write:invoices"},
+			}})
+		case "auth0|2/permissions":
+			json.NewEncoder(w).Encode(map[string]interface{}{"start": 0, "limit": 100, "total": 0, "permissions": []interface{}{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"The user does not exist."}`))
+		}
+	}))
+
+	dir := t.TempDir()
+	usersFile := filepath.Join(dir, "users.json")
+	os.WriteFile(usersFile, []byte(`{"user_id":"auth0|1"}
+{"user_id":"auth0|2"}
+{"user_id":"auth0|3"}
+`), 0o600)
+
+	var exported bytes.Buffer
+	users, permissions, err := exportUserPermissions(context.Background(), source, usersFile, &exported)
+	if err != nil {
+		t.Fatalf("Failed to export permissions: %v", err)
+	}
+	if users != 1 || permissions != 2 {
+		t.Fatalf("Expected 2 permissions of 1 user, got %d of %d", permissions, users)
+	}
+	permissionsFile := filepath.Join(dir, "permissions.json")
+	os.WriteFile(permissionsFile, append(exported.Bytes(), []byte(`{"user_id":"auth0|9","permissions":[{"resource_server_identifier":"https://api.old.example.com","permission_name":"read:reports"}]}`+"\n")...), 0o600)
+
+	loaded, err := loadUserPermissions(permissionsFile)
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("Expected 2 users in the permissions file, got %d %v", len(loaded), err)
+	}
+
+	var mu sync.Mutex
+	var assigned []map[string]interface{}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/v2/resource-servers":
+			json.NewEncoder(w).Encode(map[string]interface{}{"resource_servers": []map[string]interface{}{
+				{"identifier": "https://api.example.com", "scopes": []map[string]interface{}{{"value": "read:reports"}}},
+			}})
+		case r.URL.Path == "/api/v2/users/auth0|1/permissions" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"start": 0, "limit": 100, "total": 0, "permissions": []interface{}{}})
+		case r.URL.Path == "/api/v2/users/auth0|1/permissions" && r.Method == http.MethodPost:
+			var body map[string][]map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			assigned = append(assigned, body["permissions"]...)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"statusCode":404,"error":"Not Found","message":"The user does not exist."}`))
+		}
+	}))
+
+	remap := nameRemap{"apis": {"https://api.old.example.com": "https://api.example.com"}}
+	dryRun, err := importUserPermissions(context.Background(), target, loaded, remap, true)
+	if err != nil {
+		t.Fatalf("Failed to plan the import: %v", err)
+	}
+	if dryRun.Assigned != 1 || len(assigned) != 0 {
+		t.Errorf("Expected 1 permission to be planned and none assigned on a dry run, got %d and %v", dryRun.Assigned, assigned)
+	}
+
+	result, err := importUserPermissions(context.Background(), target, loaded, remap, false)
+	if err != nil {
+		t.Fatalf("Failed to import permissions: %v", err)
+	}
+	if result.Users != 1 || result.Assigned != 1 || result.Missing != 1 || result.Failed != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(result.Unknown) != 1 || result.Unknown[0] != "https://billing.example.com write:invoices" {
+		t.Errorf("Expected the permission of the missing API to be unknown, got %v", result.Unknown)
+	}
+	if len(assigned) != 1 || assigned[0]["resource_server_identifier"] != "https://api.example.com" || assigned[0]["permission_name"] != "read:reports" {
+		t.Errorf("Expected the permission to be assigned under the remapped API, got %v", assigned)
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// userPatch is a partial user update read from a patches file. Only the
+// fields present in the patch are sent, so Auth0 merges them into the
+// existing user (top-level metadata keys are merged, not replaced).
+type userPatch struct {
+	userID string
+	user   *management.User
+}
+
+func loadUserPatches(filename string) ([]userPatch, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patches file: %w", err)
+	}
+	defer file.Close()
+
+	var patches []userPatch
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var user management.User
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", &ValidationError{Line: lineNumber, Err: err})
+		}
+
+		if user.GetID() == "" {
+			return nil, fmt.Errorf("invalid patch: %w", &ValidationError{Line: lineNumber, Err: errors.New("missing user_id")})
+		}
+
+		userID := user.GetID()
+		user.ID = nil
+		patches = append(patches, userPatch{userID: userID, user: &user})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patches file: %w", err)
+	}
+
+	return patches, nil
+}
+
+// applyUserPatches updates users in batches, waiting interval between
+// requests and batchDelay between batches to stay under the rate limit.
+func applyUserPatches(ctx context.Context, m *management.Management, patches []userPatch, batchSize int, interval, batchDelay time.Duration) (int, int) {
+	updated, failed := 0, 0
+
+	for start := 0; start < len(patches); start += batchSize {
+		end := min(start+batchSize, len(patches))
+		fmt.Fprintf(messageOutput, "Updating users %d-%d/%d...\n", start+1, end, len(patches))
+
+		for _, patch := range patches[start:end] {
+			err := apiError(m.User.Update(ctx, patch.userID, patch.user))
+			if err != nil {
+				fmt.Fprintf(messageOutput, "Failed to update user %s: %v\n", patch.userID, err)
+				failed++
+			} else {
+				updated++
+			}
+
+			if interval > 0 {
+				time.Sleep(interval)
+			}
+		}
+
+		if end < len(patches) && batchDelay > 0 {
+			time.Sleep(batchDelay)
+		}
+	}
+
+	return updated, failed
+}
+
+// searchUsers pages through all users matching a Lucene query. As Auth0
+// only returns the first searchResultLimit results of a search, and reports
+// at most that many as the total, a query matching that many users fails
+// rather than returning some of them.
+func searchUsers(ctx context.Context, m *management.Management, query string) ([]*management.User, error) {
+	const perPage = 100
+	var users []*management.User
+
+	for page := 0; page*perPage < searchResultLimit; page++ {
+		userList, err := m.User.Search(ctx,
+			management.Query(query),
+			management.Page(page),
+			management.PerPage(perPage),
+			management.IncludeTotals(true),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search users: %w", err)
+		}
+		if userList.Total >= searchResultLimit {
+			return nil, fmt.Errorf("query matches %d or more users but a search only returns the first %d, narrow it down", searchResultLimit, searchResultLimit)
+		}
+
+		users = append(users, userList.Users...)
+		if !userList.HasNext() || len(userList.Users) == 0 {
+			break
+		}
+	}
+
+	return users, nil
+}
+
+type fieldAssignment struct {
+	path  []string
+	value interface{}
+}
+
+// parseFieldAssignment parses a "path.to.field=value" expression. Values that
+// are valid JSON (numbers, booleans, objects) are decoded, anything else is
+// kept as a string.
+func parseFieldAssignment(expr string) (fieldAssignment, error) {
+	key, raw, ok := strings.Cut(expr, "=")
+	if !ok || key == "" {
+		return fieldAssignment{}, fmt.Errorf("invalid assignment %q, expected field=value", expr)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	return fieldAssignment{path: strings.Split(key, "."), value: value}, nil
+}
+
+// buildUserPatch turns assignments into a partial user update. Auth0 only
+// merges the first level of metadata, so nested objects are copied from the
+// existing user before the assignment is applied to avoid dropping siblings.
+func buildUserPatch(user *management.User, assignments []fieldAssignment) (*management.User, error) {
+	current := map[string]interface{}{}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user: %w", err)
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+
+	patch := map[string]interface{}{}
+	for _, assignment := range assignments {
+		path := assignment.path
+		if len(path) > 2 {
+			if _, ok := getPath(patch, path[:2]); !ok {
+				existing, _ := getPath(current, path[:2])
+				if _, isMap := existing.(map[string]interface{}); isMap {
+					setPath(patch, path[:2], existing)
+				}
+			}
+		}
+		setPath(patch, path, assignment.value)
+	}
+
+	data, err = json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	var result management.User
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	return &result, nil
+}
+
+func getPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = data
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func setPath(data map[string]interface{}, path []string, value interface{}) {
+	current := data
+	for _, key := range path[:len(path)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	current[path[len(path)-1]] = value
+}
+
+func newUsersCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var usersCmd = &cobra.Command{
+		Use:   "users",
+		Short: "Manage existing users in the source or target tenant",
+	}
+
+	var (
+		fromFile   string
+		query      string
+		sets       []string
+		dryRun     bool
+		yes        bool
+		tenant     string
+		batchSize  int
+		batchDelay time.Duration
+		rate       float64
+	)
+
+	var updateCmd = &cobra.Command{
+		Use:   "update",
+		Short: "Apply partial updates (PATCH semantics) to existing users",
+		Run: func(cmd *cobra.Command, args []string) {
+			if (fromFile == "") == (query == "") {
+				log.Fatalf("Exactly one of --from-file or --query is required")
+			}
+			if query != "" && len(sets) == 0 {
+				log.Fatalf("At least one --set is required with --query")
+			}
+			if batchSize <= 0 {
+				log.Fatalf("Batch size must be greater than zero")
+			}
+
+			client, err := selectTenant(tenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+
+			var patches []userPatch
+			if fromFile != "" {
+				patches, err = loadUserPatches(fromFile)
+				if err != nil {
+					log.Fatalf("Failed to load patches: %v", err)
+				}
+
+				fmt.Fprintf(messageOutput, "%d users to update from %s\n", len(patches), fromFile)
+				if dryRun || len(patches) == 0 {
+					reportResult("users update", map[string]interface{}{"patches": len(patches), "dry_run": dryRun})
+					return
+				}
+				checkWritable("update users")
+				guardProtected(tenantDomain(tenant), "update users")
+			} else {
+				var assignments []fieldAssignment
+				for _, expr := range sets {
+					assignment, err := parseFieldAssignment(expr)
+					if err != nil {
+						log.Fatalf("Failed to parse --set: %v", err)
+					}
+					assignments = append(assignments, assignment)
+				}
+
+				users, err := searchUsers(ctx, client, query)
+				if err != nil {
+					log.Fatalf("Failed to search users: %v", err)
+				}
+
+				fmt.Fprintf(messageOutput, "%d users match query %s\n", len(users), query)
+				if dryRun || len(users) == 0 {
+					reportResult("users update", map[string]interface{}{"matched": len(users), "dry_run": dryRun})
+					return
+				}
+				checkWritable("update users")
+				guardProtected(tenantDomain(tenant), "update users")
+				if !yes {
+					ok, err := confirm(fmt.Sprintf("Update %d users?", len(users)))
+					if err != nil {
+						log.Fatalf("%v (re-run with --yes to confirm)", err)
+					}
+					if !ok {
+						fmt.Fprintln(messageOutput, "Aborted.")
+						return
+					}
+				}
+
+				for _, user := range users {
+					patch, err := buildUserPatch(user, assignments)
+					if err != nil {
+						log.Fatalf("Failed to build patch for user %s: %v", user.GetID(), err)
+					}
+					patches = append(patches, userPatch{userID: user.GetID(), user: patch})
+				}
+			}
+
+			var interval time.Duration
+			if rate > 0 {
+				interval = time.Duration(float64(time.Second) / rate)
+			}
+
+			updated, failed := applyUserPatches(ctx, client, patches, batchSize, interval, batchDelay)
+			fmt.Fprintf(messageOutput, "Update finished: %d updated, %d failed.\n", updated, failed)
+			reportResult("users update", map[string]interface{}{"updated": updated, "failed": failed})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	updateCmd.Flags().StringVar(&fromFile, "from-file", "", "NDJSON file with one partial user object (including user_id) per line")
+	updateCmd.Flags().StringVar(&query, "query", "", "Lucene query selecting the users to update, e.g. 'app_metadata.plan:\"legacy\"'")
+	updateCmd.Flags().StringArrayVar(&sets, "set", nil, "Field assignment applied to every matching user, e.g. app_metadata.plan=standard (repeatable)")
+	updateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print how many users match the query or are listed in --from-file, without updating them")
+	updateCmd.Flags().BoolVar(&yes, "yes", false, "Skip the confirmation prompt")
+	updateCmd.Flags().StringVar(&tenant, "tenant", "target", "Tenant to update: source, target or a profile from the config")
+	updateCmd.Flags().IntVar(&batchSize, "batch-size", 50, "Number of users updated per batch")
+	updateCmd.Flags().DurationVar(&batchDelay, "batch-delay", time.Second, "Delay between batches")
+	updateCmd.Flags().Float64Var(&rate, "rate", 10, "Maximum update requests per second (0 for unlimited)")
+
+	var (
+		deleteConnection string
+		deleteQuery      string
+		deleteConfirm    bool
+		deleteTenant     string
+		deleteWorkers    int
+		deleteRate       float64
+		deleteJobTimeout time.Duration
+		deleteLedgerFile string
+	)
+
+	var deleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete all users of a connection, e.g. to reset a staging tenant between test runs",
+		Run: func(cmd *cobra.Command, args []string) {
+			if deleteConnection == "" {
+				log.Fatalf("--connection is required")
+			}
+			if deleteWorkers <= 0 {
+				log.Fatalf("Workers must be greater than zero")
+			}
+			if deleteConfirm {
+				checkWritable("delete users")
+				guardProtected(tenantDomain(deleteTenant), "delete users")
+			}
+
+			client, err := selectTenant(deleteTenant, clients)
+			if err != nil {
+				log.Fatalf("Failed to select tenant: %v", err)
+			}
+
+			// The search returns at most searchResultLimit users, so the
+			// users of a whole connection come from an export instead.
+			query := connectionQuery(deleteConnection, deleteQuery)
+			var ids []string
+			var matched int
+			var connectionID string
+			if deleteQuery == "" {
+				connection, err := client.Connection.ReadByName(ctx, deleteConnection)
+				if err != nil {
+					log.Fatalf("Failed to read connection %s: %v", deleteConnection, apiError(err))
+				}
+				connectionID = connection.GetID()
+				fmt.Fprintf(messageOutput, "Exporting the users of connection %s...\n", deleteConnection)
+				if ids, err = exportUserIDs(ctx, client, connectionID, deleteJobTimeout); err != nil {
+					log.Fatalf("Failed to export users: %v", err)
+				}
+				matched = len(ids)
+				fmt.Fprintf(messageOutput, "Connection %s has %d users\n", deleteConnection, matched)
+			} else {
+				if matched, err = countUsers(ctx, client, query); err != nil {
+					log.Fatalf("Failed to search users: %v", err)
+				}
+				if matched >= searchResultLimit {
+					fmt.Fprintf(messageOutput, "At least %d users match query %s\n", matched, query)
+				} else {
+					fmt.Fprintf(messageOutput, "%d users match query %s\n", matched, query)
+				}
+			}
+			if !deleteConfirm || matched == 0 {
+				if matched > 0 {
+					fmt.Fprintln(messageOutput, "Re-run with --confirm to delete them.")
+				}
+				reportResult("users delete", map[string]interface{}{"matched": matched, "deleted": 0, "confirmed": deleteConfirm})
+				return
+			}
+
+			var interval time.Duration
+			if deleteRate > 0 {
+				interval = time.Duration(float64(time.Second) / deleteRate)
+			}
+
+			bar := newProgress("Deleting users", int64(matched), false)
+			var deleted, failed int
+			if ids != nil {
+				deleted, failed = deleteUsers(ctx, client, ids, deleteWorkers, interval, bar)
+			} else {
+				deleted, failed, err = wipeUsers(ctx, client, query, deleteWorkers, interval, bar)
+			}
+			bar.done()
+			if err != nil {
+				log.Fatalf("Failed to delete users: %v", err)
+			}
+
+			fmt.Fprintf(messageOutput, "Delete finished: %d deleted, %d failed.\n", deleted, failed)
+
+			// Chunks imported to a wiped connection would otherwise be
+			// skipped when the same file is imported again.
+			if connectionID != "" && failed == 0 {
+				ledger, err := loadChunkLedger(deleteLedgerFile)
+				if err != nil {
+					log.Fatalf("Failed to load the chunk ledger: %v", err)
+				}
+				destination := tenantDomain(deleteTenant) + "/" + connectionID
+				if chunks, err := ledger.forget(destination); err != nil {
+					log.Fatalf("Failed to clear the chunk ledger: %v", err)
+				} else if chunks > 0 {
+					fmt.Fprintf(messageOutput, "Removed %d chunks imported to %s from the chunk ledger.\n", chunks, destination)
+				}
+			} else if deleteQuery != "" && deleted > 0 {
+				fmt.Fprintln(messageOutput, "The chunk ledger still lists the chunks these users were imported in; import them again with --force.")
+			}
+			reportResult("users delete", map[string]interface{}{"matched": matched, "deleted": deleted, "failed": failed, "confirmed": true})
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&deleteConnection, "connection", "", "Name of the connection whose users are deleted")
+	deleteCmd.Flags().StringVar(&deleteQuery, "query", "", "Lucene query further selecting the users to delete, e.g. 'email:*@test.example.com'")
+	deleteCmd.Flags().BoolVar(&deleteConfirm, "confirm", false, "Delete the matching users; without it, only print how many users match")
+	deleteCmd.Flags().StringVar(&deleteTenant, "tenant", "target", "Tenant to delete users from: source, target or a profile from the config")
+	deleteCmd.Flags().IntVar(&deleteWorkers, "workers", 5, "Number of users deleted concurrently")
+	deleteCmd.Flags().Float64Var(&deleteRate, "rate", 10, "Maximum delete requests per second across all workers (0 for unlimited)")
+	deleteCmd.Flags().StringVar(&deleteLedgerFile, "ledger-file", defaultChunkLedgerFile, "Chunk ledger of the import, from which the chunks imported to the connection are removed when all its users are deleted (empty to leave it alone)")
+	deleteCmd.Flags().DurationVar(&deleteJobTimeout, "job-timeout", time.Hour, "How long to wait for the export job listing the users of the connection when no --query is given (0 to wait indefinitely)")
+
+	usersCmd.AddCommand(updateCmd, deleteCmd, newUserPermissionsCmd(ctx, clients))
+	return usersCmd
+}
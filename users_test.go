@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestLoadUserPatches(t *testing.T) {
+	filename := "patches.ndjson"
+	content := `{"user_id": "auth0|1", "app_metadata": {"plan": "standard"}}
+
+{"user_id": "auth0|2", "user_metadata": {"locale": "en"}}`
+
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write patches file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	patches, err := loadUserPatches(filename)
+	if err != nil {
+		t.Fatalf("Failed to load patches: %v", err)
+	}
+
+	if len(patches) != 2 {
+		t.Fatalf("Expected 2 patches, got %d", len(patches))
+	}
+
+	if patches[0].userID != "auth0|1" || patches[0].user.ID != nil {
+		t.Errorf("Expected user_id to be moved out of the patch body, got %+v", patches[0])
+	}
+
+	plan := (*patches[0].user.AppMetadata)["plan"]
+	if plan != "standard" {
+		t.Errorf("Expected plan standard, got %v", plan)
+	}
+
+	if err := os.WriteFile(filename, []byte(`{"app_metadata": {}}`), 0o600); err != nil {
+		t.Fatalf("Failed to write patches file: %v", err)
+	}
+	if _, err := loadUserPatches(filename); err == nil {
+		t.Errorf("Expected an error for a patch without user_id")
+	}
+}
+
+func TestBuildUserPatch(t *testing.T) {
+	appMetadata := map[string]interface{}{
+		"plan":    "legacy",
+		"billing": map[string]interface{}{"cycle": "monthly", "seats": float64(3)},
+	}
+	user := &management.User{AppMetadata: &appMetadata}
+
+	var assignments []fieldAssignment
+	for _, expr := range []string{"app_metadata.plan=standard", "app_metadata.billing.cycle=yearly", "blocked=false"} {
+		assignment, err := parseFieldAssignment(expr)
+		if err != nil {
+			t.Fatalf("Failed to parse assignment %s: %v", expr, err)
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	patch, err := buildUserPatch(user, assignments)
+	if err != nil {
+		t.Fatalf("Failed to build patch: %v", err)
+	}
+
+	metadata := *patch.AppMetadata
+	if metadata["plan"] != "standard" {
+		t.Errorf("Expected plan standard, got %v", metadata["plan"])
+	}
+
+	billing := metadata["billing"].(map[string]interface{})
+	if billing["cycle"] != "yearly" || billing["seats"] != float64(3) {
+		t.Errorf("Expected nested siblings to be preserved, got %v", billing)
+	}
+
+	if patch.Blocked == nil || *patch.Blocked {
+		t.Errorf("Expected blocked to be decoded as false, got %v", patch.Blocked)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	total := 250
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if page*perPage >= 1000 {
+			t.Errorf("Unexpected request for page %d", page)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		start, end := min(page*perPage, total), min((page+1)*perPage, total)
+		var users []map[string]interface{}
+		for i := start; i < end; i++ {
+			users = append(users, map[string]interface{}{"user_id": "auth0|" + strconv.Itoa(i)})
+		}
+		// Like Auth0, the total is capped at the 1000 results a search
+		// returns.
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": users, "start": start, "limit": perPage, "length": len(users), "total": min(total, 1000)})
+	}))
+
+	users, err := searchUsers(context.Background(), m, "logins_count:0")
+	if err != nil || len(users) != 250 {
+		t.Fatalf("Expected 250 users, got %d %v", len(users), err)
+	}
+
+	total = 5000
+	if _, err := searchUsers(context.Background(), m, "logins_count:0"); err == nil || !strings.Contains(err.Error(), "1000 or more users") {
+		t.Errorf("Expected the search to fail for more than 1000 users, got %v", err)
+	}
+}
+
+func TestUpdateUsersFromFileDryRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	readOnly = true
+	defer func() { readOnly = false }()
+
+	filename := filepath.Join(t.TempDir(), "patches.ndjson")
+	os.WriteFile(filename, []byte(`{"user_id":"auth0|1","app_metadata":{"plan":"standard"}}`+"\n"), 0644)
+
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected %s %s during a dry run", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	cmd := newUsersCmd(context.Background(), &tenantClients{ctx: context.Background(), target: target})
+	cmd.SetArgs([]string{"update", "--from-file", filename, "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Failed to run users update: %v", err)
+	}
+}
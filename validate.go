@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// passwordHashAlgorithms are the custom_password_hash algorithms Auth0
+// accepts in bulk imports.
+var passwordHashAlgorithms = []string{"argon2", "bcrypt", "hmac", "ldap", "md4", "md5", "sha1", "sha256", "sha512", "pbkdf2", "scrypt"}
+
+var (
+	importStringFields = []string{"user_id", "username", "given_name", "family_name", "name", "nickname", "picture"}
+	importBoolFields   = []string{"email_verified", "blocked"}
+	importObjectFields = []string{"app_metadata", "user_metadata", "custom_password_hash"}
+)
+
+// validateImportUser checks a user against the parts of Auth0's bulk import
+// schema that most often reject users: the required email and its format,
+// the types of the profile fields and the shape of the password hashes. All
+// problems of the user are returned in one error.
+func validateImportUser(user map[string]interface{}) error {
+	var problems []string
+
+	switch email, ok := user["email"]; {
+	case !ok || email == nil || email == "":
+		problems = append(problems, "email is required")
+	case !validEmail(email):
+		problems = append(problems, "email is not a valid address")
+	}
+
+	for _, field := range importStringFields {
+		if value, ok := user[field]; ok {
+			if _, isString := value.(string); !isString {
+				problems = append(problems, field+" must be a string")
+			}
+		}
+	}
+	for _, field := range importBoolFields {
+		if value, ok := user[field]; ok {
+			if _, isBool := value.(bool); !isBool {
+				problems = append(problems, field+" must be true or false")
+			}
+		}
+	}
+	for _, field := range importObjectFields {
+		if value, ok := user[field]; ok {
+			if _, isObject := value.(map[string]interface{}); !isObject {
+				problems = append(problems, field+" must be an object")
+			}
+		}
+	}
+
+	if value, ok := user["password_hash"]; ok {
+		if hash, _ := value.(string); !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$") {
+			problems = append(problems, "password_hash must be a bcrypt hash")
+		}
+		if _, ok := user["custom_password_hash"]; ok {
+			problems = append(problems, "password_hash and custom_password_hash can't both be set")
+		}
+	}
+	if hash, ok := user["custom_password_hash"].(map[string]interface{}); ok {
+		problems = append(problems, customPasswordHashProblems(hash)...)
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validEmail(value interface{}) bool {
+	email, ok := value.(string)
+	if !ok {
+		return false
+	}
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// customPasswordHashProblems checks the algorithm and the hash and salt
+// objects of a custom_password_hash.
+func customPasswordHashProblems(hash map[string]interface{}) []string {
+	var problems []string
+
+	algorithm, _ := hash["algorithm"].(string)
+	if !slices.Contains(passwordHashAlgorithms, algorithm) {
+		problems = append(problems, fmt.Sprintf("custom_password_hash.algorithm must be one of %s", strings.Join(passwordHashAlgorithms, ", ")))
+	}
+
+	value, _ := hash["hash"].(map[string]interface{})
+	if hashValue, _ := value["value"].(string); hashValue == "" {
+		problems = append(problems, "custom_password_hash.hash.value is required")
+	}
+	if encoding, ok := value["encoding"]; ok && !slices.Contains([]interface{}{"base64", "hex", "utf8"}, encoding) {
+		problems = append(problems, "custom_password_hash.hash.encoding must be base64, hex or utf8")
+	}
+
+	if salt, ok := hash["salt"]; ok {
+		saltObject, _ := salt.(map[string]interface{})
+		if saltValue, _ := saltObject["value"].(string); saltValue == "" {
+			problems = append(problems, "custom_password_hash.salt.value is required")
+		}
+		if position, ok := saltObject["position"]; ok && position != "prefix" && position != "suffix" {
+			problems = append(problems, "custom_password_hash.salt.position must be prefix or suffix")
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateImportUser(t *testing.T) {
+	valid := []string{
+		`{"email": "a@example.com", "email_verified": true, "user_metadata": {"plan": "pro"}}`,
+		`{"email": "a@example.com", "password_hash": "$2b$10$abcdefghijklmnopqrstuv"}`,
+		`{"email": "a@example.com", "custom_password_hash": {"algorithm": "sha256", "hash": {"value": "abc", "encoding": "hex"}, "salt": {"value": "s", "position": "prefix"}}}`,
+	}
+	for _, input := range valid {
+		var user map[string]interface{}
+		json.Unmarshal([]byte(input), &user)
+		if err := validateImportUser(user); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", input, err)
+		}
+	}
+
+	invalid := map[string]string{
+		`{"name": "No Email"}`:                                     "email is required",
+		`{"email": "Someone <a@example.com>"}`:                     "not a valid address",
+		`{"email": "not-an-email"}`:                                "not a valid address",
+		`{"email": "a@example.com", "email_verified": "yes"}`:      "email_verified must be true or false",
+		`{"email": "a@example.com", "app_metadata": "pro"}`:        "app_metadata must be an object",
+		`{"email": "a@example.com", "password_hash": "plaintext"}`: "must be a bcrypt hash",
+		`{"email": "a@example.com", "custom_password_hash": {"algorithm": "rot13", "hash": {"value": "abc"}}}`:                                             "algorithm must be one of",
+		`{"email": "a@example.com", "custom_password_hash": {"algorithm": "md5", "hash": {}}}`:                                                             "hash.value is required",
+		`{"email": "a@example.com", "custom_password_hash": {"algorithm": "md5", "hash": {"value": "abc"}, "salt": {"value": "s", "position": "middle"}}}`: "prefix or suffix",
+		`{"email": "a@example.com", "password_hash": "$2b$10$abc", "custom_password_hash": {"algorithm": "md5", "hash": {"value": "abc"}}}`:                "can't both be set",
+	}
+	for input, expected := range invalid {
+		var user map[string]interface{}
+		json.Unmarshal([]byte(input), &user)
+		if err := validateImportUser(user); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q for %s, got %v", expected, input, err)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/auth0/go-auth0/management"
+	"github.com/spf13/cobra"
+)
+
+// triggerBindings returns, per trigger, the names of the actions bound to it
+// in execution order. Triggers without bindings are left out.
+func triggerBindings(ctx context.Context, m *management.Management) (map[string][]string, error) {
+	triggers, err := m.Action.Triggers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list triggers: %w", apiError(err))
+	}
+
+	bindings := map[string][]string{}
+	for _, trigger := range triggers.Triggers {
+		id := trigger.GetID()
+		if _, seen := bindings[id]; seen {
+			continue // listed once per version
+		}
+
+		var names []string
+		for page := 0; ; page++ {
+			list, err := m.Action.Bindings(ctx, id, management.Page(page), management.PerPage(100))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list bindings of trigger %s: %w", id, apiError(err))
+			}
+			for _, binding := range list.Bindings {
+				name := binding.GetAction().GetName()
+				if name == "" {
+					name = binding.GetDisplayName()
+				}
+				names = append(names, name)
+			}
+			if !list.HasNext() {
+				break
+			}
+		}
+		bindings[id] = names
+	}
+
+	for id, names := range bindings {
+		if len(names) == 0 {
+			delete(bindings, id)
+		}
+	}
+	return bindings, nil
+}
+
+// bindingMismatch is a trigger whose bound actions differ between tenants.
+type bindingMismatch struct {
+	Trigger string   `json:"trigger"`
+	Problem string   `json:"problem"`
+	Source  []string `json:"source"`
+	Target  []string `json:"target"`
+}
+
+// compareBindings checks that every trigger runs the same actions in the
+// same order on the target as on the source. Actions are matched by name.
+func compareBindings(source, target map[string][]string) []bindingMismatch {
+	triggers := map[string]bool{}
+	for trigger := range source {
+		triggers[trigger] = true
+	}
+	for trigger := range target {
+		triggers[trigger] = true
+	}
+
+	var mismatches []bindingMismatch
+	for _, trigger := range sortedKeys(triggers) {
+		sourceNames, targetNames := source[trigger], target[trigger]
+		if slices.Equal(sourceNames, targetNames) {
+			continue
+		}
+
+		var missing, extra []string
+		for _, name := range sourceNames {
+			if !slices.Contains(targetNames, name) {
+				missing = append(missing, name)
+			}
+		}
+		for _, name := range targetNames {
+			if !slices.Contains(sourceNames, name) {
+				extra = append(extra, name)
+			}
+		}
+
+		var problems []string
+		if len(missing) > 0 {
+			problems = append(problems, "missing in target: "+strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			problems = append(problems, "only in target: "+strings.Join(extra, ", "))
+		}
+		if len(problems) == 0 {
+			problems = append(problems, "actions run in a different order")
+		}
+
+		mismatches = append(mismatches, bindingMismatch{
+			Trigger: trigger,
+			Problem: strings.Join(problems, "; "),
+			Source:  sourceNames,
+			Target:  targetNames,
+		})
+	}
+	return mismatches
+}
+
+// defaultVerifyFields are the user fields verify users compares.
+var defaultVerifyFields = []string{"email_verified", "name", "given_name", "family_name", "nickname", "picture", "blocked", "app_metadata", "user_metadata"}
+
+// connectionUserCounts returns the number of users of every connection of a
+// tenant, by connection name. Counts come from the user search, so a count
+// of searchResultLimit means at least that many users.
+func connectionUserCounts(ctx context.Context, m *management.Management) (map[string]int, error) {
+	connections, err := listConnections(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for name := range connections {
+		if counts[name], err = countUsers(ctx, m, connectionQuery(name, "")); err != nil {
+			return nil, fmt.Errorf("failed to count the users of %s: %w", name, err)
+		}
+	}
+	return counts, nil
+}
+
+// countMismatch is a connection whose user count differs between tenants
+// by more than the threshold.
+type countMismatch struct {
+	Connection string `json:"connection"`
+	Source     int    `json:"source"`
+	Target     int    `json:"target"`
+}
+
+// compareUserCounts returns the connections whose target count differs from
+// the source count by more than threshold, a fraction of the source count.
+// A connection missing on one side counts as having no users there.
+// Connections with at least searchResultLimit users on both sides can't be
+// compared and are left out.
+func compareUserCounts(source, target map[string]int, threshold float64) []countMismatch {
+	connections := map[string]bool{}
+	for name := range source {
+		connections[name] = true
+	}
+	for name := range target {
+		connections[name] = true
+	}
+
+	var mismatches []countMismatch
+	for _, name := range sortedKeys(connections) {
+		if source[name] >= searchResultLimit && target[name] >= searchResultLimit {
+			continue
+		}
+		difference := math.Abs(float64(source[name] - target[name]))
+		if difference == 0 || (source[name] > 0 && difference/float64(source[name]) <= threshold) {
+			continue
+		}
+		mismatches = append(mismatches, countMismatch{Connection: name, Source: source[name], Target: target[name]})
+	}
+	return mismatches
+}
+
+// sampleUsers picks up to n distinct random users of a tenant. Auth0 only
+// pages through the first searchResultLimit results of a search, so larger
+// tenants are sampled from those, which is reported as limited.
+func sampleUsers(ctx context.Context, m *management.Management, n int, rng *rand.Rand) ([]*management.User, bool, error) {
+	list, err := m.User.List(ctx, management.PerPage(1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to count users: %w", apiError(err))
+	}
+
+	var users []*management.User
+	for _, offset := range rng.Perm(min(list.Total, searchResultLimit))[:min(n, list.Total, searchResultLimit)] {
+		page, err := m.User.List(ctx, management.Page(offset), management.PerPage(1))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read users: %w", apiError(err))
+		}
+		users = append(users, page.Users...)
+	}
+	return users, list.Total >= searchResultLimit, nil
+}
+
+// userData returns all fields of a user, as they are exported.
+func userData(user *management.User) map[string]interface{} {
+	data, _ := json.Marshal(user)
+	var all map[string]interface{}
+	json.Unmarshal(data, &all)
+	return all
+}
+
+// selectUserFields returns the given fields of a user, as they are exported.
+func selectUserFields(user *management.User, fields []string) map[string]interface{} {
+	all := userData(user)
+	selected := map[string]interface{}{}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
+
+// userConnection returns the connection of the first identity of a user.
+func userConnection(user *management.User) string {
+	if len(user.Identities) == 0 {
+		return ""
+	}
+	return user.Identities[0].GetConnection()
+}
+
+// sampleMismatch is a sampled user that is missing on the target or whose
+// fields differ.
+type sampleMismatch struct {
+	User       string            `json:"user"`
+	Connection string            `json:"connection"`
+	Problem    string            `json:"problem"`
+	Fields     []fieldDifference `json:"fields,omitempty"`
+}
+
+// compareSampledUsers looks up every sampled source user on the target with
+// matcher, within the same connection, and compares the given fields. Users
+// without the value the matcher needs can't be matched and are returned as
+// skipped.
+func compareSampledUsers(ctx context.Context, target *management.Management, matcher *userMatcher, users []*management.User, fields []string) ([]sampleMismatch, int, error) {
+	var mismatches []sampleMismatch
+	skipped := 0
+
+	for _, user := range users {
+		record := userData(user)
+		if _, ok := matcher.key(record); !ok {
+			skipped++
+			continue
+		}
+		mismatch := sampleMismatch{User: user.GetID(), Connection: userConnection(user)}
+		if user.GetEmail() != "" {
+			mismatch.User = redactPII("email", user.GetEmail())
+		}
+
+		candidates, err := matcher.find(ctx, target, record)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to look up a target user: %w", apiError(err))
+		}
+		var targetUser *management.User
+		for _, candidate := range candidates {
+			if userConnection(candidate) == mismatch.Connection {
+				targetUser = candidate
+				break
+			}
+		}
+
+		if targetUser == nil {
+			mismatch.Problem = "missing in target"
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		if differences := fieldDifferences("", selectUserFields(user, fields), selectUserFields(targetUser, fields)); len(differences) > 0 {
+			var names []string
+			for i, difference := range differences {
+				names = append(names, difference.Field)
+				differences[i].Source = redactValue(difference.Field, difference.Source)
+				differences[i].Target = redactValue(difference.Field, difference.Target)
+			}
+			mismatch.Problem = "differs in " + strings.Join(names, ", ")
+			mismatch.Fields = differences
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches, skipped, nil
+}
+
+func newVerifyCmd(ctx context.Context, clients *tenantClients) *cobra.Command {
+	var verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check that the target tenant behaves like the source after a migration",
+	}
+
+	var actionsCmd = &cobra.Command{
+		Use:   "actions",
+		Short: "Check that every trigger runs the same actions in the same order on both tenants",
+		Run: func(cmd *cobra.Command, args []string) {
+			source, err := triggerBindings(ctx, clients.sourceClient())
+			if err != nil {
+				log.Fatalf("Failed to read source bindings: %v", err)
+			}
+			target, err := triggerBindings(ctx, clients.targetClient())
+			if err != nil {
+				log.Fatalf("Failed to read target bindings: %v", err)
+			}
+
+			mismatches := compareBindings(source, target)
+			for _, mismatch := range mismatches {
+				fmt.Fprintf(messageOutput, "%s: %s\n", mismatch.Trigger, mismatch.Problem)
+				fmt.Fprintf(messageOutput, "    source: %s\n", strings.Join(mismatch.Source, " -> "))
+				fmt.Fprintf(messageOutput, "    target: %s\n", strings.Join(mismatch.Target, " -> "))
+				annotate("error", "Action bindings differ", "", fmt.Errorf("%s: %s", mismatch.Trigger, mismatch.Problem))
+			}
+			fmt.Fprintf(messageOutput, "Compared the bindings of %d source triggers: %d triggers differ.\n", len(source), len(mismatches))
+
+			reportResult("verify actions", map[string]interface{}{"triggers": len(source), "mismatches": mismatches})
+			if len(mismatches) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	var (
+		sample    int
+		fields    []string
+		threshold float64
+		match     string
+	)
+
+	var usersCmd = &cobra.Command{
+		Use:   "users",
+		Short: "Compare the user counts per connection of both tenants and check that sampled users match",
+		Run: func(cmd *cobra.Command, args []string) {
+			if sample < 0 {
+				log.Fatalf("Sample size must not be negative")
+			}
+			if threshold < 0 || threshold >= 1 {
+				log.Fatalf("Threshold must be between 0 and 1")
+			}
+			matcher, err := parseMatcher(match)
+			if err != nil {
+				log.Fatalf("Invalid --match: %v", err)
+			}
+
+			source, err := connectionUserCounts(ctx, clients.sourceClient())
+			if err != nil {
+				log.Fatalf("Failed to count source users: %v", err)
+			}
+			target, err := connectionUserCounts(ctx, clients.targetClient())
+			if err != nil {
+				log.Fatalf("Failed to count target users: %v", err)
+			}
+			countMismatches := compareUserCounts(source, target, threshold)
+			for _, mismatch := range countMismatches {
+				fmt.Fprintf(messageOutput, "%s: %d source and %d target users\n", mismatch.Connection, mismatch.Source, mismatch.Target)
+				annotate("error", "User counts differ", "", fmt.Errorf("%s: %d source and %d target users", mismatch.Connection, mismatch.Source, mismatch.Target))
+			}
+			fmt.Fprintf(messageOutput, "Compared the user counts of %d connections: %d differ.\n", len(source), len(countMismatches))
+			var uncounted []string
+			for name, count := range source {
+				if count >= searchResultLimit && target[name] >= searchResultLimit {
+					uncounted = append(uncounted, name)
+				}
+			}
+			sort.Strings(uncounted)
+			if len(uncounted) > 0 {
+				fmt.Fprintf(messageOutput, "Connections with at least %d users on both tenants can't be counted exactly and weren't compared: %s\n", searchResultLimit, strings.Join(uncounted, ", "))
+			}
+
+			users, limited, err := sampleUsers(ctx, clients.sourceClient(), sample, rand.New(rand.NewSource(time.Now().UnixNano())))
+			if err != nil {
+				log.Fatalf("Failed to sample source users: %v", err)
+			}
+			if limited {
+				fmt.Fprintf(messageOutput, "The source tenant has at least %d users; users are only sampled from the first %d search results.\n", searchResultLimit, searchResultLimit)
+			}
+			sampleMismatches, skipped, err := compareSampledUsers(ctx, clients.targetClient(), matcher, users, fields)
+			if err != nil {
+				log.Fatalf("Failed to compare sampled users: %v", err)
+			}
+			for _, mismatch := range sampleMismatches {
+				fmt.Fprintf(messageOutput, "%s (%s): %s\n", mismatch.User, mismatch.Connection, mismatch.Problem)
+				for _, field := range mismatch.Fields {
+					fmt.Fprintf(messageOutput, "    %s: %v != %v\n", field.Field, field.Source, field.Target)
+				}
+			}
+			compared := len(users) - skipped
+			fmt.Fprintf(messageOutput, "Compared %d sampled users: %d differ", compared, len(sampleMismatches))
+			if skipped > 0 {
+				fmt.Fprintf(messageOutput, ", %d without a %s to match on were skipped", skipped, matcher)
+			}
+			fmt.Fprintln(messageOutput, ".")
+
+			failed := len(countMismatches) > 0
+			if compared > 0 && float64(len(sampleMismatches))/float64(compared) > threshold {
+				failed = true
+				annotate("error", "Sampled users differ", "", fmt.Errorf("%d of %d sampled users differ", len(sampleMismatches), compared))
+			}
+
+			reportResult("verify users", map[string]interface{}{
+				"connections":       len(source),
+				"count_mismatches":  countMismatches,
+				"uncounted":         uncounted,
+				"sampled":           compared,
+				"sample_limited":    limited,
+				"sample_mismatches": sampleMismatches,
+				"passed":            !failed,
+			})
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	usersCmd.Flags().IntVar(&sample, "sample", 100, "Number of random source users compared with their target user")
+	usersCmd.Flags().StringSliceVar(&fields, "fields", defaultVerifyFields, "User fields compared for the sampled users")
+	usersCmd.Flags().StringVar(&match, "match", "email", "How sampled users are matched across tenants: email, username, user_id, metadata:<key> or expr:<query>")
+	usersCmd.Flags().Float64Var(&threshold, "threshold", 0, "Share of differing sampled users, and relative difference of a connection's user count, that is tolerated")
+
+	verifyCmd.AddCommand(actionsCmd, usersCmd)
+	return verifyCmd
+}
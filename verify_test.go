@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/auth0/go-auth0"
+	"github.com/auth0/go-auth0/management"
+)
+
+func TestTriggerBindings(t *testing.T) {
+	m := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/actions/triggers"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"triggers": []map[string]interface{}{
+				{"id": "post-login", "version": "v2", "status": "deprecated"},
+				{"id": "post-login", "version": "v3", "status": "current"},
+				{"id": "pre-user-registration", "version": "v2", "status": "current"},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/post-login/bindings"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"bindings": []map[string]interface{}{
+				{"id": "b1", "action": map[string]interface{}{"name": "add-claims"}},
+				{"id": "b2", "display_name": "audit-login"},
+			}})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"bindings": []interface{}{}})
+		}
+	}))
+
+	bindings, err := triggerBindings(context.Background(), m)
+	if err != nil {
+		t.Fatalf("Failed to read bindings: %v", err)
+	}
+	expected := map[string][]string{"post-login": {"add-claims", "audit-login"}}
+	if !reflect.DeepEqual(bindings, expected) {
+		t.Errorf("Expected %v, got %v", expected, bindings)
+	}
+}
+
+func TestCompareBindings(t *testing.T) {
+	source := map[string][]string{
+		"post-login":            {"add-claims", "enforce-mfa", "audit-login"},
+		"pre-user-registration": {"deny-disposable"},
+		"credentials-exchange":  {"add-scopes"},
+	}
+	target := map[string][]string{
+		"post-login":            {"enforce-mfa", "add-claims", "audit-login"},
+		"pre-user-registration": {"deny-disposable", "legacy-check"},
+		"credentials-exchange":  {"add-scopes"},
+		"post-change-password":  {"notify"},
+	}
+
+	mismatches := compareBindings(source, target)
+	problems := map[string]string{}
+	for _, mismatch := range mismatches {
+		problems[mismatch.Trigger] = mismatch.Problem
+	}
+
+	expected := map[string]string{
+		"post-login":            "actions run in a different order",
+		"pre-user-registration": "only in target: legacy-check",
+		"post-change-password":  "only in target: notify",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected %v, got %v", expected, problems)
+	}
+}
+
+func TestCompareUserCounts(t *testing.T) {
+	// archive-db has too many users on both tenants to be counted.
+	source := map[string]int{"Username-Password-Authentication": 1000, "google-oauth2": 200, "legacy-db": 5, "archive-db": 1000}
+	target := map[string]int{"Username-Password-Authentication": 995, "google-oauth2": 200, "staging-db": 3, "archive-db": 1200}
+
+	expected := []countMismatch{
+		{Connection: "Username-Password-Authentication", Source: 1000, Target: 995},
+		{Connection: "legacy-db", Source: 5, Target: 0},
+		{Connection: "staging-db", Source: 0, Target: 3},
+	}
+	if mismatches := compareUserCounts(source, target, 0); !reflect.DeepEqual(mismatches, expected) {
+		t.Errorf("Expected mismatches %+v, got %+v", expected, mismatches)
+	}
+	if mismatches := compareUserCounts(source, target, 0.01); len(mismatches) != 2 {
+		t.Errorf("Expected a difference of 0.5%% to be tolerated, got %+v", mismatches)
+	}
+}
+
+func TestSampleAndCompareUsers(t *testing.T) {
+	identity := []map[string]interface{}{{"connection": "Username-Password-Authentication", "provider": "auth0", "user_id": "1"}}
+	sourceUsers := []map[string]interface{}{
+		{"user_id": "auth0|1", "email": "same@example.com", "email_verified": true, "identities": identity},
+		{"user_id": "auth0|2", "email": "drift@example.com", "email_verified": true, "app_metadata": map[string]interface{}{"plan": "pro"}, "identities": identity},
+		{"user_id": "auth0|3", "email": "missing@example.com", "identities": identity},
+		{"user_id": "sms|4", "identities": identity},
+	}
+	source := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := 0
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": sourceUsers[page : page+1], "start": page, "limit": 1, "length": 1, "total": len(sourceUsers)})
+	}))
+
+	targetUsers := map[string][]map[string]interface{}{
+		"same@example.com": {
+			{"user_id": "google-oauth2|9", "email": "same@example.com", "identities": []map[string]interface{}{{"connection": "google-oauth2"}}},
+			{"user_id": "auth0|1", "email": "same@example.com", "email_verified": true, "identities": identity},
+		},
+		"drift@example.com": {
+			{"user_id": "auth0|2", "email": "drift@example.com", "email_verified": false, "app_metadata": map[string]interface{}{"plan": "free"}, "identities": identity},
+		},
+	}
+	target := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users := targetUsers[r.URL.Query().Get("email")]
+		if users == nil {
+			users = []map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(users)
+	}))
+
+	ctx := context.Background()
+	users, limited, err := sampleUsers(ctx, source, 10, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("Failed to sample users: %v", err)
+	}
+	if len(users) != 4 || limited {
+		t.Fatalf("Expected all 4 users to be sampled, got %d (limited %v)", len(users), limited)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].GetID() < users[j].GetID() })
+
+	matcher, _ := parseMatcher("email")
+	mismatches, skipped, err := compareSampledUsers(ctx, target, matcher, users, defaultVerifyFields)
+	if err != nil {
+		t.Fatalf("Failed to compare users: %v", err)
+	}
+	if skipped != 1 || len(mismatches) != 2 {
+		t.Fatalf("Expected 2 mismatches and 1 skipped user, got %+v and %d", mismatches, skipped)
+	}
+	if mismatches[0].User != "drift@example.com" || mismatches[0].Problem != "differs in app_metadata.plan, email_verified" {
+		t.Errorf("Unexpected mismatch %+v", mismatches[0])
+	}
+	if mismatches[1].User != "missing@example.com" || mismatches[1].Problem != "missing in target" {
+		t.Errorf("Unexpected mismatch %+v", mismatches[1])
+	}
+
+	// Values of no_log fields are redacted.
+	pii = piiConfig{Fields: map[string][]string{"app_metadata.plan": {piiNoLog}}}
+	defer func() { pii = piiConfig{} }()
+	mismatches, _, err = compareSampledUsers(ctx, target, matcher, users, defaultVerifyFields)
+	if err != nil {
+		t.Fatalf("Failed to compare users: %v", err)
+	}
+	for _, field := range mismatches[0].Fields {
+		if field.Field == "app_metadata.plan" && (field.Source != "[redacted]" || field.Target != "[redacted]") {
+			t.Errorf("Expected the plan to be redacted, got %+v", field)
+		}
+		if field.Field == "email_verified" && field.Source != true {
+			t.Errorf("Expected email_verified to be kept, got %+v", field)
+		}
+	}
+	// With --match, users are looked up with the matcher's search query.
+	byUsername := newTestManagement(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != `username:"jdoe"` {
+			t.Errorf("Unexpected query %s", q)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": []map[string]interface{}{{"user_id": "auth0|7", "username": "jdoe", "identities": identity}}})
+	}))
+	matcher, _ = parseMatcher("username")
+	sampled := []*management.User{{ID: auth0.String("auth0|7"), Username: auth0.String("jdoe"), Identities: []*management.UserIdentity{{Connection: auth0.String("Username-Password-Authentication")}}}, users[0]}
+	mismatches, skipped, err = compareSampledUsers(ctx, byUsername, matcher, sampled, []string{"username"})
+	if err != nil || skipped != 1 || len(mismatches) != 0 {
+		t.Errorf("Expected the user to match by username and the user without one to be skipped, got %+v, %d, %v", mismatches, skipped, err)
+	}
+}